@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/yourusername/user-management-api/internal/services"
+)
+
+const adminUsage = `usage: server [admin <command>]
+
+Without arguments the API server is started.
+
+Admin commands run against the configured database (DB_PATH):
+  pepper-report  report how many password hashes are still on old pepper versions
+`
+
+// parseAdminArgs returns the admin command to run instead of the server, or
+// nil to start the server. Anything but "admin <command>" is rejected, so
+// stray arguments never change what the binary does.
+func parseAdminArgs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if args[0] != "admin" {
+		return nil, fmt.Errorf("unknown argument %q", args[0])
+	}
+	if len(args) == 1 {
+		return nil, errors.New("missing admin command")
+	}
+	return args[1:], nil
+}
+
+// runAdminCommand runs a one-off admin command against the configured
+// database and returns the exit code
+func runAdminCommand(args []string, userService services.UserService, currentKeyID string, out io.Writer) int {
+	switch args[0] {
+	case "pepper-report":
+		return pepperReport(userService, currentKeyID, out)
+	case "help", "-h", "--help":
+		fmt.Fprint(out, adminUsage)
+		return 0
+	default:
+		fmt.Fprintf(out, "unknown admin command %q\n\n%s", args[0], adminUsage)
+		return 2
+	}
+}
+
+func pepperReport(userService services.UserService, currentKeyID string, out io.Writer) int {
+	report, err := userService.PepperReport(currentKeyID)
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return 1
+	}
+
+	current := report.CurrentKeyID
+	if current == "" {
+		current = "(none)"
+	}
+	fmt.Fprintf(out, "Current pepper: %s\n\n", current)
+
+	keyIDs := make([]string, 0, len(report.Hashes))
+	for keyID := range report.Hashes {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PEPPER\tHASHES")
+	for _, keyID := range keyIDs {
+		name := keyID
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Fprintf(w, "%s\t%d\n", name, report.Hashes[keyID])
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nHashes on old pepper versions: %d\n", report.Outdated)
+	return 0
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/passwordhash"
+)
+
+func TestParseAdminArgs(t *testing.T) {
+	args, err := parseAdminArgs(nil)
+	require.NoError(t, err)
+	assert.Nil(t, args)
+
+	args, err = parseAdminArgs([]string{"admin", "pepper-report"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pepper-report"}, args)
+
+	// Stray arguments are refused instead of leaving server mode
+	for _, invalid := range [][]string{{"pepper-report"}, {"--port=8080"}, {"admin"}} {
+		_, err = parseAdminArgs(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPepperReportCommand(t *testing.T) {
+	databaseConfig := sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "users.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	}
+	inner := passwordhash.NewBcryptHasher(4)
+	peppers := map[string][]byte{
+		"1": []byte("first-pepper-0123456789abcdefghij"),
+		"2": []byte("second-pepper-0123456789abcdefghi"),
+	}
+
+	// The server stores users in the persistent database
+	db, err := sqlite.InitializeDatabase(databaseConfig)
+	require.NoError(t, err)
+	for i, keyID := range []string{"", "1", "1", "2"} {
+		var hasher passwordhash.PasswordHasher = inner
+		if keyID != "" {
+			hasher, err = passwordhash.NewPepperedHasher(inner, peppers, keyID)
+			require.NoError(t, err)
+		}
+		hash, err := hasher.Hash("StrongP@ssw0rd2024!")
+		require.NoError(t, err)
+		username := fmt.Sprintf("user%d", i)
+		require.NoError(t, db.Create(&database.User{Username: username, Email: username + "@example.com", Password: hash}).Error)
+	}
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	// and the admin command, run as its own process, reads them back
+	db, err = sqlite.InitializeDatabase(databaseConfig)
+	require.NoError(t, err)
+	userService := services.NewUserService(repository.NewUserRepository(db, zerolog.Nop()),
+		repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+
+	var out bytes.Buffer
+	require.Equal(t, 0, runAdminCommand([]string{"pepper-report"}, userService, "2", &out))
+	assert.Equal(t, "Current pepper: 2\n\n"+
+		"PEPPER  HASHES\n"+
+		"(none)  1\n"+
+		"1       2\n"+
+		"2       1\n"+
+		"\nHashes on old pepper versions: 3\n", out.String())
+
+	out.Reset()
+	assert.Equal(t, 2, runAdminCommand([]string{"unknown"}, userService, "2", &out))
+	assert.Contains(t, out.String(), "usage:")
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/yourusername/user-management-api/internal/config"
+	"github.com/yourusername/user-management-api/internal/database"
 	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
 	"github.com/yourusername/user-management-api/internal/handlers"
 	"github.com/yourusername/user-management-api/internal/middleware"
@@ -20,10 +23,22 @@ import (
 	"github.com/yourusername/user-management-api/internal/services"
 	"github.com/yourusername/user-management-api/pkg/authentication"
 	"github.com/yourusername/user-management-api/pkg/logger"
+	"github.com/yourusername/user-management-api/pkg/mailer"
+	"github.com/yourusername/user-management-api/pkg/oidc"
+	"github.com/yourusername/user-management-api/pkg/passwordhash"
 	"github.com/yourusername/user-management-api/pkg/token"
+	"github.com/yourusername/user-management-api/pkg/totp"
+	"github.com/yourusername/user-management-api/pkg/webauthn"
 )
 
 func main() {
+	// Without arguments the server starts; admin commands must be named explicitly
+	adminArgs, err := parseAdminArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, adminUsage)
+		os.Exit(2)
+	}
+
 	// Set Gin to production mode
 	gin.SetMode(gin.ReleaseMode)
 
@@ -45,22 +60,28 @@ func main() {
 		log.Warn().Err(err).Msg("Error loading .env file")
 	}
 
+	// Initialize configuration
+	// Invalid configuration must not start a server with insecure or missing settings
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	// Hash new passwords with the configured algorithm and pepper
+	passwordHasher, err := newPasswordHasher(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Str("algorithm", cfg.PasswordHashAlgorithm).Msg("Failed to initialize password hasher")
+	}
+	passwordhash.SetDefault(passwordHasher)
+
+	// Initialize database connection, the persistent database admin commands run against too
 	databaseConfig := sqlite.DatabaseConfig{
-		Path:            "./data/users.db",
-		InMemory:        true,
+		Path:            cfg.DatabasePath,
 		MaxOpenConns:    10,
 		MaxIdleConns:    5,
 		ConnMaxLifetime: 5 * time.Minute,
 		ConnMaxIdleTime: 30 * time.Second,
 	}
-
-	// Initialize configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to load configuration")
-	}
-
-	// Initialize database connection
 	// db, err := sqlite.NewSQLiteDatabase(sqliteConfig)
 	db, err := sqlite.InitializeDatabase(databaseConfig)
 	if err != nil {
@@ -69,16 +90,154 @@ func main() {
 
 	// inject to repository
 	userRepository := repository.NewUserRepository(db, log)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(db, log)
 	// inject to service
-	userService := services.NewUserService(userRepository, log)
+	userService := services.NewUserService(userRepository, loginAttemptRepository, log)
+	// Admin commands run against the database and exit without starting the server
+	if adminArgs != nil {
+		os.Exit(runAdminCommand(adminArgs, userService, cfg.PasswordPepperKeyID, os.Stdout))
+	}
 	// inject to auth service
-	loginAttemptRepository := repository.NewLoginAttemptRepository(db, log)
-	tokenManager := token.NewTokenManager(cfg.JWTSecret, cfg.JWTRefreshSecret)
-	authManager := authentication.NewAuthenticationManager(userRepository, tokenManager, loginAttemptRepository, log)
-	authService := services.NewAuthService(tokenManager, authManager, userRepository, log)
+	revokedTokenRepository := repository.NewRevokedTokenRepository(db, log)
+	refreshTokenRepository := repository.NewRefreshTokenRepository(db, log)
+	signingKeyRepository := repository.NewSigningKeyRepository(db, log)
+	sessionRepository := repository.NewSessionRepository(db, log)
+	opaqueTokenRepository := repository.NewOpaqueTokenRepository(db, log)
+	oneTimeTokenRepository := repository.NewOneTimeTokenRepository(db, log)
+	mfaRepository := repository.NewMFARepository(db, log)
+	webAuthnRepository := repository.NewWebAuthnRepository(db, log)
+	oauthClientRepository := repository.NewOAuthClientRepository(db, log)
+	userIdentityRepository := repository.NewUserIdentityRepository(db, log)
+	apiKeyRepository := repository.NewAPIKeyRepository(db, log)
+	securityEventRepository := repository.NewSecurityEventRepository(db, log)
+	accessKeys, refreshKeys, err := newKeyrings(cfg, signingKeyRepository, log)
+	if err != nil {
+		log.Fatal().Err(err).Str("algorithm", cfg.JWTAlgorithm).Msg("Failed to initialize signing keys")
+	}
+	tokenPolicy := token.TokenPolicy{
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+		Issuer:          cfg.JWTIssuer,
+		Audiences:       cfg.JWTAudiences,
+		Leeway:          cfg.JWTLeeway,
+	}
+	tokenManager, err := newTokenManager(cfg, tokenPolicy, revokedTokenRepository, opaqueTokenRepository, accessKeys, refreshKeys)
+	if err != nil {
+		log.Fatal().Err(err).Str("format", cfg.TokenFormat).Msg("Failed to initialize token manager")
+	}
+	// Sliding window counters of failed logins that catch credential stuffing and password spraying
+	stuffingDetector := authentication.NewStuffingDetector(authentication.StuffingPolicy{
+		Window:        cfg.StuffingWindow,
+		IP:            stuffingThresholds(cfg.StuffingIPThresholds),
+		Subnet:        stuffingThresholds(cfg.StuffingSubnetThresholds),
+		User:          stuffingThresholds(cfg.StuffingUserThresholds),
+		Delay:         cfg.StuffingDelay,
+		BlockDuration: cfg.StuffingBlockDuration,
+	}, securityEventRepository, log)
+	// Periodically purge expired revocations, refresh tokens, sessions, opaque and one-time tokens, WebAuthn challenges,
+	// authorization codes, federated login states, revoked and expired API keys, old security events, stale failed
+	// login counters and retired keys
+	revocationSweeper := token.NewRevocationSweeper(cfg.RevocationSweepInterval, log,
+		revokedTokenRepository, refreshTokenRepository, sessionRepository, opaqueTokenRepository, oneTimeTokenRepository,
+		webAuthnRepository, oauthClientRepository, userIdentityRepository, apiKeyRepository, securityEventRepository,
+		stuffingDetector, accessKeys, refreshKeys)
+	revocationSweeper.Start()
+	// Scheduled signing key rotation
+	keyRotator := token.NewKeyRotator(cfg.JWTKeyRotationInterval, log, accessKeys, refreshKeys)
+	if cfg.JWTKeyRotationInterval > 0 {
+		keyRotator.Start()
+	}
+	authManagerOptions := []authentication.AuthenticationManagerOption{
+		authentication.WithEmailVerificationRequired(cfg.RequireEmailVerification),
+		authentication.WithLockoutPolicy(authentication.LockoutPolicy{
+			MaxAttempts:   cfg.MaxLoginAttempts,
+			Window:        cfg.LockoutWindow,
+			Duration:      cfg.LockoutDuration,
+			BackoffFactor: cfg.LockoutBackoffFactor,
+			MaxDuration:   cfg.LockoutMaxDuration,
+			Permanent:     cfg.LockoutPermanent,
+		}),
+	}
+	if cfg.StuffingDetectionEnabled {
+		authManagerOptions = append(authManagerOptions, authentication.WithStuffingDetector(stuffingDetector))
+		if cfg.ChallengeVerifyURL != "" {
+			authManagerOptions = append(authManagerOptions, authentication.WithChallengeVerifier(
+				authentication.NewSiteVerifyChallenge(cfg.ChallengeVerifyURL, cfg.ChallengeSecret, &http.Client{Timeout: 5 * time.Second})))
+		}
+	}
+	authManager := authentication.NewAuthenticationManager(userRepository, tokenManager, loginAttemptRepository, log,
+		authManagerOptions...)
+	mail, err := newMailer(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Str("driver", cfg.MailerDriver).Msg("Failed to initialize mailer")
+	}
+	oneTimeTokenService := services.NewOneTimeTokenService(userRepository, oneTimeTokenRepository, log,
+		services.WithOneTimeTokenTTL(services.PurposeMagicLink, cfg.MagicLinkTTL))
+	emailVerificationService := services.NewEmailVerificationService(oneTimeTokenService, mail, userRepository, log,
+		services.WithVerificationURL(cfg.EmailVerificationURL))
+	totpSecrets, err := totp.NewSecretCipher(cfg.MFAEncryptionSecret)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize TOTP secret encryption")
+	}
+	mfaService := services.NewMFAService(userRepository, mfaRepository, totpSecrets, log,
+		services.WithMFAIssuer(cfg.MFAIssuer))
+	relyingParty, err := webauthn.NewRelyingParty(webauthn.Config{
+		RPID:    cfg.WebAuthnRPID,
+		RPName:  cfg.WebAuthnRPName,
+		Origins: cfg.WebAuthnOrigins,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Str("rp_id", cfg.WebAuthnRPID).Msg("Failed to initialize WebAuthn")
+	}
+	webAuthnService := services.NewWebAuthnService(relyingParty, userRepository, webAuthnRepository, log)
+	federatedProviders, err := newFederatedProviders(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize federated providers")
+	}
+	federationService := services.NewFederationService(federatedProviders, userRepository, userIdentityRepository, log)
+	sessionService := services.NewSessionService(sessionRepository, refreshTokenRepository, revokedTokenRepository, log,
+		services.WithMaxSessions(cfg.MaxSessionsPerUser))
+	authServiceOptions := []services.AuthServiceOption{
+		services.WithDeviceMismatchPolicy(services.DeviceMismatchPolicy(cfg.DeviceMismatchPolicy)),
+		services.WithEmailVerification(emailVerificationService),
+		services.WithMFA(mfaService, oneTimeTokenService),
+		services.WithPasskeys(webAuthnService, oneTimeTokenService),
+		services.WithFederation(federationService),
+	}
+	if cfg.MagicLinkEnabled {
+		magicLinkService := services.NewMagicLinkService(oneTimeTokenService, mail, userRepository, authManager, log,
+			services.WithMagicLinkURL(cfg.MagicLinkURL))
+		authServiceOptions = append(authServiceOptions, services.WithMagicLinks(magicLinkService))
+	}
+	authService := services.NewAuthService(tokenManager, authManager, userRepository, refreshTokenRepository, sessionService, log,
+		authServiceOptions...)
+	passwordResetService := services.NewPasswordResetService(oneTimeTokenService, mail, userRepository, loginAttemptRepository, sessionService, log,
+		services.WithPasswordResetURL(cfg.PasswordResetURL))
+	oauthService := services.NewOAuthService(tokenManager, authManager, userRepository, refreshTokenRepository, log)
+	// ID tokens are JWTs signed with the access token keys whatever the token format
+	idTokenSigner := token.NewIDTokenSigner(accessKeys, cfg.OIDCIssuer, cfg.IDTokenTTL)
+	oidcService := services.NewOIDCService(userRepository, oauthClientRepository, tokenManager, idTokenSigner, authManager,
+		cfg.OIDCIssuer, log)
+	apiKeyService := services.NewAPIKeyService(userRepository, apiKeyRepository, authManager, log,
+		services.WithAPIKeyMaxLifetime(cfg.APIKeyMaxLifetime))
+	securityEventService := services.NewSecurityEventService(securityEventRepository, log)
+	impersonationService := services.NewImpersonationService(tokenManager, authManager, userRepository, log,
+		services.WithImpersonationTTL(cfg.ImpersonationTokenTTL))
 	// inject to handler
 	userHandler := handlers.NewUserHandler(userService, log)
 	authHandler := handlers.NewAuthHandler(authService, log)
+	keyHandler := handlers.NewKeyHandler(accessKeys, refreshKeys, log)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, log)
+	sessionHandler := handlers.NewSessionHandler(sessionService, log)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService, log)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(emailVerificationService, log)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService, log)
+	mfaHandler := handlers.NewMFAHandler(mfaService, log)
+	passkeyHandler := handlers.NewPasskeyHandler(webAuthnService, log)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, cfg.OIDCConsentURL, log)
+	federationHandler := handlers.NewFederationHandler(federationService, authService, log)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, log)
+	securityEventHandler := handlers.NewSecurityEventHandler(securityEventService, log)
 
 	// Setup Gin router
 	router := gin.New()
@@ -102,10 +261,18 @@ func main() {
 	// Add CSRF middleware
 	csrfMiddleware := middleware.NewCSRFMiddleware(&log,
 		middleware.WithCookieDomain("localhost"),
-		middleware.WithExcludedRoutes([]string{"/api/v1/health"}),
+		// OAuth clients authenticate with their credentials instead of cookies
+		middleware.WithExcludedRoutes([]string{"/api/v1/health", "/api/v1/oauth/introspect", "/api/v1/oauth/revoke",
+			"/api/v1/oauth/token", "/api/v1/oauth/userinfo"}),
 		middleware.WithCookieName("X-CSRF-Token"))
 	router.Use(csrfMiddleware.Handler())
 
+	// Public verification keys for downstream services and OIDC clients. They
+	// verify JWT access tokens as well as ID tokens.
+	jwksHandler := handlers.NewJWKSHandler(idTokenSigner, log)
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+
 	// Api routes
 	v1Group := router.Group("/api/v1")
 	{
@@ -114,17 +281,95 @@ func main() {
 		{
 			authGroup.POST("/register", authHandler.RegisterUser)
 			authGroup.POST("/login", authHandler.LoginUser)
+			authGroup.POST("/mfa/verify", authHandler.VerifyMFA)
+			authGroup.POST("/mfa/webauthn/begin", authHandler.BeginPasskeyMFA)
+			authGroup.POST("/mfa/webauthn/verify", authHandler.VerifyMFAWithPasskey)
+			authGroup.POST("/passkey/login/begin", authHandler.BeginPasskeyLogin)
+			authGroup.POST("/passkey/login/finish", authHandler.LoginWithPasskey)
+			authGroup.POST("/magic-link", authHandler.RequestMagicLink)
+			authGroup.POST("/magic-link/login", authHandler.LoginWithMagicLink)
+			authGroup.POST("/verify-email", emailVerificationHandler.VerifyEmail)
+			authGroup.POST("/resend-verification", emailVerificationHandler.ResendVerification)
+			authGroup.POST("/password/forgot", passwordResetHandler.ForgotPassword)
+			authGroup.POST("/password/reset", passwordResetHandler.ResetPassword)
 			authGroup.POST("/refresh", authHandler.RefreshTokens)
+			// Sign in with external OpenID Connect providers
+			authGroup.GET("/federated", federationHandler.ListProviders)
+			authGroup.GET("/federated/:provider/login", federationHandler.BeginLogin)
+			authGroup.GET("/federated/:provider/callback", federationHandler.Callback)
+			authGroup.POST("/logout", middleware.AuthMiddleware(authManager, log), authHandler.LogoutUser)
+			// RFC 8693 token exchange, used by support staff to act as a user
+			authGroup.POST("/token-exchange", middleware.AuthMiddleware(authManager, log),
+				middleware.RequirePermission(log, database.PermissionImpersonate), impersonationHandler.ExchangeToken)
+		}
+		// OAuth routes for resource servers (client authenticated)
+		oauthGroup := v1Group.Group("/oauth")
+		{
+			resourceServerAuth := middleware.ClientAuthMiddleware(middleware.StaticClients(cfg.OAuthClients), log)
+			oauthGroup.POST("/introspect", resourceServerAuth, oauthHandler.IntrospectToken)
+			oauthGroup.POST("/revoke", resourceServerAuth, oauthHandler.RevokeToken)
+			// Authorization server routes for registered client applications
+			oauthGroup.GET("/authorize", oidcHandler.StartAuthorization)
+			// Consent is personal, so support staff cannot give it while impersonating
+			oauthGroup.POST("/authorize", middleware.AuthMiddleware(authManager, log), middleware.RejectImpersonation(log), oidcHandler.Authorize)
+			oauthGroup.POST("/token", middleware.ClientAuthMiddleware(oidcService, log), oidcHandler.Token)
+			oauthGroup.GET("/userinfo", oidcHandler.UserInfo)
+			oauthGroup.POST("/userinfo", oidcHandler.UserInfo)
 		}
-		// User routes (protected)
+		// User routes (protected), also open to machine clients with an API key
 		userGroup := v1Group.Group("/users")
 		// Add Auth middleware
-		userGroup.Use(middleware.AuthMiddleware(authManager, log))
+		userGroup.Use(middleware.AuthOrAPIKeyMiddleware(middleware.AuthMiddleware(authManager, log),
+			middleware.APIKeyMiddleware(apiKeyService, log)))
+		{
+			userGroup.GET("/", middleware.RequireScope(log, services.ScopeRead), userHandler.GetAllUsers)
+			userGroup.GET("/:id", middleware.RequireScope(log, services.ScopeRead), userHandler.GetUserByID)
+			// Updates can set the password, which would outlive an impersonation
+			userGroup.PUT("/:id", middleware.RequireScope(log, services.ScopeWrite), middleware.RejectImpersonation(log), userHandler.UpdateUser)
+			userGroup.DELETE("/:id", middleware.RequireScope(log, services.ScopeWrite), userHandler.DeleteUser)
+		}
+		// Current user routes (protected)
+		meGroup := v1Group.Group("/me")
+		meGroup.Use(middleware.AuthMiddleware(authManager, log))
 		{
-			userGroup.GET("/", userHandler.GetAllUsers)
-			userGroup.GET("/:id", userHandler.GetUserByID)
-			userGroup.PUT("/:id", userHandler.UpdateUser)
-			userGroup.DELETE("/:id", userHandler.DeleteUser)
+			meGroup.GET("/sessions", sessionHandler.ListSessions)
+			meGroup.DELETE("/sessions/:id", sessionHandler.RevokeSession)
+			meGroup.POST("/sessions/logout-others", sessionHandler.RevokeOtherSessions)
+			meGroup.GET("/mfa", mfaHandler.GetStatus)
+			meGroup.GET("/passkeys", passkeyHandler.ListPasskeys)
+			meGroup.GET("/consents", oidcHandler.ListConsents)
+			meGroup.DELETE("/consents/:client_id", oidcHandler.RevokeConsent)
+			meGroup.GET("/identities", federationHandler.ListIdentities)
+			meGroup.GET("/api-keys", apiKeyHandler.ListKeys)
+		}
+		// Credential routes, which support staff cannot use while impersonating
+		credentialGroup := meGroup.Group("", middleware.RejectImpersonation(log))
+		{
+			credentialGroup.POST("/mfa/totp", mfaHandler.EnrollTOTP)
+			credentialGroup.POST("/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+			credentialGroup.POST("/mfa/totp/disable", mfaHandler.DisableTOTP)
+			credentialGroup.POST("/passkeys/register/begin", passkeyHandler.BeginRegistration)
+			credentialGroup.POST("/passkeys/register/finish", passkeyHandler.FinishRegistration)
+			credentialGroup.DELETE("/passkeys/:id", passkeyHandler.DeletePasskey)
+			credentialGroup.POST("/identities/:provider", federationHandler.LinkIdentity)
+			credentialGroup.DELETE("/identities/:id", federationHandler.UnlinkIdentity)
+			credentialGroup.POST("/api-keys", apiKeyHandler.CreateKey)
+			credentialGroup.DELETE("/api-keys/:id", apiKeyHandler.RevokeKey)
+		}
+		// Admin routes (protected)
+		adminGroup := v1Group.Group("/admin")
+		adminGroup.Use(middleware.AuthMiddleware(authManager, log), middleware.RequireRole(log, database.UserRoleAdmin))
+		{
+			adminGroup.GET("/keys", keyHandler.ListKeys)
+			adminGroup.POST("/keys/rotate", keyHandler.RotateKeys)
+			adminGroup.POST("/users/:id/unlock", userHandler.UnlockUser)
+			adminGroup.GET("/users/:id/sessions", sessionHandler.ListUserSessions)
+			adminGroup.GET("/users/:id/api-keys", apiKeyHandler.ListUserKeys)
+			adminGroup.DELETE("/users/:id/api-keys/:key_id", apiKeyHandler.RevokeUserKey)
+			adminGroup.GET("/security-events", securityEventHandler.ListEvents)
+			adminGroup.GET("/oauth/clients", oidcHandler.ListClients)
+			adminGroup.POST("/oauth/clients", oidcHandler.RegisterClient)
+			adminGroup.DELETE("/oauth/clients/:client_id", oidcHandler.DeleteClient)
 		}
 	}
 
@@ -164,6 +409,10 @@ func main() {
 		log.Error().Err(err).Msg("Failed to shutdown server")
 	}
 
+	// Stop background workers
+	keyRotator.Stop()
+	revocationSweeper.Stop()
+
 	select {
 	case <-ctx.Done():
 		log.Error().Err(ctx.Err()).Msg("Timeout shutting down server")
@@ -171,3 +420,134 @@ func main() {
 		log.Info().Msg("Server shut down")
 	}
 }
+
+// newTokenManager creates the token manager for the configured token format.
+// Only JWTs are signed with the keyrings.
+func newTokenManager(
+	cfg *config.Config,
+	policy token.TokenPolicy,
+	revocations token.RevocationStore,
+	opaqueTokens token.OpaqueTokenStore,
+	accessKeys, refreshKeys *token.Keyring,
+) (token.TokenManager, error) {
+	switch cfg.TokenFormat {
+	case "paseto-local":
+		return token.NewPasetoTokenManager(
+			token.NewPasetoLocalKeyFromSecret(cfg.JWTSecret),
+			token.NewPasetoLocalKeyFromSecret(cfg.JWTRefreshSecret),
+			policy, revocations), nil
+	case "paseto-public":
+		accessKey, err := token.LoadPasetoPublicKey(cfg.PasetoPrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		// Refresh tokens are only read by this service, so they stay encrypted
+		return token.NewPasetoTokenManager(accessKey, token.NewPasetoLocalKeyFromSecret(cfg.JWTRefreshSecret), policy, revocations), nil
+	case "opaque":
+		return token.NewOpaqueTokenManager(opaqueTokens, policy, revocations), nil
+	default:
+		return token.NewTokenManager(cfg.JWTSecret, cfg.JWTRefreshSecret, policy, revocations, token.WithKeyrings(accessKeys, refreshKeys)), nil
+	}
+}
+
+// newFederatedProviders creates the external providers users can sign in
+// with. Their callbacks are routed under the public base URL in OIDCIssuer.
+func newFederatedProviders(cfg *config.Config) (map[string]*oidc.Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	providers := make(map[string]*oidc.Provider, len(cfg.FederatedProviders))
+	for _, provider := range cfg.FederatedProviders {
+		var err error
+		providers[provider.Name], err = oidc.NewProvider(oidc.Config{
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  strings.TrimSuffix(cfg.OIDCIssuer, "/") + "/api/v1/auth/federated/" + provider.Name + "/callback",
+			Scopes:       provider.Scopes,
+		}, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return providers, nil
+}
+
+// stuffingThresholds reads the delay, challenge and block thresholds of a
+// validated configuration
+func stuffingThresholds(values []int) authentication.StuffingThresholds {
+	return authentication.StuffingThresholds{Delay: values[0], Challenge: values[1], Block: values[2]}
+}
+
+// newPasswordHasher creates the hasher for the configured algorithm,
+// peppered if peppers are configured
+func newPasswordHasher(cfg *config.Config) (passwordhash.PasswordHasher, error) {
+	var hasher passwordhash.PasswordHasher
+	if cfg.PasswordHashAlgorithm == "bcrypt" {
+		hasher = passwordhash.NewBcryptHasher(cfg.BcryptCost)
+	} else {
+		params := passwordhash.DefaultArgon2idParams()
+		params.Memory = uint32(cfg.Argon2Memory)
+		params.Iterations = uint32(cfg.Argon2Iterations)
+		params.Parallelism = uint8(cfg.Argon2Parallelism)
+		hasher = passwordhash.NewArgon2idHasher(params)
+	}
+	if len(cfg.PasswordPeppers) == 0 {
+		return hasher, nil
+	}
+
+	peppers := make(map[string][]byte, len(cfg.PasswordPeppers))
+	for keyID, secret := range cfg.PasswordPeppers {
+		peppers[keyID] = []byte(secret)
+	}
+	return passwordhash.NewPepperedHasher(hasher, peppers, cfg.PasswordPepperKeyID)
+}
+
+// newMailer creates the mailer for the configured driver
+func newMailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.MailerDriver {
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}), nil
+	case "memory":
+		return mailer.NewMemoryMailer(), nil
+	default:
+		return mailer.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	}
+}
+
+// newKeyrings loads the persisted signing keys and rotates in the configured
+// keys if they are new, so changing a configured secret does not invalidate
+// tokens signed with the previous one
+func newKeyrings(cfg *config.Config, store token.KeyringStore, log zerolog.Logger) (*token.Keyring, *token.Keyring, error) {
+	// Retired keys must outlive every token they signed
+	gracePeriod := max(cfg.AccessTokenTTL, cfg.RefreshTokenTTL) + cfg.JWTLeeway
+
+	accessKeys, err := token.NewKeyring(token.AccessToken, cfg.JWTAlgorithm, gracePeriod, store, cfg.JWTKeyringSecret, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	accessSeed := token.NewHMACSigningKey(cfg.JWTSecret)
+	if cfg.JWTAlgorithm != token.AlgorithmHS256 {
+		if accessSeed, err = token.LoadSigningKey(cfg.JWTAlgorithm, cfg.JWTPrivateKeyPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := accessKeys.Seed(accessSeed); err != nil {
+		return nil, nil, err
+	}
+
+	// Refresh tokens are only verified by this service, so a shared secret is enough
+	refreshKeys, err := token.NewKeyring(token.RefreshToken, token.AlgorithmHS256, gracePeriod, store, cfg.JWTKeyringSecret, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := refreshKeys.Seed(token.NewHMACSigningKey(cfg.JWTRefreshSecret)); err != nil {
+		return nil, nil, err
+	}
+
+	return accessKeys, refreshKeys, nil
+}
@@ -3,6 +3,7 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -13,6 +14,9 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/yourusername/user-management-api/pkg/passwordhash"
 )
 
 // Config represents the application configuration
@@ -36,6 +40,40 @@ type Config struct {
 	JWTRefreshSecret string
 	AccessTokenTTL   time.Duration
 	RefreshTokenTTL  time.Duration
+	// JWTAlgorithm selects how access tokens are signed (HS256, RS256, ES256 or EdDSA)
+	JWTAlgorithm      string
+	JWTPrivateKeyPath string
+	// JWTKeyringSecret encrypts persisted signing keys at rest. It is required
+	// because signing keys are always persisted and must outlive a restart.
+	JWTKeyringSecret       string
+	JWTKeyRotationInterval time.Duration
+	// JWTIssuer and JWTAudiences are written to and required of every token
+	JWTIssuer    string
+	JWTAudiences []string
+	// JWTLeeway tolerates clock skew between issuer and verifier
+	JWTLeeway time.Duration
+	// TokenFormat is "jwt", "paseto-local", "paseto-public" or "opaque"
+	TokenFormat string
+	// PasetoPrivateKeyPath is the Ed25519 key signing paseto-public access tokens
+	PasetoPrivateKeyPath string
+
+	// Token Revocation Configuration
+	RevocationSweepInterval time.Duration
+
+	// OAuthClients maps client IDs to secrets for the introspection and
+	// revocation endpoints
+	OAuthClients map[string]string
+
+	// OpenID Connect Provider Configuration
+	// OIDCIssuer is the public base URL of this service and the iss of ID tokens
+	OIDCIssuer string
+	// OIDCConsentURL is the page that signs users in and asks for consent
+	OIDCConsentURL string
+	IDTokenTTL     time.Duration
+
+	// FederatedProviders are the external OpenID Connect providers users can
+	// sign in with. Their callbacks are under OIDCIssuer.
+	FederatedProviders []FederatedProviderConfig
 
 	// Rate Limit Configuration
 	RateLimitLimit    int
@@ -50,6 +88,100 @@ type Config struct {
 	AllowedOrigins   []string
 	MaxLoginAttempts int
 	LockoutDuration  time.Duration
+	// LockoutWindow is how long a failed login counts; 0 counts them until the next successful login
+	LockoutWindow time.Duration
+	// LockoutBackoffFactor multiplies the lock for every failed login beyond MaxLoginAttempts
+	LockoutBackoffFactor float64
+	// LockoutMaxDuration caps the growing locks; 0 leaves them uncapped
+	LockoutMaxDuration time.Duration
+	// LockoutPermanent keeps accounts locked until they are unlocked
+	LockoutPermanent bool
+	// StuffingDetectionEnabled delays, challenges and blocks sources failing logins
+	// for many usernames, and usernames failing from many sources
+	StuffingDetectionEnabled bool
+	// StuffingWindow is how long a failed login counts towards detection
+	StuffingWindow time.Duration
+	// StuffingIPThresholds, StuffingSubnetThresholds and StuffingUserThresholds are the
+	// delay, challenge and block thresholds of distinct usernames per address, usernames
+	// per /24 or /64 network and addresses per username; 0 leaves a step out
+	StuffingIPThresholds     []int
+	StuffingSubnetThresholds []int
+	StuffingUserThresholds   []int
+	// StuffingDelay slows down logins from delayed sources
+	StuffingDelay time.Duration
+	// StuffingBlockDuration is how long blocked sources are refused
+	StuffingBlockDuration time.Duration
+	// ChallengeVerifyURL is the CAPTCHA siteverify endpoint checking challenges;
+	// without it challenged sources are only delayed
+	ChallengeVerifyURL string
+	ChallengeSecret    string
+	// PasswordHashAlgorithm is "argon2id" or "bcrypt" for new password hashes. Hashes
+	// of either algorithm keep working and are upgraded on the next login.
+	PasswordHashAlgorithm string
+	// Argon2Memory (in KiB), Argon2Iterations and Argon2Parallelism are the argon2id costs
+	Argon2Memory      int
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+	// PasswordPeppers maps pepper key IDs to the secrets password hashes are keyed
+	// with; PasswordPepperKeyID names the one new hashes use. Hashes under other
+	// peppers are re-peppered on the next login.
+	PasswordPeppers     map[string]string
+	PasswordPepperKeyID string
+	// PasswordPepperFile holds further peppers, one key_id:secret pair per line
+	PasswordPepperFile string
+	// DeviceMismatchPolicy is "flag" or "reject" for refreshes from another device
+	DeviceMismatchPolicy string
+	// MaxSessionsPerUser evicts the oldest sessions beyond the cap; 0 disables it
+	MaxSessionsPerUser int
+	// ImpersonationTokenTTL is the lifetime of tokens obtained through token exchange
+	ImpersonationTokenTTL time.Duration
+	// RequireEmailVerification refuses login until the user verified their email
+	RequireEmailVerification bool
+	// EmailVerificationURL is the page verification emails link to
+	EmailVerificationURL string
+	// PasswordResetURL is the page password reset emails link to
+	PasswordResetURL string
+	// MFAIssuer names the account in authenticator apps, defaulting to JWTIssuer
+	MFAIssuer string
+	// MFAEncryptionSecret encrypts TOTP secrets at rest. TOTP is always
+	// offered, so it is required.
+	MFAEncryptionSecret string
+	// APIKeyMaxLifetime bounds the expiry of API keys; 0 allows keys that never expire
+	APIKeyMaxLifetime time.Duration
+	// MagicLinkEnabled lets users sign in through a single-use link mailed to them
+	MagicLinkEnabled bool
+	// MagicLinkURL is the page magic link emails link to
+	MagicLinkURL string
+	// MagicLinkTTL is how long a magic link can be used
+	MagicLinkTTL time.Duration
+
+	// WebAuthn Configuration
+	// WebAuthnRPID is the domain passkeys are bound to; WebAuthnOrigins are
+	// the exact origins of the pages running the ceremonies
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+
+	// Mail Configuration
+	// MailerDriver is "smtp", "file" (a maildir in MailDir) or "memory"
+	MailerDriver string
+	MailDir      string
+	MailFrom     string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// FederatedProviderConfig is the client registration at an external provider
+type FederatedProviderConfig struct {
+	// Name identifies the provider in URLs and linked identities
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 // DefaultConfig provides sensible default configuration values
@@ -72,6 +204,17 @@ func DefaultConfig() *Config {
 		// JWT Defaults
 		AccessTokenTTL:  15 * time.Minute,
 		RefreshTokenTTL: 7 * 24 * time.Hour,
+		JWTAlgorithm:    "HS256",
+		JWTIssuer:       "user-management-api",
+		TokenFormat:     "jwt",
+
+		// Token Revocation Defaults
+		RevocationSweepInterval: 10 * time.Minute,
+
+		// OpenID Connect Provider Defaults
+		OIDCIssuer:     "http://localhost:8080",
+		OIDCConsentURL: "http://localhost:8080/consent",
+		IDTokenTTL:     time.Hour,
 
 		// Logging Defaults
 		LogLevel: zerolog.InfoLevel,
@@ -81,6 +224,43 @@ func DefaultConfig() *Config {
 		AllowedOrigins:   []string{"*"},
 		MaxLoginAttempts: 5,
 		LockoutDuration:  30 * time.Minute,
+
+		LockoutWindow:        24 * time.Hour,
+		LockoutBackoffFactor: 2,
+		LockoutMaxDuration:   24 * time.Hour,
+
+		StuffingDetectionEnabled: true,
+		StuffingWindow:           15 * time.Minute,
+		StuffingIPThresholds:     []int{5, 10, 20},
+		StuffingSubnetThresholds: []int{15, 30, 60},
+		StuffingUserThresholds:   []int{5, 10, 0},
+		StuffingDelay:            2 * time.Second,
+		StuffingBlockDuration:    time.Hour,
+
+		PasswordHashAlgorithm: "argon2id",
+		Argon2Memory:          64 * 1024,
+		Argon2Iterations:      3,
+		Argon2Parallelism:     2,
+		BcryptCost:            bcrypt.DefaultCost + 4,
+
+		DeviceMismatchPolicy:  "flag",
+		ImpersonationTokenTTL: 10 * time.Minute,
+		EmailVerificationURL:  "http://localhost:8080/verify-email",
+		PasswordResetURL:      "http://localhost:8080/reset-password",
+		APIKeyMaxLifetime:     365 * 24 * time.Hour,
+		MagicLinkURL:          "http://localhost:8080/magic-link",
+		MagicLinkTTL:          15 * time.Minute,
+
+		// WebAuthn Defaults
+		WebAuthnRPID:    "localhost",
+		WebAuthnRPName:  "User Management API",
+		WebAuthnOrigins: []string{"http://localhost:8080"},
+
+		// Mail Defaults
+		MailerDriver: "file",
+		MailDir:      "./data/mail",
+		MailFrom:     "noreply@localhost",
+		SMTPPort:     587,
 	}
 }
 
@@ -114,6 +294,13 @@ func LoadConfig(envFiles ...string) (*Config, error) {
 	// Override defaults with environment variables
 	cfg = overrideConfigFromEnv(cfg)
 
+	// Peppers can be kept out of the environment in a file
+	if cfg.PasswordPepperFile != "" {
+		if err := loadPepperFile(cfg); err != nil {
+			return &Config{}, err
+		}
+	}
+
 	// Validate configuration
 	if err := validateConfig(cfg); err != nil {
 		return &Config{}, err
@@ -144,6 +331,35 @@ func overrideConfigFromEnv(cfg *Config) *Config {
 	cfg.JWTRefreshSecret = getEnvOrDefault("JWT_REFRESH_SECRET", generateDefaultSecret(64))
 	cfg.AccessTokenTTL = getEnvDurationOrDefault("ACCESS_TOKEN_TTL", cfg.AccessTokenTTL)
 	cfg.RefreshTokenTTL = getEnvDurationOrDefault("REFRESH_TOKEN_TTL", cfg.RefreshTokenTTL)
+	cfg.JWTAlgorithm = getEnvOrDefault("JWT_ALGORITHM", cfg.JWTAlgorithm)
+	cfg.JWTPrivateKeyPath = getEnvOrDefault("JWT_PRIVATE_KEY_PATH", cfg.JWTPrivateKeyPath)
+	cfg.JWTKeyringSecret = os.Getenv("JWT_KEYRING_SECRET")
+	cfg.JWTKeyRotationInterval = getEnvDurationOrDefault("JWT_KEY_ROTATION_INTERVAL", cfg.JWTKeyRotationInterval)
+	cfg.JWTIssuer = getEnvOrDefault("JWT_ISSUER", cfg.JWTIssuer)
+	if audiences := os.Getenv("JWT_AUDIENCES"); audiences != "" {
+		cfg.JWTAudiences = strings.Split(audiences, ",")
+	}
+	cfg.JWTLeeway = getEnvDurationOrDefault("JWT_LEEWAY", cfg.JWTLeeway)
+	cfg.TokenFormat = getEnvOrDefault("TOKEN_FORMAT", cfg.TokenFormat)
+	cfg.PasetoPrivateKeyPath = getEnvOrDefault("PASETO_PRIVATE_KEY_PATH", cfg.PasetoPrivateKeyPath)
+
+	// Token Revocation Configuration
+	cfg.RevocationSweepInterval = getEnvDurationOrDefault("REVOCATION_SWEEP_INTERVAL", cfg.RevocationSweepInterval)
+
+	// OAuth Client Configuration
+	if clients := os.Getenv("OAUTH_CLIENTS"); clients != "" {
+		cfg.OAuthClients = parseSecretPairs(clients)
+	}
+
+	// OpenID Connect Provider Configuration
+	cfg.OIDCIssuer = getEnvOrDefault("OIDC_ISSUER", cfg.OIDCIssuer)
+	cfg.OIDCConsentURL = getEnvOrDefault("OIDC_CONSENT_URL", cfg.OIDCConsentURL)
+	cfg.IDTokenTTL = getEnvDurationOrDefault("ID_TOKEN_TTL", cfg.IDTokenTTL)
+
+	// Federated Login Configuration
+	if providers := os.Getenv("FEDERATED_PROVIDERS"); providers != "" {
+		cfg.FederatedProviders = parseFederatedProviders(providers)
+	}
 
 	// Logging Configuration
 	cfg.LogLevel = getEnvLogLevelOrDefault("LOG_LEVEL", cfg.LogLevel)
@@ -155,6 +371,57 @@ func overrideConfigFromEnv(cfg *Config) *Config {
 	}
 	cfg.MaxLoginAttempts = getEnvIntOrDefault("MAX_LOGIN_ATTEMPTS", cfg.MaxLoginAttempts)
 	cfg.LockoutDuration = getEnvDurationOrDefault("LOCKOUT_DURATION", cfg.LockoutDuration)
+	cfg.LockoutWindow = getEnvDurationOrDefault("LOCKOUT_WINDOW", cfg.LockoutWindow)
+	cfg.LockoutBackoffFactor = getEnvFloatOrDefault("LOCKOUT_BACKOFF_FACTOR", cfg.LockoutBackoffFactor)
+	cfg.LockoutMaxDuration = getEnvDurationOrDefault("LOCKOUT_MAX_DURATION", cfg.LockoutMaxDuration)
+	cfg.LockoutPermanent = getEnvBoolOrDefault("LOCKOUT_PERMANENT", cfg.LockoutPermanent)
+	cfg.StuffingDetectionEnabled = getEnvBoolOrDefault("STUFFING_DETECTION_ENABLED", cfg.StuffingDetectionEnabled)
+	cfg.StuffingWindow = getEnvDurationOrDefault("STUFFING_WINDOW", cfg.StuffingWindow)
+	cfg.StuffingIPThresholds = getEnvIntsOrDefault("STUFFING_IP_THRESHOLDS", cfg.StuffingIPThresholds)
+	cfg.StuffingSubnetThresholds = getEnvIntsOrDefault("STUFFING_SUBNET_THRESHOLDS", cfg.StuffingSubnetThresholds)
+	cfg.StuffingUserThresholds = getEnvIntsOrDefault("STUFFING_USER_THRESHOLDS", cfg.StuffingUserThresholds)
+	cfg.StuffingDelay = getEnvDurationOrDefault("STUFFING_DELAY", cfg.StuffingDelay)
+	cfg.StuffingBlockDuration = getEnvDurationOrDefault("STUFFING_BLOCK_DURATION", cfg.StuffingBlockDuration)
+	cfg.ChallengeVerifyURL = getEnvOrDefault("CHALLENGE_VERIFY_URL", cfg.ChallengeVerifyURL)
+	cfg.ChallengeSecret = getEnvOrDefault("CHALLENGE_SECRET", cfg.ChallengeSecret)
+	cfg.PasswordHashAlgorithm = getEnvOrDefault("PASSWORD_HASH_ALGORITHM", cfg.PasswordHashAlgorithm)
+	cfg.Argon2Memory = getEnvIntOrDefault("ARGON2_MEMORY", cfg.Argon2Memory)
+	cfg.Argon2Iterations = getEnvIntOrDefault("ARGON2_ITERATIONS", cfg.Argon2Iterations)
+	cfg.Argon2Parallelism = getEnvIntOrDefault("ARGON2_PARALLELISM", cfg.Argon2Parallelism)
+	cfg.BcryptCost = getEnvIntOrDefault("BCRYPT_COST", cfg.BcryptCost)
+	if peppers := os.Getenv("PASSWORD_PEPPERS"); peppers != "" {
+		cfg.PasswordPeppers = parseSecretPairs(peppers)
+	}
+	cfg.PasswordPepperKeyID = getEnvOrDefault("PASSWORD_PEPPER_KEY_ID", cfg.PasswordPepperKeyID)
+	cfg.PasswordPepperFile = getEnvOrDefault("PASSWORD_PEPPER_FILE", cfg.PasswordPepperFile)
+	cfg.DeviceMismatchPolicy = getEnvOrDefault("DEVICE_MISMATCH_POLICY", cfg.DeviceMismatchPolicy)
+	cfg.MaxSessionsPerUser = getEnvIntOrDefault("MAX_SESSIONS_PER_USER", cfg.MaxSessionsPerUser)
+	cfg.ImpersonationTokenTTL = getEnvDurationOrDefault("IMPERSONATION_TOKEN_TTL", cfg.ImpersonationTokenTTL)
+	cfg.RequireEmailVerification = getEnvBoolOrDefault("REQUIRE_EMAIL_VERIFICATION", cfg.RequireEmailVerification)
+	cfg.EmailVerificationURL = getEnvOrDefault("EMAIL_VERIFICATION_URL", cfg.EmailVerificationURL)
+	cfg.PasswordResetURL = getEnvOrDefault("PASSWORD_RESET_URL", cfg.PasswordResetURL)
+	cfg.MFAIssuer = getEnvOrDefault("MFA_ISSUER", cfg.JWTIssuer)
+	cfg.MFAEncryptionSecret = os.Getenv("MFA_ENCRYPTION_SECRET")
+	cfg.APIKeyMaxLifetime = getEnvDurationOrDefault("API_KEY_MAX_LIFETIME", cfg.APIKeyMaxLifetime)
+	cfg.MagicLinkEnabled = getEnvBoolOrDefault("MAGIC_LINK_ENABLED", cfg.MagicLinkEnabled)
+	cfg.MagicLinkURL = getEnvOrDefault("MAGIC_LINK_URL", cfg.MagicLinkURL)
+	cfg.MagicLinkTTL = getEnvDurationOrDefault("MAGIC_LINK_TTL", cfg.MagicLinkTTL)
+
+	// WebAuthn Configuration
+	cfg.WebAuthnRPID = getEnvOrDefault("WEBAUTHN_RP_ID", cfg.WebAuthnRPID)
+	cfg.WebAuthnRPName = getEnvOrDefault("WEBAUTHN_RP_NAME", cfg.WebAuthnRPName)
+	if origins := os.Getenv("WEBAUTHN_ORIGINS"); origins != "" {
+		cfg.WebAuthnOrigins = strings.Split(origins, ",")
+	}
+
+	// Mail Configuration
+	cfg.MailerDriver = getEnvOrDefault("MAILER_DRIVER", cfg.MailerDriver)
+	cfg.MailDir = getEnvOrDefault("MAIL_DIR", cfg.MailDir)
+	cfg.MailFrom = getEnvOrDefault("MAIL_FROM", cfg.MailFrom)
+	cfg.SMTPHost = getEnvOrDefault("SMTP_HOST", cfg.SMTPHost)
+	cfg.SMTPPort = getEnvIntOrDefault("SMTP_PORT", cfg.SMTPPort)
+	cfg.SMTPUsername = getEnvOrDefault("SMTP_USERNAME", cfg.SMTPUsername)
+	cfg.SMTPPassword = getEnvOrDefault("SMTP_PASSWORD", cfg.SMTPPassword)
 
 	// Rate Limit Configuration
 	cfg.RateLimitLimit = getEnvIntOrDefault("RATE_LIMIT_LIMIT", cfg.RateLimitLimit)
@@ -179,6 +446,197 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("token TTLs must be positive")
 	}
 
+	switch cfg.JWTAlgorithm {
+	case "HS256":
+	case "RS256", "ES256", "EdDSA":
+		if cfg.JWTPrivateKeyPath == "" {
+			return fmt.Errorf("JWT private key path is required for %s", cfg.JWTAlgorithm)
+		}
+	default:
+		return fmt.Errorf("unsupported JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+
+	// A random per-process secret would make every persisted key unreadable after a restart
+	if cfg.JWTKeyringSecret == "" {
+		return fmt.Errorf("JWT keyring secret is required to persist signing keys")
+	}
+	if len(cfg.JWTKeyringSecret) < 32 {
+		return fmt.Errorf("JWT keyring secret must be at least 32 characters")
+	}
+
+	if cfg.JWTKeyRotationInterval < 0 {
+		return fmt.Errorf("key rotation interval cannot be negative")
+	}
+
+	if cfg.JWTLeeway < 0 {
+		return fmt.Errorf("JWT leeway cannot be negative")
+	}
+
+	switch cfg.TokenFormat {
+	case "jwt", "paseto-local", "opaque":
+	case "paseto-public":
+		if cfg.PasetoPrivateKeyPath == "" {
+			return fmt.Errorf("PASETO private key path is required for %s", cfg.TokenFormat)
+		}
+	default:
+		return fmt.Errorf("unsupported token format %q", cfg.TokenFormat)
+	}
+
+	if cfg.MaxLoginAttempts < 1 {
+		return fmt.Errorf("max login attempts must be at least 1")
+	}
+
+	if cfg.LockoutWindow < 0 || cfg.LockoutMaxDuration < 0 {
+		return fmt.Errorf("lockout window and max duration cannot be negative")
+	}
+
+	if !cfg.LockoutPermanent && cfg.LockoutDuration <= 0 {
+		return fmt.Errorf("lockout duration must be positive")
+	}
+
+	if cfg.LockoutBackoffFactor < 1 {
+		return fmt.Errorf("lockout backoff factor must be at least 1")
+	}
+
+	if cfg.StuffingWindow <= 0 || cfg.StuffingBlockDuration <= 0 {
+		return fmt.Errorf("stuffing window and block duration must be positive")
+	}
+	if cfg.StuffingDelay < 0 {
+		return fmt.Errorf("stuffing delay cannot be negative")
+	}
+	if err := validateStuffingThresholds(cfg.StuffingIPThresholds); err != nil {
+		return fmt.Errorf("invalid stuffing IP thresholds: %w", err)
+	}
+	if err := validateStuffingThresholds(cfg.StuffingSubnetThresholds); err != nil {
+		return fmt.Errorf("invalid stuffing subnet thresholds: %w", err)
+	}
+	if err := validateStuffingThresholds(cfg.StuffingUserThresholds); err != nil {
+		return fmt.Errorf("invalid stuffing user thresholds: %w", err)
+	}
+	if cfg.ChallengeVerifyURL != "" && cfg.ChallengeSecret == "" {
+		return fmt.Errorf("challenge secret is required with a challenge verify URL")
+	}
+
+	switch cfg.PasswordHashAlgorithm {
+	case "argon2id":
+		if cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 {
+			return fmt.Errorf("argon2 iterations must be positive and parallelism between 1 and 255")
+		}
+		if cfg.Argon2Memory < 8*cfg.Argon2Parallelism || cfg.Argon2Memory > math.MaxUint32 {
+			return fmt.Errorf("argon2 memory must be at least 8 KiB per lane")
+		}
+	case "bcrypt":
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("unsupported password hash algorithm %q", cfg.PasswordHashAlgorithm)
+	}
+
+	if len(cfg.PasswordPeppers) > 0 || cfg.PasswordPepperKeyID != "" {
+		if _, ok := cfg.PasswordPeppers[cfg.PasswordPepperKeyID]; !ok {
+			return fmt.Errorf("password pepper key ID %q is not configured", cfg.PasswordPepperKeyID)
+		}
+		for keyID, secret := range cfg.PasswordPeppers {
+			if !passwordhash.ValidPepperKeyID(keyID) {
+				return fmt.Errorf("invalid password pepper key ID %q", keyID)
+			}
+			if len(secret) < 32 {
+				return fmt.Errorf("password pepper %q must be at least 32 characters", keyID)
+			}
+		}
+	}
+
+	if cfg.DeviceMismatchPolicy != "flag" && cfg.DeviceMismatchPolicy != "reject" {
+		return fmt.Errorf("unsupported device mismatch policy %q", cfg.DeviceMismatchPolicy)
+	}
+
+	if cfg.MaxSessionsPerUser < 0 {
+		return fmt.Errorf("max sessions per user cannot be negative")
+	}
+
+	if cfg.ImpersonationTokenTTL <= 0 {
+		return fmt.Errorf("impersonation token TTL must be positive")
+	}
+
+	// Enrolled TOTP secrets would be unreadable after a restart under a random secret
+	if cfg.MFAEncryptionSecret == "" {
+		return fmt.Errorf("MFA encryption secret is required to store TOTP secrets")
+	}
+	if len(cfg.MFAEncryptionSecret) < 32 {
+		return fmt.Errorf("MFA encryption secret must be at least 32 characters")
+	}
+
+	if cfg.APIKeyMaxLifetime < 0 {
+		return fmt.Errorf("API key max lifetime cannot be negative")
+	}
+
+	if cfg.MagicLinkTTL <= 0 {
+		return fmt.Errorf("magic link TTL must be positive")
+	}
+
+	switch cfg.MailerDriver {
+	case "file":
+		if cfg.MailDir == "" {
+			return fmt.Errorf("mail directory is required for the file mailer")
+		}
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return fmt.Errorf("SMTP host is required for the smtp mailer")
+		}
+	case "memory":
+	default:
+		return fmt.Errorf("unsupported mailer driver %q", cfg.MailerDriver)
+	}
+
+	if cfg.OIDCIssuer == "" || cfg.OIDCConsentURL == "" {
+		return fmt.Errorf("OIDC issuer and consent URL cannot be empty")
+	}
+
+	if cfg.IDTokenTTL <= 0 {
+		return fmt.Errorf("ID token TTL must be positive")
+	}
+
+	seen := make(map[string]bool)
+	for _, provider := range cfg.FederatedProviders {
+		if !validProviderName(provider.Name) {
+			return fmt.Errorf("invalid federated provider name %q", provider.Name)
+		}
+		if seen[provider.Name] {
+			return fmt.Errorf("federated provider %q is configured twice", provider.Name)
+		}
+		seen[provider.Name] = true
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return fmt.Errorf("federated provider %q needs an issuer and a client ID", provider.Name)
+		}
+	}
+
+	if cfg.RevocationSweepInterval <= 0 {
+		return fmt.Errorf("revocation sweep interval must be positive")
+	}
+
+	return nil
+}
+
+// validateStuffingThresholds checks delay, challenge and block thresholds.
+// Zero leaves a step out; the others have to grow from step to step.
+func validateStuffingThresholds(thresholds []int) error {
+	if len(thresholds) != 3 {
+		return fmt.Errorf("expected delay, challenge and block thresholds, got %d values", len(thresholds))
+	}
+	previous := 0
+	for _, threshold := range thresholds {
+		if threshold < 0 {
+			return fmt.Errorf("thresholds cannot be negative")
+		}
+		if threshold == 0 {
+			continue
+		}
+		if threshold <= previous {
+			return fmt.Errorf("thresholds must increase from delay to block")
+		}
+		previous = threshold
+	}
 	return nil
 }
 
@@ -199,6 +657,41 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
+// getEnvIntsOrDefault parses a comma separated list of integers
+func getEnvIntsOrDefault(key string, defaultValue []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var ints []int
+	for _, field := range strings.Split(value, ",") {
+		intVal, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return defaultValue
+		}
+		ints = append(ints, intVal)
+	}
+	return ints
+}
+
 func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -228,6 +721,79 @@ func getEnvLogLevelOrDefault(key string, defaultValue zerolog.Level) zerolog.Lev
 	return defaultValue
 }
 
+// parseSecretPairs parses a comma separated list of id:secret pairs, such as
+// OAuth client credentials
+func parseSecretPairs(value string) map[string]string {
+	secrets := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || secret == "" {
+			continue
+		}
+		secrets[id] = secret
+	}
+	return secrets
+}
+
+// loadPepperFile adds the peppers in a file of key_id:secret lines to the
+// configured ones. Blank lines and lines starting with # are skipped.
+func loadPepperFile(cfg *Config) error {
+	content, err := os.ReadFile(cfg.PasswordPepperFile)
+	if err != nil {
+		return fmt.Errorf("error reading password pepper file: %w", err)
+	}
+
+	if cfg.PasswordPeppers == nil {
+		cfg.PasswordPeppers = make(map[string]string)
+	}
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyID, secret, ok := strings.Cut(line, ":")
+		if !ok || keyID == "" || secret == "" {
+			return fmt.Errorf("invalid password pepper on line %d of %s", i+1, cfg.PasswordPepperFile)
+		}
+		cfg.PasswordPeppers[keyID] = secret
+	}
+	return nil
+}
+
+// parseFederatedProviders reads the providers named in a comma separated list
+// from FEDERATED_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _SCOPES
+func parseFederatedProviders(value string) []FederatedProviderConfig {
+	var providers []FederatedProviderConfig
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "FEDERATED_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, FederatedProviderConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(getEnvOrDefault(prefix+"SCOPES", "openid email profile")),
+		})
+	}
+	return providers
+}
+
+// validProviderName accepts lowercase letters, digits and dashes
+func validProviderName(name string) bool {
+	if name == "" || len(name) > 50 {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
 // generateDefaultSecret creates a secure random secret if not provided
 func generateDefaultSecret(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*()_+"
@@ -13,6 +13,23 @@ func RunMigrations(db *gorm.DB) error {
 	err := db.AutoMigrate(
 		&database.User{},
 		&database.LoginAttempt{},
+		&database.RevokedToken{},
+		&database.RefreshToken{},
+		&database.SigningKey{},
+		&database.Session{},
+		&database.OpaqueToken{},
+		&database.OneTimeToken{},
+		&database.TOTPFactor{},
+		&database.RecoveryCode{},
+		&database.WebAuthnCredential{},
+		&database.WebAuthnChallenge{},
+		&database.OAuthClient{},
+		&database.OAuthConsent{},
+		&database.OAuthAuthorizationCode{},
+		&database.UserIdentity{},
+		&database.FederatedLoginState{},
+		&database.APIKey{},
+		&database.SecurityEvent{},
 	)
 
 	if err != nil {
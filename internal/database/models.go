@@ -3,8 +3,9 @@ package database
 import (
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/yourusername/user-management-api/pkg/passwordhash"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserStatus string
@@ -14,20 +15,58 @@ const (
 	UserStatusLocked   UserStatus = "locked"
 	UserStatusInactive UserStatus = "inactive"
 	UserStatusDeleted  UserStatus = "deleted"
+	// UserStatusPendingVerification users have not confirmed their email yet
+	UserStatusPendingVerification UserStatus = "pending_verification"
 )
 
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
+// Permission is a privileged action granted to some roles
+type Permission string
+
+const (
+	// PermissionImpersonate allows obtaining tokens that act as another user
+	PermissionImpersonate Permission = "users:impersonate"
+)
+
+var rolePermissions = map[UserRole][]Permission{
+	UserRoleAdmin: {PermissionImpersonate},
+}
+
+// HasPermission reports whether the role grants the permission
+func (r UserRole) HasPermission(permission Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
 type User struct {
 	ID             uint           `gorm:"primarykey" json:"id"`
 	Username       string         `gorm:"unique;not null;size:100" json:"username"`
 	Email          string         `gorm:"unique;not null;size:100" json:"email"`
 	Password       string         `gorm:"not null" json:"-"`
 	Status         UserStatus     `gorm:"not null;default:'active'" json:"status"`
+	Role           UserRole       `gorm:"not null;default:'user'" json:"role"`
 	LastActivityAt time.Time      `gorm:"default:null" json:"last_activity_at"`
 	LockedUntil    time.Time      `gorm:"default:null" json:"locked_until,omitempty"`
 	LockReason     string         `gorm:"default:null" json:"lock_reason,omitempty"`
 	CreatedAt      time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt      time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"default:null" json:"deleted_at,omitempty"`
+
+	// TokenVersion is stamped into every token issued to the user; bumping it
+	// invalidates them all
+	TokenVersion uint `gorm:"not null;default:0" json:"-"`
+	// EmailVerifiedAt is set once the user proves they own Email
+	EmailVerifiedAt *time.Time `gorm:"default:null" json:"email_verified_at,omitempty"`
 }
 
 type TokenPair struct {
@@ -35,19 +74,54 @@ type TokenPair struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// MFAChallenge is returned by a login that still needs a second factor. The
+// challenge token is exchanged for a TokenPair at /auth/mfa/verify.
+type MFAChallenge struct {
+	MFARequired    bool     `json:"mfa_required"`
+	ChallengeToken string   `json:"challenge_token"`
+	Methods        []string `json:"methods"`
+	ExpiresIn      int64    `json:"expires_in"`
+}
+
+// NextTokenVersion is the update that bumps TokenVersion in place, so
+// concurrent invalidations cannot undo each other
+func NextTokenVersion() clause.Expr {
+	return gorm.Expr("token_version + 1")
+}
+
+// InvalidateTokens invalidates every token issued to the user so far
+func (u *User) InvalidateTokens() {
+	u.TokenVersion++
+}
+
+// TokenInvalidated reports whether a token stamped with tokenVersion has been
+// invalidated by a later TokenVersion bump
+func (u *User) TokenInvalidated(tokenVersion uint) bool {
+	return tokenVersion != u.TokenVersion
+}
+
+// HashPassword replaces the plain text Password with a hash made by the
+// configured password hasher
 func (u *User) HashPassword() error {
-	// Use bcrypt with high cost for password hashing
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost+4)
+	hashedPassword, err := passwordhash.Default().Hash(u.Password)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashedPassword)
+	u.Password = hashedPassword
 	return nil
 }
 
+// CheckPasswordHash reports whether password matches the stored hash,
+// whichever supported algorithm made it
 func (u *User) CheckPasswordHash(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+	valid, err := passwordhash.Default().Verify(password, u.Password)
+	return err == nil && valid
+}
+
+// PasswordNeedsRehash reports whether the stored hash was made with another
+// algorithm or other parameters than the configured password hasher uses
+func (u *User) PasswordNeedsRehash() bool {
+	return passwordhash.Default().NeedsRehash(u.Password)
 }
 
 type LoginAttempt struct {
@@ -58,3 +132,245 @@ type LoginAttempt struct {
 	Success     bool      `gorm:"not null" json:"success"`
 	LastAttempt time.Time `gorm:"default:null" json:"last_attempt"`
 }
+
+type RevokedToken struct {
+	JTI       string    `gorm:"primarykey;size:64" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// RefreshToken tracks an issued refresh token. Tokens rotated from the same
+// login share a FamilyID so a replayed token can revoke the whole chain.
+type RefreshToken struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	JTI        string     `gorm:"unique;not null;size:64" json:"jti"`
+	FamilyID   string     `gorm:"not null;index;size:64" json:"family_id"`
+	ParentJTI  string     `gorm:"size:64;default:null" json:"parent_jti,omitempty"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	ConsumedAt *time.Time `gorm:"default:null" json:"consumed_at,omitempty"`
+	RevokedAt  *time.Time `gorm:"default:null" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	// Device the token was issued to
+	IPAddress         string `gorm:"size:45" json:"ip_address"`
+	UserAgent         string `gorm:"size:512" json:"user_agent"`
+	DeviceID          string `gorm:"size:128" json:"device_id,omitempty"`
+	DeviceFingerprint string `gorm:"size:64" json:"device_fingerprint"`
+	// DeviceMismatch is set when the token was issued to a refresh request
+	// from a different device than its predecessor
+	DeviceMismatch bool `gorm:"default:false" json:"device_mismatch"`
+}
+
+// Session is a login on one device. Its ID is the family ID shared by the
+// refresh tokens rotated from that login and carried by its access tokens.
+type Session struct {
+	ID                string    `gorm:"primarykey;size:64" json:"id"`
+	UserID            uint      `gorm:"not null;index" json:"user_id"`
+	IPAddress         string    `gorm:"size:45" json:"ip_address"`
+	UserAgent         string    `gorm:"size:512" json:"user_agent"`
+	DeviceID          string    `gorm:"size:128" json:"device_id,omitempty"`
+	DeviceFingerprint string    `gorm:"size:64" json:"device_fingerprint"`
+	CreatedAt         time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	LastSeenAt        time.Time `gorm:"not null" json:"last_seen_at"`
+	// ExpiresAt follows the expiry of the session's latest refresh token
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	// The latest access token, revoked together with the session
+	AccessTokenJTI       string     `gorm:"size:64" json:"-"`
+	AccessTokenExpiresAt time.Time  `json:"-"`
+	RevokedAt            *time.Time `gorm:"default:null" json:"revoked_at,omitempty"`
+}
+
+// SigningKey is a persisted token signing key. Material is encrypted by the
+// keyring and erased once the key is retired.
+type SigningKey struct {
+	KID       string     `gorm:"column:kid;primarykey;size:64" json:"kid"`
+	TokenType string     `gorm:"not null;index;size:20" json:"token_type"`
+	Algorithm string     `gorm:"size:20" json:"algorithm"`
+	Material  []byte     `json:"-"`
+	Active    bool       `gorm:"not null;default:false" json:"active"`
+	Retired   bool       `gorm:"not null;default:false" json:"retired"`
+	RetireAt  *time.Time `gorm:"default:null" json:"retire_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// OpaqueToken holds the claims behind an opaque reference token. Only a hash
+// of the token is stored.
+type OpaqueToken struct {
+	TokenHash string    `gorm:"primarykey;size:64" json:"-"`
+	TokenType string    `gorm:"not null;size:20" json:"token_type"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Claims    string    `gorm:"type:text;not null" json:"-"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// OneTimeToken is a single-use token for an account flow such as email
+// verification. Only a hash of the token is stored. BindingHash covers the
+// user field the token was issued for, so changing it voids the token.
+type OneTimeToken struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	TokenHash   string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Purpose     string     `gorm:"not null;size:32;index:idx_one_time_token_user_purpose" json:"purpose"`
+	UserID      uint       `gorm:"not null;index:idx_one_time_token_user_purpose" json:"user_id"`
+	BindingHash string     `gorm:"size:64;not null" json:"-"`
+	ExpiresAt   time.Time  `gorm:"not null;index" json:"expires_at"`
+	ConsumedAt  *time.Time `gorm:"default:null" json:"consumed_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// TOTPFactor is a user's TOTP authenticator. Secret is encrypted at rest and
+// the factor only guards logins once ConfirmedAt is set. LastUsedStep keeps
+// a code from being accepted twice.
+type TOTPFactor struct {
+	UserID       uint       `gorm:"primarykey" json:"user_id"`
+	Secret       string     `gorm:"not null" json:"-"`
+	ConfirmedAt  *time.Time `gorm:"default:null" json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"`
+	CreatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the
+// authenticator is lost. Only a hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	UsedAt    *time.Time `gorm:"default:null" json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// WebAuthnCredential is a passkey or security key registered by a user.
+// SignCount is the authenticator's signature counter, checked on every login
+// to detect cloned credentials.
+type WebAuthnCredential struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	CredentialID   string     `gorm:"uniqueIndex;size:1400;not null" json:"credential_id"`
+	PublicKey      []byte     `gorm:"not null" json:"-"`
+	Algorithm      int64      `gorm:"not null" json:"algorithm"`
+	SignCount      uint32     `gorm:"not null;default:0" json:"-"`
+	AAGUID         string     `gorm:"size:36" json:"aaguid,omitempty"`
+	Transports     string     `gorm:"size:255" json:"-"`
+	Name           string     `gorm:"size:100" json:"name"`
+	BackupEligible bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	LastUsedAt     *time.Time `gorm:"default:null" json:"last_used_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// WebAuthnChallenge is the server side of an unfinished WebAuthn ceremony.
+// UserID is zero for logins that let the authenticator pick the account.
+type WebAuthnChallenge struct {
+	Challenge        string    `gorm:"primarykey;size:64" json:"-"`
+	Ceremony         string    `gorm:"size:20;not null" json:"ceremony"`
+	UserID           uint      `gorm:"index" json:"user_id"`
+	UserVerification string    `gorm:"size:20;not null" json:"user_verification"`
+	ExpiresAt        time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// OAuthClient is an application that signs users in through the OAuth
+// authorization endpoint. Public clients have no secret and rely on PKCE
+// alone. RedirectURIs and Scopes are space separated.
+type OAuthClient struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	ClientID     string    `gorm:"uniqueIndex;size:64;not null" json:"client_id"`
+	SecretHash   string    `gorm:"size:64" json:"-"`
+	Name         string    `gorm:"size:100;not null" json:"name"`
+	RedirectURIs string    `gorm:"type:text;not null" json:"-"`
+	Scopes       string    `gorm:"size:255;not null" json:"-"`
+	Public       bool      `gorm:"not null;default:false" json:"public"`
+	CreatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// OAuthConsent records the scopes a user has granted a client, so the
+// consent screen is only shown again when a client asks for more
+type OAuthConsent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client" json:"user_id"`
+	ClientID  string    `gorm:"not null;size:64;uniqueIndex:idx_oauth_consent_user_client" json:"client_id"`
+	Scopes    string    `gorm:"size:255;not null" json:"scopes"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// OAuthAuthorizationCode is an issued authorization code waiting to be
+// exchanged at the token endpoint. Only a hash of the code is stored, along
+// with the PKCE challenge the exchange has to answer.
+type OAuthAuthorizationCode struct {
+	CodeHash      string `gorm:"primarykey;size:64" json:"-"`
+	ClientID      string `gorm:"not null;size:64" json:"client_id"`
+	UserID        uint   `gorm:"not null;index" json:"user_id"`
+	RedirectURI   string `gorm:"type:text;not null" json:"redirect_uri"`
+	Scopes        string `gorm:"size:255;not null" json:"scopes"`
+	Nonce         string `gorm:"size:255" json:"-"`
+	CodeChallenge string `gorm:"size:128;not null" json:"-"`
+	// TokenVersion is the user's token version when they authorized the client
+	TokenVersion uint      `gorm:"not null;default:0" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// UserIdentity links an account at an external OpenID Connect provider to a
+// user. Subject is the provider's stable identifier for the account; Email is
+// what the provider last reported and is informational only.
+type UserIdentity struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"not null;size:50;uniqueIndex:idx_user_identity_provider_subject" json:"provider"`
+	Subject     string     `gorm:"not null;size:255;uniqueIndex:idx_user_identity_provider_subject" json:"subject"`
+	Email       string     `gorm:"size:100" json:"email,omitempty"`
+	LastLoginAt *time.Time `gorm:"default:null" json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// FederatedLoginState is a login or account link in progress at an external
+// provider. Only a hash of the state parameter is stored; UserID is set when
+// the identity is to be linked to a signed in user.
+type FederatedLoginState struct {
+	StateHash    string    `gorm:"primarykey;size:64" json:"-"`
+	Provider     string    `gorm:"not null;size:50" json:"provider"`
+	Nonce        string    `gorm:"not null;size:64" json:"-"`
+	CodeVerifier string    `gorm:"not null;size:128" json:"-"`
+	UserID       uint      `gorm:"index" json:"user_id"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// APIKey lets a machine client act as the user who created it, limited to
+// Scopes (space separated). Only a hash of the key is stored; Prefix is the
+// public part of the key it is looked up by.
+type APIKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"uniqueIndex;size:16;not null" json:"prefix"`
+	KeyHash    string     `gorm:"size:64;not null" json:"-"`
+	Scopes     string     `gorm:"size:255;not null" json:"-"`
+	ExpiresAt  *time.Time `gorm:"default:null;index" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `gorm:"default:null" json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"size:45" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `gorm:"default:null;index" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	// TokenVersion is the user's token version when the key was created
+	TokenVersion uint `gorm:"not null;default:0" json:"-"`
+}
+
+// Usable reports whether the key is neither revoked nor expired
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// SecurityEvent records an attack pattern the login detector noticed and how
+// it responded. Subnet is set for events about a whole network, IPAddress for
+// events about a single address and Username for events about one account.
+type SecurityEvent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Type      string    `gorm:"size:50;not null;index" json:"type"`
+	Action    string    `gorm:"size:20;not null" json:"action"`
+	IPAddress string    `gorm:"size:45;index" json:"ip_address,omitempty"`
+	Subnet    string    `gorm:"size:49" json:"subnet,omitempty"`
+	Username  string    `gorm:"size:100;index" json:"username,omitempty"`
+	Count     int       `gorm:"not null" json:"count"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;index" json:"created_at"`
+}
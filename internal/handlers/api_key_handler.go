@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+type APIKeyHandlerImpl struct {
+	service *services.APIKeyServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyServiceImpl, logger zerolog.Logger) *APIKeyHandlerImpl {
+	return &APIKeyHandlerImpl{
+		service: apiKeyService,
+		logger:  logger.With().Str("handler", "APIKeyHandler").Logger(),
+	}
+}
+
+// ListKeys lists the authenticated user's API keys
+func (h *APIKeyHandlerImpl) ListKeys(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	keys, err := h.service.ListKeys(ctx, c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to list API keys")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toListAPIKeysResponse(keys))
+}
+
+// CreateKey creates an API key for the authenticated user. The key is only
+// part of this response.
+func (h *APIKeyHandlerImpl) CreateKey(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "CreateKey").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	key, plaintext, err := h.service.CreateKey(ctx, c.GetUint("user_id"), services.APIKeyRequest{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		h.logger.Err(err).Str("name", req.Name).Msg("Failed to create API key")
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, newAPIKeyResponse(key, plaintext))
+}
+
+// RevokeKey revokes one of the authenticated user's API keys
+func (h *APIKeyHandlerImpl) RevokeKey(c *gin.Context) {
+	id, ok := h.parseID(c, "id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+	h.revoke(c, c.GetUint("user_id"), id)
+}
+
+// ListUserKeys lets admins list the API keys of any user
+func (h *APIKeyHandlerImpl) ListUserKeys(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	userID, ok := h.parseID(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.ListKeys(ctx, userID)
+	if err != nil {
+		h.logger.Err(err).Uint("user_id", userID).Msg("Failed to list user API keys")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toListAPIKeysResponse(keys))
+}
+
+// RevokeUserKey lets admins revoke an API key of any user
+func (h *APIKeyHandlerImpl) RevokeUserKey(c *gin.Context) {
+	userID, ok := h.parseID(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	id, ok := h.parseID(c, "key_id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+	h.revoke(c, userID, id)
+}
+
+func (h *APIKeyHandlerImpl) revoke(c *gin.Context, userID, id uint) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	if err := h.service.RevokeKey(ctx, userID, id); err != nil {
+		h.logger.Err(err).Uint("user_id", userID).Uint("id", id).Msg("Failed to revoke API key")
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseID reads a numeric path parameter
+func (h *APIKeyHandlerImpl) parseID(c *gin.Context, param, message string) (uint, bool) {
+	idStr := c.Param(param)
+	id, parseErr := strconv.ParseUint(idStr, 10, 64)
+	if parseErr != nil {
+		h.logger.Error().Err(parseErr).Str(param, idStr).Msg(message)
+		c.Error(apperrors.NewValidationErrors(message, parseErr))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func newAPIKeyResponse(key *database.APIKey, plaintext string) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Key:        plaintext,
+		Prefix:     key.Prefix,
+		Scopes:     strings.Fields(key.Scopes),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+func toListAPIKeysResponse(keys []database.APIKey) ListAPIKeysResponse {
+	response := ListAPIKeysResponse{APIKeys: make([]APIKeyResponse, 0, len(keys))}
+	for i := range keys {
+		response.APIKeys = append(response.APIKeys, newAPIKeyResponse(&keys[i], ""))
+	}
+	return response
+}
@@ -0,0 +1,121 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// setupAPIKeyRouter serves the API key routes and an endpoint echoing the
+// identity either authentication put into the context, behind a scope
+func setupAPIKeyRouter(t *testing.T) (*gin.Engine, *services.AuthServiceImpl) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "apikeys.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, token.NewMemoryRevocationStore(), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop())
+	apiKeyService := services.NewAPIKeyService(userRepo, repository.NewAPIKeyRepository(db, zerolog.Nop()), authManager, zerolog.Nop())
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, zerolog.Nop())
+
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	meGroup := router.Group("/me", middleware.AuthMiddleware(authManager, zerolog.Nop()))
+	meGroup.GET("/api-keys", apiKeyHandler.ListKeys)
+	meGroup.POST("/api-keys", apiKeyHandler.CreateKey)
+	meGroup.DELETE("/api-keys/:id", apiKeyHandler.RevokeKey)
+
+	whoami := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"username": c.GetString("username"), "api_key_id": c.GetUint("api_key_id")})
+	}
+	usersGroup := router.Group("/users", middleware.AuthOrAPIKeyMiddleware(middleware.AuthMiddleware(authManager, zerolog.Nop()),
+		middleware.APIKeyMiddleware(apiKeyService, zerolog.Nop())))
+	usersGroup.GET("/whoami", middleware.RequireScope(zerolog.Nop(), services.ScopeRead), whoami)
+	usersGroup.POST("/whoami", middleware.RequireScope(zerolog.Nop(), services.ScopeWrite), whoami)
+	return router, authService
+}
+
+func serveWithHeader(router *gin.Engine, method, path, header, value string, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	router, authService := setupAPIKeyRouter(t)
+	tokens := loginOAuthTestUser(t, authService, "batchjob")
+	bearer := "Bearer " + tokens.AccessToken
+
+	body, _ := json.Marshal(handlers.CreateAPIKeyRequest{Name: "export", Scopes: []string{"read"}})
+	w := serveWithHeader(router, "POST", "/me/api-keys", "Authorization", bearer, body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	var created handlers.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Key)
+	assert.Equal(t, []string{"read"}, created.Scopes)
+
+	// The key authenticates in either header and acts as its owner
+	w = serveWithHeader(router, "GET", "/users/whoami", "Authorization", "ApiKey "+created.Key, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"username":"batchjob","api_key_id":`+strconv.FormatUint(uint64(created.ID), 10)+`}`, w.Body.String())
+	w = serveWithHeader(router, "GET", "/users/whoami", "X-API-Key", created.Key, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// but only within its scopes, which access tokens hold all of
+	w = serveWithHeader(router, "POST", "/users/whoami", "X-API-Key", created.Key, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serveWithHeader(router, "POST", "/users/whoami", "Authorization", bearer, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The key is not shown again
+	w = serveWithHeader(router, "GET", "/me/api-keys", "Authorization", bearer, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed handlers.ListAPIKeysResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.APIKeys, 1)
+	assert.Empty(t, listed.APIKeys[0].Key)
+	assert.NotNil(t, listed.APIKeys[0].LastUsedAt)
+
+	// API keys cannot manage API keys
+	w = serveWithHeader(router, "GET", "/me/api-keys", "X-API-Key", created.Key, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serveWithHeader(router, "DELETE", "/me/api-keys/"+strconv.FormatUint(uint64(created.ID), 10), "Authorization", bearer, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = serveWithHeader(router, "GET", "/users/whoami", "X-API-Key", created.Key, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serveWithHeader(router, "GET", "/users/whoami", "X-API-Key", "uma_000000000000_unknown", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
 	"github.com/yourusername/user-management-api/pkg/utils"
+	"github.com/yourusername/user-management-api/pkg/webauthn"
 )
 
 type AuthHandlerImpl struct {
@@ -61,14 +67,178 @@ func (a *AuthHandlerImpl) LoginUser(c *gin.Context) {
 		return
 	}
 
-	ipAddr := c.ClientIP()
-	tokenPair, err := a.service.LoginUser(ctx, req.Username, req.Password, ipAddr)
+	// Logins from suspicious sources answer a challenge in the X-Challenge-Response header
+	ctx = authentication.WithChallengeResponse(ctx, c.GetHeader("X-Challenge-Response"))
+	tokenPair, challenge, err := a.service.LoginUser(ctx, req.Username, req.Password, deviceFromRequest(c))
 	if err != nil {
 		a.logger.Err(err).Msg("Failed to login user")
 		c.Error(err)
 		return
 	}
 
+	// Users with MFA enabled continue at /auth/mfa/verify
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenPair)
+}
+
+// VerifyMFA completes a login with the second factor
+func (a *AuthHandlerImpl) VerifyMFA(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		a.logger.Err(err).Str("handler", "VerifyMFA").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	tokenPair, err := a.service.VerifyMFA(ctx, req.ChallengeToken, req.Code, deviceFromRequest(c))
+	if err != nil {
+		a.logger.Err(err).Msg("Failed to verify second factor")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenPair)
+}
+
+// BeginPasskeyMFA returns the options to complete a login with a passkey
+func (a *AuthHandlerImpl) BeginPasskeyMFA(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req PasskeyMFABeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		a.logger.Err(err).Str("handler", "BeginPasskeyMFA").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	options, err := a.service.BeginPasskeyMFA(ctx, req.ChallengeToken)
+	if err != nil {
+		a.logger.Err(err).Msg("Failed to start passkey verification")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// VerifyMFAWithPasskey completes a login with a passkey as the second factor
+func (a *AuthHandlerImpl) VerifyMFAWithPasskey(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req PasskeyMFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		a.logger.Err(err).Str("handler", "VerifyMFAWithPasskey").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	tokenPair, err := a.service.VerifyMFAWithPasskey(ctx, req.ChallengeToken, &req.Credential, deviceFromRequest(c))
+	if err != nil {
+		a.logger.Err(err).Msg("Failed to verify passkey")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenPair)
+}
+
+// BeginPasskeyLogin returns the options for a passwordless login. The
+// username is optional.
+func (a *AuthHandlerImpl) BeginPasskeyLogin(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req PasskeyLoginBeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		a.logger.Err(err).Str("handler", "BeginPasskeyLogin").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	options, err := a.service.BeginPasskeyLogin(ctx, req.Username)
+	if err != nil {
+		a.logger.Err(err).Msg("Failed to start passkey login")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// LoginWithPasskey completes a passwordless login
+func (a *AuthHandlerImpl) LoginWithPasskey(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var credential webauthn.AssertionCredential
+	if err := c.ShouldBindJSON(&credential); err != nil {
+		a.logger.Err(err).Str("handler", "LoginWithPasskey").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	tokenPair, err := a.service.LoginWithPasskey(ctx, &credential, deviceFromRequest(c))
+	if err != nil {
+		a.logger.Err(err).Msg("Failed to login user with passkey")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenPair)
+}
+
+// RequestMagicLink mails a login link. It answers the same way whether or
+// not the address is registered, and even when sending fails.
+func (a *AuthHandlerImpl) RequestMagicLink(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		a.logger.Err(err).Str("handler", "RequestMagicLink").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	if err := a.service.RequestMagicLink(ctx, req.Email, c.ClientIP()); err != nil {
+		a.logger.Err(err).Msg("Failed to request magic link")
+		// Deployments without magic links say so
+		if err.Code() == apperrors.ErrCodeValidationError {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{})
+}
+
+// LoginWithMagicLink exchanges a magic link for a token pair
+func (a *AuthHandlerImpl) LoginWithMagicLink(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req MagicLinkLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		a.logger.Err(err).Str("handler", "LoginWithMagicLink").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	tokenPair, challenge, err := a.service.LoginWithMagicLink(ctx, req.Token, deviceFromRequest(c))
+	if err != nil {
+		a.logger.Err(err).Msg("Failed to login user with magic link")
+		c.Error(err)
+		return
+	}
+
+	// Users with MFA enabled continue at /auth/mfa/verify
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
 	c.JSON(http.StatusOK, tokenPair)
 }
 
@@ -85,21 +255,24 @@ func (a *AuthHandlerImpl) RefreshTokens(c *gin.Context) {
 		return
 	}
 
-	// Validate refresh token
-	userID, username, err := a.service.ValidateRefreshToken(ctx, refreshRequest.RefreshToken)
+	// Validate and rotate refresh token
+	tokenPair, err := a.service.RefreshTokens(ctx, refreshRequest.RefreshToken, deviceFromRequest(c))
 	if err != nil {
-		a.logger.Err(err).Str("refresh_token", refreshRequest.RefreshToken).Msg("Invalid refresh token")
+		a.logger.Err(err).Msg("Failed to refresh tokens")
 		c.Error(err)
 		return
 	}
+	c.JSON(http.StatusOK, tokenPair)
+}
 
-	tokenPair, err := a.service.RefreshTokens(ctx, userID, username)
-	if err != nil {
-		a.logger.Err(err).Uint("user_id", userID).Str("username", username).Msg("Failed to refresh tokens")
-		c.Error(err)
-		return
+// deviceFromRequest describes the client making the request. Clients may
+// identify themselves further with the X-Device-ID header.
+func deviceFromRequest(c *gin.Context) token.Device {
+	return token.Device{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		DeviceID:  c.GetHeader("X-Device-ID"),
 	}
-	c.JSON(http.StatusOK, tokenPair)
 }
 
 func (a *AuthHandlerImpl) LogoutUser(c *gin.Context) {
@@ -122,7 +295,14 @@ func (a *AuthHandlerImpl) LogoutUser(c *gin.Context) {
 	}
 	token := parts[1]
 
-	if err := a.service.LogoutUser(ctx, token); err != nil {
+	// scope=all logs the user out of every session
+	scope := c.Query("scope")
+	if scope != "" && scope != "all" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid logout scope"})
+		return
+	}
+
+	if err := a.service.LogoutUser(ctx, token, scope == "all"); err != nil {
 		a.logger.Err(err).Str("token", token).Msg("Failed to logout user")
 		c.Error(err)
 		return
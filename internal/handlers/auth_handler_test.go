@@ -2,9 +2,12 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -15,6 +18,7 @@ import (
 
 	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
 	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
 	"github.com/yourusername/user-management-api/internal/repository"
 	"github.com/yourusername/user-management-api/internal/services"
 	"github.com/yourusername/user-management-api/pkg/authentication"
@@ -30,9 +34,11 @@ var db, _ = sqlite.InitializeDatabase(sqlite.DatabaseConfig{
 })
 var repo = repository.NewUserRepository(db, zerolog.Logger{})
 var loginAttemptRepo = repository.NewLoginAttemptRepository(db, zerolog.Logger{})
-var tokenManager = token.NewTokenManager("secret_key", "refresh_secret_key")
+var tokenManager = token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), nil)
 var authManager = authentication.NewAuthenticationManager(repo, tokenManager, loginAttemptRepo, zerolog.Logger{})
-var authService = services.NewAuthService(tokenManager, authManager, repo, zerolog.Logger{})
+var refreshTokenRepo = repository.NewRefreshTokenRepository(db, zerolog.Logger{})
+var sessionService = services.NewSessionService(repository.NewSessionRepository(db, zerolog.Logger{}), refreshTokenRepo, token.NewMemoryRevocationStore(), zerolog.Logger{})
+var authService = services.NewAuthService(tokenManager, authManager, repo, refreshTokenRepo, sessionService, zerolog.Logger{})
 var authHandler = handlers.NewAuthHandler(authService, zerolog.Logger{})
 
 func setupTestRouter() *gin.Engine {
@@ -167,3 +173,47 @@ func TestLoginUser(t *testing.T) {
 	assert.Contains(t, response, "access_token")
 	assert.Contains(t, response, "refresh_token")
 }
+
+func TestLoginLockoutResponse(t *testing.T) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "lockout.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokens := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	auth := services.NewAuthService(tokenManager,
+		authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop()),
+		userRepo, refreshTokens, services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokens, token.NewMemoryRevocationStore(), zerolog.Nop()),
+		zerolog.Nop())
+
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	router.POST("/auth/login", handlers.NewAuthHandler(auth, zerolog.Nop()).LoginUser)
+	_, err = auth.RegisterUser(context.Background(), "lockoutuser", "StrongP@ssw0rd2024!", "lockoutuser@example.com")
+	require.NoError(t, err)
+
+	policy := authentication.DefaultLockoutPolicy()
+	for range policy.MaxAttempts - 1 {
+		w := postJSON(router, "/auth/login", gin.H{"username": "lockoutuser", "password": "Wr0ng!Password"})
+		require.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+		assert.Empty(t, w.Header().Get("Retry-After"))
+	}
+
+	// Locked responses tell clients when to come back, for the right password too
+	for _, password := range []string{"Wr0ng!Password", "StrongP@ssw0rd2024!"} {
+		w := postJSON(router, "/auth/login", gin.H{"username": "lockoutuser", "password": password})
+		require.Equal(t, http.StatusForbidden, w.Code)
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		require.NoError(t, err)
+		assert.InDelta(t, policy.Duration.Seconds(), retryAfter, 5)
+
+		var response middleware.ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.LockedUntil)
+		assert.WithinDuration(t, time.Now().Add(policy.Duration), *response.LockedUntil, 5*time.Second)
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+type EmailVerificationHandlerImpl struct {
+	service *services.EmailVerificationServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewEmailVerificationHandler(emailVerificationService *services.EmailVerificationServiceImpl, logger zerolog.Logger) *EmailVerificationHandlerImpl {
+	return &EmailVerificationHandlerImpl{
+		service: emailVerificationService,
+		logger:  logger.With().Str("handler", "EmailVerificationHandler").Logger(),
+	}
+}
+
+// VerifyEmail activates the account the verification token was mailed for
+func (h *EmailVerificationHandlerImpl) VerifyEmail(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "VerifyEmail").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	if _, err := h.service.VerifyEmail(ctx, req.Token); err != nil {
+		h.logger.Err(err).Msg("Failed to verify email")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// ResendVerification mails a new verification token. It always responds with
+// 202 Accepted so it cannot be used to find out which addresses are registered.
+func (h *EmailVerificationHandlerImpl) ResendVerification(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "ResendVerification").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	if err := h.service.ResendVerification(ctx, req.Email); err != nil {
+		h.logger.Err(err).Msg("Failed to resend verification email")
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{})
+}
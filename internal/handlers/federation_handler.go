@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+const (
+	// federatedStateCookie binds a federated login to the browser that started
+	// it, so a callback URL cannot be used to sign someone else in
+	federatedStateCookie = "federated_login_state"
+	// federatedPath is where the provider callbacks are routed in cmd/server
+	federatedPath = "/api/v1/auth/federated"
+)
+
+type FederationHandlerImpl struct {
+	service     *services.FederationServiceImpl
+	authService *services.AuthServiceImpl
+	logger      zerolog.Logger
+}
+
+func NewFederationHandler(federationService *services.FederationServiceImpl, authService *services.AuthServiceImpl, logger zerolog.Logger) *FederationHandlerImpl {
+	return &FederationHandlerImpl{
+		service:     federationService,
+		authService: authService,
+		logger:      logger.With().Str("handler", "FederationHandler").Logger(),
+	}
+}
+
+// ListProviders lists the providers users can sign in with
+func (h *FederationHandlerImpl) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, ListFederatedProvidersResponse{Providers: h.service.Providers()})
+}
+
+// BeginLogin sends the browser to sign in at the provider
+func (h *FederationHandlerImpl) BeginLogin(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	start, err := h.service.BeginLogin(ctx, c.Param("provider"), 0)
+	if err != nil {
+		h.logger.Err(err).Str("provider", c.Param("provider")).Msg("Failed to start federated login")
+		c.Error(err)
+		return
+	}
+
+	h.setStateCookie(c, start.State, int(services.FederatedLoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, start.AuthorizationURL)
+}
+
+// Callback completes a federated login or account link when the provider
+// redirects the browser back. Logins respond with a token pair, links with
+// the linked identity.
+func (h *FederationHandlerImpl) Callback(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req FederatedCallbackRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		h.logger.Err(err).Str("handler", "Callback").Msg("Invalid callback parameters")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid callback parameters", Details: err.Error()})
+		return
+	}
+
+	cookie, cookieErr := c.Cookie(federatedStateCookie)
+	h.setStateCookie(c, "", -1)
+	if cookieErr != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(req.State)) != 1 {
+		h.logger.Warn().Str("provider", c.Param("provider")).Msg("Federated login callback from another browser")
+		c.Error(apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "Federated login was started in another browser", nil))
+		return
+	}
+	if req.Error != "" || req.Code == "" {
+		h.logger.Warn().Str("provider", c.Param("provider")).Str("error", req.Error).Str("error_description", req.ErrorDescription).
+			Msg("Identity provider did not complete the login")
+		c.Error(apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "Sign-in at the identity provider was not completed", nil))
+		return
+	}
+
+	tokenPair, login, err := h.authService.LoginWithProvider(ctx, c.Param("provider"), req.State, req.Code, deviceFromRequest(c))
+	if err != nil {
+		h.logger.Err(err).Str("provider", c.Param("provider")).Msg("Failed to complete federated login")
+		c.Error(err)
+		return
+	}
+
+	if tokenPair == nil {
+		c.JSON(http.StatusOK, login)
+		return
+	}
+	c.JSON(http.StatusOK, tokenPair)
+}
+
+// ListIdentities lists the identities linked to the authenticated user
+func (h *FederationHandlerImpl) ListIdentities(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	identities, err := h.service.ListIdentities(ctx, c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to list identities")
+		c.Error(err)
+		return
+	}
+
+	if identities == nil {
+		identities = []database.UserIdentity{}
+	}
+	c.JSON(http.StatusOK, ListIdentitiesResponse{Identities: identities})
+}
+
+// LinkIdentity starts linking an identity at the provider to the
+// authenticated user. The client sends the browser to the returned URL.
+func (h *FederationHandlerImpl) LinkIdentity(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	start, err := h.service.BeginLogin(ctx, c.Param("provider"), c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Str("provider", c.Param("provider")).Msg("Failed to start linking identity")
+		c.Error(err)
+		return
+	}
+
+	h.setStateCookie(c, start.State, int(services.FederatedLoginTTL.Seconds()))
+	c.JSON(http.StatusOK, start)
+}
+
+// UnlinkIdentity removes one of the authenticated user's identities
+func (h *FederationHandlerImpl) UnlinkIdentity(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	idStr := c.Param("id")
+	id, parseErr := strconv.ParseUint(idStr, 10, 64)
+	if parseErr != nil {
+		h.logger.Error().Err(parseErr).Str("handler", "UnlinkIdentity").Str("id", idStr).Msg("Invalid identity ID")
+		c.Error(apperrors.NewValidationErrors("Invalid identity ID", parseErr))
+		return
+	}
+
+	if err := h.service.UnlinkIdentity(ctx, c.GetUint("user_id"), uint(id)); err != nil {
+		h.logger.Err(err).Uint64("id", id).Msg("Failed to unlink identity")
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// setStateCookie sets or, with a negative maxAge, clears the state cookie.
+// Lax keeps it on the top-level navigation back from the provider.
+func (h *FederationHandlerImpl) setStateCookie(c *gin.Context, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(federatedStateCookie, state, maxAge, federatedPath, "", true, true)
+}
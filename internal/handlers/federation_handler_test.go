@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/oidc"
+	"github.com/yourusername/user-management-api/pkg/oidc/oidctest"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const federatedCallbackPath = "/api/v1/auth/federated/corp/callback"
+
+func setupFederationRouter(t *testing.T) (*gin.Engine, *oidctest.Provider) {
+	idp, err := oidctest.NewProvider()
+	require.NoError(t, err)
+	t.Cleanup(idp.Close)
+	idp.RegisterClient("user-management-api", "secret", "https://id.example.com"+federatedCallbackPath)
+	idp.User = oidctest.User{Subject: "corp-1", Email: "jane@corp.example.com", EmailVerified: true, PreferredUsername: "jane"}
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "user-management-api",
+		ClientSecret: "secret",
+		RedirectURL:  "https://id.example.com" + federatedCallbackPath,
+	}, nil)
+	require.NoError(t, err)
+
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "federation.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, token.NewMemoryRevocationStore(), zerolog.Nop())
+	federationService := services.NewFederationService(map[string]*oidc.Provider{"corp": provider}, userRepo,
+		repository.NewUserIdentityRepository(db, zerolog.Nop()), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop(),
+		services.WithFederation(federationService))
+	federationHandler := handlers.NewFederationHandler(federationService, authService, zerolog.Nop())
+
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	router.GET("/api/v1/auth/federated", federationHandler.ListProviders)
+	router.GET("/api/v1/auth/federated/:provider/login", federationHandler.BeginLogin)
+	router.GET("/api/v1/auth/federated/:provider/callback", federationHandler.Callback)
+	return router, idp
+}
+
+// beginFederatedLogin starts a login and returns the state cookie and the
+// query the provider redirects back with
+func beginFederatedLogin(t *testing.T, router *gin.Engine, idp *oidctest.Provider) (*http.Cookie, string) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/auth/federated/corp/login", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+
+	callback, err := idp.Authorize(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, cookies[0].Value, callback.Query().Get("state"))
+	return cookies[0], callback.RawQuery
+}
+
+func TestFederatedLoginCallback(t *testing.T) {
+	router, idp := setupFederationRouter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/auth/federated", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"providers":["corp"]}`, w.Body.String())
+
+	// A callback is only accepted in the browser that started the login
+	_, query := beginFederatedLogin(t, router, idp)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", federatedCallbackPath+"?"+query, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	otherCookie, _ := beginFederatedLogin(t, router, idp)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", federatedCallbackPath+"?"+query, nil)
+	req.AddCookie(otherCookie)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	cookie, query := beginFederatedLogin(t, router, idp)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", federatedCallbackPath+"?"+query, nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var tokens database.TokenPair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	// Errors reported by the provider fail the login
+	cookie, _ = beginFederatedLogin(t, router, idp)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", federatedCallbackPath+"?state="+cookie.Value+"&error=access_denied", nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
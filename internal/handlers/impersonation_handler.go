@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+// Identifiers defined by RFC 8693
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+type ImpersonationHandlerImpl struct {
+	service *services.ImpersonationServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewImpersonationHandler(impersonationService *services.ImpersonationServiceImpl, logger zerolog.Logger) *ImpersonationHandlerImpl {
+	return &ImpersonationHandlerImpl{
+		service: impersonationService,
+		logger:  logger.With().Str("handler", "ImpersonationHandler").Logger(),
+	}
+}
+
+// ExchangeToken exchanges the caller's access token for a short-lived access
+// token of another user (RFC 8693). No refresh token is issued.
+func (h *ImpersonationHandlerImpl) ExchangeToken(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req TokenExchangeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.logger.Err(err).Str("handler", "ExchangeToken").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "grant_type and requested_subject are required"})
+		return
+	}
+	if req.GrantType != grantTypeTokenExchange {
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "unsupported_grant_type"})
+		return
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != tokenTypeAccessToken {
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "only access tokens can be requested"})
+		return
+	}
+	targetUserID, parseErr := strconv.ParseUint(req.RequestedSubject, 10, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "requested_subject must be a user ID"})
+		return
+	}
+
+	accessToken, claims, err := h.service.Impersonate(ctx, c.GetUint("user_id"), uint(targetUserID))
+	if err != nil {
+		h.logger.Err(err).Uint("actor_id", c.GetUint("user_id")).Uint64("user_id", targetUserID).Msg("Token exchange failed")
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TokenExchangeResponse{
+		AccessToken:     accessToken,
+		IssuedTokenType: tokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(time.Until(claims.ExpiresAt.Time).Seconds()),
+	})
+}
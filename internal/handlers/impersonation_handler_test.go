@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// setupImpersonationRouter serves the token exchange and an endpoint echoing
+// the identities AuthMiddleware put into the context
+func setupImpersonationRouter(t *testing.T) (*gin.Engine, *services.AuthServiceImpl, repository.UserRepository) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "impersonation.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, token.NewMemoryRevocationStore(), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop())
+	impersonationHandler := handlers.NewImpersonationHandler(services.NewImpersonationService(tokenManager, authManager, userRepo, zerolog.Nop(),
+		services.WithImpersonationTTL(time.Minute)), zerolog.Nop())
+
+	router := gin.Default()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	router.POST("/auth/token-exchange", middleware.AuthMiddleware(authManager, zerolog.Nop()),
+		middleware.RequirePermission(zerolog.Nop(), database.PermissionImpersonate), impersonationHandler.ExchangeToken)
+	router.GET("/whoami", middleware.AuthMiddleware(authManager, zerolog.Nop()), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"username": c.GetString("username"), "actor_username": c.GetString("actor_username")})
+	})
+	return router, authService, userRepo
+}
+
+func exchangeToken(router *gin.Engine, accessToken string, form url.Values) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/token-exchange", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func impersonationForm(userID uint) url.Values {
+	return url.Values{
+		"grant_type":        {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"requested_subject": {strconv.FormatUint(uint64(userID), 10)},
+	}
+}
+
+func TestTokenExchangeImpersonatesUser(t *testing.T) {
+	router, authService, userRepo := setupImpersonationRouter(t)
+	adminTokens := loginOAuthTestUser(t, authService, "supportadmin")
+	userTokens := loginOAuthTestUser(t, authService, "customer")
+
+	admin, err := userRepo.FindUserByUsername("supportadmin")
+	require.NoError(t, err)
+	admin.Role = database.UserRoleAdmin
+	require.NoError(t, userRepo.UpdateUser(admin))
+	customer, err := userRepo.FindUserByUsername("customer")
+	require.NoError(t, err)
+
+	// Regular users lack the permission
+	w := exchangeToken(router, userTokens.AccessToken, impersonationForm(admin.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = exchangeToken(router, adminTokens.AccessToken, url.Values{"grant_type": {"password"}, "requested_subject": {"1"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unsupported_grant_type")
+
+	w = exchangeToken(router, adminTokens.AccessToken, impersonationForm(customer.ID))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "refresh_token")
+	var response handlers.TokenExchangeResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "urn:ietf:params:oauth:token-type:access_token", response.IssuedTokenType)
+	assert.LessOrEqual(t, response.ExpiresIn, int64(60))
+
+	// Requests made with the token act as the customer on behalf of the admin
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"username":"customer","actor_username":"supportadmin"}`, w.Body.String())
+
+	// An impersonation token cannot be exchanged again
+	w = exchangeToken(router, response.AccessToken, impersonationForm(admin.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Demoting the admin ends the impersonation
+	admin.Role = database.UserRoleUser
+	require.NoError(t, userRepo.UpdateUser(admin))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
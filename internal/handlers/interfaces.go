@@ -1,6 +1,14 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+)
 
 // Define response structs
 type GetAllUsersResponse struct {
@@ -43,11 +51,276 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// MFACodeRequest carries a TOTP code, or a recovery code where accepted
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type PasskeyLoginBeginRequest struct {
+	Username string `json:"username"`
+}
+
+type PasskeyMFABeginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+type PasskeyMFAVerifyRequest struct {
+	ChallengeToken string                       `json:"challenge_token" binding:"required"`
+	Credential     webauthn.AssertionCredential `json:"credential" binding:"required"`
+}
+
+type PasskeyRegistrationRequest struct {
+	Name       string                          `json:"name" binding:"max=100"`
+	Credential webauthn.RegistrationCredential `json:"credential" binding:"required"`
+}
+
+type ListPasskeysResponse struct {
+	Passkeys []database.WebAuthnCredential `json:"passkeys"`
+}
+
+type RotateKeysRequest struct {
+	TokenType token.TokenType `json:"token_type" binding:"omitempty,oneof=access refresh"`
+}
+
+type RotateKeysResponse struct {
+	Rotated map[token.TokenType]string `json:"rotated"`
+}
+
+type ListKeysResponse struct {
+	AccessKeys  []token.KeyInfo `json:"access_keys"`
+	RefreshKeys []token.KeyInfo `json:"refresh_keys"`
+}
+
+// SessionResponse describes a session without its token links
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	DeviceID   string    `json:"device_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+type ListSessionsResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// TokenRequest is the form body of the introspection and revocation endpoints
+type TokenRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+// IntrospectionResponse follows RFC 7662 section 2.2. Inactive tokens only
+// report active=false.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+}
+
+// OAuthErrorResponse follows RFC 6749 section 5.2
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// TokenExchangeRequest is the form body of the RFC 8693 token exchange. The
+// actor authenticates with its own access token and names the user to act as
+// in requested_subject, as the subject's token is not available to it.
+type TokenExchangeRequest struct {
+	GrantType          string `form:"grant_type" binding:"required"`
+	RequestedSubject   string `form:"requested_subject" binding:"required"`
+	RequestedTokenType string `form:"requested_token_type"`
+}
+
+// AuthorizeRequest completes an authorization request on behalf of the
+// signed-in user. Decision is empty until the user answered the consent screen.
+type AuthorizeRequest struct {
+	services.AuthorizationRequest
+	Decision string `json:"decision" binding:"omitempty,oneof=approve deny"`
+}
+
+// OAuthTokenRequest is the form body of the token endpoint
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+// OAuthTokenResponse follows RFC 6749 section 5.1 and OIDC Core 3.1.3.3
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token,omitempty"`
+	Scope       string `json:"scope"`
+}
+
+// DiscoveryDocument is the OpenID Provider metadata of OIDC Discovery 1.0
+type DiscoveryDocument struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	UserInfoEndpoint                           string   `json:"userinfo_endpoint"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+type RegisterOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1"`
+	Scopes       []string `json:"scopes" binding:"required,min=1"`
+	Public       bool     `json:"public"`
+}
+
+// OAuthClientResponse describes a client. ClientSecret is only set right
+// after registering a confidential client.
+type OAuthClientResponse struct {
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ListOAuthClientsResponse struct {
+	Clients []OAuthClientResponse `json:"clients"`
+}
+
+type ListConsentsResponse struct {
+	Consents []database.OAuthConsent `json:"consents"`
+}
+
+type ListFederatedProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// FederatedCallbackRequest is the query of a provider's redirect back. Error
+// is set instead of Code when the login did not succeed at the provider.
+type FederatedCallbackRequest struct {
+	State            string `form:"state" binding:"required"`
+	Code             string `form:"code"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+type ListIdentitiesResponse struct {
+	Identities []database.UserIdentity `json:"identities"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse describes an API key. Key is only set right after the key
+// was created.
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ListAPIKeysResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+}
+
+// ListSecurityEventsRequest filters security events; Since is RFC 3339
+type ListSecurityEventsRequest struct {
+	Type      string    `form:"type"`
+	IPAddress string    `form:"ip"`
+	Username  string    `form:"username"`
+	Since     time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit     int       `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// ListSecurityEventsResponse lists security events, newest first
+type ListSecurityEventsResponse struct {
+	Events []database.SecurityEvent `json:"events"`
+}
+
+// TokenExchangeResponse follows RFC 8693 section 2.2.1
+type TokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+}
+
 type UserHandler interface {
 	GetAllUsers(c *gin.Context)
 	GetUserByID(c *gin.Context)
 	UpdateUser(c *gin.Context)
 	DeleteUser(c *gin.Context)
+	UnlockUser(c *gin.Context)
 }
 
 type AuthHandler interface {
@@ -55,7 +328,110 @@ type AuthHandler interface {
 	LoginUser(c *gin.Context)
 	RefreshTokens(c *gin.Context)
 	LogoutUser(c *gin.Context)
+	VerifyMFA(c *gin.Context)
+	BeginPasskeyMFA(c *gin.Context)
+	VerifyMFAWithPasskey(c *gin.Context)
+	BeginPasskeyLogin(c *gin.Context)
+	LoginWithPasskey(c *gin.Context)
+	RequestMagicLink(c *gin.Context)
+	LoginWithMagicLink(c *gin.Context)
+}
+
+type PasskeyHandler interface {
+	ListPasskeys(c *gin.Context)
+	BeginRegistration(c *gin.Context)
+	FinishRegistration(c *gin.Context)
+	DeletePasskey(c *gin.Context)
+}
+
+type MFAHandler interface {
+	GetStatus(c *gin.Context)
+	EnrollTOTP(c *gin.Context)
+	ConfirmTOTP(c *gin.Context)
+	DisableTOTP(c *gin.Context)
+}
+
+type EmailVerificationHandler interface {
+	VerifyEmail(c *gin.Context)
+	ResendVerification(c *gin.Context)
+}
+
+type PasswordResetHandler interface {
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
+}
+
+type JWKSHandler interface {
+	GetJWKS(c *gin.Context)
+}
+
+type KeyHandler interface {
+	ListKeys(c *gin.Context)
+	RotateKeys(c *gin.Context)
+}
+
+type SessionHandler interface {
+	ListSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	RevokeOtherSessions(c *gin.Context)
+	ListUserSessions(c *gin.Context)
+}
+
+type OAuthHandler interface {
+	IntrospectToken(c *gin.Context)
+	RevokeToken(c *gin.Context)
+}
+
+type OIDCHandler interface {
+	Discovery(c *gin.Context)
+	StartAuthorization(c *gin.Context)
+	Authorize(c *gin.Context)
+	Token(c *gin.Context)
+	UserInfo(c *gin.Context)
+	ListConsents(c *gin.Context)
+	RevokeConsent(c *gin.Context)
+	ListClients(c *gin.Context)
+	RegisterClient(c *gin.Context)
+	DeleteClient(c *gin.Context)
+}
+
+type FederationHandler interface {
+	ListProviders(c *gin.Context)
+	BeginLogin(c *gin.Context)
+	Callback(c *gin.Context)
+	ListIdentities(c *gin.Context)
+	LinkIdentity(c *gin.Context)
+	UnlinkIdentity(c *gin.Context)
+}
+
+type APIKeyHandler interface {
+	ListKeys(c *gin.Context)
+	CreateKey(c *gin.Context)
+	RevokeKey(c *gin.Context)
+	ListUserKeys(c *gin.Context)
+	RevokeUserKey(c *gin.Context)
+}
+
+type SecurityEventHandler interface {
+	ListEvents(c *gin.Context)
+}
+
+type ImpersonationHandler interface {
+	ExchangeToken(c *gin.Context)
 }
 
 var _ UserHandler = (*UserHandlerImpl)(nil)
 var _ AuthHandler = (*AuthHandlerImpl)(nil)
+var _ EmailVerificationHandler = (*EmailVerificationHandlerImpl)(nil)
+var _ PasswordResetHandler = (*PasswordResetHandlerImpl)(nil)
+var _ MFAHandler = (*MFAHandlerImpl)(nil)
+var _ PasskeyHandler = (*PasskeyHandlerImpl)(nil)
+var _ JWKSHandler = (*JWKSHandlerImpl)(nil)
+var _ KeyHandler = (*KeyHandlerImpl)(nil)
+var _ OAuthHandler = (*OAuthHandlerImpl)(nil)
+var _ SessionHandler = (*SessionHandlerImpl)(nil)
+var _ ImpersonationHandler = (*ImpersonationHandlerImpl)(nil)
+var _ OIDCHandler = (*OIDCHandlerImpl)(nil)
+var _ FederationHandler = (*FederationHandlerImpl)(nil)
+var _ APIKeyHandler = (*APIKeyHandlerImpl)(nil)
+var _ SecurityEventHandler = (*SecurityEventHandlerImpl)(nil)
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+type JWKSHandlerImpl struct {
+	keys   token.KeySetProvider
+	logger zerolog.Logger
+}
+
+func NewJWKSHandler(keys token.KeySetProvider, logger zerolog.Logger) *JWKSHandlerImpl {
+	return &JWKSHandlerImpl{
+		keys:   keys,
+		logger: logger.With().Str("handler", "JWKSHandler").Logger(),
+	}
+}
+
+// GetJWKS serves the public keys downstream services use to verify access tokens
+func (h *JWKSHandlerImpl) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+type KeyHandlerImpl struct {
+	keyrings map[token.TokenType]*token.Keyring
+	logger   zerolog.Logger
+}
+
+func NewKeyHandler(accessKeys, refreshKeys *token.Keyring, logger zerolog.Logger) *KeyHandlerImpl {
+	return &KeyHandlerImpl{
+		keyrings: map[token.TokenType]*token.Keyring{
+			token.AccessToken:  accessKeys,
+			token.RefreshToken: refreshKeys,
+		},
+		logger: logger.With().Str("handler", "KeyHandler").Logger(),
+	}
+}
+
+// ListKeys describes the signing and verification keys of every keyring
+func (h *KeyHandlerImpl) ListKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, ListKeysResponse{
+		AccessKeys:  h.keyrings[token.AccessToken].Keys(),
+		RefreshKeys: h.keyrings[token.RefreshToken].Keys(),
+	})
+}
+
+// RotateKeys switches to a freshly generated signing key. Without a token
+// type both keyrings are rotated.
+func (h *KeyHandlerImpl) RotateKeys(c *gin.Context) {
+	var req RotateKeysRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.logger.Err(err).Str("handler", "RotateKeys").Msg("Invalid request body")
+			c.Error(err)
+			return
+		}
+	}
+
+	tokenTypes := []token.TokenType{token.AccessToken, token.RefreshToken}
+	if req.TokenType != "" {
+		if _, ok := h.keyrings[req.TokenType]; !ok {
+			c.Error(apperrors.NewValidationErrors("Unknown token type", nil))
+			return
+		}
+		tokenTypes = []token.TokenType{req.TokenType}
+	}
+
+	rotated := map[token.TokenType]string{}
+	for _, tokenType := range tokenTypes {
+		key, err := h.keyrings[tokenType].RotateGenerated()
+		if err != nil {
+			h.logger.Err(err).Str("token_type", string(tokenType)).Msg("Failed to rotate signing key")
+			c.Error(apperrors.NewInternalError("Failed to rotate signing key", err))
+			return
+		}
+		rotated[tokenType] = key.ID
+	}
+
+	h.logger.Info().
+		Interface("rotated", rotated).
+		Interface("admin_id", c.Value("user_id")).
+		Msg("Signing keys rotated by admin")
+
+	c.JSON(http.StatusOK, RotateKeysResponse{Rotated: rotated})
+}
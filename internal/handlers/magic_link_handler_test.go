@@ -0,0 +1,108 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/mailer"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+var magicLink = regexp.MustCompile(`https://app\.example\.com/magic\S+`)
+
+// setupMagicLinkRouter serves the magic link routes, switched on or off like
+// the MAGIC_LINK_ENABLED setting does
+func setupMagicLinkRouter(t *testing.T, enabled bool) (*gin.Engine, *services.AuthServiceImpl, *mailer.MemoryMailer) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "magic_link.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokens := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessions := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokens, token.NewMemoryRevocationStore(), zerolog.Nop())
+	mail := mailer.NewMemoryMailer()
+
+	var opts []services.AuthServiceOption
+	if enabled {
+		oneTimeTokens := services.NewOneTimeTokenService(userRepo, repository.NewOneTimeTokenRepository(db, zerolog.Nop()), zerolog.Nop(),
+			services.WithOneTimeTokenTTL(services.PurposeMagicLink, 5*time.Minute))
+		opts = append(opts, services.WithMagicLinks(services.NewMagicLinkService(oneTimeTokens, mail, userRepo, authManager, zerolog.Nop(),
+			services.WithMagicLinkURL("https://app.example.com/magic"))))
+	}
+	auth := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokens, sessions, zerolog.Nop(), opts...)
+	authHandler := handlers.NewAuthHandler(auth, zerolog.Nop())
+
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	router.POST("/auth/magic-link", authHandler.RequestMagicLink)
+	router.POST("/auth/magic-link/login", authHandler.LoginWithMagicLink)
+	return router, auth, mail
+}
+
+func TestMagicLinkFlow(t *testing.T) {
+	router, auth, mail := setupMagicLinkRouter(t, true)
+	_, err := auth.RegisterUser(context.Background(), "linkclicker", "StrongP@ssw0rd2024!", "clicker@example.com")
+	require.NoError(t, err)
+
+	// Known and unknown addresses get the same answer
+	unknown := postJSON(router, "/auth/magic-link", gin.H{"email": "nobody@example.com"})
+	known := postJSON(router, "/auth/magic-link", gin.H{"email": "clicker@example.com"})
+	assert.Equal(t, http.StatusAccepted, unknown.Code)
+	assert.Equal(t, unknown.Code, known.Code)
+	assert.Equal(t, unknown.Body.String(), known.Body.String())
+	require.Len(t, mail.Messages(), 1)
+
+	message, ok := mail.LastMessageTo("clicker@example.com")
+	require.True(t, ok)
+	assert.Contains(t, message.Body, "expires in 5m0s")
+	link, err := url.Parse(magicLink.FindString(message.Body))
+	require.NoError(t, err)
+	loginToken := link.Query().Get("token")
+	require.NotEmpty(t, loginToken)
+
+	w := postJSON(router, "/auth/magic-link/login", gin.H{"token": loginToken})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var pair database.TokenPair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+	claims, appErr := tokenManager.ValidateToken(pair.AccessToken, token.AccessToken)
+	require.NoError(t, appErr)
+	assert.Equal(t, "linkclicker", claims.Username)
+
+	// The link is single-use
+	w = postJSON(router, "/auth/magic-link/login", gin.H{"token": loginToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMagicLinkDisabled(t *testing.T) {
+	router, _, mail := setupMagicLinkRouter(t, false)
+
+	w := postJSON(router, "/auth/magic-link", gin.H{"email": "nobody@example.com"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = postJSON(router, "/auth/magic-link/login", gin.H{"token": "token"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, mail.Messages())
+}
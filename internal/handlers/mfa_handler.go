@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+type MFAHandlerImpl struct {
+	service *services.MFAServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewMFAHandler(mfaService *services.MFAServiceImpl, logger zerolog.Logger) *MFAHandlerImpl {
+	return &MFAHandlerImpl{
+		service: mfaService,
+		logger:  logger.With().Str("handler", "MFAHandler").Logger(),
+	}
+}
+
+// GetStatus reports the second factors of the authenticated user
+func (h *MFAHandlerImpl) GetStatus(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	status, err := h.service.Status(ctx, c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to get MFA status")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollTOTP returns a new TOTP secret and the provisioning URI to render as
+// a QR code
+func (h *MFAHandlerImpl) EnrollTOTP(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	enrollment, err := h.service.EnrollTOTP(ctx, c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to enroll TOTP")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP enables TOTP with a first code and returns the recovery codes
+func (h *MFAHandlerImpl) ConfirmTOTP(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "ConfirmTOTP").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	recoveryCodes, err := h.service.ConfirmTOTP(ctx, c.GetUint("user_id"), req.Code)
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to confirm TOTP")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// DisableTOTP turns TOTP off after checking a current or recovery code
+func (h *MFAHandlerImpl) DisableTOTP(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "DisableTOTP").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	if err := h.service.DisableTOTP(ctx, c.GetUint("user_id"), req.Code); err != nil {
+		h.logger.Err(err).Msg("Failed to disable TOTP")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
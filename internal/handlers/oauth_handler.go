@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+// Token type hints defined by RFC 7009 and used by RFC 7662
+const (
+	tokenTypeHintAccessToken  = "access_token"
+	tokenTypeHintRefreshToken = "refresh_token"
+)
+
+type OAuthHandlerImpl struct {
+	service *services.OAuthServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewOAuthHandler(oauthService *services.OAuthServiceImpl, logger zerolog.Logger) *OAuthHandlerImpl {
+	return &OAuthHandlerImpl{
+		service: oauthService,
+		logger:  logger.With().Str("handler", "OAuthHandler").Logger(),
+	}
+}
+
+// IntrospectToken reports whether a token is active and describes it (RFC 7662)
+func (h *OAuthHandlerImpl) IntrospectToken(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.logger.Err(err).Str("handler", "IntrospectToken").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "token is required"})
+		return
+	}
+
+	claims, err := h.service.IntrospectToken(ctx, req.Token, tokenTypeFromHint(req.TokenTypeHint))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to introspect token")
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if claims == nil {
+		c.JSON(http.StatusOK, IntrospectionResponse{Active: false})
+		return
+	}
+
+	response := IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(claims.Permissions, " "),
+		ClientID:  claims.ClientID,
+		Username:  claims.Username,
+		TokenType: hintFromTokenType(claims.TokenType),
+		Sub:       strconv.FormatUint(uint64(claims.UserID), 10),
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		response.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		response.Nbf = claims.NotBefore.Unix()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeToken revokes an access or refresh token (RFC 7009). Invalid tokens
+// are answered with 200 as well, so the response does not reveal validity.
+func (h *OAuthHandlerImpl) RevokeToken(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.logger.Err(err).Str("handler", "RevokeToken").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "token is required"})
+		return
+	}
+
+	if err := h.service.RevokeToken(ctx, req.Token, tokenTypeFromHint(req.TokenTypeHint)); err != nil {
+		h.logger.Err(err).Msg("Failed to revoke token")
+		c.Error(err)
+		return
+	}
+
+	h.logger.Info().Interface("client_id", c.Value("client_id")).Msg("Token revocation requested")
+	c.Status(http.StatusOK)
+}
+
+// tokenTypeFromHint maps a token_type_hint to a token type. Unknown hints are
+// ignored, as the server must search all token types anyway.
+func tokenTypeFromHint(hint string) token.TokenType {
+	switch hint {
+	case tokenTypeHintAccessToken:
+		return token.AccessToken
+	case tokenTypeHintRefreshToken:
+		return token.RefreshToken
+	default:
+		return ""
+	}
+}
+
+func hintFromTokenType(tokenType token.TokenType) string {
+	if tokenType == token.RefreshToken {
+		return tokenTypeHintRefreshToken
+	}
+	return tokenTypeHintAccessToken
+}
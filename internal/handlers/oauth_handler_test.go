@@ -0,0 +1,176 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// setupOAuthRouter builds the OAuth routes over a file-backed database, as
+// the shared in-memory one disappears once its connections expire
+func setupOAuthRouter(t *testing.T) (*gin.Engine, *services.AuthServiceImpl, repository.UserRepository) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "oauth.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, token.NewMemoryRevocationStore(), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop())
+	oauthHandler := handlers.NewOAuthHandler(services.NewOAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, zerolog.Nop()), zerolog.Nop())
+
+	router := gin.Default()
+	oauthGroup := router.Group("/oauth")
+	oauthGroup.Use(middleware.ClientAuthMiddleware(middleware.StaticClients{"gateway": "gateway_secret"}, zerolog.Logger{}))
+	oauthGroup.POST("/introspect", oauthHandler.IntrospectToken)
+	oauthGroup.POST("/revoke", oauthHandler.RevokeToken)
+	return router, authService, userRepo
+}
+
+func postTokenForm(router *gin.Engine, path string, form url.Values, clientSecret string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("gateway", clientSecret)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func introspect(t *testing.T, router *gin.Engine, tokenString, hint string) handlers.IntrospectionResponse {
+	w := postTokenForm(router, "/oauth/introspect", url.Values{"token": {tokenString}, "token_type_hint": {hint}}, "gateway_secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var response handlers.IntrospectionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func loginOAuthTestUser(t *testing.T, authService *services.AuthServiceImpl, username string) *database.TokenPair {
+	ctx := context.Background()
+	_, err := authService.RegisterUser(ctx, username, "StrongP@ssw0rd2024!", username+"@example.com")
+	require.NoError(t, err)
+	tokens, _, appErr := authService.LoginUser(ctx, username, "StrongP@ssw0rd2024!", token.Device{IP: "127.0.0.1"})
+	require.NoError(t, appErr)
+	return tokens
+}
+
+func TestOAuthEndpointsRequireClientAuthentication(t *testing.T) {
+	router, _, _ := setupOAuthRouter(t)
+
+	w := postTokenForm(router, "/oauth/introspect", url.Values{"token": {"anything"}}, "wrong_secret")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_client")
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	// Credentials in the form body are accepted too
+	form := url.Values{"token": {"anything"}, "client_id": {"gateway"}, "client_secret": {"gateway_secret"}}
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/oauth/revoke", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestIntrospectAndRevokeAccessToken(t *testing.T) {
+	router, authService, _ := setupOAuthRouter(t)
+	tokens := loginOAuthTestUser(t, authService, "introspectuser")
+
+	response := introspect(t, router, tokens.AccessToken, "access_token")
+	assert.True(t, response.Active)
+	assert.Equal(t, "introspectuser", response.Username)
+	assert.Equal(t, "access_token", response.TokenType)
+	assert.NotZero(t, response.Exp)
+
+	w := postTokenForm(router, "/oauth/revoke", url.Values{"token": {tokens.AccessToken}}, "gateway_secret")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Revocation is visible to introspection
+	response = introspect(t, router, tokens.AccessToken, "")
+	assert.Equal(t, handlers.IntrospectionResponse{Active: false}, response)
+}
+
+func TestIntrospectAndRevokeRefreshToken(t *testing.T) {
+	router, authService, _ := setupOAuthRouter(t)
+	tokens := loginOAuthTestUser(t, authService, "introspectrefreshuser")
+
+	// A wrong hint does not prevent the token from being found
+	response := introspect(t, router, tokens.RefreshToken, "access_token")
+	assert.True(t, response.Active)
+	assert.Equal(t, "refresh_token", response.TokenType)
+
+	w := postTokenForm(router, "/oauth/revoke", url.Values{"token": {tokens.RefreshToken}, "token_type_hint": {"refresh_token"}}, "gateway_secret")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	response = introspect(t, router, tokens.RefreshToken, "refresh_token")
+	assert.False(t, response.Active)
+	_, err := authService.RefreshTokens(context.Background(), tokens.RefreshToken, token.Device{IP: "127.0.0.1"})
+	assert.Error(t, err)
+}
+
+func TestIntrospectTokensOfInvalidatedUsers(t *testing.T) {
+	router, authService, userRepo := setupOAuthRouter(t)
+	locked := loginOAuthTestUser(t, authService, "introspectlocked")
+	deleted := loginOAuthTestUser(t, authService, "introspectdeleted")
+	bystander := loginOAuthTestUser(t, authService, "introspectbystander")
+
+	user, err := userRepo.FindUserByUsername("introspectlocked")
+	require.NoError(t, err)
+	require.NoError(t, userRepo.LockUser(user.ID, "test", time.Hour))
+	user, err = userRepo.FindUserByUsername("introspectdeleted")
+	require.NoError(t, err)
+	require.NoError(t, userRepo.DeleteUser(user.ID))
+
+	// Resource servers learn that tokens of locked and deleted users are dead
+	for _, tokens := range []*database.TokenPair{locked, deleted} {
+		assert.False(t, introspect(t, router, tokens.AccessToken, "access_token").Active)
+		assert.False(t, introspect(t, router, tokens.RefreshToken, "refresh_token").Active)
+	}
+	assert.True(t, introspect(t, router, bystander.AccessToken, "access_token").Active)
+
+	// Unlocking does not revive tokens invalidated by the lock
+	user, err = userRepo.FindUserByUsername("introspectlocked")
+	require.NoError(t, err)
+	require.NoError(t, userRepo.UnlockUser(user.ID))
+	assert.False(t, introspect(t, router, locked.AccessToken, "access_token").Active)
+}
+
+func TestIntrospectInvalidToken(t *testing.T) {
+	router, _, _ := setupOAuthRouter(t)
+
+	response := introspect(t, router, "not-a-token", "")
+	assert.False(t, response.Active)
+
+	w := postTokenForm(router, "/oauth/revoke", url.Values{"token": {"not-a-token"}}, "gateway_secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postTokenForm(router, "/oauth/introspect", url.Values{}, "gateway_secret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_request")
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+// Paths of the endpoints advertised by the discovery document, as routed in cmd/server
+const (
+	oidcAuthorizationPath = "/api/v1/oauth/authorize"
+	oidcTokenPath         = "/api/v1/oauth/token"
+	oidcUserInfoPath      = "/api/v1/oauth/userinfo"
+	oidcJWKSPath          = "/.well-known/jwks.json"
+)
+
+const grantTypeAuthorizationCode = "authorization_code"
+
+type OIDCHandlerImpl struct {
+	service    *services.OIDCServiceImpl
+	consentURL string
+	logger     zerolog.Logger
+}
+
+// NewOIDCHandler creates the handler of the authorization server endpoints.
+// Browsers starting an authorization are sent on to the page at consentURL,
+// which signs the user in and completes the request through Authorize.
+func NewOIDCHandler(oidcService *services.OIDCServiceImpl, consentURL string, logger zerolog.Logger) *OIDCHandlerImpl {
+	return &OIDCHandlerImpl{
+		service:    oidcService,
+		consentURL: consentURL,
+		logger:     logger.With().Str("handler", "OIDCHandler").Logger(),
+	}
+}
+
+// Discovery serves the OpenID Provider metadata (OIDC Discovery 1.0 section 3)
+func (h *OIDCHandlerImpl) Discovery(c *gin.Context) {
+	metadata := h.service.Metadata()
+	issuer := strings.TrimSuffix(metadata.Issuer, "/")
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, DiscoveryDocument{
+		Issuer:                                     metadata.Issuer,
+		AuthorizationEndpoint:                      issuer + oidcAuthorizationPath,
+		TokenEndpoint:                              issuer + oidcTokenPath,
+		UserInfoEndpoint:                           issuer + oidcUserInfoPath,
+		JWKSURI:                                    issuer + oidcJWKSPath,
+		ScopesSupported:                            metadata.ScopesSupported,
+		ResponseTypesSupported:                     []string{"code"},
+		GrantTypesSupported:                        []string{grantTypeAuthorizationCode},
+		SubjectTypesSupported:                      []string{"public"},
+		IDTokenSigningAlgValuesSupported:           []string{metadata.IDTokenSigningAlgValue},
+		TokenEndpointAuthMethodsSupported:          []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:              []string{"S256"},
+		ClaimsSupported:                            []string{"sub", "iss", "aud", "exp", "iat", "nonce", "at_hash", "azp", "preferred_username", "email", "email_verified"},
+		AuthorizationResponseIssParameterSupported: true,
+	})
+}
+
+// StartAuthorization is the authorization endpoint browsers are sent to by
+// clients. Valid requests continue at the consent page with the same query.
+func (h *OIDCHandlerImpl) StartAuthorization(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req services.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		h.logger.Err(err).Str("handler", "StartAuthorization").Msg("Invalid query")
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "invalid_request", ErrorDescription: err.Error()})
+		return
+	}
+
+	result, err := h.service.CheckAuthorizationRequest(ctx, &req)
+	if err != nil {
+		h.logger.Err(err).Str("client_id", req.ClientID).Msg("Invalid authorization request")
+		h.writeOAuthError(c, err)
+		return
+	}
+	if result.RedirectTo != "" {
+		c.Redirect(http.StatusFound, result.RedirectTo)
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.consentURL+"?"+c.Request.URL.RawQuery)
+}
+
+// Authorize completes an authorization request for the signed-in user. It
+// answers with the redirect back to the client, or asks for consent first.
+func (h *OIDCHandlerImpl) Authorize(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req AuthorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "Authorize").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	result, err := h.service.Authorize(ctx, c.GetUint("user_id"), &req.AuthorizationRequest, services.ConsentDecision(req.Decision))
+	if err != nil {
+		h.logger.Err(err).Str("client_id", req.ClientID).Msg("Authorization failed")
+		h.writeOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Token exchanges an authorization code for tokens (RFC 6749 section 4.1.3).
+// The client has been authenticated by ClientAuthMiddleware.
+func (h *OIDCHandlerImpl) Token(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req OAuthTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.logger.Err(err).Str("handler", "Token").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "grant_type is required"})
+		return
+	}
+	if req.GrantType != grantTypeAuthorizationCode {
+		c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: "unsupported_grant_type"})
+		return
+	}
+
+	tokens, err := h.service.ExchangeCode(ctx, c.GetString("client_id"), req.Code, req.RedirectURI, req.CodeVerifier)
+	if err != nil {
+		h.logger.Err(err).Str("client_id", c.GetString("client_id")).Msg("Authorization code exchange failed")
+		h.writeOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, OAuthTokenResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   tokens.ExpiresIn,
+		IDToken:     tokens.IDToken,
+		Scope:       strings.Join(tokens.Scopes, " "),
+	})
+}
+
+// UserInfo returns the claims about the user an access token was issued for
+// (OIDC Core 5.3). Errors follow RFC 6750 section 3.
+func (h *OIDCHandlerImpl) UserInfo(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	accessToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || accessToken == "" {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	info, err := h.service.UserInfo(ctx, accessToken)
+	if err != nil {
+		h.logger.Err(err).Msg("Userinfo request rejected")
+		var oauthErr apperrors.OAuthError
+		if !errors.As(err, &oauthErr) {
+			c.Error(err)
+			return
+		}
+		status := http.StatusUnauthorized
+		if oauthErr.Code() == apperrors.ErrCodeOAuthInsufficientScope {
+			status = http.StatusForbidden
+		}
+		c.Header("WWW-Authenticate", `Bearer error="`+string(oauthErr.Code())+`"`)
+		c.JSON(status, OAuthErrorResponse{Error: string(oauthErr.Code()), ErrorDescription: oauthErr.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, info)
+}
+
+// ListConsents lists the clients the authenticated user has granted access to
+func (h *OIDCHandlerImpl) ListConsents(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	consents, err := h.service.ListConsents(ctx, c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to list consents")
+		c.Error(err)
+		return
+	}
+
+	if consents == nil {
+		consents = []database.OAuthConsent{}
+	}
+	c.JSON(http.StatusOK, ListConsentsResponse{Consents: consents})
+}
+
+// RevokeConsent withdraws the authenticated user's consent for a client
+func (h *OIDCHandlerImpl) RevokeConsent(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	if err := h.service.RevokeConsent(ctx, c.GetUint("user_id"), c.Param("client_id")); err != nil {
+		h.logger.Err(err).Str("client_id", c.Param("client_id")).Msg("Failed to revoke consent")
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListClients lists the registered client applications
+func (h *OIDCHandlerImpl) ListClients(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	clients, err := h.service.ListClients(ctx)
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to list OAuth clients")
+		c.Error(err)
+		return
+	}
+
+	response := ListOAuthClientsResponse{Clients: make([]OAuthClientResponse, 0, len(clients))}
+	for i := range clients {
+		response.Clients = append(response.Clients, newOAuthClientResponse(&clients[i], ""))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RegisterClient registers a client application. The client secret is only
+// part of this response.
+func (h *OIDCHandlerImpl) RegisterClient(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req RegisterOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "RegisterClient").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	client, secret, err := h.service.RegisterClient(ctx, services.ClientRegistration{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+	})
+	if err != nil {
+		h.logger.Err(err).Str("name", req.Name).Msg("Failed to register OAuth client")
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, newOAuthClientResponse(client, secret))
+}
+
+// DeleteClient removes a client application and the consents given to it
+func (h *OIDCHandlerImpl) DeleteClient(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	if err := h.service.DeleteClient(ctx, c.Param("client_id")); err != nil {
+		h.logger.Err(err).Str("client_id", c.Param("client_id")).Msg("Failed to delete OAuth client")
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// writeOAuthError answers protocol errors in the RFC 6749 format and leaves
+// every other error to the error middleware. Clients failing authentication
+// are already turned away by ClientAuthMiddleware.
+func (h *OIDCHandlerImpl) writeOAuthError(c *gin.Context, err apperrors.AppError) {
+	var oauthErr apperrors.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusBadRequest, OAuthErrorResponse{Error: string(oauthErr.Code()), ErrorDescription: oauthErr.Error()})
+}
+
+func newOAuthClientResponse(client *database.OAuthClient, secret string) OAuthClientResponse {
+	return OAuthClientResponse{
+		ClientID:     client.ClientID,
+		ClientSecret: secret,
+		Name:         client.Name,
+		RedirectURIs: strings.Fields(client.RedirectURIs),
+		Scopes:       strings.Fields(client.Scopes),
+		Public:       client.Public,
+		CreatedAt:    client.CreatedAt,
+	}
+}
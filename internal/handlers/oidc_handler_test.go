@@ -0,0 +1,228 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const (
+	testOIDCIssuer    = "https://id.example.com"
+	testConsentURL    = "https://id.example.com/consent"
+	testOIDCRedirect  = "https://app.example.com/callback"
+	testOIDCVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testAuthorizePath = "/oauth/authorize"
+)
+
+type oidcTestServer struct {
+	router       *gin.Engine
+	service      *services.OIDCServiceImpl
+	authService  *services.AuthServiceImpl
+	clientID     string
+	clientSecret string
+}
+
+// setupOIDCRouter builds the authorization server routes with one registered client
+func setupOIDCRouter(t *testing.T) *oidcTestServer {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "oidc.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, token.NewMemoryRevocationStore(), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop())
+	idTokens := token.NewIDTokenSigner(token.NewStaticKeyring(token.AccessToken, token.NewHMACSigningKey("secret_key")), testOIDCIssuer, 0)
+	oidcService := services.NewOIDCService(userRepo, repository.NewOAuthClientRepository(db, zerolog.Nop()), tokenManager, idTokens,
+		authManager, testOIDCIssuer, zerolog.Nop())
+	oidcHandler := handlers.NewOIDCHandler(oidcService, testConsentURL, zerolog.Nop())
+
+	client, secret, appErr := oidcService.RegisterClient(context.Background(), services.ClientRegistration{
+		Name:         "Dashboard",
+		RedirectURIs: []string{testOIDCRedirect},
+		Scopes:       []string{services.ScopeOpenID, services.ScopeProfile},
+	})
+	require.NoError(t, appErr)
+
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+	router.GET(testAuthorizePath, oidcHandler.StartAuthorization)
+	router.POST(testAuthorizePath, middleware.AuthMiddleware(authManager, zerolog.Nop()), oidcHandler.Authorize)
+	router.POST("/oauth/token", middleware.ClientAuthMiddleware(oidcService, zerolog.Nop()), oidcHandler.Token)
+	router.GET("/oauth/userinfo", oidcHandler.UserInfo)
+	router.GET("/me", middleware.AuthMiddleware(authManager, zerolog.Nop()), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	return &oidcTestServer{
+		router:       router,
+		service:      oidcService,
+		authService:  authService,
+		clientID:     client.ClientID,
+		clientSecret: secret,
+	}
+}
+
+func (s *oidcTestServer) authorizationQuery() url.Values {
+	challenge := sha256.Sum256([]byte(testOIDCVerifier))
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.clientID},
+		"redirect_uri":          {testOIDCRedirect},
+		"scope":                 {"openid profile"},
+		"state":                 {"af0ifjsldkj"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func (s *oidcTestServer) do(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *oidcTestServer) postToken(form url.Values) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+	return s.do(req)
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	server := setupOIDCRouter(t)
+
+	req, _ := http.NewRequest("GET", "/.well-known/openid-configuration", nil)
+	w := server.do(req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var document handlers.DiscoveryDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &document))
+	assert.Equal(t, testOIDCIssuer, document.Issuer)
+	assert.Equal(t, testOIDCIssuer+"/api/v1/oauth/token", document.TokenEndpoint)
+	assert.Equal(t, testOIDCIssuer+"/.well-known/jwks.json", document.JWKSURI)
+	assert.Equal(t, []string{"HS256"}, document.IDTokenSigningAlgValuesSupported)
+	assert.Equal(t, []string{"S256"}, document.CodeChallengeMethodsSupported)
+}
+
+func TestOIDCStartAuthorization(t *testing.T) {
+	server := setupOIDCRouter(t)
+
+	// Valid requests continue at the consent page
+	query := server.authorizationQuery()
+	req, _ := http.NewRequest("GET", testAuthorizePath+"?"+query.Encode(), nil)
+	w := server.do(req)
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, testConsentURL+"?"+query.Encode(), w.Header().Get("Location"))
+
+	// Unknown redirect URIs get an error page instead of a redirect
+	query.Set("redirect_uri", "https://evil.example.com/callback")
+	req, _ = http.NewRequest("GET", testAuthorizePath+"?"+query.Encode(), nil)
+	w = server.do(req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), "invalid_request")
+
+	// Other errors go back to the client
+	query = server.authorizationQuery()
+	query.Set("response_type", "token")
+	req, _ = http.NewRequest("GET", testAuthorizePath+"?"+query.Encode(), nil)
+	w = server.do(req)
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Location"), testOIDCRedirect+"?"))
+	assert.Contains(t, w.Header().Get("Location"), "error=unsupported_response_type")
+}
+
+func TestOIDCCodeExchange(t *testing.T) {
+	server := setupOIDCRouter(t)
+	tokens := loginOAuthTestUser(t, server.authService, "oidchandleruser")
+
+	// The signed-in user approves the request
+	query := server.authorizationQuery()
+	body := map[string]string{"decision": "approve"}
+	for key := range query {
+		body[key] = query.Get(key)
+	}
+	encoded, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", testAuthorizePath, strings.NewReader(string(encoded)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	w := server.do(req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result services.AuthorizationResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	redirect, err := url.Parse(result.RedirectTo)
+	require.NoError(t, err)
+	code := redirect.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	w = server.postToken(url.Values{"grant_type": {"password"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unsupported_grant_type")
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testOIDCRedirect},
+		"code_verifier": {testOIDCVerifier},
+	}
+	w = server.postToken(form)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var response handlers.OAuthTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Bearer", response.TokenType)
+	assert.NotEmpty(t, response.IDToken)
+	assert.Equal(t, "openid profile", response.Scope)
+
+	// Codes are single use
+	w = server.postToken(form)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_grant")
+
+	req, _ = http.NewRequest("GET", "/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+	w = server.do(req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"preferred_username":"oidchandleruser"`)
+
+	// The client's access token does not open the API itself
+	req, _ = http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+	w = server.do(req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("GET", "/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	w = server.do(req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+type PasskeyHandlerImpl struct {
+	service *services.WebAuthnServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewPasskeyHandler(webAuthnService *services.WebAuthnServiceImpl, logger zerolog.Logger) *PasskeyHandlerImpl {
+	return &PasskeyHandlerImpl{
+		service: webAuthnService,
+		logger:  logger.With().Str("handler", "PasskeyHandler").Logger(),
+	}
+}
+
+// ListPasskeys lists the authenticated user's passkeys
+func (h *PasskeyHandlerImpl) ListPasskeys(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	passkeys, err := h.service.ListCredentials(ctx, c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to list passkeys")
+		c.Error(err)
+		return
+	}
+
+	if passkeys == nil {
+		passkeys = []database.WebAuthnCredential{}
+	}
+	c.JSON(http.StatusOK, ListPasskeysResponse{Passkeys: passkeys})
+}
+
+// BeginRegistration returns the options for navigator.credentials.create()
+func (h *PasskeyHandlerImpl) BeginRegistration(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	options, err := h.service.BeginRegistration(ctx, c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to start passkey registration")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishRegistration stores the passkey created by the authenticator
+func (h *PasskeyHandlerImpl) FinishRegistration(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	var req PasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "FinishRegistration").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	passkey, err := h.service.FinishRegistration(ctx, c.GetUint("user_id"), req.Name, &req.Credential)
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to register passkey")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, passkey)
+}
+
+// DeletePasskey removes one of the authenticated user's passkeys
+func (h *PasskeyHandlerImpl) DeletePasskey(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	idStr := c.Param("id")
+	id, parseErr := strconv.ParseUint(idStr, 10, 64)
+	if parseErr != nil {
+		h.logger.Error().Err(parseErr).Str("handler", "DeletePasskey").Str("id", idStr).Msg("Invalid passkey ID")
+		c.Error(apperrors.NewValidationErrors("Invalid passkey ID", parseErr))
+		return
+	}
+
+	if err := h.service.DeleteCredential(ctx, c.GetUint("user_id"), uint(id)); err != nil {
+		h.logger.Err(err).Uint64("id", id).Msg("Failed to delete passkey")
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+)
+
+// setupPasskeyRouter serves the passkey routes the way the server guards them
+func setupPasskeyRouter(t *testing.T) (*gin.Engine, *services.AuthServiceImpl, *services.ImpersonationServiceImpl, repository.UserRepository) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "passkeys.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, token.NewMemoryRevocationStore(), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop())
+	impersonationService := services.NewImpersonationService(tokenManager, authManager, userRepo, zerolog.Nop(),
+		services.WithImpersonationTTL(time.Minute))
+
+	relyingParty, err := webauthn.NewRelyingParty(webauthn.Config{
+		RPID:    "example.com",
+		RPName:  "Example",
+		Origins: []string{"https://login.example.com"},
+	})
+	require.NoError(t, err)
+	passkeyHandler := handlers.NewPasskeyHandler(services.NewWebAuthnService(relyingParty, userRepo,
+		repository.NewWebAuthnRepository(db, zerolog.Nop()), zerolog.Nop()), zerolog.Nop())
+
+	router := gin.Default()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	meGroup := router.Group("/me", middleware.AuthMiddleware(authManager, zerolog.Nop()))
+	meGroup.GET("/passkeys", passkeyHandler.ListPasskeys)
+	credentialGroup := meGroup.Group("", middleware.RejectImpersonation(zerolog.Nop()))
+	credentialGroup.POST("/passkeys/register/begin", passkeyHandler.BeginRegistration)
+	credentialGroup.POST("/passkeys/register/finish", passkeyHandler.FinishRegistration)
+	return router, authService, impersonationService, userRepo
+}
+
+// impersonate signs in an admin and a customer and returns the customer's own
+// access token together with one the admin obtained by impersonating them
+func impersonate(t *testing.T, authService *services.AuthServiceImpl, impersonationService *services.ImpersonationServiceImpl,
+	userRepo repository.UserRepository) (own, impersonated string) {
+	loginOAuthTestUser(t, authService, "supportadmin")
+	customerTokens := loginOAuthTestUser(t, authService, "customer")
+
+	admin, err := userRepo.FindUserByUsername("supportadmin")
+	require.NoError(t, err)
+	admin.Role = database.UserRoleAdmin
+	require.NoError(t, userRepo.UpdateUser(admin))
+	customer, err := userRepo.FindUserByUsername("customer")
+	require.NoError(t, err)
+
+	impersonationToken, _, appErr := impersonationService.Impersonate(context.Background(), admin.ID, customer.ID)
+	require.NoError(t, appErr)
+	return customerTokens.AccessToken, impersonationToken
+}
+
+func TestPasskeyRegistrationRejectsImpersonation(t *testing.T) {
+	router, authService, impersonationService, userRepo := setupPasskeyRouter(t)
+	own, impersonated := impersonate(t, authService, impersonationService, userRepo)
+
+	// Support staff cannot add a passkey they could later sign in with
+	w := serveWithHeader(router, http.MethodPost, "/me/passkeys/register/begin", "Authorization", "Bearer "+impersonated, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	w = serveWithHeader(router, http.MethodPost, "/me/passkeys/register/finish", "Authorization", "Bearer "+impersonated,
+		[]byte(`{"name":"support","credential":{}}`))
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	// Reading is still allowed, and no passkey was added
+	w = serveWithHeader(router, http.MethodGet, "/me/passkeys", "Authorization", "Bearer "+impersonated, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"passkeys":[]}`, w.Body.String())
+
+	// The user can register their own passkeys
+	w = serveWithHeader(router, http.MethodPost, "/me/passkeys/register/begin", "Authorization", "Bearer "+own, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+type PasswordResetHandlerImpl struct {
+	service *services.PasswordResetServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewPasswordResetHandler(passwordResetService *services.PasswordResetServiceImpl, logger zerolog.Logger) *PasswordResetHandlerImpl {
+	return &PasswordResetHandlerImpl{
+		service: passwordResetService,
+		logger:  logger.With().Str("handler", "PasswordResetHandler").Logger(),
+	}
+}
+
+// ForgotPassword mails a password reset link. It answers the same way whether
+// or not the address is registered, and even when sending fails.
+func (h *PasswordResetHandlerImpl) ForgotPassword(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "ForgotPassword").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	if err := h.service.RequestPasswordReset(ctx, req.Email); err != nil {
+		h.logger.Err(err).Msg("Failed to request password reset")
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{})
+}
+
+// ResetPassword sets a new password with a token from a reset email
+func (h *PasswordResetHandlerImpl) ResetPassword(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Err(err).Str("handler", "ResetPassword").Msg("Invalid request body")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	if err := h.service.ResetPassword(ctx, req.Token, req.Password); err != nil {
+		h.logger.Err(err).Msg("Failed to reset password")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
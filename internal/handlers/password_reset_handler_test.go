@@ -0,0 +1,121 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/mailer"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+var resetLink = regexp.MustCompile(`https://app\.example\.com/reset\S+`)
+
+func postJSON(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "password_reset.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	attemptRepo := repository.NewLoginAttemptRepository(db, zerolog.Nop())
+	refreshTokens := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	tokens := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations)
+	sessions := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokens, revocations, zerolog.Nop())
+	auth := services.NewAuthService(tokens, authentication.NewAuthenticationManager(userRepo, tokens, attemptRepo, zerolog.Nop()),
+		userRepo, refreshTokens, sessions, zerolog.Nop())
+	mail := mailer.NewMemoryMailer()
+	oneTimeTokens := services.NewOneTimeTokenService(userRepo, repository.NewOneTimeTokenRepository(db, zerolog.Nop()), zerolog.Nop())
+	passwordResetHandler := handlers.NewPasswordResetHandler(services.NewPasswordResetService(oneTimeTokens, mail, userRepo, attemptRepo, sessions, zerolog.Nop(),
+		services.WithPasswordResetURL("https://app.example.com/reset")), zerolog.Nop())
+
+	router := gin.Default()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	router.POST("/auth/password/forgot", passwordResetHandler.ForgotPassword)
+	router.POST("/auth/password/reset", passwordResetHandler.ResetPassword)
+
+	ctx := context.Background()
+	user, err := auth.RegisterUser(ctx, "forgetful", "StrongP@ssw0rd2024!", "forgetful@example.com")
+	require.NoError(t, err)
+	pair, _, appErr := auth.LoginUser(ctx, "forgetful", "StrongP@ssw0rd2024!", token.Device{IP: "10.0.0.1"})
+	require.NoError(t, appErr)
+	require.NoError(t, attemptRepo.IncrementLoginAttempts("forgetful", "10.0.0.2", false))
+
+	// Known and unknown addresses get the same answer
+	unknown := postJSON(router, "/auth/password/forgot", gin.H{"email": "nobody@example.com"})
+	known := postJSON(router, "/auth/password/forgot", gin.H{"email": "forgetful@example.com"})
+	assert.Equal(t, http.StatusAccepted, unknown.Code)
+	assert.Equal(t, unknown.Code, known.Code)
+	assert.Equal(t, unknown.Body.String(), known.Body.String())
+	require.Len(t, mail.Messages(), 1)
+
+	message, ok := mail.LastMessageTo("forgetful@example.com")
+	require.True(t, ok)
+	link, err := url.Parse(resetLink.FindString(message.Body))
+	require.NoError(t, err)
+	resetToken := link.Query().Get("token")
+	require.NotEmpty(t, resetToken)
+
+	// A weak password is refused without using up the token
+	w := postJSON(router, "/auth/password/reset", gin.H{"token": resetToken, "password": "weak"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(router, "/auth/password/reset", gin.H{"token": resetToken, "password": "N3w-Str0ng-P@ssword!"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = postJSON(router, "/auth/password/reset", gin.H{"token": resetToken, "password": "An0ther-Str0ng-P@ss!"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Existing tokens and sessions are gone
+	_, appErr = auth.RefreshTokens(ctx, pair.RefreshToken, token.Device{IP: "10.0.0.1"})
+	assert.Error(t, appErr)
+	_, appErr = tokens.ValidateToken(pair.AccessToken, token.AccessToken)
+	assert.Error(t, appErr)
+	active, appErr := sessions.ListSessions(ctx, user.ID)
+	require.NoError(t, appErr)
+	assert.Empty(t, active)
+
+	// Failed logins from every address are forgotten
+	var attempts int64
+	require.NoError(t, db.Model(&database.LoginAttempt{}).Where("username = ?", "forgetful").Count(&attempts).Error)
+	assert.Zero(t, attempts)
+
+	// Tokens issued right after the reset, even within the same second, are valid
+	pair, _, appErr = auth.LoginUser(ctx, "forgetful", "N3w-Str0ng-P@ssword!", token.Device{IP: "10.0.0.1"})
+	require.NoError(t, appErr)
+	_, appErr = auth.RefreshTokens(ctx, pair.RefreshToken, token.Device{IP: "10.0.0.1"})
+	assert.NoError(t, appErr)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+type SecurityEventHandlerImpl struct {
+	service *services.SecurityEventServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewSecurityEventHandler(securityEventService *services.SecurityEventServiceImpl, logger zerolog.Logger) *SecurityEventHandlerImpl {
+	return &SecurityEventHandlerImpl{
+		service: securityEventService,
+		logger:  logger.With().Str("handler", "SecurityEventHandler").Logger(),
+	}
+}
+
+// ListEvents lets admins query the detected attack patterns by type,
+// address, username and time
+func (h *SecurityEventHandlerImpl) ListEvents(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	var req ListSecurityEventsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		h.logger.Err(err).Str("handler", "ListEvents").Msg("Invalid query parameters")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid query parameters", Details: err.Error()})
+		return
+	}
+
+	events, err := h.service.ListEvents(ctx, repository.SecurityEventFilter{
+		Type:      req.Type,
+		IPAddress: req.IPAddress,
+		Username:  req.Username,
+		Since:     req.Since,
+		Limit:     req.Limit,
+	})
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to list security events")
+		c.Error(err)
+		return
+	}
+
+	if events == nil {
+		events = []database.SecurityEvent{}
+	}
+	c.JSON(http.StatusOK, ListSecurityEventsResponse{Events: events})
+}
@@ -0,0 +1,135 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// loginFrom posts a login from the given address, answering the challenge
+// if one is given
+func loginFrom(router *gin.Engine, ip, challengeResponse, username, password string) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(gin.H{"username": username, "password": password})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if challengeResponse != "" {
+		req.Header.Set("X-Challenge-Response", challengeResponse)
+	}
+	req.RemoteAddr = ip + ":40000"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCredentialStuffingResponses(t *testing.T) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "stuffing.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	securityEvents := repository.NewSecurityEventRepository(db, zerolog.Nop())
+	detector := authentication.NewStuffingDetector(authentication.StuffingPolicy{
+		Window:        time.Minute,
+		IP:            authentication.StuffingThresholds{Delay: 2, Challenge: 3, Block: 5},
+		Delay:         10 * time.Millisecond,
+		BlockDuration: time.Hour,
+	}, securityEvents, zerolog.Nop())
+	verifier := authentication.ChallengeVerifierFunc(func(ctx context.Context, response, ipAddress string) (bool, error) {
+		return response == "solved", nil
+	})
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokens := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop(),
+		authentication.WithStuffingDetector(detector), authentication.WithChallengeVerifier(verifier))
+	auth := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokens,
+		services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokens, token.NewMemoryRevocationStore(), zerolog.Nop()),
+		zerolog.Nop())
+
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	router.POST("/auth/login", handlers.NewAuthHandler(auth, zerolog.Nop()).LoginUser)
+	router.GET("/admin/security-events", handlers.NewSecurityEventHandler(services.NewSecurityEventService(securityEvents, zerolog.Nop()), zerolog.Nop()).ListEvents)
+	_, err = auth.RegisterUser(context.Background(), "stuffed", "StrongP@ssw0rd2024!", "stuffed@example.com")
+	require.NoError(t, err)
+
+	// One address tries a list of accounts once each
+	const attacker = "203.0.113.7"
+	for _, username := range []string{"alice", "bob", "carol"} {
+		w := loginFrom(router, attacker, "", username, "Wr0ng!Password")
+		require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	}
+
+	// It now has to solve a challenge, even with the right password
+	w := loginFrom(router, attacker, "", "stuffed", "StrongP@ssw0rd2024!")
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	w = loginFrom(router, attacker, "wrong", "stuffed", "StrongP@ssw0rd2024!")
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	w = loginFrom(router, attacker, "solved", "stuffed", "StrongP@ssw0rd2024!")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Other addresses are not affected
+	w = loginFrom(router, "198.51.100.1", "", "stuffed", "StrongP@ssw0rd2024!")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// and solving challenges does not stop it from being blocked
+	for _, username := range []string{"dave", "erin"} {
+		w := loginFrom(router, attacker, "solved", username, "Wr0ng!Password")
+		require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	}
+	w = loginFrom(router, attacker, "solved", "stuffed", "StrongP@ssw0rd2024!")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour.Seconds(), retryAfter, 5)
+
+	// Every escalation is recorded for admins
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/security-events?type="+authentication.EventManyUsersFromIP+"&ip="+attacker, nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response handlers.ListSecurityEventsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Events, 3)
+	for i, expected := range []struct {
+		action string
+		count  int
+	}{{"block", 5}, {"challenge", 3}, {"delay", 2}} {
+		assert.Equal(t, expected.action, response.Events[i].Action)
+		assert.Equal(t, expected.count, response.Events[i].Count)
+		assert.Equal(t, attacker, response.Events[i].IPAddress)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/admin/security-events?username=stuffed", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"events": []}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/admin/security-events?limit=5000", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+type SessionHandlerImpl struct {
+	service *services.SessionServiceImpl
+	logger  zerolog.Logger
+}
+
+func NewSessionHandler(sessionService *services.SessionServiceImpl, logger zerolog.Logger) *SessionHandlerImpl {
+	return &SessionHandlerImpl{
+		service: sessionService,
+		logger:  logger.With().Str("handler", "SessionHandler").Logger(),
+	}
+}
+
+// ListSessions lists the authenticated user's active sessions
+func (h *SessionHandlerImpl) ListSessions(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	sessions, err := h.service.ListSessions(ctx, c.GetUint("user_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to list sessions")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toListSessionsResponse(sessions, c.GetString("session_id")))
+}
+
+// RevokeSession logs out one of the authenticated user's sessions
+func (h *SessionHandlerImpl) RevokeSession(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	sessionID := c.Param("id")
+	if err := h.service.RevokeSession(ctx, c.GetUint("user_id"), sessionID); err != nil {
+		h.logger.Err(err).Str("session_id", sessionID).Msg("Failed to revoke session")
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions logs out every session except the one making the request
+func (h *SessionHandlerImpl) RevokeOtherSessions(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	revoked, err := h.service.RevokeOtherSessions(ctx, c.GetUint("user_id"), c.GetString("session_id"))
+	if err != nil {
+		h.logger.Err(err).Msg("Failed to revoke other sessions")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RevokeSessionsResponse{Revoked: revoked})
+}
+
+// ListUserSessions lets admins list the active sessions of any user
+func (h *SessionHandlerImpl) ListUserSessions(c *gin.Context) {
+	ctx, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+
+	userIDStr := c.Param("id")
+	userID, parseErr := strconv.ParseUint(userIDStr, 10, 64)
+	if parseErr != nil {
+		h.logger.Error().Err(parseErr).Str("handler", "ListUserSessions").Str("id", userIDStr).Msg("Invalid user ID")
+		c.Error(apperrors.NewValidationErrors("Invalid user ID", parseErr))
+		return
+	}
+
+	sessions, err := h.service.ListSessions(ctx, uint(userID))
+	if err != nil {
+		h.logger.Err(err).Uint64("user_id", userID).Msg("Failed to list user sessions")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toListSessionsResponse(sessions, ""))
+}
+
+func toListSessionsResponse(sessions []database.Session, currentSessionID string) ListSessionsResponse {
+	response := ListSessionsResponse{Sessions: make([]SessionResponse, 0, len(sessions))}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, SessionResponse{
+			ID:         session.ID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			DeviceID:   session.DeviceID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+	return response
+}
@@ -121,6 +121,9 @@ func (h *UserHandlerImpl) UpdateUser(c *gin.Context) {
 			c.Error(err)
 			return
 		}
+
+		// Tokens issued with the old password must not outlive it
+		user.InvalidateTokens()
 	}
 
 	// Save updated user
@@ -152,6 +155,24 @@ func (h *UserHandlerImpl) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, DeleteUserResponse{Message: "User deleted successfully"})
 }
 
+// UnlockUser lets admins lift a lock before it ends, or a permanent one
+func (h *UserHandlerImpl) UnlockUser(c *gin.Context) {
+	_, cancel := utils.GetContextWithTimeout()
+	defer cancel()
+	userID, ok := h.parseUserID(c)
+	if !ok {
+		return // parseUserID already handled error response
+	}
+
+	if err := h.service.UnlockUser(uint(userID)); err != nil {
+		h.log.Error().Err(err).Str("handler", "UnlockUser").Uint64("id", userID).Msg("Failed to unlock user")
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UpdateUserResponse{Message: "User unlocked successfully"})
+}
+
 func (h *UserHandlerImpl) parseUserID(c *gin.Context) (uint64, bool) {
 	userIDStr := c.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
@@ -0,0 +1,174 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/handlers"
+	"github.com/yourusername/user-management-api/internal/middleware"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// setupUserRouter serves the user routes behind AuthMiddleware
+func setupUserRouter(t *testing.T, opts ...authentication.AuthenticationManagerOption) (*gin.Engine, *services.AuthServiceImpl, *gorm.DB) {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "users.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, loginAttemptRepo, zerolog.Nop(), opts...)
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, token.NewMemoryRevocationStore(), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop())
+	userHandler := handlers.NewUserHandler(services.NewUserService(userRepo, loginAttemptRepo, zerolog.Nop()), zerolog.Nop())
+
+	router := gin.Default()
+	router.Use(middleware.ErrorMiddleware(zerolog.Nop()))
+	userGroup := router.Group("/users", middleware.AuthMiddleware(authManager, zerolog.Nop()))
+	userGroup.GET("/:id", userHandler.GetUserByID)
+	userGroup.PUT("/:id", middleware.RejectImpersonation(zerolog.Nop()), userHandler.UpdateUser)
+	userGroup.DELETE("/:id", userHandler.DeleteUser)
+	return router, authService, db
+}
+
+func TestUpdateUserPasswordInvalidatesTokens(t *testing.T) {
+	router, authService, _ := setupUserRouter(t)
+	ctx := context.Background()
+	tokens := loginOAuthTestUser(t, authService, "changer")
+	other := loginOAuthTestUser(t, authService, "bystander")
+	claims, appErr := tokenManager.ValidateToken(tokens.AccessToken, token.AccessToken)
+	require.NoError(t, appErr)
+	path := "/users/" + strconv.FormatUint(uint64(claims.UserID), 10)
+
+	w := serveWithHeader(router, http.MethodGet, path, "Authorization", "Bearer "+tokens.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Changing only the email leaves tokens valid
+	body, _ := json.Marshal(gin.H{"email": "changer@example.org"})
+	w = serveWithHeader(router, http.MethodPut, path, "Authorization", "Bearer "+tokens.AccessToken, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serveWithHeader(router, http.MethodGet, path, "Authorization", "Bearer "+tokens.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body, _ = json.Marshal(gin.H{"email": "changer@example.org", "password": "N3w-Str0ng-P@ssword!"})
+	w = serveWithHeader(router, http.MethodPut, path, "Authorization", "Bearer "+tokens.AccessToken, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Tokens issued with the old password are rejected
+	w = serveWithHeader(router, http.MethodGet, path, "Authorization", "Bearer "+tokens.AccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	_, appErr = authService.RefreshTokens(ctx, tokens.RefreshToken, token.Device{IP: "127.0.0.1"})
+	assert.Error(t, appErr)
+
+	// A login right away, within the same second, gets working tokens
+	fresh, _, appErr := authService.LoginUser(ctx, "changer", "N3w-Str0ng-P@ssword!", token.Device{IP: "127.0.0.1"})
+	require.NoError(t, appErr)
+	w = serveWithHeader(router, http.MethodGet, path, "Authorization", "Bearer "+fresh.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	_, appErr = authService.RefreshTokens(ctx, fresh.RefreshToken, token.Device{IP: "127.0.0.1"})
+	assert.NoError(t, appErr)
+
+	// Other users are unaffected
+	w = serveWithHeader(router, http.MethodGet, path, "Authorization", "Bearer "+other.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestDeleteUserInvalidatesTokens(t *testing.T) {
+	router, authService, db := setupUserRouter(t)
+	ctx := context.Background()
+	tokens := loginOAuthTestUser(t, authService, "leaver")
+	claims, appErr := tokenManager.ValidateToken(tokens.AccessToken, token.AccessToken)
+	require.NoError(t, appErr)
+	path := "/users/" + strconv.FormatUint(uint64(claims.UserID), 10)
+
+	w := serveWithHeader(router, http.MethodDelete, path, "Authorization", "Bearer "+tokens.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var deleted database.User
+	require.NoError(t, db.Unscoped().First(&deleted, claims.UserID).Error)
+	assert.Equal(t, database.UserStatusDeleted, deleted.Status)
+	assert.True(t, deleted.TokenInvalidated(claims.TokenVersion))
+
+	_, appErr = authService.RefreshTokens(ctx, tokens.RefreshToken, token.Device{IP: "127.0.0.1"})
+	assert.Error(t, appErr)
+}
+
+func TestAuthMiddlewareRejectsUnavailableUsers(t *testing.T) {
+	router, authService, db := setupUserRouter(t, authentication.WithEmailVerificationRequired(true))
+	ctx := context.Background()
+	user, err := authService.RegisterUser(ctx, "unavailable", "StrongP@ssw0rd2024!", "unavailable@example.com")
+	require.NoError(t, err)
+	path := "/users/" + strconv.FormatUint(uint64(user.ID), 10)
+	setStatus := func(status database.UserStatus) {
+		require.NoError(t, db.Model(&database.User{}).Where("id = ?", user.ID).Update("status", status).Error)
+	}
+
+	// Sign in while verified, then lose the verified status
+	setStatus(database.UserStatusActive)
+	tokens, _, appErr := authService.LoginUser(ctx, "unavailable", "StrongP@ssw0rd2024!", token.Device{IP: "127.0.0.1"})
+	require.NoError(t, appErr)
+
+	w := serveWithHeader(router, http.MethodGet, path, "Authorization", "Bearer "+tokens.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// The handler must not run for users who may not sign in
+	for _, status := range []database.UserStatus{database.UserStatusPendingVerification, database.UserStatusInactive} {
+		setStatus(status)
+		w = serveWithHeader(router, http.MethodGet, path, "Authorization", "Bearer "+tokens.AccessToken, nil)
+		assert.GreaterOrEqual(t, w.Code, 400, status)
+		assert.Less(t, w.Code, 500, status)
+		assert.NotContains(t, w.Body.String(), "unavailable@example.com", status)
+	}
+
+	// Nor for users that no longer exist
+	setStatus(database.UserStatusActive)
+	require.NoError(t, db.Delete(&database.User{}, user.ID).Error)
+	w = serveWithHeader(router, http.MethodDelete, path, "Authorization", "Bearer "+tokens.AccessToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "User deleted successfully")
+}
+
+func TestUpdateUserRejectsImpersonation(t *testing.T) {
+	router, authService, db := setupUserRouter(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	impersonationService := services.NewImpersonationService(tokenManager, authManager, userRepo, zerolog.Nop(),
+		services.WithImpersonationTTL(time.Minute))
+	own, impersonated := impersonate(t, authService, impersonationService, userRepo)
+	customer, err := userRepo.FindUserByUsername("customer")
+	require.NoError(t, err)
+	path := "/users/" + strconv.FormatUint(uint64(customer.ID), 10)
+
+	// Support staff cannot set a password that would outlive the impersonation
+	body, _ := json.Marshal(gin.H{"email": "customer@example.com", "password": "Supp0rt-Kn0ws-Th1s!"})
+	w := serveWithHeader(router, http.MethodPut, path, "Authorization", "Bearer "+impersonated, body)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	_, _, appErr := authService.LoginUser(context.Background(), "customer", "Supp0rt-Kn0ws-Th1s!", token.Device{IP: "127.0.0.1"})
+	assert.Error(t, appErr)
+
+	// The user's own token still works
+	w = serveWithHeader(router, http.MethodGet, path, "Authorization", "Bearer "+own, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
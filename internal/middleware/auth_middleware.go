@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
 	"github.com/yourusername/user-management-api/pkg/authentication"
 	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
 	"github.com/yourusername/user-management-api/pkg/token"
@@ -37,6 +38,18 @@ func AuthMiddleware(authManager *authentication.AuthenticationManagerImpl, logge
 			return
 		}
 
+		// Tokens issued to OAuth clients only grant the scopes the user
+		// consented to, which never include this API
+		if claims.ClientID != "" {
+			logger.Warn().
+				Str("client_id", claims.ClientID).
+				Str("jti", claims.ID).
+				Msg("OAuth client token used on the API")
+			c.Error(apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidAudience, "Token was issued to an OAuth client", nil))
+			c.Abort()
+			return
+		}
+
 		// Additional user status check
 		user, err := authManager.FindUserByUsername(claims.Username)
 		if err != nil {
@@ -44,6 +57,7 @@ func AuthMiddleware(authManager *authentication.AuthenticationManagerImpl, logge
 				Str("username", claims.Username).
 				Msg("User not found")
 			c.Error(err)
+			c.Abort()
 			return
 		}
 
@@ -54,12 +68,129 @@ func AuthMiddleware(authManager *authentication.AuthenticationManagerImpl, logge
 				Str("username", claims.Username).
 				Msg("User status check failed")
 			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		// Tokens issued before a password change, lock or deletion are no longer valid
+		if user.TokenInvalidated(claims.TokenVersion) {
+			logger.Warn().
+				Str("username", claims.Username).
+				Str("jti", claims.ID).
+				Msg("Token issued before the user's tokens were invalidated")
+			c.Error(apperrors.NewTokenError(apperrors.ErrCodeTokenBlacklisted, "Token has been invalidated", nil))
+			c.Abort()
 			return
 		}
 
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("role", user.Role)
+		c.Set("session_id", claims.FamilyID)
+		c.Set("scopes", claims.Permissions)
+
+		if claims.Actor == nil {
+			c.Next()
+			return
+		}
+
+		// Impersonation tokens are only valid while the actor may still impersonate
+		actor, err := authManager.FindUserByUsername(claims.Actor.Username)
+		if err == nil {
+			err = authManager.CheckUserStatus(actor)
+		}
+		if err == nil && (actor.ID != claims.Actor.UserID || !actor.Role.HasPermission(database.PermissionImpersonate) ||
+			actor.TokenInvalidated(claims.Actor.TokenVersion)) {
+			err = apperrors.NewTokenError(apperrors.ErrCodeTokenBlacklisted, "Impersonation is no longer permitted", nil)
+		}
+		if err != nil {
+			logger.Warn().
+				Err(err).
+				Str("actor_username", claims.Actor.Username).
+				Str("username", claims.Username).
+				Msg("Impersonation token rejected")
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Set("actor_id", actor.ID)
+		c.Set("actor_username", actor.Username)
 		c.Next()
+
+		// Every impersonated request is audited with both identities
+		logger.Info().
+			Uint("actor_id", actor.ID).
+			Str("actor_username", actor.Username).
+			Uint("user_id", claims.UserID).
+			Str("username", claims.Username).
+			Int("status", c.Writer.Status()).
+			Msg("Impersonated request")
+	}
+}
+
+// RequireRole only lets through users authenticated by AuthMiddleware whose role is one of roles
+func RequireRole(logger zerolog.Logger, roles ...database.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		logger.Warn().
+			Str("uri", c.Request.URL.Path).
+			Interface("role", role).
+			Interface("user_id", c.Value("user_id")).
+			Msg("Insufficient role")
+		c.Error(apperrors.New(apperrors.ErrCodeUnauthorized, "Insufficient permissions", nil))
+		c.Abort()
+	}
+}
+
+// RequirePermission only lets through users authenticated by AuthMiddleware
+// whose role grants the permission. Impersonated users never pass, so an
+// impersonation token cannot be used to gain the target's privileges.
+func RequirePermission(logger zerolog.Logger, permission database.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Value("role").(database.UserRole)
+		_, impersonated := c.Get("actor_id")
+		if role.HasPermission(permission) && !impersonated {
+			c.Next()
+			return
+		}
+
+		logger.Warn().
+			Str("uri", c.Request.URL.Path).
+			Str("permission", string(permission)).
+			Interface("role", role).
+			Interface("user_id", c.Value("user_id")).
+			Interface("actor_id", c.Value("actor_id")).
+			Msg("Missing permission")
+		c.Error(apperrors.New(apperrors.ErrCodeUnauthorized, "Insufficient permissions", nil))
+		c.Abort()
+	}
+}
+
+// RejectImpersonation refuses requests made with an impersonation token. It
+// guards routes that change how a user signs in, so support staff cannot
+// keep access to an account once the impersonation has ended.
+func RejectImpersonation(logger zerolog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonated := c.Get("actor_id"); !impersonated {
+			c.Next()
+			return
+		}
+
+		logger.Warn().
+			Str("uri", c.Request.URL.Path).
+			Interface("user_id", c.Value("user_id")).
+			Interface("actor_id", c.Value("actor_id")).
+			Msg("Request refused while impersonating")
+		c.Error(apperrors.New(apperrors.ErrCodeUnauthorized, "Not allowed while impersonating", nil))
+		c.Abort()
 	}
 }
 
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -12,6 +15,8 @@ type ErrorResponse struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Details interface{} `json:"details,omitempty"`
+	// LockedUntil tells clients of locked accounts when they can log in again
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
 }
 
 func ErrorMiddleware(log zerolog.Logger) gin.HandlerFunc {
@@ -79,9 +84,25 @@ func handleAppError(c *gin.Context, appErr apperrors.AppError) {
 		}
 	}
 
+	// Locks that end by themselves say when, so clients know when to retry
+	if locked, ok := appErr.(apperrors.AccountLockedError); ok && !locked.LockedUntil().IsZero() {
+		lockedUntil := locked.LockedUntil().UTC()
+		response.LockedUntil = &lockedUntil
+		setRetryAfter(c, lockedUntil)
+	}
+	if blocked, ok := appErr.(apperrors.BlockedError); ok {
+		setRetryAfter(c, blocked.BlockedUntil())
+	}
+
 	c.JSON(status, response)
 }
 
+// setRetryAfter tells clients how many seconds to wait before trying again
+func setRetryAfter(c *gin.Context, until time.Time) {
+	retryAfter := math.Ceil(time.Until(until).Seconds())
+	c.Header("Retry-After", strconv.Itoa(int(max(retryAfter, 1))))
+}
+
 func handleValidationError(c *gin.Context, err *gin.Error) {
 	c.JSON(http.StatusBadRequest, ErrorResponse{
 		Code:    http.StatusBadRequest,
@@ -103,10 +124,23 @@ func mapAppErrorToHTTPStatus(err apperrors.AppError) int {
 	switch err.Code() {
 	case apperrors.ErrCodeNotFound:
 		return http.StatusNotFound
-	case apperrors.ErrCodeInvalidCredentials:
+	case apperrors.ErrCodeInvalidCredentials, apperrors.ErrCodeRefreshTokenReused,
+		apperrors.ErrCodeTokenInvalidIssuer, apperrors.ErrCodeTokenInvalidAudience, apperrors.ErrCodeTokenNotYetValid,
+		apperrors.ErrCodeDeviceMismatch, apperrors.ErrCodeTokenBlacklisted, apperrors.ErrCodeTokenInvalidClaim,
+		apperrors.ErrCodeInvalidToken, apperrors.ErrCodeTokenExpired, apperrors.ErrCodeTokenAlreadyUsed,
+		apperrors.ErrCodeInvalidAPIKey:
 		return http.StatusUnauthorized
-	case apperrors.ErrCodeUserLocked, apperrors.ErrCodeUserInactive, apperrors.ErrCodeUserDeleted, apperrors.ErrCodeUnauthorized:
+	case apperrors.ErrCodeUserLocked, apperrors.ErrCodeUserInactive, apperrors.ErrCodeUserDeleted, apperrors.ErrCodeUnauthorized,
+		apperrors.ErrCodeEmailNotVerified:
 		return http.StatusForbidden
+	case apperrors.ErrCodeChallengeRequired:
+		return http.StatusPreconditionRequired
+	case apperrors.ErrCodeTooManyRequests:
+		return http.StatusTooManyRequests
+	case apperrors.ErrCodeIdentityAlreadyLinked, apperrors.ErrCodeAccountExists:
+		return http.StatusConflict
+	case apperrors.ErrCodeIdentityProviderError:
+		return http.StatusBadGateway
 	case apperrors.ErrCodeDatabaseError:
 		return http.StatusInternalServerError
 	case apperrors.ErrCodeValidationError:
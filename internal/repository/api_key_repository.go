@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+)
+
+// apiKeyRetention is how long revoked and expired keys stay listed before
+// they are purged
+const apiKeyRetention = 30 * 24 * time.Hour
+
+type APIKeyRepository interface {
+	CreateKey(key *database.APIKey) error
+	FindKeyByPrefix(prefix string) (*database.APIKey, error)
+	FindKeysByUserID(userID uint) ([]database.APIKey, error)
+	CountUsableKeys(userID uint) (int64, error)
+	RecordKeyUse(id uint, ip string) error
+	RevokeKey(userID, id uint) error
+	PurgeExpired() (int64, error)
+}
+
+type APIKeyRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewAPIKeyRepository(db *gorm.DB, log zerolog.Logger) *APIKeyRepositoryImpl {
+	return &APIKeyRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "APIKeyRepository").Logger(),
+	}
+}
+
+func (r *APIKeyRepositoryImpl) CreateKey(key *database.APIKey) error {
+	result := r.db.Create(key)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", key.UserID).Msg("Failed to create API key")
+		return apperrors.NewDatabaseError("Failed to create API key", result.Error)
+	}
+	return nil
+}
+
+func (r *APIKeyRepositoryImpl) FindKeyByPrefix(prefix string) (*database.APIKey, error) {
+	key := &database.APIKey{}
+	result := r.db.First(key, "prefix = ?", prefix)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("API key not found", result.Error, "api_key", prefix)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("prefix", prefix).Msg("Failed to find API key")
+		return nil, apperrors.NewDatabaseError("Failed to find API key", result.Error)
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepositoryImpl) FindKeysByUserID(userID uint) ([]database.APIKey, error) {
+	var keys []database.APIKey
+	result := r.db.Where("user_id = ?", userID).Order("created_at").Find(&keys)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to find API keys")
+		return nil, apperrors.NewDatabaseError("Failed to find API keys", result.Error)
+	}
+	return keys, nil
+}
+
+// CountUsableKeys counts the user's keys that are neither revoked nor expired
+func (r *APIKeyRepositoryImpl) CountUsableKeys(userID uint) (int64, error) {
+	var count int64
+	result := r.db.Model(&database.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Count(&count)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to count API keys")
+		return 0, apperrors.NewDatabaseError("Failed to count API keys", result.Error)
+	}
+	return count, nil
+}
+
+// RecordKeyUse stores the time and client address of a request made with the key
+func (r *APIKeyRepositoryImpl) RecordKeyUse(id uint, ip string) error {
+	result := r.db.Model(&database.APIKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_used_at": time.Now(),
+			"last_used_ip": ip,
+		})
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("id", id).Msg("Failed to record API key use")
+		return apperrors.NewDatabaseError("Failed to record API key use", result.Error)
+	}
+	return nil
+}
+
+// RevokeKey revokes one of the user's keys. Revoked keys stay listed until
+// they are purged.
+func (r *APIKeyRepositoryImpl) RevokeKey(userID, id uint) error {
+	result := r.db.Model(&database.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Uint("id", id).Msg("Failed to revoke API key")
+		return apperrors.NewDatabaseError("Failed to revoke API key", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NewNotFoundError("API key not found", nil, "api_key", id)
+	}
+	return nil
+}
+
+// PurgeExpired deletes keys that were revoked or expired longer than the
+// retention period ago
+func (r *APIKeyRepositoryImpl) PurgeExpired() (int64, error) {
+	cutoff := time.Now().Add(-apiKeyRetention)
+	result := r.db.Where("revoked_at <= ? OR expires_at <= ?", cutoff, cutoff).Delete(&database.APIKey{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired API keys")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired API keys", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ APIKeyRepository = (*APIKeyRepositoryImpl)(nil)
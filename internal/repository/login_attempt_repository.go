@@ -13,6 +13,7 @@ type LoginAttemptRepository interface {
 	IncrementLoginAttempts(username string, ipAddress string, success bool) error
 	ResetLoginAttempts(username string, ipAddress string) error
 	GetLoginAttempts(username string, ipAddress string) (int, time.Time, error)
+	ClearLoginAttempts(username string) error
 }
 
 type LoginAttemptRepositoryImpl struct {
@@ -56,7 +57,7 @@ func (r *LoginAttemptRepositoryImpl) IncrementLoginAttempts(username string, ipA
 
 	// If record doesn't exist, create a new one
 	if result.Error == gorm.ErrRecordNotFound {
-		createResult := r.db.Create(newLoginAttempt)
+		createResult := r.db.Create(&newLoginAttempt)
 		if createResult.Error != nil {
 			r.log.Error().
 				Err(createResult.Error).
@@ -136,6 +137,19 @@ func (r *LoginAttemptRepositoryImpl) ResetLoginAttempts(username string, ipAddre
 	return nil
 }
 
+// ClearLoginAttempts deletes the login attempts of a username from every IP
+// address. The records are removed for good so the unique index stays usable.
+func (r *LoginAttemptRepositoryImpl) ClearLoginAttempts(username string) error {
+	result := r.db.Unscoped().Where("username = ?", username).Delete(&database.LoginAttempt{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("username", username).Msg("Failed to clear login attempts")
+		return apperrors.NewDatabaseError("Failed to clear login attempts", result.Error)
+	}
+
+	r.log.Info().Str("username", username).Int64("cleared", result.RowsAffected).Msg("Login attempts cleared")
+	return nil
+}
+
 func (r *LoginAttemptRepositoryImpl) GetLoginAttempts(username string, ipAddress string) (int, time.Time, error) {
 	var loginAttempt database.LoginAttempt
 
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MFARepository interface {
+	SaveTOTPFactor(factor *database.TOTPFactor) error
+	FindTOTPFactor(userID uint) (*database.TOTPFactor, error)
+	ConfirmTOTPFactor(userID uint, step int64) error
+	UseTOTPStep(userID uint, step int64) (bool, error)
+	DeleteTOTPFactor(userID uint) error
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(userID uint) (int64, error)
+}
+
+type MFARepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewMFARepository(db *gorm.DB, log zerolog.Logger) *MFARepositoryImpl {
+	return &MFARepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "MFARepository").Logger(),
+	}
+}
+
+// SaveTOTPFactor stores the user's TOTP factor, replacing an earlier one
+func (r *MFARepositoryImpl) SaveTOTPFactor(factor *database.TOTPFactor) error {
+	result := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(factor)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", factor.UserID).Msg("Failed to save TOTP factor")
+		return apperrors.NewDatabaseError("Failed to save TOTP factor", result.Error)
+	}
+	return nil
+}
+
+func (r *MFARepositoryImpl) FindTOTPFactor(userID uint) (*database.TOTPFactor, error) {
+	factor := &database.TOTPFactor{}
+	result := r.db.First(factor, "user_id = ?", userID)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("TOTP factor not found", result.Error, "user_id", userID)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to find TOTP factor")
+		return nil, apperrors.NewDatabaseError("Failed to find TOTP factor", result.Error)
+	}
+
+	return factor, nil
+}
+
+// ConfirmTOTPFactor enables the factor, recording the step of the code that
+// confirmed it as used
+func (r *MFARepositoryImpl) ConfirmTOTPFactor(userID uint, step int64) error {
+	result := r.db.Model(&database.TOTPFactor{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"confirmed_at":   time.Now(),
+			"last_used_step": step,
+		})
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to confirm TOTP factor")
+		return apperrors.NewDatabaseError("Failed to confirm TOTP factor", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NewNotFoundError("TOTP factor not found", nil, "user_id", userID)
+	}
+	return nil
+}
+
+// UseTOTPStep atomically records a time step as used. It reports false when
+// a code of that step or a later one was already accepted.
+func (r *MFARepositoryImpl) UseTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&database.TOTPFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to record TOTP step")
+		return false, apperrors.NewDatabaseError("Failed to record TOTP step", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteTOTPFactor removes the user's TOTP factor and recovery codes
+func (r *MFARepositoryImpl) DeleteTOTPFactor(userID uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&database.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&database.TOTPFactor{}).Error
+	})
+
+	if err != nil {
+		r.log.Error().Err(err).Uint("user_id", userID).Msg("Failed to delete TOTP factor")
+		return apperrors.NewDatabaseError("Failed to delete TOTP factor", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for a new set
+func (r *MFARepositoryImpl) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	codes := make([]database.RecoveryCode, len(codeHashes))
+	for i, codeHash := range codeHashes {
+		codes[i] = database.RecoveryCode{UserID: userID, CodeHash: codeHash}
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&database.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+
+	if err != nil {
+		r.log.Error().Err(err).Uint("user_id", userID).Msg("Failed to replace recovery codes")
+		return apperrors.NewDatabaseError("Failed to replace recovery codes", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode atomically marks an unused recovery code as used. It
+// reports false when the code is unknown or was already used.
+func (r *MFARepositoryImpl) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&database.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to use recovery code")
+		return false, apperrors.NewDatabaseError("Failed to use recovery code", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *MFARepositoryImpl) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	result := r.db.Model(&database.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count)
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to count recovery codes")
+		return 0, apperrors.NewDatabaseError("Failed to count recovery codes", result.Error)
+	}
+	return count, nil
+}
+
+var _ MFARepository = (*MFARepositoryImpl)(nil)
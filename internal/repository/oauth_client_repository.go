@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OAuthClientRepository interface {
+	CreateClient(client *database.OAuthClient) error
+	FindClientByClientID(clientID string) (*database.OAuthClient, error)
+	ListClients() ([]database.OAuthClient, error)
+	DeleteClient(clientID string) error
+	SaveConsent(consent *database.OAuthConsent) error
+	FindConsent(userID uint, clientID string) (*database.OAuthConsent, error)
+	ListConsents(userID uint) ([]database.OAuthConsent, error)
+	DeleteConsent(userID uint, clientID string) error
+	CreateAuthorizationCode(code *database.OAuthAuthorizationCode) error
+	ConsumeAuthorizationCode(codeHash string) (*database.OAuthAuthorizationCode, error)
+	PurgeExpired() (int64, error)
+}
+
+type OAuthClientRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewOAuthClientRepository(db *gorm.DB, log zerolog.Logger) *OAuthClientRepositoryImpl {
+	return &OAuthClientRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "OAuthClientRepository").Logger(),
+	}
+}
+
+func (r *OAuthClientRepositoryImpl) CreateClient(client *database.OAuthClient) error {
+	result := r.db.Create(client)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("client_id", client.ClientID).Msg("Failed to create OAuth client")
+		return apperrors.NewDatabaseError("Failed to create OAuth client", result.Error)
+	}
+	return nil
+}
+
+func (r *OAuthClientRepositoryImpl) FindClientByClientID(clientID string) (*database.OAuthClient, error) {
+	client := &database.OAuthClient{}
+	result := r.db.First(client, "client_id = ?", clientID)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("OAuth client not found", result.Error, "oauth_client", clientID)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("client_id", clientID).Msg("Failed to find OAuth client")
+		return nil, apperrors.NewDatabaseError("Failed to find OAuth client", result.Error)
+	}
+
+	return client, nil
+}
+
+func (r *OAuthClientRepositoryImpl) ListClients() ([]database.OAuthClient, error) {
+	var clients []database.OAuthClient
+	result := r.db.Order("created_at").Find(&clients)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to list OAuth clients")
+		return nil, apperrors.NewDatabaseError("Failed to list OAuth clients", result.Error)
+	}
+	return clients, nil
+}
+
+// DeleteClient removes a client together with its consents and unexchanged
+// authorization codes
+func (r *OAuthClientRepositoryImpl) DeleteClient(clientID string) error {
+	var deleted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("client_id = ?", clientID).Delete(&database.OAuthClient{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		if err := tx.Where("client_id = ?", clientID).Delete(&database.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("client_id = ?", clientID).Delete(&database.OAuthAuthorizationCode{}).Error
+	})
+
+	if err != nil {
+		r.log.Error().Err(err).Str("client_id", clientID).Msg("Failed to delete OAuth client")
+		return apperrors.NewDatabaseError("Failed to delete OAuth client", err)
+	}
+	if deleted == 0 {
+		return apperrors.NewNotFoundError("OAuth client not found", nil, "oauth_client", clientID)
+	}
+	return nil
+}
+
+// SaveConsent creates the user's consent for the client or replaces its scopes
+func (r *OAuthClientRepositoryImpl) SaveConsent(consent *database.OAuthConsent) error {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(consent)
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", consent.UserID).Str("client_id", consent.ClientID).Msg("Failed to save OAuth consent")
+		return apperrors.NewDatabaseError("Failed to save OAuth consent", result.Error)
+	}
+	return nil
+}
+
+func (r *OAuthClientRepositoryImpl) FindConsent(userID uint, clientID string) (*database.OAuthConsent, error) {
+	consent := &database.OAuthConsent{}
+	result := r.db.First(consent, "user_id = ? AND client_id = ?", userID, clientID)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("OAuth consent not found", result.Error, "oauth_consent", clientID)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Str("client_id", clientID).Msg("Failed to find OAuth consent")
+		return nil, apperrors.NewDatabaseError("Failed to find OAuth consent", result.Error)
+	}
+
+	return consent, nil
+}
+
+func (r *OAuthClientRepositoryImpl) ListConsents(userID uint) ([]database.OAuthConsent, error) {
+	var consents []database.OAuthConsent
+	result := r.db.Where("user_id = ?", userID).Order("created_at").Find(&consents)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to list OAuth consents")
+		return nil, apperrors.NewDatabaseError("Failed to list OAuth consents", result.Error)
+	}
+	return consents, nil
+}
+
+func (r *OAuthClientRepositoryImpl) DeleteConsent(userID uint, clientID string) error {
+	result := r.db.Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&database.OAuthConsent{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Str("client_id", clientID).Msg("Failed to delete OAuth consent")
+		return apperrors.NewDatabaseError("Failed to delete OAuth consent", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NewNotFoundError("OAuth consent not found", nil, "oauth_consent", clientID)
+	}
+	return nil
+}
+
+func (r *OAuthClientRepositoryImpl) CreateAuthorizationCode(code *database.OAuthAuthorizationCode) error {
+	result := r.db.Create(code)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", code.UserID).Str("client_id", code.ClientID).Msg("Failed to create authorization code")
+		return apperrors.NewDatabaseError("Failed to create authorization code", result.Error)
+	}
+	return nil
+}
+
+// ConsumeAuthorizationCode atomically removes an unexpired code and returns
+// it, so every code is exchanged at most once
+func (r *OAuthClientRepositoryImpl) ConsumeAuthorizationCode(codeHash string) (*database.OAuthAuthorizationCode, error) {
+	code := &database.OAuthAuthorizationCode{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(code, "code_hash = ? AND expires_at > ?", codeHash, time.Now()).Error; err != nil {
+			return err
+		}
+		result := tx.Where("code_hash = ?", codeHash).Delete(&database.OAuthAuthorizationCode{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("Authorization code not found", err, "oauth_authorization_code", "")
+	}
+	if err != nil {
+		r.log.Error().Err(err).Msg("Failed to consume authorization code")
+		return nil, apperrors.NewDatabaseError("Failed to consume authorization code", err)
+	}
+	return code, nil
+}
+
+// PurgeExpired deletes authorization codes that were never exchanged
+func (r *OAuthClientRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&database.OAuthAuthorizationCode{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired authorization codes")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired authorization codes", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ OAuthClientRepository = (*OAuthClientRepositoryImpl)(nil)
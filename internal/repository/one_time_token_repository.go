@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+)
+
+type OneTimeTokenRepository interface {
+	CreateOneTimeToken(oneTimeToken *database.OneTimeToken) error
+	FindOneTimeTokenByHash(tokenHash string) (*database.OneTimeToken, error)
+	ConsumeOneTimeToken(id uint) (bool, error)
+	ConsumeOutstandingTokens(userID uint, purpose string) error
+	PurgeExpired() (int64, error)
+}
+
+type OneTimeTokenRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewOneTimeTokenRepository(db *gorm.DB, log zerolog.Logger) *OneTimeTokenRepositoryImpl {
+	return &OneTimeTokenRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "OneTimeTokenRepository").Logger(),
+	}
+}
+
+func (r *OneTimeTokenRepositoryImpl) CreateOneTimeToken(oneTimeToken *database.OneTimeToken) error {
+	result := r.db.Create(oneTimeToken)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", oneTimeToken.UserID).Str("purpose", oneTimeToken.Purpose).Msg("Failed to create one-time token")
+		return apperrors.NewDatabaseError("Failed to create one-time token", result.Error)
+	}
+	return nil
+}
+
+func (r *OneTimeTokenRepositoryImpl) FindOneTimeTokenByHash(tokenHash string) (*database.OneTimeToken, error) {
+	oneTimeToken := &database.OneTimeToken{}
+	result := r.db.First(oneTimeToken, "token_hash = ?", tokenHash)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("One-time token not found", result.Error, "one_time_token", "")
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to find one-time token")
+		return nil, apperrors.NewDatabaseError("Failed to find one-time token", result.Error)
+	}
+
+	return oneTimeToken, nil
+}
+
+// ConsumeOneTimeToken atomically marks an unexpired token as used. It reports
+// false when the token was already consumed, has expired or is unknown.
+func (r *OneTimeTokenRepositoryImpl) ConsumeOneTimeToken(id uint) (bool, error) {
+	result := r.db.Model(&database.OneTimeToken{}).
+		Where("id = ? AND consumed_at IS NULL AND expires_at > ?", id, time.Now()).
+		Update("consumed_at", time.Now())
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("id", id).Msg("Failed to consume one-time token")
+		return false, apperrors.NewDatabaseError("Failed to consume one-time token", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// ConsumeOutstandingTokens voids the user's unused tokens for a purpose
+func (r *OneTimeTokenRepositoryImpl) ConsumeOutstandingTokens(userID uint, purpose string) error {
+	result := r.db.Model(&database.OneTimeToken{}).
+		Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", userID, purpose).
+		Update("consumed_at", time.Now())
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Str("purpose", purpose).Msg("Failed to void one-time tokens")
+		return apperrors.NewDatabaseError("Failed to void one-time tokens", result.Error)
+	}
+	return nil
+}
+
+// PurgeExpired deletes one-time tokens that can no longer be used
+func (r *OneTimeTokenRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&database.OneTimeToken{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired one-time tokens")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired one-time tokens", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ OneTimeTokenRepository = (*OneTimeTokenRepositoryImpl)(nil)
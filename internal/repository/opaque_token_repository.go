@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"gorm.io/gorm"
+)
+
+type OpaqueTokenRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewOpaqueTokenRepository(db *gorm.DB, log zerolog.Logger) *OpaqueTokenRepositoryImpl {
+	return &OpaqueTokenRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "OpaqueTokenRepository").Logger(),
+	}
+}
+
+func (r *OpaqueTokenRepositoryImpl) StoreToken(tokenHash string, claims *token.Claims) error {
+	encoded, err := json.Marshal(claims)
+	if err != nil {
+		return apperrors.NewInternalError("Failed to encode token claims", err)
+	}
+
+	result := r.db.Create(&database.OpaqueToken{
+		TokenHash: tokenHash,
+		TokenType: string(claims.TokenType),
+		UserID:    claims.UserID,
+		Claims:    string(encoded),
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", claims.UserID).Msg("Failed to store opaque token")
+		return apperrors.NewDatabaseError("Failed to store opaque token", result.Error)
+	}
+	return nil
+}
+
+func (r *OpaqueTokenRepositoryImpl) LookupToken(tokenHash string) (*token.Claims, error) {
+	record := &database.OpaqueToken{}
+	result := r.db.First(record, "token_hash = ?", tokenHash)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to look up opaque token")
+		return nil, apperrors.NewDatabaseError("Failed to look up opaque token", result.Error)
+	}
+
+	claims := &token.Claims{}
+	if err := json.Unmarshal([]byte(record.Claims), claims); err != nil {
+		r.log.Error().Err(err).Uint("user_id", record.UserID).Msg("Failed to decode opaque token claims")
+		return nil, apperrors.NewInternalError("Failed to decode token claims", err)
+	}
+	return claims, nil
+}
+
+func (r *OpaqueTokenRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&database.OpaqueToken{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired opaque tokens")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired opaque tokens", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ token.OpaqueTokenStore = (*OpaqueTokenRepositoryImpl)(nil)
@@ -0,0 +1,50 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func TestOpaqueTokenRepository(t *testing.T) {
+	t.Cleanup(func() { db.Exec("DELETE FROM opaque_tokens") })
+	repo := repository.NewOpaqueTokenRepository(db, zerolog.Logger{})
+
+	claims := &token.Claims{
+		UserID:    7,
+		Username:  "opaqueuser",
+		TokenType: token.AccessToken,
+		FamilyID:  "family-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "active-jti",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	require.NoError(t, repo.StoreToken("active-hash", claims))
+
+	expired := *claims
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	require.NoError(t, repo.StoreToken("expired-hash", &expired))
+
+	found, err := repo.LookupToken("active-hash")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, claims.Username, found.Username)
+	assert.Equal(t, claims.FamilyID, found.FamilyID)
+	assert.Equal(t, claims.ID, found.ID)
+
+	found, err = repo.LookupToken("unknown-hash")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	purged, err := repo.PurgeExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
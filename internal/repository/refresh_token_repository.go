@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(refreshToken *database.RefreshToken) error
+	FindRefreshTokenByJTI(jti string) (*database.RefreshToken, error)
+	ConsumeRefreshToken(jti string) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeUserRefreshTokens(userID uint) error
+	PurgeExpired() (int64, error)
+}
+
+type RefreshTokenRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewRefreshTokenRepository(db *gorm.DB, log zerolog.Logger) *RefreshTokenRepositoryImpl {
+	return &RefreshTokenRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "RefreshTokenRepository").Logger(),
+	}
+}
+
+func (r *RefreshTokenRepositoryImpl) CreateRefreshToken(refreshToken *database.RefreshToken) error {
+	result := r.db.Create(refreshToken)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("family_id", refreshToken.FamilyID).Msg("Failed to create refresh token")
+		return apperrors.NewDatabaseError("Failed to create refresh token", result.Error)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepositoryImpl) FindRefreshTokenByJTI(jti string) (*database.RefreshToken, error) {
+	refreshToken := &database.RefreshToken{}
+	result := r.db.First(refreshToken, "jti = ?", jti)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		r.log.Error().Err(result.Error).Str("jti", jti).Msg("Refresh token not found")
+		return nil, apperrors.NewNotFoundError("Refresh token not found", result.Error, "jti", jti)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("jti", jti).Msg("Failed to find refresh token")
+		return nil, apperrors.NewDatabaseError("Failed to find refresh token", result.Error)
+	}
+
+	return refreshToken, nil
+}
+
+// ConsumeRefreshToken atomically marks an active refresh token as used.
+// It reports false when the token was already consumed, revoked or is unknown.
+func (r *RefreshTokenRepositoryImpl) ConsumeRefreshToken(jti string) (bool, error) {
+	result := r.db.Model(&database.RefreshToken{}).
+		Where("jti = ? AND consumed_at IS NULL AND revoked_at IS NULL AND expires_at > ?", jti, time.Now()).
+		Update("consumed_at", time.Now())
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("jti", jti).Msg("Failed to consume refresh token")
+		return false, apperrors.NewDatabaseError("Failed to consume refresh token", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *RefreshTokenRepositoryImpl) RevokeFamily(familyID string) error {
+	result := r.db.Model(&database.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("family_id", familyID).Msg("Failed to revoke refresh token family")
+		return apperrors.NewDatabaseError("Failed to revoke refresh token family", result.Error)
+	}
+
+	r.log.Info().
+		Str("family_id", familyID).
+		Int64("revoked_tokens", result.RowsAffected).
+		Msg("Revoked refresh token family")
+
+	return nil
+}
+
+func (r *RefreshTokenRepositoryImpl) RevokeUserRefreshTokens(userID uint) error {
+	result := r.db.Model(&database.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to revoke user refresh tokens")
+		return apperrors.NewDatabaseError("Failed to revoke user refresh tokens", result.Error)
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes refresh tokens that can no longer be presented
+func (r *RefreshTokenRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&database.RefreshToken{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired refresh tokens")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired refresh tokens", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ RefreshTokenRepository = (*RefreshTokenRepositoryImpl)(nil)
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevokedTokenRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewRevokedTokenRepository(db *gorm.DB, log zerolog.Logger) *RevokedTokenRepositoryImpl {
+	return &RevokedTokenRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "RevokedTokenRepository").Logger(),
+	}
+}
+
+func (r *RevokedTokenRepositoryImpl) Revoke(jti string, expiresAt time.Time) error {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "jti"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&database.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("jti", jti).Msg("Failed to revoke token")
+		return apperrors.NewDatabaseError("Failed to revoke token", result.Error)
+	}
+	return nil
+}
+
+func (r *RevokedTokenRepositoryImpl) IsRevoked(jti string) (bool, error) {
+	var count int64
+	result := r.db.Model(&database.RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).
+		Count(&count)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("jti", jti).Msg("Failed to check token revocation")
+		return false, apperrors.NewDatabaseError("Failed to check token revocation", result.Error)
+	}
+	return count > 0, nil
+}
+
+func (r *RevokedTokenRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&database.RevokedToken{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired revocations")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired revocations", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ token.RevocationStore = (*RevokedTokenRepositoryImpl)(nil)
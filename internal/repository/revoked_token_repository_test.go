@@ -0,0 +1,38 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/repository"
+)
+
+func TestRevokedTokenRepository(t *testing.T) {
+	t.Cleanup(func() { db.Exec("DELETE FROM revoked_tokens") })
+	repo := repository.NewRevokedTokenRepository(db, zerolog.Logger{})
+
+	require.NoError(t, repo.Revoke("active-jti", time.Now().Add(time.Hour)))
+	require.NoError(t, repo.Revoke("expired-jti", time.Now().Add(-time.Hour)))
+	// Revoking twice must not fail
+	require.NoError(t, repo.Revoke("active-jti", time.Now().Add(time.Hour)))
+
+	revoked, err := repo.IsRevoked("active-jti")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = repo.IsRevoked("expired-jti")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = repo.IsRevoked("unknown-jti")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	purged, err := repo.PurgeExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+)
+
+// securityEventRetention is how long security events stay queryable before
+// they are purged
+const securityEventRetention = 90 * 24 * time.Hour
+
+// defaultSecurityEventLimit caps the events returned when the filter sets no limit
+const defaultSecurityEventLimit = 100
+
+// SecurityEventFilter narrows down the security events returned; zero
+// fields match every event
+type SecurityEventFilter struct {
+	Type      string
+	IPAddress string
+	Username  string
+	Since     time.Time
+	Limit     int
+}
+
+type SecurityEventRepository interface {
+	CreateEvent(event *database.SecurityEvent) error
+	FindEvents(filter SecurityEventFilter) ([]database.SecurityEvent, error)
+	PurgeExpired() (int64, error)
+}
+
+type SecurityEventRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewSecurityEventRepository(db *gorm.DB, log zerolog.Logger) *SecurityEventRepositoryImpl {
+	return &SecurityEventRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "SecurityEventRepository").Logger(),
+	}
+}
+
+func (r *SecurityEventRepositoryImpl) CreateEvent(event *database.SecurityEvent) error {
+	result := r.db.Create(event)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("type", event.Type).Msg("Failed to create security event")
+		return apperrors.NewDatabaseError("Failed to create security event", result.Error)
+	}
+	return nil
+}
+
+// FindEvents returns the events matching the filter, newest first
+func (r *SecurityEventRepositoryImpl) FindEvents(filter SecurityEventFilter) ([]database.SecurityEvent, error) {
+	query := r.db.Model(&database.SecurityEvent{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.IPAddress != "" {
+		query = query.Where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultSecurityEventLimit
+	}
+
+	var events []database.SecurityEvent
+	result := query.Order("created_at DESC, id DESC").Limit(limit).Find(&events)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to find security events")
+		return nil, apperrors.NewDatabaseError("Failed to find security events", result.Error)
+	}
+	return events, nil
+}
+
+// PurgeExpired deletes events older than the retention period
+func (r *SecurityEventRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("created_at <= ?", time.Now().Add(-securityEventRetention)).Delete(&database.SecurityEvent{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired security events")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired security events", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ SecurityEventRepository = (*SecurityEventRepositoryImpl)(nil)
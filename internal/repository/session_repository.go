@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	CreateSession(session *database.Session) error
+	FindSessionByID(sessionID string) (*database.Session, error)
+	FindActiveSessionsByUserID(userID uint) ([]database.Session, error)
+	UpdateSession(session *database.Session) error
+	RevokeSession(sessionID string) (bool, error)
+	PurgeExpired() (int64, error)
+}
+
+type SessionRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewSessionRepository(db *gorm.DB, log zerolog.Logger) *SessionRepositoryImpl {
+	return &SessionRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "SessionRepository").Logger(),
+	}
+}
+
+func (r *SessionRepositoryImpl) CreateSession(session *database.Session) error {
+	result := r.db.Create(session)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", session.UserID).Msg("Failed to create session")
+		return apperrors.NewDatabaseError("Failed to create session", result.Error)
+	}
+	return nil
+}
+
+func (r *SessionRepositoryImpl) FindSessionByID(sessionID string) (*database.Session, error) {
+	session := &database.Session{}
+	result := r.db.First(session, "id = ?", sessionID)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("Session not found", result.Error, "session", sessionID)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("session_id", sessionID).Msg("Failed to find session")
+		return nil, apperrors.NewDatabaseError("Failed to find session", result.Error)
+	}
+
+	return session, nil
+}
+
+// FindActiveSessionsByUserID returns the user's unrevoked, unexpired sessions, oldest first
+func (r *SessionRepositoryImpl) FindActiveSessionsByUserID(userID uint) ([]database.Session, error) {
+	var sessions []database.Session
+	result := r.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at ASC").
+		Find(&sessions)
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to find sessions")
+		return nil, apperrors.NewDatabaseError("Failed to find sessions", result.Error)
+	}
+
+	return sessions, nil
+}
+
+func (r *SessionRepositoryImpl) UpdateSession(session *database.Session) error {
+	result := r.db.Save(session)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("session_id", session.ID).Msg("Failed to update session")
+		return apperrors.NewDatabaseError("Failed to update session", result.Error)
+	}
+	return nil
+}
+
+// RevokeSession marks a session as revoked. It reports false when the session
+// was already revoked or does not exist.
+func (r *SessionRepositoryImpl) RevokeSession(sessionID string) (bool, error) {
+	result := r.db.Model(&database.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("session_id", sessionID).Msg("Failed to revoke session")
+		return false, apperrors.NewDatabaseError("Failed to revoke session", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// PurgeExpired deletes sessions whose refresh tokens have all expired
+func (r *SessionRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&database.Session{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired sessions")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired sessions", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ SessionRepository = (*SessionRepositoryImpl)(nil)
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SigningKeyRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewSigningKeyRepository(db *gorm.DB, log zerolog.Logger) *SigningKeyRepositoryImpl {
+	return &SigningKeyRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "SigningKeyRepository").Logger(),
+	}
+}
+
+func (r *SigningKeyRepositoryImpl) LoadSigningKeys(tokenType token.TokenType) ([]token.StoredSigningKey, error) {
+	var records []database.SigningKey
+	result := r.db.Where("token_type = ?", string(tokenType)).Order("created_at").Find(&records)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("token_type", string(tokenType)).Msg("Failed to load signing keys")
+		return nil, apperrors.NewDatabaseError("Failed to load signing keys", result.Error)
+	}
+
+	keys := make([]token.StoredSigningKey, 0, len(records))
+	for _, record := range records {
+		keys = append(keys, token.StoredSigningKey{
+			ID:        record.KID,
+			TokenType: token.TokenType(record.TokenType),
+			Algorithm: record.Algorithm,
+			Material:  record.Material,
+			Active:    record.Active,
+			CreatedAt: record.CreatedAt,
+			RetireAt:  record.RetireAt,
+			Retired:   record.Retired,
+		})
+	}
+	return keys, nil
+}
+
+func (r *SigningKeyRepositoryImpl) SaveSigningKey(key token.StoredSigningKey) error {
+	record := &database.SigningKey{
+		KID:       key.ID,
+		TokenType: string(key.TokenType),
+		Algorithm: key.Algorithm,
+		Material:  key.Material,
+		Active:    key.Active,
+		Retired:   key.Retired,
+		RetireAt:  key.RetireAt,
+		CreatedAt: key.CreatedAt,
+	}
+
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"material", "active", "retired", "retire_at", "updated_at"}),
+	}).Create(record)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("kid", key.ID).Msg("Failed to save signing key")
+		return apperrors.NewDatabaseError("Failed to save signing key", result.Error)
+	}
+	return nil
+}
+
+var _ token.KeyringStore = (*SigningKeyRepositoryImpl)(nil)
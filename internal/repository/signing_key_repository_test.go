@@ -0,0 +1,45 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func TestSigningKeyRepository(t *testing.T) {
+	t.Cleanup(func() { db.Exec("DELETE FROM signing_keys") })
+	repo := repository.NewSigningKeyRepository(db, zerolog.Logger{})
+
+	key := token.StoredSigningKey{
+		ID:        "kid-1",
+		TokenType: token.AccessToken,
+		Algorithm: token.AlgorithmES256,
+		Material:  []byte("sealed"),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	require.NoError(t, repo.SaveSigningKey(key))
+
+	// Saving again updates the existing record
+	retireAt := time.Now().Add(time.Hour)
+	key.Active = false
+	key.RetireAt = &retireAt
+	require.NoError(t, repo.SaveSigningKey(key))
+
+	keys, err := repo.LoadSigningKeys(token.AccessToken)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.False(t, keys[0].Active)
+	assert.NotNil(t, keys[0].RetireAt)
+	assert.Equal(t, []byte("sealed"), keys[0].Material)
+
+	keys, err = repo.LoadSigningKeys(token.RefreshToken)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
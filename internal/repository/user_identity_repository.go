@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+)
+
+type UserIdentityRepository interface {
+	CreateIdentity(identity *database.UserIdentity) error
+	CreateUserWithIdentity(user *database.User, identity *database.UserIdentity) error
+	FindIdentity(provider, subject string) (*database.UserIdentity, error)
+	FindIdentitiesByUserID(userID uint) ([]database.UserIdentity, error)
+	RecordIdentityLogin(id uint, email string) error
+	DeleteIdentity(userID, id uint) error
+	CreateLoginState(state *database.FederatedLoginState) error
+	ConsumeLoginState(stateHash string) (*database.FederatedLoginState, error)
+	PurgeExpired() (int64, error)
+}
+
+type UserIdentityRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewUserIdentityRepository(db *gorm.DB, log zerolog.Logger) *UserIdentityRepositoryImpl {
+	return &UserIdentityRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "UserIdentityRepository").Logger(),
+	}
+}
+
+func (r *UserIdentityRepositoryImpl) CreateIdentity(identity *database.UserIdentity) error {
+	result := r.db.Create(identity)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", identity.UserID).Str("provider", identity.Provider).Msg("Failed to create user identity")
+		return apperrors.NewDatabaseError("Failed to create user identity", result.Error)
+	}
+	return nil
+}
+
+// CreateUserWithIdentity provisions a user and links the identity it was
+// provisioned for, so a failed link does not leave an orphaned account
+func (r *UserIdentityRepositoryImpl) CreateUserWithIdentity(user *database.User, identity *database.UserIdentity) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+
+	if err != nil {
+		r.log.Error().Err(err).Str("user", user.Username).Str("provider", identity.Provider).Msg("Failed to provision user")
+		return apperrors.NewDatabaseError("Failed to provision user", err)
+	}
+	return nil
+}
+
+func (r *UserIdentityRepositoryImpl) FindIdentity(provider, subject string) (*database.UserIdentity, error) {
+	identity := &database.UserIdentity{}
+	result := r.db.First(identity, "provider = ? AND subject = ?", provider, subject)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("User identity not found", result.Error, "user_identity", provider)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("provider", provider).Msg("Failed to find user identity")
+		return nil, apperrors.NewDatabaseError("Failed to find user identity", result.Error)
+	}
+
+	return identity, nil
+}
+
+func (r *UserIdentityRepositoryImpl) FindIdentitiesByUserID(userID uint) ([]database.UserIdentity, error) {
+	var identities []database.UserIdentity
+	result := r.db.Where("user_id = ?", userID).Order("created_at").Find(&identities)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to find user identities")
+		return nil, apperrors.NewDatabaseError("Failed to find user identities", result.Error)
+	}
+	return identities, nil
+}
+
+// RecordIdentityLogin stores the time of a login with the identity and the
+// email the provider reported with it
+func (r *UserIdentityRepositoryImpl) RecordIdentityLogin(id uint, email string) error {
+	result := r.db.Model(&database.UserIdentity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"email":         email,
+			"last_login_at": time.Now(),
+		})
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("id", id).Msg("Failed to update user identity")
+		return apperrors.NewDatabaseError("Failed to update user identity", result.Error)
+	}
+	return nil
+}
+
+func (r *UserIdentityRepositoryImpl) DeleteIdentity(userID, id uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&database.UserIdentity{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Uint("id", id).Msg("Failed to delete user identity")
+		return apperrors.NewDatabaseError("Failed to delete user identity", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NewNotFoundError("User identity not found", nil, "user_identity", id)
+	}
+	return nil
+}
+
+func (r *UserIdentityRepositoryImpl) CreateLoginState(state *database.FederatedLoginState) error {
+	result := r.db.Create(state)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("provider", state.Provider).Msg("Failed to create federated login state")
+		return apperrors.NewDatabaseError("Failed to create federated login state", result.Error)
+	}
+	return nil
+}
+
+// ConsumeLoginState atomically removes an unexpired login state and returns
+// it, so every provider callback is accepted at most once
+func (r *UserIdentityRepositoryImpl) ConsumeLoginState(stateHash string) (*database.FederatedLoginState, error) {
+	record := &database.FederatedLoginState{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(record, "state_hash = ? AND expires_at > ?", stateHash, time.Now()).Error; err != nil {
+			return err
+		}
+		result := tx.Where("state_hash = ?", stateHash).Delete(&database.FederatedLoginState{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("Federated login state not found", err, "federated_login_state", "")
+	}
+	if err != nil {
+		r.log.Error().Err(err).Msg("Failed to consume federated login state")
+		return nil, apperrors.NewDatabaseError("Failed to consume federated login state", err)
+	}
+	return record, nil
+}
+
+// PurgeExpired deletes the state of abandoned logins
+func (r *UserIdentityRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&database.FederatedLoginState{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired federated login states")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired federated login states", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ UserIdentityRepository = (*UserIdentityRepositoryImpl)(nil)
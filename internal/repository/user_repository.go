@@ -12,8 +12,11 @@ import (
 type UserRepository interface {
 	CreateUser(user *database.User) error
 	FindUserByUsername(username string) (*database.User, error)
+	FindUserByEmail(email string) (*database.User, error)
 	FindUserByID(userID uint) (*database.User, error)
 	UpdateUser(user *database.User) error
+	UpdatePasswordHash(userID uint, passwordHash string) error
+	FindPasswordHashes() ([]string, error)
 	DeleteUser(userID uint) error
 	GetAllUsers() ([]database.User, error)
 	LockUser(userID uint, reason string, duration time.Duration) error
@@ -62,6 +65,22 @@ func (r *UserRepositoryImpl) FindUserByUsername(username string) (*database.User
 	return user, nil
 }
 
+func (r *UserRepositoryImpl) FindUserByEmail(email string) (*database.User, error) {
+	user := &database.User{}
+	result := r.db.First(user, "email = ?", email)
+	if result.Error == gorm.ErrRecordNotFound {
+		r.log.Error().Err(result.Error).Str("email", email).Msg("User not found")
+		return &database.User{}, apperrors.NewNotFoundError("User not found", result.Error, "email", email)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("email", email).Msg("Failed to find user")
+		return &database.User{}, apperrors.NewDatabaseError("Failed to find user", result.Error)
+	}
+
+	return user, nil
+}
+
 func (r *UserRepositoryImpl) FindUserByID(userID uint) (*database.User, error) {
 	user := &database.User{}
 	result := r.db.First(user, "id = ?", userID)
@@ -89,10 +108,33 @@ func (r *UserRepositoryImpl) UpdateUser(user *database.User) error {
 	return nil
 }
 
+// UpdatePasswordHash replaces the stored hash of an unchanged password, so
+// unlike a password change it leaves issued tokens valid
+func (r *UserRepositoryImpl) UpdatePasswordHash(userID uint, passwordHash string) error {
+	result := r.db.Model(&database.User{ID: userID}).Update("password", passwordHash)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to update password hash")
+		return apperrors.NewDatabaseError("Failed to update password hash", result.Error)
+	}
+	return nil
+}
+
+// FindPasswordHashes returns the password hashes of every user
+func (r *UserRepositoryImpl) FindPasswordHashes() ([]string, error) {
+	var hashes []string
+	result := r.db.Model(&database.User{}).Pluck("password", &hashes)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to find password hashes")
+		return nil, apperrors.NewDatabaseError("Failed to find password hashes", result.Error)
+	}
+	return hashes, nil
+}
+
 func (r *UserRepositoryImpl) DeleteUser(userID uint) error {
-	result := r.db.Where("id = ?", userID).Updates(&database.User{
-		Status:    database.UserStatusDeleted,
-		DeletedAt: gorm.DeletedAt{Valid: true},
+	result := r.db.Model(&database.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"status":        database.UserStatusDeleted,
+		"deleted_at":    gorm.DeletedAt{Valid: true},
+		"token_version": database.NextTokenVersion(),
 	})
 	if result.Error != nil {
 		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to delete user")
@@ -114,13 +156,18 @@ func (r *UserRepositoryImpl) GetAllUsers() ([]database.User, error) {
 	return users, nil
 }
 
+// LockUser locks the user for the duration. Locks without a positive
+// duration last until the user is unlocked.
 func (r *UserRepositoryImpl) LockUser(userID uint, reason string, duration time.Duration) error {
-	lockedUntil := time.Now().Add(duration)
-	result := r.db.Updates(&database.User{
-		ID:          userID,
-		Status:      database.UserStatusLocked,
-		LockReason:  reason,
-		LockedUntil: lockedUntil,
+	var lockedUntil interface{}
+	if duration > 0 {
+		lockedUntil = time.Now().Add(duration)
+	}
+	result := r.db.Model(&database.User{ID: userID}).Updates(map[string]interface{}{
+		"status":        database.UserStatusLocked,
+		"lock_reason":   reason,
+		"locked_until":  lockedUntil,
+		"token_version": database.NextTokenVersion(),
 	})
 	if result.Error != nil {
 		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to lock user")
@@ -130,10 +177,10 @@ func (r *UserRepositoryImpl) LockUser(userID uint, reason string, duration time.
 }
 
 func (r *UserRepositoryImpl) UnlockUser(userID uint) error {
-	result := r.db.Updates(&database.User{
-		ID:         userID,
-		Status:     database.UserStatusActive,
-		LockReason: "",
+	result := r.db.Model(&database.User{ID: userID}).Updates(map[string]interface{}{
+		"status":       database.UserStatusActive,
+		"lock_reason":  nil,
+		"locked_until": nil,
 	})
 	if result.Error != nil {
 		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to unlock user")
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"gorm.io/gorm"
+)
+
+type WebAuthnRepository interface {
+	CreateCredential(credential *database.WebAuthnCredential) error
+	FindCredentialByCredentialID(credentialID string) (*database.WebAuthnCredential, error)
+	FindCredentialsByUserID(userID uint) ([]database.WebAuthnCredential, error)
+	CountCredentials(userID uint) (int64, error)
+	UpdateCredentialUsage(id uint, previousSignCount, signCount uint32) (bool, error)
+	DeleteCredential(userID, id uint) error
+	CreateChallenge(challenge *database.WebAuthnChallenge) error
+	ConsumeChallenge(challenge, ceremony string) (*database.WebAuthnChallenge, error)
+	PurgeExpired() (int64, error)
+}
+
+type WebAuthnRepositoryImpl struct {
+	db  *gorm.DB
+	log zerolog.Logger
+}
+
+func NewWebAuthnRepository(db *gorm.DB, log zerolog.Logger) *WebAuthnRepositoryImpl {
+	return &WebAuthnRepositoryImpl{
+		db:  db,
+		log: log.With().Str("repository", "WebAuthnRepository").Logger(),
+	}
+}
+
+func (r *WebAuthnRepositoryImpl) CreateCredential(credential *database.WebAuthnCredential) error {
+	result := r.db.Create(credential)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", credential.UserID).Msg("Failed to create WebAuthn credential")
+		return apperrors.NewDatabaseError("Failed to create WebAuthn credential", result.Error)
+	}
+	return nil
+}
+
+func (r *WebAuthnRepositoryImpl) FindCredentialByCredentialID(credentialID string) (*database.WebAuthnCredential, error) {
+	credential := &database.WebAuthnCredential{}
+	result := r.db.First(credential, "credential_id = ?", credentialID)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("WebAuthn credential not found", result.Error, "webauthn_credential", credentialID)
+	}
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to find WebAuthn credential")
+		return nil, apperrors.NewDatabaseError("Failed to find WebAuthn credential", result.Error)
+	}
+
+	return credential, nil
+}
+
+func (r *WebAuthnRepositoryImpl) FindCredentialsByUserID(userID uint) ([]database.WebAuthnCredential, error) {
+	var credentials []database.WebAuthnCredential
+	result := r.db.Where("user_id = ?", userID).Order("created_at").Find(&credentials)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to find WebAuthn credentials")
+		return nil, apperrors.NewDatabaseError("Failed to find WebAuthn credentials", result.Error)
+	}
+	return credentials, nil
+}
+
+func (r *WebAuthnRepositoryImpl) CountCredentials(userID uint) (int64, error) {
+	var count int64
+	result := r.db.Model(&database.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Msg("Failed to count WebAuthn credentials")
+		return 0, apperrors.NewDatabaseError("Failed to count WebAuthn credentials", result.Error)
+	}
+	return count, nil
+}
+
+// UpdateCredentialUsage stores the new sign count of a credential. It reports
+// false when another login changed the count since it was read.
+func (r *WebAuthnRepositoryImpl) UpdateCredentialUsage(id uint, previousSignCount, signCount uint32) (bool, error) {
+	result := r.db.Model(&database.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, previousSignCount).
+		Updates(map[string]interface{}{
+			"sign_count":   signCount,
+			"last_used_at": time.Now(),
+		})
+
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("id", id).Msg("Failed to update WebAuthn credential")
+		return false, apperrors.NewDatabaseError("Failed to update WebAuthn credential", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *WebAuthnRepositoryImpl) DeleteCredential(userID, id uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&database.WebAuthnCredential{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Uint("user_id", userID).Uint("id", id).Msg("Failed to delete WebAuthn credential")
+		return apperrors.NewDatabaseError("Failed to delete WebAuthn credential", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NewNotFoundError("WebAuthn credential not found", nil, "webauthn_credential", id)
+	}
+	return nil
+}
+
+func (r *WebAuthnRepositoryImpl) CreateChallenge(challenge *database.WebAuthnChallenge) error {
+	result := r.db.Create(challenge)
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Str("ceremony", challenge.Ceremony).Msg("Failed to create WebAuthn challenge")
+		return apperrors.NewDatabaseError("Failed to create WebAuthn challenge", result.Error)
+	}
+	return nil
+}
+
+// ConsumeChallenge atomically removes an unexpired challenge of the ceremony
+// and returns it, so every challenge is answered at most once
+func (r *WebAuthnRepositoryImpl) ConsumeChallenge(challenge, ceremony string) (*database.WebAuthnChallenge, error) {
+	record := &database.WebAuthnChallenge{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(record, "challenge = ? AND ceremony = ? AND expires_at > ?", challenge, ceremony, time.Now()).Error; err != nil {
+			return err
+		}
+		result := tx.Where("challenge = ?", challenge).Delete(&database.WebAuthnChallenge{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, apperrors.NewNotFoundError("WebAuthn challenge not found", err, "webauthn_challenge", ceremony)
+	}
+	if err != nil {
+		r.log.Error().Err(err).Str("ceremony", ceremony).Msg("Failed to consume WebAuthn challenge")
+		return nil, apperrors.NewDatabaseError("Failed to consume WebAuthn challenge", err)
+	}
+	return record, nil
+}
+
+// PurgeExpired deletes challenges of abandoned ceremonies
+func (r *WebAuthnRepositoryImpl) PurgeExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&database.WebAuthnChallenge{})
+	if result.Error != nil {
+		r.log.Error().Err(result.Error).Msg("Failed to purge expired WebAuthn challenges")
+		return 0, apperrors.NewDatabaseError("Failed to purge expired WebAuthn challenges", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+var _ WebAuthnRepository = (*WebAuthnRepositoryImpl)(nil)
@@ -0,0 +1,262 @@
+// internal/services/api_key_service.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+)
+
+const (
+	// DefaultAPIKeyMaxLifetime bounds how far in the future keys may expire
+	DefaultAPIKeyMaxLifetime = 365 * 24 * time.Hour
+	// apiKeyMarker starts every key, so leaked keys are easy to scan for
+	apiKeyMarker = "uma_"
+	// apiKeyPrefixSize is the number of random bytes in the public part of a key
+	apiKeyPrefixSize = 6
+	// maxAPIKeysPerUser caps the usable keys a user can hold
+	maxAPIKeysPerUser = 50
+	// apiKeyUseInterval limits how often the last use of a key is written
+	apiKeyUseInterval = time.Minute
+)
+
+// APIKeyScopes are the scopes keys can be created with, the same permissions
+// first-party access tokens carry
+var APIKeyScopes = []string{ScopeRead, ScopeWrite}
+
+// APIKeyRequest describes a key to create. Without ExpiresAt the key expires
+// after the maximum lifetime.
+type APIKeyRequest struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// APIKeyServiceImpl issues API keys that let machine clients act as the user
+// who created them, and authenticates requests made with them
+type APIKeyServiceImpl struct {
+	logger                zerolog.Logger
+	repo                  repository.UserRepository
+	keys                  repository.APIKeyRepository
+	authenticationManager *authentication.AuthenticationManagerImpl
+	maxLifetime           time.Duration
+}
+
+// APIKeyServiceOption customizes an APIKeyServiceImpl
+type APIKeyServiceOption func(*APIKeyServiceImpl)
+
+// WithAPIKeyMaxLifetime bounds the lifetime of new keys. Zero lets keys
+// never expire.
+func WithAPIKeyMaxLifetime(maxLifetime time.Duration) APIKeyServiceOption {
+	return func(s *APIKeyServiceImpl) {
+		s.maxLifetime = maxLifetime
+	}
+}
+
+func NewAPIKeyService(repo repository.UserRepository,
+	keys repository.APIKeyRepository,
+	authenticationManager *authentication.AuthenticationManagerImpl,
+	logger zerolog.Logger,
+	opts ...APIKeyServiceOption) *APIKeyServiceImpl {
+	s := &APIKeyServiceImpl{
+		logger:                logger.With().Str("service", "APIKeyService").Logger(),
+		repo:                  repo,
+		keys:                  keys,
+		authenticationManager: authenticationManager,
+		maxLifetime:           DefaultAPIKeyMaxLifetime,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// CreateKey creates a key for the user. The key itself is only returned
+// here; afterwards only its prefix is known.
+func (s *APIKeyServiceImpl) CreateKey(ctx context.Context, userID uint, req APIKeyRequest) (*database.APIKey, string, apperrors.AppError) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", apperrors.NewValidationErrors("API key name is required", nil)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, "", apperrors.NewValidationErrors("At least one scope is required", nil)
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(APIKeyScopes, scope) {
+			return nil, "", apperrors.NewValidationErrors("Unsupported scope: "+scope, nil)
+		}
+	}
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	now := time.Now()
+	expiresAt := req.ExpiresAt
+	if expiresAt == nil && s.maxLifetime > 0 {
+		defaultExpiry := now.Add(s.maxLifetime)
+		expiresAt = &defaultExpiry
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, "", apperrors.NewValidationErrors("API key expiry must be in the future", nil)
+	}
+	if expiresAt != nil && s.maxLifetime > 0 && expiresAt.After(now.Add(s.maxLifetime)) {
+		return nil, "", apperrors.NewValidationErrors("API key expiry exceeds the maximum lifetime of "+s.maxLifetime.String(), nil)
+	}
+
+	user, appErr := s.findUser(userID)
+	if appErr != nil {
+		return nil, "", appErr
+	}
+	count, err := s.keys.CountUsableKeys(userID)
+	if err != nil {
+		return nil, "", apperrors.NewInternalError("Failed to count API keys", err)
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, "", apperrors.NewValidationErrors("Too many API keys, revoke one first", nil)
+	}
+
+	prefix, secret, appErr := randomAPIKey()
+	if appErr != nil {
+		return nil, "", appErr
+	}
+	plaintext := apiKeyMarker + prefix + "_" + secret
+	key := &database.APIKey{
+		UserID:       userID,
+		Name:         name,
+		Prefix:       prefix,
+		KeyHash:      hashOAuthSecret(plaintext),
+		Scopes:       strings.Join(scopes, " "),
+		ExpiresAt:    expiresAt,
+		TokenVersion: user.TokenVersion,
+	}
+	if err := s.keys.CreateKey(key); err != nil {
+		return nil, "", apperrors.NewInternalError("Failed to create API key", err)
+	}
+
+	s.logger.Info().
+		Uint("user_id", userID).
+		Uint("api_key_id", key.ID).
+		Str("prefix", prefix).
+		Strs("scopes", scopes).
+		Msg("API key created")
+
+	return key, plaintext, nil
+}
+
+// ListKeys lists the user's keys, including revoked and expired ones until
+// they are purged
+func (s *APIKeyServiceImpl) ListKeys(ctx context.Context, userID uint) ([]database.APIKey, apperrors.AppError) {
+	keys, err := s.keys.FindKeysByUserID(userID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to list API keys", err)
+	}
+	return keys, nil
+}
+
+// RevokeKey revokes one of the user's keys
+func (s *APIKeyServiceImpl) RevokeKey(ctx context.Context, userID, id uint) apperrors.AppError {
+	err := s.keys.RevokeKey(userID, id)
+	if isNotFound(err) {
+		return apperrors.NewNotFoundError("API key not found", err, "api_key", id)
+	}
+	if err != nil {
+		return apperrors.NewInternalError("Failed to revoke API key", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Uint("api_key_id", id).Msg("API key revoked")
+	return nil
+}
+
+// AuthenticateAPIKey returns the key and the user a request made with it
+// acts as. Keys stop working while the user could not log in, and for good
+// once a password change, an administrator's lock or a deletion invalidated
+// the user's tokens. Lockouts after failed logins leave keys working.
+func (s *APIKeyServiceImpl) AuthenticateAPIKey(ctx context.Context, plaintext, ip string) (*database.APIKey, *database.User, apperrors.AppError) {
+	invalid := apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidAPIKey, "Invalid API key", nil)
+
+	prefix, ok := parseAPIKey(plaintext)
+	if !ok {
+		return nil, nil, invalid
+	}
+	key, err := s.keys.FindKeyByPrefix(prefix)
+	if isNotFound(err) {
+		return nil, nil, invalid
+	}
+	if err != nil {
+		return nil, nil, apperrors.NewInternalError("Failed to find API key", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashOAuthSecret(plaintext))) != 1 {
+		return nil, nil, invalid
+	}
+
+	now := time.Now()
+	if !key.Usable(now) {
+		s.logger.Warn().Uint("user_id", key.UserID).Uint("api_key_id", key.ID).Str("ip", ip).Msg("Revoked or expired API key used")
+		return nil, nil, invalid
+	}
+
+	user, appErr := s.findUser(key.UserID)
+	if appErr != nil {
+		return nil, nil, invalid
+	}
+	if appErr := s.authenticationManager.CheckUserStatus(user); appErr != nil {
+		return nil, nil, appErr
+	}
+	if user.TokenInvalidated(key.TokenVersion) {
+		s.logger.Warn().Uint("user_id", key.UserID).Uint("api_key_id", key.ID).Msg("API key created before the user's tokens were invalidated")
+		return nil, nil, apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidAPIKey, "API key has been invalidated", nil)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyUseInterval || key.LastUsedIP != ip {
+		if err := s.keys.RecordKeyUse(key.ID, ip); err != nil {
+			s.logger.Warn().Err(err).Uint("api_key_id", key.ID).Msg("Failed to record API key use")
+		}
+	}
+	return key, user, nil
+}
+
+func (s *APIKeyServiceImpl) findUser(userID uint) (*database.User, apperrors.AppError) {
+	user, err := s.repo.FindUserByID(userID)
+	if isNotFound(err) {
+		return nil, apperrors.NewNotFoundError("User not found", err, "user", userID)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find user", err)
+	}
+	return user, nil
+}
+
+// randomAPIKey generates the public prefix and the secret of a key
+func randomAPIKey() (string, string, apperrors.AppError) {
+	random := make([]byte, apiKeyPrefixSize)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", apperrors.NewInternalError("Failed to generate API key", err)
+	}
+	secret, appErr := randomOAuthSecret()
+	if appErr != nil {
+		return "", "", appErr
+	}
+	return hex.EncodeToString(random), secret, nil
+}
+
+// parseAPIKey returns the prefix of a well-formed key
+func parseAPIKey(plaintext string) (string, bool) {
+	rest, ok := strings.CutPrefix(plaintext, apiKeyMarker)
+	prefixLength := hex.EncodedLen(apiKeyPrefixSize)
+	if !ok || len(rest) <= prefixLength+1 || rest[prefixLength] != '_' {
+		return "", false
+	}
+	return rest[:prefixLength], true
+}
@@ -0,0 +1,127 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	s := newTestServices(t, nil)
+	authManager := authentication.NewAuthenticationManager(s.userRepo, s.tokenManager,
+		repository.NewLoginAttemptRepository(s.db, zerolog.Nop()), zerolog.Nop())
+	apiKeys := services.NewAPIKeyService(s.userRepo, repository.NewAPIKeyRepository(s.db, zerolog.Nop()), authManager, zerolog.Nop(),
+		services.WithAPIKeyMaxLifetime(30*24*time.Hour))
+	ctx := context.Background()
+
+	user, err := s.auth.RegisterUser(ctx, "batchuser", testPassword, "batch@example.com")
+	require.NoError(t, err)
+
+	// Scopes and expiry are validated
+	_, _, appErr := apiKeys.CreateKey(ctx, user.ID, services.APIKeyRequest{Name: "job", Scopes: []string{"admin"}})
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeValidationError, appErr.Code())
+	past := time.Now().Add(-time.Hour)
+	_, _, appErr = apiKeys.CreateKey(ctx, user.ID, services.APIKeyRequest{Name: "job", Scopes: []string{"read"}, ExpiresAt: &past})
+	require.Error(t, appErr)
+	tooLate := time.Now().Add(60 * 24 * time.Hour)
+	_, _, appErr = apiKeys.CreateKey(ctx, user.ID, services.APIKeyRequest{Name: "job", Scopes: []string{"read"}, ExpiresAt: &tooLate})
+	require.Error(t, appErr)
+
+	// Keys default to the maximum lifetime and are only returned once
+	key, plaintext, appErr := apiKeys.CreateKey(ctx, user.ID, services.APIKeyRequest{Name: "nightly export", Scopes: []string{"read", "read"}})
+	require.NoError(t, appErr)
+	assert.True(t, strings.HasPrefix(plaintext, "uma_"+key.Prefix+"_"))
+	assert.Equal(t, "read", key.Scopes)
+	require.NotNil(t, key.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), *key.ExpiresAt, time.Minute)
+
+	authenticated, owner, appErr := apiKeys.AuthenticateAPIKey(ctx, plaintext, "10.0.0.1")
+	require.NoError(t, appErr)
+	assert.Equal(t, key.ID, authenticated.ID)
+	assert.Equal(t, user.ID, owner.ID)
+
+	keys, appErr := apiKeys.ListKeys(ctx, user.ID)
+	require.NoError(t, appErr)
+	require.Len(t, keys, 1)
+	require.NotNil(t, keys[0].LastUsedAt)
+	assert.Equal(t, "10.0.0.1", keys[0].LastUsedIP)
+
+	// A key with the right prefix but another secret is rejected
+	_, _, appErr = apiKeys.AuthenticateAPIKey(ctx, "uma_"+key.Prefix+"_forged", "10.0.0.1")
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidAPIKey, appErr.Code())
+	_, _, appErr = apiKeys.AuthenticateAPIKey(ctx, "not-a-key", "10.0.0.1")
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidAPIKey, appErr.Code())
+
+	// Keys are only revoked by their owner
+	other, err := s.auth.RegisterUser(ctx, "otheruser", testPassword, "other@example.com")
+	require.NoError(t, err)
+	appErr = apiKeys.RevokeKey(ctx, other.ID, key.ID)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeNotFound, appErr.Code())
+
+	require.NoError(t, apiKeys.RevokeKey(ctx, user.ID, key.ID))
+	_, _, appErr = apiKeys.AuthenticateAPIKey(ctx, plaintext, "10.0.0.1")
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidAPIKey, appErr.Code())
+	appErr = apiKeys.RevokeKey(ctx, user.ID, key.ID)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeNotFound, appErr.Code())
+
+	// Keys stop working once the user is locked
+	_, plaintext, appErr = apiKeys.CreateKey(ctx, user.ID, services.APIKeyRequest{Name: "sync", Scopes: []string{"read", "write"}})
+	require.NoError(t, appErr)
+	require.NoError(t, s.userRepo.LockUser(user.ID, "compromised", time.Hour))
+	_, _, appErr = apiKeys.AuthenticateAPIKey(ctx, plaintext, "10.0.0.1")
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeUserLocked, appErr.Code())
+}
+
+func TestAPIKeysSurviveLoginLockouts(t *testing.T) {
+	s := newTestServices(t, nil)
+	authManager := authentication.NewAuthenticationManager(s.userRepo, s.tokenManager,
+		repository.NewLoginAttemptRepository(s.db, zerolog.Nop()), zerolog.Nop())
+	apiKeys := services.NewAPIKeyService(s.userRepo, repository.NewAPIKeyRepository(s.db, zerolog.Nop()), authManager, zerolog.Nop())
+	ctx := context.Background()
+
+	user, err := s.auth.RegisterUser(ctx, "cronuser", testPassword, "cron@example.com")
+	require.NoError(t, err)
+	_, plaintext, appErr := apiKeys.CreateKey(ctx, user.ID, services.APIKeyRequest{Name: "cron", Scopes: []string{"read"}})
+	require.NoError(t, appErr)
+
+	// Guessing the password locks out the guesser, not the batch job
+	guesser := testDevice
+	guesser.IP = "203.0.113.7"
+	for range 6 {
+		_, _, appErr = s.auth.LoginUser(ctx, "cronuser", "Wr0ng!Password", guesser)
+		require.Error(t, appErr)
+	}
+	_, _, appErr = apiKeys.AuthenticateAPIKey(ctx, plaintext, "10.0.0.1")
+	require.NoError(t, appErr)
+
+	// A password change does invalidate the keys made with the old password
+	user, err = s.userRepo.FindUserByID(user.ID)
+	require.NoError(t, err)
+	user.InvalidateTokens()
+	require.NoError(t, s.userRepo.UpdateUser(user))
+	_, _, appErr = apiKeys.AuthenticateAPIKey(ctx, plaintext, "10.0.0.1")
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidAPIKey, appErr.Code())
+
+	_, plaintext, appErr = apiKeys.CreateKey(ctx, user.ID, services.APIKeyRequest{Name: "cron", Scopes: []string{"read"}})
+	require.NoError(t, appErr)
+	_, _, appErr = apiKeys.AuthenticateAPIKey(ctx, plaintext, "10.0.0.1")
+	require.NoError(t, appErr)
+}
@@ -4,6 +4,7 @@ package services
 import (
 	"context"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/yourusername/user-management-api/internal/database"
 	"github.com/yourusername/user-management-api/internal/repository"
@@ -11,25 +12,107 @@ import (
 	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
 	"github.com/yourusername/user-management-api/pkg/token"
 	"github.com/yourusername/user-management-api/pkg/utils"
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+)
+
+// DeviceMismatchPolicy decides what happens when a refresh token is presented
+// from a different device than the one it was issued to
+type DeviceMismatchPolicy string
+
+const (
+	// DeviceMismatchFlag allows the refresh but logs it and marks the new token
+	DeviceMismatchFlag DeviceMismatchPolicy = "flag"
+	// DeviceMismatchReject refuses the refresh
+	DeviceMismatchReject DeviceMismatchPolicy = "reject"
 )
 
 type AuthServiceImpl struct {
 	logger                zerolog.Logger
 	repo                  repository.UserRepository
+	refreshTokenRepo      repository.RefreshTokenRepository
+	sessions              *SessionServiceImpl
 	tokenManager          token.TokenManager
 	authenticationManager *authentication.AuthenticationManagerImpl
+	deviceMismatchPolicy  DeviceMismatchPolicy
+	emailVerification     *EmailVerificationServiceImpl
+	// mfa, passkeys and oneTimeTokens hold back tokens until the second factor is passed
+	mfa           *MFAServiceImpl
+	passkeys      *WebAuthnServiceImpl
+	oneTimeTokens *OneTimeTokenServiceImpl
+	federation    *FederationServiceImpl
+	magicLinks    *MagicLinkServiceImpl
+}
+
+// AuthServiceOption customizes an AuthServiceImpl
+type AuthServiceOption func(*AuthServiceImpl)
+
+// WithDeviceMismatchPolicy sets how refreshes from a different device are handled
+func WithDeviceMismatchPolicy(policy DeviceMismatchPolicy) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.deviceMismatchPolicy = policy
+	}
+}
+
+// WithEmailVerification mails new users a token to verify their email with
+func WithEmailVerification(emailVerification *EmailVerificationServiceImpl) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.emailVerification = emailVerification
+	}
+}
+
+// WithMFA asks users with a second factor for it before issuing tokens
+func WithMFA(mfa *MFAServiceImpl, oneTimeTokens *OneTimeTokenServiceImpl) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.mfa = mfa
+		s.oneTimeTokens = oneTimeTokens
+	}
+}
+
+// WithPasskeys enables passwordless passkey logins and asks users with a
+// passkey for it as a second factor after the password
+func WithPasskeys(passkeys *WebAuthnServiceImpl, oneTimeTokens *OneTimeTokenServiceImpl) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.passkeys = passkeys
+		s.oneTimeTokens = oneTimeTokens
+	}
+}
+
+// WithFederation enables signing in with identities at external providers
+func WithFederation(federation *FederationServiceImpl) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.federation = federation
+	}
+}
+
+// WithMagicLinks enables logging in through links mailed to the user
+func WithMagicLinks(magicLinks *MagicLinkServiceImpl) AuthServiceOption {
+	return func(s *AuthServiceImpl) {
+		s.magicLinks = magicLinks
+	}
 }
 
 func NewAuthService(tokenManager token.TokenManager,
 	authenticationManager *authentication.AuthenticationManagerImpl,
 	repo repository.UserRepository,
-	logger zerolog.Logger) *AuthServiceImpl {
-	return &AuthServiceImpl{
+	refreshTokenRepo repository.RefreshTokenRepository,
+	sessions *SessionServiceImpl,
+	logger zerolog.Logger,
+	opts ...AuthServiceOption) *AuthServiceImpl {
+	s := &AuthServiceImpl{
 		repo:                  repo,
+		refreshTokenRepo:      refreshTokenRepo,
+		sessions:              sessions,
 		logger:                logger.With().Str("service", "AuthService").Logger(),
 		tokenManager:          tokenManager,
 		authenticationManager: authenticationManager,
+		deviceMismatchPolicy:  DeviceMismatchFlag,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 func (s *AuthServiceImpl) GenerateAccessToken(ctx context.Context, userID uint, username string) (string, apperrors.AppError) {
@@ -40,21 +123,31 @@ func (s *AuthServiceImpl) GenerateRefreshToken(ctx context.Context, userID uint,
 	return s.tokenManager.GenerateToken(userID, username, "refresh")
 }
 
-func (s *AuthServiceImpl) RefreshTokens(ctx context.Context, userID uint, username string) (*database.TokenPair, apperrors.AppError) {
-
-	accessToken, err := s.GenerateAccessToken(ctx, userID, username)
+// RefreshTokens consumes the presented refresh token and issues a new token
+// pair whose refresh token belongs to the same family
+func (s *AuthServiceImpl) RefreshTokens(ctx context.Context, refreshToken string, device token.Device) (*database.TokenPair, apperrors.AppError) {
+	claims, err := s.ValidateRefreshToken(ctx, refreshToken)
 	if err != nil {
-		return &database.TokenPair{}, err
+		return nil, err
 	}
-	refreshToken, err := s.GenerateRefreshToken(ctx, userID, username)
-	if err != nil {
-		return &database.TokenPair{}, err
+
+	mismatch := claims.DeviceFingerprint != "" && claims.DeviceFingerprint != device.Fingerprint()
+	if mismatch {
+		if err := s.handleDeviceMismatch(claims, device); err != nil {
+			return nil, err
+		}
 	}
 
-	return &database.TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	consumed, consumeErr := s.refreshTokenRepo.ConsumeRefreshToken(claims.ID)
+	if consumeErr != nil {
+		return nil, apperrors.NewInternalError("Failed to consume refresh token", consumeErr)
+	}
+	if !consumed {
+		// Another request consumed this token between validation and now
+		return nil, s.handleRefreshTokenReuse(ctx, claims)
+	}
+
+	return s.issueTokenPair(claims.UserID, claims.Username, claims.TokenVersion, device, claims.FamilyID, claims.ID, mismatch)
 }
 
 // TODO: CURRENTLY UNUSED, IMPLEMENT LATER
@@ -78,38 +171,418 @@ func (s *AuthServiceImpl) RefreshTokens(ctx context.Context, userID uint, userna
 // 	return user, nil
 // }
 
-func (s *AuthServiceImpl) ValidateRefreshToken(ctx context.Context, tokenString string) (uint, string, error) {
-	claims, err := s.tokenManager.ValidateToken(tokenString, "refresh")
+// ValidateRefreshToken checks the refresh token signature and its server-side
+// record. Presenting a token that was already rotated revokes its whole family.
+func (s *AuthServiceImpl) ValidateRefreshToken(ctx context.Context, tokenString string) (*token.Claims, apperrors.AppError) {
+	claims, err := s.tokenManager.ValidateToken(tokenString, token.RefreshToken)
 	if err != nil {
-		return 0, "", err
+		return nil, err
+	}
+
+	if claims.TokenType != token.RefreshToken {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Invalid token type", nil)
+	}
+
+	// Impersonation must end when its access token expires
+	if claims.Actor != nil {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidClaim, "Impersonation tokens cannot be refreshed", nil)
+	}
+
+	record, findErr := s.refreshTokenRepo.FindRefreshTokenByJTI(claims.ID)
+	if findErr != nil {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidToken, "Refresh token is not recognized", findErr)
 	}
 
-	if claims.TokenType != "refresh" {
-		return 0, "", apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Invalid token type", nil)
+	if record.ConsumedAt != nil || record.RevokedAt != nil {
+		return nil, s.handleRefreshTokenReuse(ctx, claims)
 	}
-	return claims.UserID, claims.Username, nil
+
+	// Refresh tokens issued before a password change, lock or deletion are no longer valid
+	user, findErr := s.repo.FindUserByID(claims.UserID)
+	if findErr != nil {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidToken, "Refresh token user not found", findErr)
+	}
+	if user.TokenInvalidated(claims.TokenVersion) {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenBlacklisted, "Refresh token has been invalidated", nil)
+	}
+
+	return claims, nil
+}
+
+// handleRefreshTokenReuse ends the session of a replayed refresh token, so
+// neither its refresh nor its access tokens keep working
+func (s *AuthServiceImpl) handleRefreshTokenReuse(ctx context.Context, claims *token.Claims) apperrors.AppError {
+	s.logger.Warn().
+		Str("event", "refresh_token_reuse").
+		Uint("user_id", claims.UserID).
+		Str("username", claims.Username).
+		Str("jti", claims.ID).
+		Str("family_id", claims.FamilyID).
+		Msg("Refresh token reuse detected, ending its session")
+
+	if err := s.sessions.EndCompromisedSession(ctx, claims.UserID, claims.FamilyID); err != nil {
+		return err
+	}
+
+	return apperrors.NewTokenError(apperrors.ErrCodeRefreshTokenReused, "Refresh token has already been used", nil)
+}
+
+// handleDeviceMismatch applies the device mismatch policy to a refresh token
+// presented from a device other than the one it was issued to
+func (s *AuthServiceImpl) handleDeviceMismatch(claims *token.Claims, device token.Device) apperrors.AppError {
+	event := s.logger.Warn().
+		Str("event", "device_mismatch").
+		Uint("user_id", claims.UserID).
+		Str("username", claims.Username).
+		Str("jti", claims.ID).
+		Str("family_id", claims.FamilyID).
+		Str("ip", device.IP).
+		Str("user_agent", device.UserAgent).
+		Str("policy", string(s.deviceMismatchPolicy))
+
+	if s.deviceMismatchPolicy == DeviceMismatchReject {
+		event.Msg("Refresh token presented from a different device, rejecting")
+		return apperrors.NewTokenError(apperrors.ErrCodeDeviceMismatch, "Refresh token was issued to a different device", nil)
+	}
+
+	event.Msg("Refresh token presented from a different device")
+	return nil
 }
 
-func (s *AuthServiceImpl) LoginUser(ctx context.Context, username, password, ipAddr string) (*database.TokenPair, apperrors.AppError) {
+// issueTokenPair mints an access token and a tracked refresh token bound to
+// the device, and records them with the session. An empty familyID starts a
+// new family and session.
+func (s *AuthServiceImpl) issueTokenPair(
+	userID uint,
+	username string,
+	tokenVersion uint,
+	device token.Device,
+	familyID, parentJTI string,
+	deviceMismatch bool,
+) (*database.TokenPair, apperrors.AppError) {
+	newSession := familyID == ""
+	if newSession {
+		familyID = uuid.New().String()
+	}
 
-	// Validate the user's credentials
-	user, err := s.authenticationManager.ValidateUserAuthentication(ctx, username, password, ipAddr)
+	accessToken, accessClaims, err := s.tokenManager.IssueToken(userID, username, token.AccessToken,
+		token.WithFamilyID(familyID), token.WithDevice(device), token.WithTokenVersion(tokenVersion))
 	if err != nil {
 		return nil, err
 	}
 
-	tokens, err := s.RefreshTokens(ctx, user.ID, user.Username)
+	refreshToken, claims, err := s.tokenManager.IssueToken(userID, username, token.RefreshToken,
+		token.WithFamilyID(familyID), token.WithDevice(device), token.WithTokenVersion(tokenVersion))
 	if err != nil {
-		return &database.TokenPair{}, err
+		return nil, err
+	}
+
+	if err := s.refreshTokenRepo.CreateRefreshToken(&database.RefreshToken{
+		JTI:               claims.ID,
+		FamilyID:          familyID,
+		ParentJTI:         parentJTI,
+		UserID:            userID,
+		ExpiresAt:         claims.ExpiresAt.Time,
+		IPAddress:         device.IP,
+		UserAgent:         device.UserAgent,
+		DeviceID:          device.DeviceID,
+		DeviceFingerprint: claims.DeviceFingerprint,
+		DeviceMismatch:    deviceMismatch,
+	}); err != nil {
+		return nil, apperrors.NewInternalError("Failed to store refresh token", err)
 	}
 
-	return tokens, nil
+	if newSession {
+		err = s.sessions.StartSession(device, accessClaims, claims)
+	} else {
+		err = s.sessions.TouchSession(device, accessClaims, claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &database.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
 }
 
-func (s *AuthServiceImpl) LogoutUser(ctx context.Context, token string) error {
-	if err := s.tokenManager.InvalidateToken(token); err != nil {
+// LoginUser checks the user's credentials and issues a token pair. Users with
+// MFA enabled get an MFAChallenge instead, to be completed with VerifyMFA.
+func (s *AuthServiceImpl) LoginUser(ctx context.Context, username, password string, device token.Device) (*database.TokenPair, *database.MFAChallenge, apperrors.AppError) {
+	if s.mfa == nil && s.passkeys == nil {
+		// Validate the user's credentials
+		user, err := s.authenticationManager.ValidateUserAuthentication(ctx, username, password, device.IP)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tokens, err := s.issueTokenPair(user.ID, user.Username, user.TokenVersion, device, "", "", false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tokens, nil, nil
+	}
+
+	// Failed attempts stay on record until the second factor is passed too
+	user, err := s.authenticationManager.ValidatePassword(ctx, username, password, device.IP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.completeFirstFactor(ctx, user, device)
+}
+
+// completeFirstFactor issues a token pair to a user who passed the first
+// factor, or an MFAChallenge if they have a second factor
+func (s *AuthServiceImpl) completeFirstFactor(ctx context.Context, user *database.User, device token.Device) (*database.TokenPair, *database.MFAChallenge, apperrors.AppError) {
+	methods, err := s.secondFactors(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(methods) > 0 {
+		challenge, err := s.oneTimeTokens.IssueToken(ctx, PurposeMFAChallenge, user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &database.MFAChallenge{
+			MFARequired:    true,
+			ChallengeToken: challenge,
+			Methods:        methods,
+			ExpiresIn:      int64(s.oneTimeTokens.ttl(PurposeMFAChallenge).Seconds()),
+		}, nil
+	}
+
+	s.authenticationManager.ResetLoginAttempts(user.Username, device.IP)
+	tokens, err := s.issueTokenPair(user.ID, user.Username, user.TokenVersion, device, "", "", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, nil, nil
+}
+
+// secondFactors lists the second factors the user can complete a login with
+func (s *AuthServiceImpl) secondFactors(userID uint) ([]string, apperrors.AppError) {
+	var methods []string
+	if s.mfa != nil {
+		enabled, err := s.mfa.TOTPEnabled(userID)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			methods = append(methods, "totp", "recovery_code")
+		}
+	}
+	if s.passkeys != nil {
+		registered, err := s.passkeys.HasCredentials(userID)
+		if err != nil {
+			return nil, err
+		}
+		if registered {
+			methods = append(methods, "webauthn")
+		}
+	}
+	return methods, nil
+}
+
+// VerifyMFA completes a login that returned an MFAChallenge with a TOTP or
+// recovery code
+func (s *AuthServiceImpl) VerifyMFA(ctx context.Context, challengeToken, code string, device token.Device) (*database.TokenPair, apperrors.AppError) {
+	if s.mfa == nil {
+		return nil, apperrors.NewValidationErrors("Multi-factor authentication is not enabled", nil)
+	}
+
+	return s.completeMFA(ctx, challengeToken, device, func(user *database.User) (bool, apperrors.AppError) {
+		return s.mfa.VerifyCode(ctx, user.ID, code)
+	})
+}
+
+// BeginPasskeyMFA returns the options to sign an MFAChallenge with a passkey
+func (s *AuthServiceImpl) BeginPasskeyMFA(ctx context.Context, challengeToken string) (*webauthn.RequestOptions, apperrors.AppError) {
+	if s.passkeys == nil {
+		return nil, apperrors.NewValidationErrors("Passkeys are not enabled", nil)
+	}
+
+	user, err := s.oneTimeTokens.PeekToken(ctx, PurposeMFAChallenge, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.passkeys.BeginSecondFactor(ctx, user.ID)
+}
+
+// VerifyMFAWithPasskey completes a login that returned an MFAChallenge with
+// a passkey assertion
+func (s *AuthServiceImpl) VerifyMFAWithPasskey(ctx context.Context, challengeToken string, response *webauthn.AssertionCredential, device token.Device) (*database.TokenPair, apperrors.AppError) {
+	if s.passkeys == nil {
+		return nil, apperrors.NewValidationErrors("Passkeys are not enabled", nil)
+	}
+
+	return s.completeMFA(ctx, challengeToken, device, func(user *database.User) (bool, apperrors.AppError) {
+		return s.passkeys.VerifySecondFactor(ctx, user.ID, response)
+	})
+}
+
+// completeMFA checks the second factor of an MFAChallenge. Failures count as
+// failed login attempts; the challenge is used up once the factor is valid.
+func (s *AuthServiceImpl) completeMFA(
+	ctx context.Context,
+	challengeToken string,
+	device token.Device,
+	verify func(user *database.User) (bool, apperrors.AppError),
+) (*database.TokenPair, apperrors.AppError) {
+	user, err := s.oneTimeTokens.PeekToken(ctx, PurposeMFAChallenge, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authenticationManager.ValidateSecondFactor(user, device.IP, func() (bool, apperrors.AppError) {
+		return verify(user)
+	}); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.oneTimeTokens.ConsumeToken(ctx, PurposeMFAChallenge, challengeToken); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Msg("Second factor verified")
+	return s.issueTokenPair(user.ID, user.Username, user.TokenVersion, device, "", "", false)
+}
+
+// BeginPasskeyLogin returns the options for a passwordless login. Without a
+// username, or with an unknown one, the authenticator offers its
+// discoverable credentials, so the response does not reveal which accounts
+// exist.
+func (s *AuthServiceImpl) BeginPasskeyLogin(ctx context.Context, username string) (*webauthn.RequestOptions, apperrors.AppError) {
+	if s.passkeys == nil {
+		return nil, apperrors.NewValidationErrors("Passkeys are not enabled", nil)
+	}
+
+	var userID uint
+	if username != "" {
+		user, err := s.repo.FindUserByUsername(username)
+		if err != nil && !isNotFound(err) {
+			return nil, apperrors.NewInternalError("Failed to find user", err)
+		}
+		if err == nil {
+			userID = user.ID
+		}
+	}
+	return s.passkeys.BeginLogin(ctx, userID)
+}
+
+// LoginWithPasskey completes a passwordless login and issues a token pair.
+// The passkey verified the user itself, so no second factor is asked for.
+func (s *AuthServiceImpl) LoginWithPasskey(ctx context.Context, response *webauthn.AssertionCredential, device token.Device) (*database.TokenPair, apperrors.AppError) {
+	if s.passkeys == nil {
+		return nil, apperrors.NewValidationErrors("Passkeys are not enabled", nil)
+	}
+
+	user, err := s.passkeys.FinishLogin(ctx, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authenticationManager.CheckUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Msg("User logged in with a passkey")
+	return s.issueTokenPair(user.ID, user.Username, user.TokenVersion, device, "", "", false)
+}
+
+// LoginWithProvider completes a login at an external provider and issues a
+// token pair. The provider authenticated the user, so no second factor is
+// asked for. Callbacks that linked an identity to a signed in user return no
+// tokens.
+func (s *AuthServiceImpl) LoginWithProvider(ctx context.Context, provider, state, code string, device token.Device) (*database.TokenPair, *FederatedLogin, apperrors.AppError) {
+	if s.federation == nil {
+		return nil, nil, apperrors.NewValidationErrors("Federated login is not enabled", nil)
+	}
+
+	login, err := s.federation.FinishLogin(ctx, provider, state, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if login.Linked {
+		return nil, login, nil
+	}
+
+	// Provisioned users whose provider did not vouch for their email verify it like new registrations
+	if login.Provisioned && login.User.Status == database.UserStatusPendingVerification && s.emailVerification != nil {
+		if err := s.emailVerification.SendVerification(ctx, login.User); err != nil {
+			s.logger.Err(err).Uint("user_id", login.User.ID).Msg("Failed to send verification email after provisioning")
+		}
+	}
+
+	if err := s.authenticationManager.CheckUserStatus(login.User); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.issueTokenPair(login.User.ID, login.User.Username, login.User.TokenVersion, device, "", "", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, login, nil
+}
+
+// RequestMagicLink mails a login link to the user with the email address
+func (s *AuthServiceImpl) RequestMagicLink(ctx context.Context, email, ip string) apperrors.AppError {
+	if s.magicLinks == nil {
+		return apperrors.NewValidationErrors("Magic links are not enabled", nil)
+	}
+	return s.magicLinks.RequestMagicLink(ctx, email, ip)
+}
+
+// LoginWithMagicLink redeems a magic link and issues a token pair. The link
+// stands in for the password only, so users with a second factor get an
+// MFAChallenge like LoginUser returns.
+func (s *AuthServiceImpl) LoginWithMagicLink(ctx context.Context, tokenString string, device token.Device) (*database.TokenPair, *database.MFAChallenge, apperrors.AppError) {
+	if s.magicLinks == nil {
+		return nil, nil, apperrors.NewValidationErrors("Magic links are not enabled", nil)
+	}
+
+	user, err := s.magicLinks.RedeemMagicLink(ctx, tokenString, device.IP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Msg("Magic link redeemed")
+	return s.completeFirstFactor(ctx, user, device)
+}
+
+// LogoutUser revokes the access token and the refresh token family of its
+// session for their remaining lifetimes. With allSessions every session of
+// the user is logged out.
+func (s *AuthServiceImpl) LogoutUser(ctx context.Context, accessToken string, allSessions bool) error {
+	claims, err := s.tokenManager.ValidateToken(accessToken, token.AccessToken)
+	if err != nil {
 		return err
 	}
+
+	if err := s.tokenManager.RevokeClaims(claims); err != nil {
+		return err
+	}
+
+	// Ending an impersonation must not log the impersonated user out
+	if claims.Actor != nil {
+		return nil
+	}
+
+	if allSessions {
+		revoked, err := s.sessions.RevokeAllSessions(ctx, claims.UserID)
+		if err != nil {
+			return err
+		}
+		s.logger.Info().Uint("user_id", claims.UserID).Int("sessions", revoked).Msg("User logged out of all sessions")
+		return nil
+	}
+
+	if claims.FamilyID != "" {
+		if err := s.sessions.EndSession(ctx, claims.UserID, claims.FamilyID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -117,10 +590,12 @@ func (s *AuthServiceImpl) RegisterUser(ctx context.Context, username, password,
 	_, cancel := utils.GetContextWithTimeout()
 	defer cancel()
 
+	// New users stay pending until they prove they own the email address
 	user := &database.User{
 		Username: username,
 		Password: password,
 		Email:    email,
+		Status:   database.UserStatusPendingVerification,
 	}
 
 	if err := user.HashPassword(); err != nil {
@@ -131,5 +606,12 @@ func (s *AuthServiceImpl) RegisterUser(ctx context.Context, username, password,
 		return &database.User{}, err
 	}
 
+	if s.emailVerification != nil {
+		// The account exists either way, the user can ask for another email
+		if err := s.emailVerification.SendVerification(ctx, user); err != nil {
+			s.logger.Err(err).Uint("user_id", user.ID).Msg("Failed to send verification email after registration")
+		}
+	}
+
 	return user, nil
 }
@@ -0,0 +1,514 @@
+package services_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/database/sqlite-gorm"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/passwordhash"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const testPassword = "StrongP@ssw0rd2024!"
+
+var testDevice = token.Device{IP: "127.0.0.1", UserAgent: "test-agent/1.0", DeviceID: "device-1"}
+
+// newTestDatabase opens a file-backed database with a single connection so
+// concurrent tests are serialized by the pool instead of failing on locks
+func newTestDatabase(t *testing.T) *gorm.DB {
+	db, err := sqlite.InitializeDatabase(sqlite.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "test.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Minute,
+	})
+	require.NoError(t, err)
+	return db
+}
+
+func newTestAuthService(t *testing.T, opts ...services.AuthServiceOption) *services.AuthServiceImpl {
+	return newTestServices(t, nil, opts...).auth
+}
+
+// testServices is an auth service wired to a session service that shares its
+// token manager's revocation store
+type testServices struct {
+	auth         *services.AuthServiceImpl
+	sessions     *services.SessionServiceImpl
+	tokenManager token.TokenManager
+	userRepo     repository.UserRepository
+	db           *gorm.DB
+}
+
+func newTestServices(t *testing.T, sessionOpts []services.SessionServiceOption, opts ...services.AuthServiceOption) testServices {
+	return newTestServicesWithTokens(t, func(db *gorm.DB, revocations token.RevocationStore) token.TokenManager {
+		return token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations)
+	}, sessionOpts, opts...)
+}
+
+// newTestServicesWithTokens is newTestServices with a custom token format
+func newTestServicesWithTokens(
+	t *testing.T,
+	newTokenManager func(db *gorm.DB, revocations token.RevocationStore) token.TokenManager,
+	sessionOpts []services.SessionServiceOption,
+	opts ...services.AuthServiceOption,
+) testServices {
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	tokenManager := newTokenManager(db, revocations)
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, loginAttemptRepo, zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, revocations, zerolog.Nop(), sessionOpts...)
+	return testServices{
+		auth:         services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop(), opts...),
+		sessions:     sessionService,
+		tokenManager: tokenManager,
+		userRepo:     userRepo,
+		db:           db,
+	}
+}
+
+func loginTestUser(t *testing.T, authService *services.AuthServiceImpl, username string) string {
+	ctx := context.Background()
+	_, err := authService.RegisterUser(ctx, username, testPassword, username+"@example.com")
+	require.NoError(t, err)
+
+	tokens, _, appErr := authService.LoginUser(ctx, username, testPassword, testDevice)
+	require.NoError(t, appErr)
+	return tokens.RefreshToken
+}
+
+func TestRefreshFlowWithEveryTokenFormat(t *testing.T) {
+	formats := map[string]func(db *gorm.DB, revocations token.RevocationStore) token.TokenManager{
+		"paseto-local": func(db *gorm.DB, revocations token.RevocationStore) token.TokenManager {
+			return token.NewPasetoTokenManager(token.NewPasetoLocalKeyFromSecret("secret_key"),
+				token.NewPasetoLocalKeyFromSecret("refresh_secret_key"), token.DefaultTokenPolicy(), revocations)
+		},
+		"opaque": func(db *gorm.DB, revocations token.RevocationStore) token.TokenManager {
+			return token.NewOpaqueTokenManager(repository.NewOpaqueTokenRepository(db, zerolog.Nop()), token.DefaultTokenPolicy(), revocations)
+		},
+	}
+
+	for name, newTokenManager := range formats {
+		t.Run(name, func(t *testing.T) {
+			ts := newTestServicesWithTokens(t, newTokenManager, nil)
+			ctx := context.Background()
+			refreshToken := loginTestUser(t, ts.auth, "formatuser")
+
+			rotated, err := ts.auth.RefreshTokens(ctx, refreshToken, testDevice)
+			require.NoError(t, err)
+			claims, err := ts.tokenManager.ValidateToken(rotated.AccessToken, token.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, "formatuser", claims.Username)
+
+			// Reuse detection works the same for every format, and cuts off the family's access tokens
+			_, err = ts.auth.RefreshTokens(ctx, refreshToken, testDevice)
+			require.Error(t, err)
+			assert.Equal(t, apperrors.ErrCodeRefreshTokenReused, err.Code())
+			_, err = ts.tokenManager.ValidateToken(rotated.AccessToken, token.AccessToken)
+			assert.Error(t, err)
+
+			fresh, _, err := ts.auth.LoginUser(ctx, "formatuser", testPassword, testDevice)
+			require.NoError(t, err)
+			require.NoError(t, ts.auth.LogoutUser(ctx, fresh.AccessToken, false))
+			_, err = ts.tokenManager.ValidateToken(fresh.AccessToken, token.AccessToken)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	authService := newTestAuthService(t)
+	ctx := context.Background()
+	refreshToken := loginTestUser(t, authService, "rotationuser")
+
+	oldClaims, err := authService.ValidateRefreshToken(ctx, refreshToken)
+	require.NoError(t, err)
+
+	rotated, err := authService.RefreshTokens(ctx, refreshToken, testDevice)
+	require.NoError(t, err)
+	assert.NotEqual(t, refreshToken, rotated.RefreshToken)
+
+	newClaims, err := authService.ValidateRefreshToken(ctx, rotated.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, oldClaims.FamilyID, newClaims.FamilyID)
+
+	// The successor can be rotated again
+	_, err = authService.RefreshTokens(ctx, rotated.RefreshToken, testDevice)
+	require.NoError(t, err)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	loginTestUser(t, ts.auth, "reuseuser")
+	first, _, err := ts.auth.LoginUser(ctx, "reuseuser", testPassword, testDevice)
+	require.NoError(t, err)
+	claims, err := ts.tokenManager.ValidateToken(first.AccessToken, token.AccessToken)
+	require.NoError(t, err)
+
+	rotated, err := ts.auth.RefreshTokens(ctx, first.RefreshToken, testDevice)
+	require.NoError(t, err)
+
+	// Replaying the consumed token is detected
+	_, err = ts.auth.RefreshTokens(ctx, first.RefreshToken, testDevice)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeRefreshTokenReused, err.Code())
+
+	// ... and takes down the legitimate successor too
+	_, err = ts.auth.RefreshTokens(ctx, rotated.RefreshToken, testDevice)
+	assert.Error(t, err)
+
+	// Access tokens minted before the replay stop working and the session ends
+	for _, accessToken := range []string{first.AccessToken, rotated.AccessToken} {
+		_, err = ts.tokenManager.ValidateToken(accessToken, token.AccessToken)
+		require.Error(t, err)
+		assert.Equal(t, apperrors.ErrCodeTokenBlacklisted, err.Code())
+	}
+	sessions, err := ts.sessions.ListSessions(ctx, claims.UserID)
+	require.NoError(t, err)
+	for _, session := range sessions {
+		assert.NotEqual(t, claims.FamilyID, session.ID)
+	}
+}
+
+func TestConcurrentRefreshAllowsSingleWinner(t *testing.T) {
+	authService := newTestAuthService(t)
+	ctx := context.Background()
+	refreshToken := loginTestUser(t, authService, "raceuser")
+
+	const workers = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes []string
+		reused    int
+	)
+
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			tokens, err := authService.RefreshTokens(ctx, refreshToken, testDevice)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				successes = append(successes, tokens.RefreshToken)
+				return
+			}
+			// Losers after the first find the family already revoked
+			if err.Code() == apperrors.ErrCodeRefreshTokenReused || err.Code() == apperrors.ErrCodeTokenBlacklisted {
+				reused++
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	require.Len(t, successes, 1)
+	assert.Equal(t, workers-1, reused)
+
+	// The losing requests look like a replay, so the family is revoked
+	_, err := authService.RefreshTokens(ctx, successes[0], testDevice)
+	assert.Error(t, err)
+}
+
+func TestRefreshFromOtherDeviceIsRejected(t *testing.T) {
+	authService := newTestAuthService(t, services.WithDeviceMismatchPolicy(services.DeviceMismatchReject))
+	ctx := context.Background()
+	refreshToken := loginTestUser(t, authService, "rejectdeviceuser")
+
+	otherDevice := testDevice
+	otherDevice.UserAgent = "curl/8.0"
+	_, err := authService.RefreshTokens(ctx, refreshToken, otherDevice)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeDeviceMismatch, err.Code())
+
+	// The rejected attempt does not consume the token
+	_, err = authService.RefreshTokens(ctx, refreshToken, testDevice)
+	assert.NoError(t, err)
+}
+
+func TestRefreshFromOtherDeviceIsFlagged(t *testing.T) {
+	authService := newTestAuthService(t, services.WithDeviceMismatchPolicy(services.DeviceMismatchFlag))
+	ctx := context.Background()
+	refreshToken := loginTestUser(t, authService, "flagdeviceuser")
+
+	claims, err := authService.ValidateRefreshToken(ctx, refreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, testDevice.Fingerprint(), claims.DeviceFingerprint)
+
+	otherDevice := token.Device{IP: "203.0.113.7", UserAgent: "curl/8.0"}
+	rotated, err := authService.RefreshTokens(ctx, refreshToken, otherDevice)
+	require.NoError(t, err)
+
+	// The new tokens are bound to the device that refreshed them
+	claims, err = authService.ValidateRefreshToken(ctx, rotated.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, otherDevice.Fingerprint(), claims.DeviceFingerprint)
+}
+
+func TestLogoutRevokesAccessAndRefreshToken(t *testing.T) {
+	ts := newTestServices(t, nil)
+	authService, tokenManager := ts.auth, ts.tokenManager
+	ctx := context.Background()
+	refreshToken := loginTestUser(t, authService, "logoutuser")
+
+	tokens, err := authService.RefreshTokens(ctx, refreshToken, testDevice)
+	require.NoError(t, err)
+
+	require.NoError(t, authService.LogoutUser(ctx, tokens.AccessToken, false))
+
+	_, err = tokenManager.ValidateToken(tokens.AccessToken, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenBlacklisted, err.Code())
+	_, err = authService.RefreshTokens(ctx, tokens.RefreshToken, testDevice)
+	assert.Error(t, err)
+}
+
+func TestLogoutAllSessions(t *testing.T) {
+	ts := newTestServices(t, nil)
+	authService, sessionService, tokenManager := ts.auth, ts.sessions, ts.tokenManager
+	ctx := context.Background()
+	loginTestUser(t, authService, "logoutalluser")
+
+	other, _, err := authService.LoginUser(ctx, "logoutalluser", testPassword, testDevice)
+	require.NoError(t, err)
+	current, _, err := authService.LoginUser(ctx, "logoutalluser", testPassword, testDevice)
+	require.NoError(t, err)
+	claims, err := tokenManager.ValidateToken(current.AccessToken, token.AccessToken)
+	require.NoError(t, err)
+
+	require.NoError(t, authService.LogoutUser(ctx, current.AccessToken, true))
+
+	// Tokens of the other sessions are revoked as well
+	_, err = tokenManager.ValidateToken(other.AccessToken, token.AccessToken)
+	assert.Error(t, err)
+	_, err = authService.RefreshTokens(ctx, other.RefreshToken, testDevice)
+	assert.Error(t, err)
+	_, err = tokenManager.ValidateToken(other.RefreshToken, token.RefreshToken)
+	assert.Error(t, err)
+
+	sessions, err := sessionService.ListSessions(ctx, claims.UserID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestLockInvalidatesOutstandingTokens(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	refreshToken := loginTestUser(t, ts.auth, "lockeduser")
+
+	claims, err := ts.auth.ValidateRefreshToken(ctx, refreshToken)
+	require.NoError(t, err)
+	require.NoError(t, ts.userRepo.LockUser(claims.UserID, "test", time.Hour))
+
+	_, err = ts.auth.RefreshTokens(ctx, refreshToken, testDevice)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenBlacklisted, err.Code())
+}
+
+func TestLoginLockoutPolicy(t *testing.T) {
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	tokenManager := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations)
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, revocations, zerolog.Nop())
+	newAuthService := func(policy authentication.LockoutPolicy) *services.AuthServiceImpl {
+		authManager := authentication.NewAuthenticationManager(userRepo, tokenManager, loginAttemptRepo, zerolog.Nop(),
+			authentication.WithLockoutPolicy(policy))
+		return services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop())
+	}
+	// expireLock dates the latest failed attempt back
+	expireLock := func(username string, lastAttemptAgo time.Duration) {
+		require.NoError(t, db.Model(&database.LoginAttempt{}).Where("username = ?", username).
+			Update("last_attempt", time.Now().Add(-lastAttemptAgo)).Error)
+	}
+	assertLockedUntil := func(appErr apperrors.AppError, expected time.Time) {
+		require.Error(t, appErr)
+		locked, ok := appErr.(apperrors.AccountLockedError)
+		require.True(t, ok, "expected a locked account, got %v", appErr)
+		assert.WithinDuration(t, expected, locked.LockedUntil(), 5*time.Second)
+	}
+	ctx := context.Background()
+
+	auth := newAuthService(authentication.LockoutPolicy{
+		MaxAttempts:   3,
+		Window:        24 * time.Hour,
+		Duration:      10 * time.Minute,
+		BackoffFactor: 2,
+	})
+	_, err := auth.RegisterUser(ctx, "guesseduser", testPassword, "guessed@example.com")
+	require.NoError(t, err)
+
+	for range 2 {
+		_, _, appErr := auth.LoginUser(ctx, "guesseduser", "Wr0ng!Password", testDevice)
+		require.Error(t, appErr)
+		assert.Equal(t, apperrors.ErrCodeInvalidCredentials, appErr.Code())
+	}
+	_, _, appErr := auth.LoginUser(ctx, "guesseduser", "Wr0ng!Password", testDevice)
+	assertLockedUntil(appErr, time.Now().Add(10*time.Minute))
+
+	// The lock is checked before the password, so even the right one is refused
+	_, _, appErr = auth.LoginUser(ctx, "guesseduser", testPassword, testDevice)
+	assertLockedUntil(appErr, time.Now().Add(10*time.Minute))
+
+	// Once the lock ended, every further failure locks for longer
+	expireLock("guesseduser", 11*time.Minute)
+	_, _, appErr = auth.LoginUser(ctx, "guesseduser", "Wr0ng!Password", testDevice)
+	assertLockedUntil(appErr, time.Now().Add(20*time.Minute))
+
+	// Attempts outside the window are forgotten
+	expireLock("guesseduser", 25*time.Hour)
+	_, _, appErr = auth.LoginUser(ctx, "guesseduser", "Wr0ng!Password", testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidCredentials, appErr.Code())
+	_, _, appErr = auth.LoginUser(ctx, "guesseduser", testPassword, testDevice)
+	require.NoError(t, appErr)
+
+	// Permanent locks stay until an administrator lifts them
+	auth = newAuthService(authentication.LockoutPolicy{MaxAttempts: 1, Permanent: true})
+	_, _, appErr = auth.LoginUser(ctx, "guesseduser", "Wr0ng!Password", testDevice)
+	assertLockedUntil(appErr, time.Time{})
+	_, _, appErr = auth.LoginUser(ctx, "guesseduser", testPassword, testDevice)
+	assertLockedUntil(appErr, time.Time{})
+
+	user, err := userRepo.FindUserByUsername("guesseduser")
+	require.NoError(t, err)
+	userService := services.NewUserService(userRepo, loginAttemptRepo, zerolog.Nop())
+	require.NoError(t, userService.UnlockUser(user.ID))
+	_, _, appErr = auth.LoginUser(ctx, "guesseduser", testPassword, testDevice)
+	require.NoError(t, appErr)
+}
+
+func TestLoginLockoutOnlyAffectsTheSourceIP(t *testing.T) {
+	svc := newTestServices(t, nil)
+	ctx := context.Background()
+	refreshToken := loginTestUser(t, svc.auth, "targeteduser")
+	attacker := token.Device{IP: "203.0.113.7", UserAgent: "attacker"}
+
+	// Anyone who knows the username can exhaust the attempts from their address
+	for range 5 {
+		_, _, appErr := svc.auth.LoginUser(ctx, "targeteduser", "Wr0ng!Password", attacker)
+		require.Error(t, appErr)
+	}
+	_, _, appErr := svc.auth.LoginUser(ctx, "targeteduser", testPassword, attacker)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeUserLocked, appErr.Code())
+
+	// but the account is not locked, and its owner keeps their sessions and can still sign in
+	user, err := svc.userRepo.FindUserByUsername("targeteduser")
+	require.NoError(t, err)
+	assert.NotEqual(t, database.UserStatusLocked, user.Status)
+	_, appErr = svc.auth.RefreshTokens(ctx, refreshToken, testDevice)
+	require.NoError(t, appErr)
+	_, _, appErr = svc.auth.LoginUser(ctx, "targeteduser", testPassword, testDevice)
+	require.NoError(t, appErr)
+}
+
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestServices(t, nil)
+	user, err := svc.auth.RegisterUser(ctx, "rehashuser", testPassword, "rehashuser@example.com")
+	require.NoError(t, err)
+	assert.False(t, user.PasswordNeedsRehash())
+
+	// Users registered before argon2id have bcrypt hashes
+	legacy, err := passwordhash.NewBcryptHasher(bcrypt.MinCost).Hash(testPassword)
+	require.NoError(t, err)
+	require.NoError(t, svc.userRepo.UpdatePasswordHash(user.ID, legacy))
+
+	// which still work, and are upgraded by the login
+	_, _, appErr := svc.auth.LoginUser(ctx, "rehashuser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	stored, err := svc.userRepo.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$"), stored.Password)
+	assert.False(t, stored.PasswordNeedsRehash())
+
+	_, _, appErr = svc.auth.LoginUser(ctx, "rehashuser", testPassword, testDevice)
+	require.NoError(t, appErr)
+
+	// Wrong passwords never touch the hash
+	require.NoError(t, svc.userRepo.UpdatePasswordHash(user.ID, legacy))
+	_, _, appErr = svc.auth.LoginUser(ctx, "rehashuser", "Wr0ng!Password", testDevice)
+	require.Error(t, appErr)
+	stored, err = svc.userRepo.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, legacy, stored.Password)
+}
+
+func TestLoginRepeppersPasswordHash(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestServices(t, nil)
+	userService := services.NewUserService(svc.userRepo, repository.NewLoginAttemptRepository(svc.db, zerolog.Nop()), zerolog.Nop())
+
+	inner := passwordhash.NewArgon2idHasher(passwordhash.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	peppers := map[string][]byte{
+		"1": []byte("first-pepper-0123456789abcdefghij"),
+		"2": []byte("second-pepper-0123456789abcdefghi"),
+	}
+	setPepper := func(keyID string) {
+		hasher, err := passwordhash.NewPepperedHasher(inner, peppers, keyID)
+		require.NoError(t, err)
+		passwordhash.SetDefault(hasher)
+	}
+	previous := passwordhash.Default()
+	t.Cleanup(func() { passwordhash.SetDefault(previous) })
+
+	// One user registered before peppering, two under the first pepper
+	legacy, err := svc.auth.RegisterUser(ctx, "legacyuser", testPassword, "legacyuser@example.com")
+	require.NoError(t, err)
+	setPepper("1")
+	user, err := svc.auth.RegisterUser(ctx, "pepperuser", testPassword, "pepperuser@example.com")
+	require.NoError(t, err)
+	_, err = svc.auth.RegisterUser(ctx, "otheruser", testPassword, "otheruser@example.com")
+	require.NoError(t, err)
+
+	// After rotating, hashes under the old pepper still log in and are re-peppered
+	setPepper("2")
+	report, err := userService.PepperReport("2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"": 1, "1": 2}, report.Hashes)
+	assert.Equal(t, int64(3), report.Outdated)
+
+	_, _, appErr := svc.auth.LoginUser(ctx, "pepperuser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	_, _, appErr = svc.auth.LoginUser(ctx, "legacyuser", testPassword, testDevice)
+	require.NoError(t, appErr)
+
+	stored, err := svc.userRepo.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "2", passwordhash.PepperKeyID(stored.Password))
+	stored, err = svc.userRepo.FindUserByID(legacy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "2", passwordhash.PepperKeyID(stored.Password))
+
+	report, err = userService.PepperReport("2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"1": 1, "2": 2}, report.Hashes)
+	assert.Equal(t, int64(1), report.Outdated)
+}
@@ -0,0 +1,149 @@
+// internal/services/email_verification_service.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/mailer"
+)
+
+// EmailVerificationServiceImpl proves that users own the email address they
+// registered with by mailing them a one-time token
+type EmailVerificationServiceImpl struct {
+	logger          zerolog.Logger
+	repo            repository.UserRepository
+	oneTimeTokens   *OneTimeTokenServiceImpl
+	mailer          mailer.Mailer
+	verificationURL string
+}
+
+// EmailVerificationServiceOption customizes an EmailVerificationServiceImpl
+type EmailVerificationServiceOption func(*EmailVerificationServiceImpl)
+
+// WithVerificationURL sets the page verification emails link to. The token is
+// appended as the token query parameter.
+func WithVerificationURL(verificationURL string) EmailVerificationServiceOption {
+	return func(s *EmailVerificationServiceImpl) {
+		s.verificationURL = verificationURL
+	}
+}
+
+func NewEmailVerificationService(oneTimeTokens *OneTimeTokenServiceImpl,
+	mailer mailer.Mailer,
+	repo repository.UserRepository,
+	logger zerolog.Logger,
+	opts ...EmailVerificationServiceOption) *EmailVerificationServiceImpl {
+	s := &EmailVerificationServiceImpl{
+		logger:        logger.With().Str("service", "EmailVerificationService").Logger(),
+		repo:          repo,
+		oneTimeTokens: oneTimeTokens,
+		mailer:        mailer,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// SendVerification mails a verification token to a user awaiting verification
+func (s *EmailVerificationServiceImpl) SendVerification(ctx context.Context, user *database.User) apperrors.AppError {
+	if user.Status != database.UserStatusPendingVerification {
+		return apperrors.NewValidationErrors("Email address is already verified", nil)
+	}
+
+	tokenString, err := s.oneTimeTokens.IssueToken(ctx, PurposeEmailVerify, user)
+	if err != nil {
+		return err
+	}
+
+	if sendErr := s.mailer.Send(ctx, s.verificationMessage(user, tokenString)); sendErr != nil {
+		s.logger.Error().Err(sendErr).Uint("user_id", user.ID).Msg("Failed to send verification email")
+		return apperrors.NewInternalError("Failed to send verification email", sendErr)
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Msg("Verification email sent")
+	return nil
+}
+
+// VerifyEmail redeems a verification token and activates its user
+func (s *EmailVerificationServiceImpl) VerifyEmail(ctx context.Context, tokenString string) (*database.User, apperrors.AppError) {
+	user, err := s.oneTimeTokens.ConsumeToken(ctx, PurposeEmailVerify, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	update := &database.User{ID: user.ID, EmailVerifiedAt: &now}
+	// Users locked or deleted in the meantime keep their status
+	if user.Status == database.UserStatusPendingVerification {
+		update.Status = database.UserStatusActive
+	}
+	if updateErr := s.repo.UpdateUser(update); updateErr != nil {
+		return nil, apperrors.NewInternalError("Failed to mark email as verified", updateErr)
+	}
+	user.EmailVerifiedAt = update.EmailVerifiedAt
+	if update.Status != "" {
+		user.Status = update.Status
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Str("username", user.Username).Msg("Email verified")
+	return user, nil
+}
+
+// ResendVerification mails a new verification token to the user registered
+// with the email address. Unknown and verified addresses are silently ignored
+// so the endpoint does not reveal which addresses are registered.
+func (s *EmailVerificationServiceImpl) ResendVerification(ctx context.Context, email string) apperrors.AppError {
+	user, err := s.repo.FindUserByEmail(email)
+	if isNotFound(err) {
+		s.logger.Debug().Msg("Verification requested for unknown email address")
+		return nil
+	}
+	if err != nil {
+		return apperrors.NewInternalError("Failed to find user", err)
+	}
+	if user.Status != database.UserStatusPendingVerification {
+		s.logger.Debug().Uint("user_id", user.ID).Msg("Verification requested for verified user")
+		return nil
+	}
+
+	return s.SendVerification(ctx, user)
+}
+
+func (s *EmailVerificationServiceImpl) verificationMessage(user *database.User, tokenString string) mailer.Message {
+	body := fmt.Sprintf("Hello %s,\n\nPlease confirm your email address", user.Username)
+	if s.verificationURL != "" {
+		body += fmt.Sprintf(" by opening the link below:\n\n%s\n", withQueryParam(s.verificationURL, "token", tokenString))
+	} else {
+		body += fmt.Sprintf(" with this verification code:\n\n%s\n", tokenString)
+	}
+	body += fmt.Sprintf("\nIt expires in %s. If you did not create an account, you can ignore this email.\n",
+		s.oneTimeTokens.ttl(PurposeEmailVerify))
+
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    body,
+	}
+}
+
+// withQueryParam adds a query parameter to a URL, keeping any it already has
+func withQueryParam(rawURL, key, value string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	query.Set(key, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
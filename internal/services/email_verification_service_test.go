@@ -0,0 +1,93 @@
+package services_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/mailer"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+var verificationLink = regexp.MustCompile(`https://app\.example\.com/verify\S+`)
+
+// verificationToken extracts the token from the latest verification email
+func verificationToken(t *testing.T, mail *mailer.MemoryMailer, to string) string {
+	message, ok := mail.LastMessageTo(to)
+	require.True(t, ok, "no email sent to %s", to)
+	link, err := url.Parse(verificationLink.FindString(message.Body))
+	require.NoError(t, err)
+	tokenString := link.Query().Get("token")
+	require.NotEmpty(t, tokenString)
+	return tokenString
+}
+
+func TestEmailVerificationFlow(t *testing.T) {
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	tokenManager := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations)
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager,
+		repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop(),
+		authentication.WithEmailVerificationRequired(true))
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, revocations, zerolog.Nop())
+
+	mail := mailer.NewMemoryMailer()
+	oneTimeTokens := services.NewOneTimeTokenService(userRepo, repository.NewOneTimeTokenRepository(db, zerolog.Nop()), zerolog.Nop())
+	emailVerification := services.NewEmailVerificationService(oneTimeTokens, mail, userRepo, zerolog.Nop(),
+		services.WithVerificationURL("https://app.example.com/verify?lang=en"))
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop(),
+		services.WithEmailVerification(emailVerification))
+	ctx := context.Background()
+
+	user, err := authService.RegisterUser(ctx, "verifyuser", testPassword, "verify@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, database.UserStatusPendingVerification, user.Status)
+	first := verificationToken(t, mail, "verify@example.com")
+
+	// Unverified users cannot log in
+	_, _, appErr := authService.LoginUser(ctx, "verifyuser", testPassword, testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeEmailNotVerified, appErr.Code())
+
+	// Resending voids the earlier token, and unknown addresses are ignored
+	require.NoError(t, emailVerification.ResendVerification(ctx, "verify@example.com"))
+	require.NoError(t, emailVerification.ResendVerification(ctx, "nobody@example.com"))
+	assert.Len(t, mail.Messages(), 2)
+	second := verificationToken(t, mail, "verify@example.com")
+
+	_, appErr = emailVerification.VerifyEmail(ctx, first)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeTokenAlreadyUsed, appErr.Code())
+
+	verified, appErr := emailVerification.VerifyEmail(ctx, second)
+	require.NoError(t, appErr)
+	assert.Equal(t, database.UserStatusActive, verified.Status)
+
+	stored, err := userRepo.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, database.UserStatusActive, stored.Status)
+	assert.NotNil(t, stored.EmailVerifiedAt)
+
+	_, appErr = emailVerification.VerifyEmail(ctx, second)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeTokenAlreadyUsed, appErr.Code())
+
+	// Verified users are not sent further emails
+	require.NoError(t, emailVerification.ResendVerification(ctx, "verify@example.com"))
+	assert.Len(t, mail.Messages(), 2)
+
+	_, _, appErr = authService.LoginUser(ctx, "verifyuser", testPassword, testDevice)
+	require.NoError(t, appErr)
+}
@@ -0,0 +1,339 @@
+// internal/services/federation_service.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/oidc"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const (
+	// FederatedLoginTTL is how long a user has to sign in at the provider
+	FederatedLoginTTL = 10 * time.Minute
+	// maxDerivedUsernameLength leaves room for a suffix when a username is taken
+	maxDerivedUsernameLength = 40
+	// usernameAttempts is how many suffixed usernames are tried when provisioning
+	usernameAttempts = 5
+)
+
+// FederatedLoginStart is where to send the user to sign in at the provider.
+// State has to come back with the provider's callback.
+type FederatedLoginStart struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"-"`
+}
+
+// FederatedLogin is the outcome of a provider callback. Linked is set when
+// the identity was linked to a signed in user rather than used to sign in;
+// Provisioned when the user was created for the identity.
+type FederatedLogin struct {
+	User        *database.User         `json:"-"`
+	Identity    *database.UserIdentity `json:"identity"`
+	Linked      bool                   `json:"linked"`
+	Provisioned bool                   `json:"provisioned,omitempty"`
+}
+
+// FederationServiceImpl signs users in with accounts at external OpenID
+// Connect providers. Unknown identities are provisioned a user on first
+// login; signed in users can link further identities to their account.
+type FederationServiceImpl struct {
+	logger     zerolog.Logger
+	repo       repository.UserRepository
+	identities repository.UserIdentityRepository
+	providers  map[string]*oidc.Provider
+}
+
+func NewFederationService(providers map[string]*oidc.Provider,
+	repo repository.UserRepository,
+	identities repository.UserIdentityRepository,
+	logger zerolog.Logger) *FederationServiceImpl {
+	return &FederationServiceImpl{
+		logger:     logger.With().Str("service", "FederationService").Logger(),
+		repo:       repo,
+		identities: identities,
+		providers:  providers,
+	}
+}
+
+// Providers lists the names of the configured providers
+func (s *FederationServiceImpl) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// BeginLogin starts signing in at the provider. A non-zero userID links the
+// identity to that user instead of signing in with it.
+func (s *FederationServiceImpl) BeginLogin(ctx context.Context, providerName string, userID uint) (*FederatedLoginStart, apperrors.AppError) {
+	provider, appErr := s.provider(providerName)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	state, appErr := randomOAuthSecret()
+	if appErr != nil {
+		return nil, appErr
+	}
+	nonce, appErr := randomOAuthSecret()
+	if appErr != nil {
+		return nil, appErr
+	}
+	codeVerifier, appErr := randomOAuthSecret()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	authorizationURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		s.logger.Error().Err(err).Str("provider", providerName).Msg("Failed to discover identity provider")
+		return nil, apperrors.New(apperrors.ErrCodeIdentityProviderError, "Identity provider is unavailable", err)
+	}
+
+	if err := s.identities.CreateLoginState(&database.FederatedLoginState{
+		StateHash:    hashOAuthSecret(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		UserID:       userID,
+		ExpiresAt:    time.Now().Add(FederatedLoginTTL),
+	}); err != nil {
+		return nil, apperrors.NewInternalError("Failed to start federated login", err)
+	}
+
+	return &FederatedLoginStart{AuthorizationURL: authorizationURL, State: state}, nil
+}
+
+// FinishLogin completes the login started with state once the provider
+// redirected back with code. The state is used up whatever the outcome.
+func (s *FederationServiceImpl) FinishLogin(ctx context.Context, providerName, state, code string) (*FederatedLogin, apperrors.AppError) {
+	provider, appErr := s.provider(providerName)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	record, err := s.identities.ConsumeLoginState(hashOAuthSecret(state))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "Federated login expired or was already completed", nil)
+		}
+		return nil, apperrors.NewInternalError("Failed to find federated login", err)
+	}
+	if record.Provider != providerName {
+		return nil, apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "Federated login was started with another provider", nil)
+	}
+
+	claims, err := provider.Authenticate(ctx, code, record.CodeVerifier, record.Nonce)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("provider", providerName).Msg("Federated login failed")
+		return nil, providerError(err)
+	}
+
+	identity, err := s.identities.FindIdentity(providerName, claims.Subject)
+	if err != nil && !isNotFound(err) {
+		return nil, apperrors.NewInternalError("Failed to find user identity", err)
+	}
+
+	if record.UserID != 0 {
+		return s.link(record.UserID, providerName, claims, identity)
+	}
+	if identity == nil {
+		return s.provision(providerName, claims)
+	}
+
+	user, err := s.repo.FindUserByID(identity.UserID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find user", err)
+	}
+	if err := s.identities.RecordIdentityLogin(identity.ID, claims.Email); err != nil {
+		return nil, apperrors.NewInternalError("Failed to update user identity", err)
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Str("provider", providerName).Msg("User signed in with identity provider")
+	return &FederatedLogin{User: user, Identity: identity}, nil
+}
+
+// ListIdentities lists the identities linked to the user
+func (s *FederationServiceImpl) ListIdentities(ctx context.Context, userID uint) ([]database.UserIdentity, apperrors.AppError) {
+	identities, err := s.identities.FindIdentitiesByUserID(userID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to list user identities", err)
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity removes one of the user's identities. Provisioned users keep
+// their account and can set a password through the password reset flow.
+func (s *FederationServiceImpl) UnlinkIdentity(ctx context.Context, userID, id uint) apperrors.AppError {
+	if err := s.identities.DeleteIdentity(userID, id); err != nil {
+		if isNotFound(err) {
+			return apperrors.NewNotFoundError("User identity not found", err, "user_identity", id)
+		}
+		return apperrors.NewInternalError("Failed to unlink user identity", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Uint("identity", id).Msg("User identity unlinked")
+	return nil
+}
+
+// link attaches the identity to the user who started linking it. An identity
+// already linked to another user stays with that user.
+func (s *FederationServiceImpl) link(userID uint, providerName string, claims *token.IDTokenClaims, identity *database.UserIdentity) (*FederatedLogin, apperrors.AppError) {
+	user, err := s.repo.FindUserByID(userID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find user", err)
+	}
+
+	if identity != nil {
+		if identity.UserID != userID {
+			s.logger.Warn().Uint("user_id", userID).Str("provider", providerName).Msg("Identity is linked to another user")
+			return nil, apperrors.New(apperrors.ErrCodeIdentityAlreadyLinked, "Identity is linked to another account", nil)
+		}
+		return &FederatedLogin{User: user, Identity: identity, Linked: true}, nil
+	}
+
+	identity = &database.UserIdentity{
+		UserID:   userID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	if err := s.identities.CreateIdentity(identity); err != nil {
+		return nil, apperrors.NewInternalError("Failed to link user identity", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Str("provider", providerName).Msg("User identity linked")
+	return &FederatedLogin{User: user, Identity: identity, Linked: true}, nil
+}
+
+// provision creates a user for an identity signing in for the first time. An
+// existing account with the same email is not taken over: its owner has to
+// sign in and link the identity themselves.
+func (s *FederationServiceImpl) provision(providerName string, claims *token.IDTokenClaims) (*FederatedLogin, apperrors.AppError) {
+	if claims.Email == "" {
+		return nil, apperrors.NewValidationErrors("Identity provider did not share an email address", nil)
+	}
+	if _, err := s.repo.FindUserByEmail(claims.Email); err == nil {
+		return nil, apperrors.New(apperrors.ErrCodeAccountExists, "An account with this email already exists, sign in to link the identity", nil)
+	} else if !isNotFound(err) {
+		return nil, apperrors.NewInternalError("Failed to find user", err)
+	}
+
+	username, appErr := s.availableUsername(claims)
+	if appErr != nil {
+		return nil, appErr
+	}
+	// The password is never disclosed, so the user signs in through the
+	// provider until they set one with a password reset
+	password, appErr := randomOAuthSecret()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	user := &database.User{
+		Username: username,
+		Password: password,
+		Email:    claims.Email,
+		Status:   database.UserStatusPendingVerification,
+	}
+	if claims.EmailVerified != nil && *claims.EmailVerified {
+		now := time.Now()
+		user.Status = database.UserStatusActive
+		user.EmailVerifiedAt = &now
+	}
+	if err := user.HashPassword(); err != nil {
+		return nil, apperrors.NewInternalError("Failed to hash password", err)
+	}
+
+	now := time.Now()
+	identity := &database.UserIdentity{
+		Provider:    providerName,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: &now,
+	}
+	if err := s.identities.CreateUserWithIdentity(user, identity); err != nil {
+		return nil, apperrors.NewInternalError("Failed to provision user", err)
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Str("provider", providerName).Msg("User provisioned from identity provider")
+	return &FederatedLogin{User: user, Identity: identity, Provisioned: true}, nil
+}
+
+// availableUsername derives a username from the provider's claims, adding a
+// random suffix when it is taken
+func (s *FederationServiceImpl) availableUsername(claims *token.IDTokenClaims) (string, apperrors.AppError) {
+	base := sanitizeUsername(claims.PreferredUsername)
+	if base == "" {
+		base = sanitizeUsername(claims.Email)
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	candidate := base
+	for range usernameAttempts {
+		_, err := s.repo.FindUserByUsername(candidate)
+		if isNotFound(err) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", apperrors.NewInternalError("Failed to find user", err)
+		}
+
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", apperrors.NewInternalError("Failed to generate username", err)
+		}
+		candidate = base + "-" + hex.EncodeToString(suffix)
+	}
+	return "", apperrors.NewInternalError("Failed to find an available username", nil)
+}
+
+func (s *FederationServiceImpl) provider(name string) (*oidc.Provider, apperrors.AppError) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, apperrors.NewNotFoundError("Identity provider not found", nil, "identity_provider", name)
+	}
+	return provider, nil
+}
+
+// sanitizeUsername keeps the local part of an email-like name and the
+// characters usernames are made of
+func sanitizeUsername(name string) string {
+	name, _, _ = strings.Cut(name, "@")
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+	username := b.String()
+	if len(username) > maxDerivedUsernameLength {
+		username = username[:maxDerivedUsernameLength]
+	}
+	return username
+}
+
+// providerError separates logins the provider or its ID token refused from
+// providers that could not be reached
+func providerError(err error) apperrors.AppError {
+	var oauthErr *oidc.Error
+	if errors.As(err, &oauthErr) || errors.Is(err, oidc.ErrInvalidIDToken) {
+		return apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "Sign-in at the identity provider failed", err)
+	}
+	return apperrors.New(apperrors.ErrCodeIdentityProviderError, "Identity provider is unavailable", err)
+}
@@ -0,0 +1,152 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/oidc"
+	"github.com/yourusername/user-management-api/pkg/oidc/oidctest"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const federatedCallback = "https://id.example.com/api/v1/auth/federated/corp/callback"
+
+// signInAt runs a federated login up to the provider's redirect back and
+// returns the state and code to complete it with
+func signInAt(t *testing.T, federation *services.FederationServiceImpl, idp *oidctest.Provider, userID uint) (string, string) {
+	start, appErr := federation.BeginLogin(context.Background(), "corp", userID)
+	require.NoError(t, appErr)
+	callback, err := idp.Authorize(start.AuthorizationURL)
+	require.NoError(t, err)
+	require.Equal(t, start.State, callback.Query().Get("state"))
+	return start.State, callback.Query().Get("code")
+}
+
+func TestFederatedLogin(t *testing.T) {
+	idp, err := oidctest.NewProvider()
+	require.NoError(t, err)
+	defer idp.Close()
+	idp.RegisterClient("user-management-api", "secret", federatedCallback)
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "user-management-api",
+		ClientSecret: "secret",
+		RedirectURL:  federatedCallback,
+		Scopes:       []string{"openid", "email", "profile"},
+	}, nil)
+	require.NoError(t, err)
+
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	tokenManager := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations)
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager,
+		repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, revocations, zerolog.Nop())
+	federation := services.NewFederationService(map[string]*oidc.Provider{"corp": provider}, userRepo,
+		repository.NewUserIdentityRepository(db, zerolog.Nop()), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop(),
+		services.WithFederation(federation))
+	ctx := context.Background()
+
+	assert.Equal(t, []string{"corp"}, federation.Providers())
+	_, appErr := federation.BeginLogin(ctx, "unknown", 0)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeNotFound, appErr.Code())
+
+	// The first login provisions a user
+	idp.User = oidctest.User{Subject: "corp-1", Email: "jane@corp.example.com", EmailVerified: true, PreferredUsername: "jane@corp.example.com"}
+	state, code := signInAt(t, federation, idp, 0)
+	tokens, login, appErr := authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.NoError(t, appErr)
+	require.NotNil(t, tokens)
+	assert.True(t, login.Provisioned)
+	assert.Equal(t, "jane", login.User.Username)
+	assert.Equal(t, database.UserStatusActive, login.User.Status)
+	assert.NotNil(t, login.User.EmailVerifiedAt)
+	claims, appErr := tokenManager.ValidateToken(tokens.AccessToken, token.AccessToken)
+	require.NoError(t, appErr)
+	assert.Equal(t, login.User.ID, claims.UserID)
+
+	// The state cannot be used twice
+	_, _, appErr = authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidCredentials, appErr.Code())
+
+	// Later logins sign in to the same user
+	state, code = signInAt(t, federation, idp, 0)
+	_, again, appErr := authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.NoError(t, appErr)
+	assert.False(t, again.Provisioned)
+	assert.Equal(t, login.User.ID, again.User.ID)
+
+	// A provider login whose ID token does not match the request fails
+	idp.ModifyIDToken = func(claims *token.IDTokenClaims) { claims.Nonce = "replayed" }
+	state, code = signInAt(t, federation, idp, 0)
+	_, _, appErr = authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidCredentials, appErr.Code())
+	idp.ModifyIDToken = nil
+
+	// Existing accounts are not taken over by an identity with their email
+	local, err := authService.RegisterUser(ctx, "bob", testPassword, "bob@corp.example.com")
+	require.NoError(t, err)
+	idp.User = oidctest.User{Subject: "corp-2", Email: "bob@corp.example.com", EmailVerified: true}
+	state, code = signInAt(t, federation, idp, 0)
+	_, _, appErr = authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeAccountExists, appErr.Code())
+
+	// but their owner can link it
+	state, code = signInAt(t, federation, idp, local.ID)
+	tokens, linked, appErr := authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.NoError(t, appErr)
+	assert.Nil(t, tokens)
+	assert.True(t, linked.Linked)
+	assert.Equal(t, local.ID, linked.Identity.UserID)
+
+	state, code = signInAt(t, federation, idp, 0)
+	_, bobLogin, appErr := authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.NoError(t, appErr)
+	assert.Equal(t, local.ID, bobLogin.User.ID)
+
+	// An identity stays with the user it is linked to
+	state, code = signInAt(t, federation, idp, login.User.ID)
+	_, _, appErr = authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeIdentityAlreadyLinked, appErr.Code())
+
+	identities, appErr := federation.ListIdentities(ctx, local.ID)
+	require.NoError(t, appErr)
+	require.Len(t, identities, 1)
+	assert.Equal(t, "corp-2", identities[0].Subject)
+
+	// Unlinking only removes the user's own identities
+	appErr = federation.UnlinkIdentity(ctx, login.User.ID, identities[0].ID)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeNotFound, appErr.Code())
+	require.NoError(t, federation.UnlinkIdentity(ctx, local.ID, identities[0].ID))
+	identities, appErr = federation.ListIdentities(ctx, local.ID)
+	require.NoError(t, appErr)
+	assert.Empty(t, identities)
+
+	// Taken usernames get a suffix and unverified emails stay pending
+	idp.User = oidctest.User{Subject: "corp-3", Email: "jane@other.example.com", PreferredUsername: "jane"}
+	state, code = signInAt(t, federation, idp, 0)
+	_, other, appErr := authService.LoginWithProvider(ctx, "corp", state, code, testDevice)
+	require.NoError(t, appErr)
+	assert.NotEqual(t, "jane", other.User.Username)
+	assert.Contains(t, other.User.Username, "jane-")
+	assert.Equal(t, database.UserStatusPendingVerification, other.User.Status)
+}
@@ -0,0 +1,60 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func TestImpersonation(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	loginTestUser(t, ts.auth, "impersonator")
+	loginTestUser(t, ts.auth, "impersonated")
+
+	admin, err := ts.userRepo.FindUserByUsername("impersonator")
+	require.NoError(t, err)
+	admin.Role = database.UserRoleAdmin
+	require.NoError(t, ts.userRepo.UpdateUser(admin))
+	target, err := ts.userRepo.FindUserByUsername("impersonated")
+	require.NoError(t, err)
+
+	authManager := authentication.NewAuthenticationManager(ts.userRepo, ts.tokenManager, nil, zerolog.Nop())
+	impersonation := services.NewImpersonationService(ts.tokenManager, authManager, ts.userRepo, zerolog.Nop(),
+		services.WithImpersonationTTL(time.Minute))
+
+	accessToken, claims, appErr := impersonation.Impersonate(ctx, admin.ID, target.ID)
+	require.NoError(t, appErr)
+	assert.Equal(t, target.ID, claims.UserID)
+	assert.Equal(t, &token.Actor{UserID: admin.ID, Username: "impersonator"}, claims.Actor)
+	assert.LessOrEqual(t, claims.ExpiresAt.Sub(claims.IssuedAt.Time), time.Minute)
+
+	validated, appErr := ts.tokenManager.ValidateToken(accessToken, token.AccessToken)
+	require.NoError(t, appErr)
+	assert.Equal(t, "impersonator", validated.Actor.Username)
+
+	// Neither the admin nor anyone else with the permission can be impersonated
+	_, _, appErr = impersonation.Impersonate(ctx, admin.ID, admin.ID)
+	assert.Error(t, appErr)
+	_, _, appErr = impersonation.Impersonate(ctx, target.ID, admin.ID)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeUnauthorized, appErr.Code())
+
+	// Refresh tokens carrying an actor are never honoured
+	refreshToken, appErr := ts.tokenManager.GenerateToken(target.ID, target.Username, token.RefreshToken,
+		token.WithActor(token.Actor{UserID: admin.ID, Username: admin.Username}))
+	require.NoError(t, appErr)
+	_, appErr = ts.auth.RefreshTokens(ctx, refreshToken, testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeTokenInvalidClaim, appErr.Code())
+}
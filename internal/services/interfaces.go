@@ -5,7 +5,10 @@ import (
 	"context"
 
 	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
 	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"github.com/yourusername/user-management-api/pkg/webauthn"
 )
 
 type UserService interface {
@@ -13,18 +16,119 @@ type UserService interface {
 	GetUserByID(userID uint) (*database.User, error)
 	UpdateUser(user *database.User) error
 	DeleteUser(userID uint) error
+	UnlockUser(userID uint) error
+	PepperReport(currentKeyID string) (*PepperReport, error)
 }
 
 type AuthService interface {
 	GenerateAccessToken(ctx context.Context, userID uint, username string) (string, apperrors.AppError)
 	GenerateRefreshToken(ctx context.Context, userID uint, username string) (string, apperrors.AppError)
-	ValidateRefreshToken(ctx context.Context, token string) (uint, string, error)
+	ValidateRefreshToken(ctx context.Context, refreshToken string) (*token.Claims, apperrors.AppError)
 	// ValidateAccessToken(ctx context.Context, token string) (*database.User, apperrors.AppError)
-	RefreshTokens(ctx context.Context, userID uint, username string) (*database.TokenPair, apperrors.AppError)
+	RefreshTokens(ctx context.Context, refreshToken string, device token.Device) (*database.TokenPair, apperrors.AppError)
 
 	RegisterUser(ctx context.Context, username, password, email string) (*database.User, error)
-	LoginUser(ctx context.Context, username, password, ipAddr string) (*database.TokenPair, apperrors.AppError)
-	LogoutUser(ctx context.Context, token string) error
+	LoginUser(ctx context.Context, username, password string, device token.Device) (*database.TokenPair, *database.MFAChallenge, apperrors.AppError)
+	VerifyMFA(ctx context.Context, challengeToken, code string, device token.Device) (*database.TokenPair, apperrors.AppError)
+	BeginPasskeyMFA(ctx context.Context, challengeToken string) (*webauthn.RequestOptions, apperrors.AppError)
+	VerifyMFAWithPasskey(ctx context.Context, challengeToken string, response *webauthn.AssertionCredential, device token.Device) (*database.TokenPair, apperrors.AppError)
+	BeginPasskeyLogin(ctx context.Context, username string) (*webauthn.RequestOptions, apperrors.AppError)
+	LoginWithPasskey(ctx context.Context, response *webauthn.AssertionCredential, device token.Device) (*database.TokenPair, apperrors.AppError)
+	LoginWithProvider(ctx context.Context, provider, state, code string, device token.Device) (*database.TokenPair, *FederatedLogin, apperrors.AppError)
+	RequestMagicLink(ctx context.Context, email, ip string) apperrors.AppError
+	LoginWithMagicLink(ctx context.Context, tokenString string, device token.Device) (*database.TokenPair, *database.MFAChallenge, apperrors.AppError)
+	LogoutUser(ctx context.Context, accessToken string, allSessions bool) error
+}
+
+type OAuthService interface {
+	IntrospectToken(ctx context.Context, tokenString string, hint token.TokenType) (*token.Claims, apperrors.AppError)
+	RevokeToken(ctx context.Context, tokenString string, hint token.TokenType) apperrors.AppError
+}
+
+type SessionService interface {
+	ListSessions(ctx context.Context, userID uint) ([]database.Session, apperrors.AppError)
+	RevokeSession(ctx context.Context, userID uint, sessionID string) apperrors.AppError
+	RevokeOtherSessions(ctx context.Context, userID uint, currentSessionID string) (int, apperrors.AppError)
+	RevokeAllSessions(ctx context.Context, userID uint) (int, apperrors.AppError)
+	EndSession(ctx context.Context, userID uint, sessionID string) apperrors.AppError
+}
+
+type ImpersonationService interface {
+	Impersonate(ctx context.Context, actorID, targetUserID uint) (string, *token.Claims, apperrors.AppError)
+}
+
+type OneTimeTokenService interface {
+	IssueToken(ctx context.Context, purpose OneTimeTokenPurpose, user *database.User) (string, apperrors.AppError)
+	ConsumeToken(ctx context.Context, purpose OneTimeTokenPurpose, tokenString string) (*database.User, apperrors.AppError)
+	PeekToken(ctx context.Context, purpose OneTimeTokenPurpose, tokenString string) (*database.User, apperrors.AppError)
+}
+
+type EmailVerificationService interface {
+	SendVerification(ctx context.Context, user *database.User) apperrors.AppError
+	VerifyEmail(ctx context.Context, tokenString string) (*database.User, apperrors.AppError)
+	ResendVerification(ctx context.Context, email string) apperrors.AppError
+}
+
+type PasswordResetService interface {
+	RequestPasswordReset(ctx context.Context, email string) apperrors.AppError
+	ResetPassword(ctx context.Context, tokenString, newPassword string) apperrors.AppError
+}
+
+type MagicLinkService interface {
+	RequestMagicLink(ctx context.Context, email, ip string) apperrors.AppError
+	RedeemMagicLink(ctx context.Context, tokenString, ip string) (*database.User, apperrors.AppError)
+}
+
+type MFAService interface {
+	EnrollTOTP(ctx context.Context, userID uint) (*TOTPEnrollment, apperrors.AppError)
+	ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, apperrors.AppError)
+	DisableTOTP(ctx context.Context, userID uint, code string) apperrors.AppError
+	Status(ctx context.Context, userID uint) (*MFAStatus, apperrors.AppError)
+	VerifyCode(ctx context.Context, userID uint, code string) (bool, apperrors.AppError)
+}
+
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userID uint) (*webauthn.CreationOptions, apperrors.AppError)
+	FinishRegistration(ctx context.Context, userID uint, name string, response *webauthn.RegistrationCredential) (*database.WebAuthnCredential, apperrors.AppError)
+	ListCredentials(ctx context.Context, userID uint) ([]database.WebAuthnCredential, apperrors.AppError)
+	DeleteCredential(ctx context.Context, userID, id uint) apperrors.AppError
+	BeginLogin(ctx context.Context, userID uint) (*webauthn.RequestOptions, apperrors.AppError)
+	FinishLogin(ctx context.Context, response *webauthn.AssertionCredential) (*database.User, apperrors.AppError)
+	BeginSecondFactor(ctx context.Context, userID uint) (*webauthn.RequestOptions, apperrors.AppError)
+	VerifySecondFactor(ctx context.Context, userID uint, response *webauthn.AssertionCredential) (bool, apperrors.AppError)
+}
+
+type OIDCService interface {
+	Metadata() ProviderMetadata
+	RegisterClient(ctx context.Context, registration ClientRegistration) (*database.OAuthClient, string, apperrors.AppError)
+	ListClients(ctx context.Context) ([]database.OAuthClient, apperrors.AppError)
+	DeleteClient(ctx context.Context, clientID string) apperrors.AppError
+	AuthenticateClient(clientID, clientSecret string) bool
+	CheckAuthorizationRequest(ctx context.Context, req *AuthorizationRequest) (*AuthorizationResult, apperrors.AppError)
+	Authorize(ctx context.Context, userID uint, req *AuthorizationRequest, decision ConsentDecision) (*AuthorizationResult, apperrors.AppError)
+	ExchangeCode(ctx context.Context, clientID, code, redirectURI, codeVerifier string) (*OIDCTokens, apperrors.AppError)
+	UserInfo(ctx context.Context, accessToken string) (*UserInfo, apperrors.AppError)
+	ListConsents(ctx context.Context, userID uint) ([]database.OAuthConsent, apperrors.AppError)
+	RevokeConsent(ctx context.Context, userID uint, clientID string) apperrors.AppError
+}
+
+type FederationService interface {
+	Providers() []string
+	BeginLogin(ctx context.Context, provider string, userID uint) (*FederatedLoginStart, apperrors.AppError)
+	FinishLogin(ctx context.Context, provider, state, code string) (*FederatedLogin, apperrors.AppError)
+	ListIdentities(ctx context.Context, userID uint) ([]database.UserIdentity, apperrors.AppError)
+	UnlinkIdentity(ctx context.Context, userID, id uint) apperrors.AppError
+}
+
+type APIKeyService interface {
+	CreateKey(ctx context.Context, userID uint, req APIKeyRequest) (*database.APIKey, string, apperrors.AppError)
+	ListKeys(ctx context.Context, userID uint) ([]database.APIKey, apperrors.AppError)
+	RevokeKey(ctx context.Context, userID, id uint) apperrors.AppError
+	AuthenticateAPIKey(ctx context.Context, plaintext, ip string) (*database.APIKey, *database.User, apperrors.AppError)
+}
+
+type SecurityEventService interface {
+	ListEvents(ctx context.Context, filter repository.SecurityEventFilter) ([]database.SecurityEvent, apperrors.AppError)
 }
 
 type UserCleanupService interface {
@@ -33,4 +137,17 @@ type UserCleanupService interface {
 
 var _ AuthService = (*AuthServiceImpl)(nil)
 var _ UserService = (*UserServiceImpl)(nil)
+var _ OAuthService = (*OAuthServiceImpl)(nil)
+var _ SessionService = (*SessionServiceImpl)(nil)
+var _ ImpersonationService = (*ImpersonationServiceImpl)(nil)
+var _ OneTimeTokenService = (*OneTimeTokenServiceImpl)(nil)
+var _ EmailVerificationService = (*EmailVerificationServiceImpl)(nil)
+var _ PasswordResetService = (*PasswordResetServiceImpl)(nil)
+var _ MagicLinkService = (*MagicLinkServiceImpl)(nil)
+var _ MFAService = (*MFAServiceImpl)(nil)
+var _ WebAuthnService = (*WebAuthnServiceImpl)(nil)
+var _ OIDCService = (*OIDCServiceImpl)(nil)
+var _ FederationService = (*FederationServiceImpl)(nil)
+var _ APIKeyService = (*APIKeyServiceImpl)(nil)
+var _ SecurityEventService = (*SecurityEventServiceImpl)(nil)
 var _ UserCleanupService = (*UserCleanupServiceImpl)(nil)
@@ -0,0 +1,130 @@
+// internal/services/magic_link_service.go
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/mailer"
+)
+
+// MagicLinkServiceImpl mails users single-use links that sign them in
+// without their password. AuthServiceImpl turns redeemed links into tokens.
+type MagicLinkServiceImpl struct {
+	logger                zerolog.Logger
+	repo                  repository.UserRepository
+	authenticationManager *authentication.AuthenticationManagerImpl
+	oneTimeTokens         *OneTimeTokenServiceImpl
+	mailer                mailer.Mailer
+	loginURL              string
+}
+
+// MagicLinkServiceOption customizes a MagicLinkServiceImpl
+type MagicLinkServiceOption func(*MagicLinkServiceImpl)
+
+// WithMagicLinkURL sets the page magic link emails link to. The token is
+// appended as the token query parameter.
+func WithMagicLinkURL(loginURL string) MagicLinkServiceOption {
+	return func(s *MagicLinkServiceImpl) {
+		s.loginURL = loginURL
+	}
+}
+
+func NewMagicLinkService(oneTimeTokens *OneTimeTokenServiceImpl,
+	mailer mailer.Mailer,
+	repo repository.UserRepository,
+	authenticationManager *authentication.AuthenticationManagerImpl,
+	logger zerolog.Logger,
+	opts ...MagicLinkServiceOption) *MagicLinkServiceImpl {
+	s := &MagicLinkServiceImpl{
+		logger:                logger.With().Str("service", "MagicLinkService").Logger(),
+		repo:                  repo,
+		authenticationManager: authenticationManager,
+		oneTimeTokens:         oneTimeTokens,
+		mailer:                mailer,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// RequestMagicLink mails a login link to the user registered with the email
+// address. Unknown addresses, and users who could not log in anyway, are
+// silently ignored so callers cannot find out which addresses are registered.
+func (s *MagicLinkServiceImpl) RequestMagicLink(ctx context.Context, email, ip string) apperrors.AppError {
+	user, err := s.repo.FindUserByEmail(email)
+	if isNotFound(err) {
+		s.logger.Debug().Msg("Magic link requested for unknown email address")
+		return nil
+	}
+	if err != nil {
+		return apperrors.NewInternalError("Failed to find user", err)
+	}
+
+	if appErr := s.checkLogin(user, ip); appErr != nil {
+		s.logger.Warn().Err(appErr).Uint("user_id", user.ID).Str("ip", ip).Msg("Magic link not sent")
+		return nil
+	}
+
+	tokenString, appErr := s.oneTimeTokens.IssueToken(ctx, PurposeMagicLink, user)
+	if appErr != nil {
+		return appErr
+	}
+
+	if sendErr := s.mailer.Send(ctx, s.loginMessage(user, tokenString)); sendErr != nil {
+		s.logger.Error().Err(sendErr).Uint("user_id", user.ID).Msg("Failed to send magic link email")
+		return apperrors.NewInternalError("Failed to send magic link email", sendErr)
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Msg("Magic link email sent")
+	return nil
+}
+
+// RedeemMagicLink uses up a magic link and returns the user it signs in. The
+// link is left alone when the user may not log in right now.
+func (s *MagicLinkServiceImpl) RedeemMagicLink(ctx context.Context, tokenString, ip string) (*database.User, apperrors.AppError) {
+	user, appErr := s.oneTimeTokens.PeekToken(ctx, PurposeMagicLink, tokenString)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr := s.checkLogin(user, ip); appErr != nil {
+		return nil, appErr
+	}
+
+	return s.oneTimeTokens.ConsumeToken(ctx, PurposeMagicLink, tokenString)
+}
+
+// checkLogin applies the same status and failed attempt checks as a
+// password login
+func (s *MagicLinkServiceImpl) checkLogin(user *database.User, ip string) apperrors.AppError {
+	if appErr := s.authenticationManager.CheckUserStatus(user); appErr != nil {
+		return appErr
+	}
+	return s.authenticationManager.CheckLoginAttempts(user.Username, ip)
+}
+
+func (s *MagicLinkServiceImpl) loginMessage(user *database.User, tokenString string) mailer.Message {
+	body := fmt.Sprintf("Hello %s,\n\nSomeone asked to sign in to your account", user.Username)
+	if s.loginURL != "" {
+		body += fmt.Sprintf(". Open the link below to sign in:\n\n%s\n", withQueryParam(s.loginURL, "token", tokenString))
+	} else {
+		body += fmt.Sprintf(". Use this code to sign in:\n\n%s\n", tokenString)
+	}
+	body += fmt.Sprintf("\nIt can be used once and expires in %s. If you did not ask for this, you can ignore this email.\n",
+		s.oneTimeTokens.ttl(PurposeMagicLink))
+
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body:    body,
+	}
+}
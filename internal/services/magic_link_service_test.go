@@ -0,0 +1,93 @@
+package services_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/mailer"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+var magicLink = regexp.MustCompile(`https://app\.example\.com/magic\S+`)
+
+// magicLinkToken extracts the token from the latest magic link email
+func magicLinkToken(t *testing.T, mail *mailer.MemoryMailer, to string) string {
+	message, ok := mail.LastMessageTo(to)
+	require.True(t, ok, "no email sent to %s", to)
+	link, err := url.Parse(magicLink.FindString(message.Body))
+	require.NoError(t, err)
+	tokenString := link.Query().Get("token")
+	require.NotEmpty(t, tokenString)
+	return tokenString
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	ctx := context.Background()
+
+	// Deployments have to switch magic links on
+	_, _, appErr := newTestServices(t, nil).auth.LoginWithMagicLink(ctx, "token", testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeValidationError, appErr.Code())
+
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	tokenManager := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations)
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager,
+		repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, revocations, zerolog.Nop())
+
+	mail := mailer.NewMemoryMailer()
+	oneTimeTokens := services.NewOneTimeTokenService(userRepo, repository.NewOneTimeTokenRepository(db, zerolog.Nop()), zerolog.Nop())
+	magicLinks := services.NewMagicLinkService(oneTimeTokens, mail, userRepo, authManager, zerolog.Nop(),
+		services.WithMagicLinkURL("https://app.example.com/magic"))
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop(),
+		services.WithMagicLinks(magicLinks))
+
+	_, err := authService.RegisterUser(ctx, "linkuser", testPassword, "link@example.com")
+	require.NoError(t, err)
+
+	// Unknown addresses are ignored
+	require.NoError(t, authService.RequestMagicLink(ctx, "nobody@example.com", testDevice.IP))
+	assert.Empty(t, mail.Messages())
+
+	require.NoError(t, authService.RequestMagicLink(ctx, "link@example.com", testDevice.IP))
+	tokens, challenge, appErr := authService.LoginWithMagicLink(ctx, magicLinkToken(t, mail, "link@example.com"), testDevice)
+	require.NoError(t, appErr)
+	assert.Nil(t, challenge)
+	claims, err := tokenManager.ValidateToken(tokens.AccessToken, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "linkuser", claims.Username)
+
+	// Links work once
+	_, _, appErr = authService.LoginWithMagicLink(ctx, magicLinkToken(t, mail, "link@example.com"), testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeTokenAlreadyUsed, appErr.Code())
+
+	// Links count against the same login attempt limit as passwords
+	require.NoError(t, authService.RequestMagicLink(ctx, "link@example.com", testDevice.IP))
+	pending := magicLinkToken(t, mail, "link@example.com")
+	for range authentication.DefaultLockoutPolicy().MaxAttempts {
+		_, _, appErr = authService.LoginUser(ctx, "linkuser", "Wr0ng!Password", testDevice)
+		require.Error(t, appErr)
+	}
+	_, _, appErr = authService.LoginWithMagicLink(ctx, pending, testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeUserLocked, appErr.Code())
+
+	// and locked users are not sent new ones
+	sent := len(mail.Messages())
+	require.NoError(t, authService.RequestMagicLink(ctx, "link@example.com", testDevice.IP))
+	assert.Len(t, mail.Messages(), sent)
+}
@@ -0,0 +1,296 @@
+// internal/services/mfa_service.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/totp"
+)
+
+const (
+	// recoveryCodeCount is the number of recovery codes issued at once
+	recoveryCodeCount = 10
+	// recoveryCodeSize is the number of random bytes in a recovery code
+	recoveryCodeSize = 10
+	// totpSkew is the number of time steps codes may be off by
+	totpSkew = 1
+	// defaultMFAIssuer names the account in authenticator apps unless configured
+	defaultMFAIssuer = "user-management-api"
+)
+
+// TOTPEnrollment is what an authenticator app needs to add an account
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFAStatus describes the second factors a user has set up
+type MFAStatus struct {
+	TOTPEnabled            bool  `json:"totp_enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// MFAServiceImpl manages TOTP second factors (RFC 6238) and the recovery
+// codes that replace them when the authenticator is lost
+type MFAServiceImpl struct {
+	logger  zerolog.Logger
+	repo    repository.UserRepository
+	mfaRepo repository.MFARepository
+	secrets *totp.SecretCipher
+	issuer  string
+}
+
+// MFAServiceOption customizes an MFAServiceImpl
+type MFAServiceOption func(*MFAServiceImpl)
+
+// WithMFAIssuer sets the issuer authenticator apps show next to the account
+func WithMFAIssuer(issuer string) MFAServiceOption {
+	return func(s *MFAServiceImpl) {
+		s.issuer = issuer
+	}
+}
+
+func NewMFAService(repo repository.UserRepository,
+	mfaRepo repository.MFARepository,
+	secrets *totp.SecretCipher,
+	logger zerolog.Logger,
+	opts ...MFAServiceOption) *MFAServiceImpl {
+	s := &MFAServiceImpl{
+		logger:  logger.With().Str("service", "MFAService").Logger(),
+		repo:    repo,
+		mfaRepo: mfaRepo,
+		secrets: secrets,
+		issuer:  defaultMFAIssuer,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// EnrollTOTP starts TOTP enrollment with a new secret. The factor does not
+// guard logins until ConfirmTOTP proves the authenticator produces its codes.
+func (s *MFAServiceImpl) EnrollTOTP(ctx context.Context, userID uint) (*TOTPEnrollment, apperrors.AppError) {
+	user, appErr := s.findUser(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	factor, err := s.mfaRepo.FindTOTPFactor(userID)
+	if err != nil && !isNotFound(err) {
+		return nil, apperrors.NewInternalError("Failed to find TOTP factor", err)
+	}
+	if err == nil && factor.ConfirmedAt != nil {
+		return nil, apperrors.NewValidationErrors("TOTP is already enabled", nil)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to generate TOTP secret", err)
+	}
+	sealed, err := s.secrets.Seal(secret, secretOwner(userID))
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to encrypt TOTP secret", err)
+	}
+	if err := s.mfaRepo.SaveTOTPFactor(&database.TOTPFactor{UserID: userID, Secret: sealed}); err != nil {
+		return nil, apperrors.NewInternalError("Failed to save TOTP factor", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Msg("TOTP enrollment started")
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.issuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables a pending TOTP factor with a first code from the
+// authenticator and returns a fresh set of recovery codes. The codes are only
+// ever shown here.
+func (s *MFAServiceImpl) ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, apperrors.AppError) {
+	factor, err := s.mfaRepo.FindTOTPFactor(userID)
+	if isNotFound(err) {
+		return nil, apperrors.NewValidationErrors("TOTP enrollment has not been started", nil)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find TOTP factor", err)
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, apperrors.NewValidationErrors("TOTP is already enabled", nil)
+	}
+
+	step, ok, appErr := s.checkTOTPCode(factor, code)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !ok {
+		return nil, apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "invalid verification code", nil)
+	}
+
+	recoveryCodes, appErr := s.replaceRecoveryCodes(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if err := s.mfaRepo.ConfirmTOTPFactor(userID, step); err != nil {
+		return nil, apperrors.NewInternalError("Failed to confirm TOTP factor", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Msg("TOTP enabled")
+	return recoveryCodes, nil
+}
+
+// DisableTOTP removes the user's TOTP factor and recovery codes. A current
+// code or a recovery code is required, so a stolen session alone cannot
+// turn MFA off.
+func (s *MFAServiceImpl) DisableTOTP(ctx context.Context, userID uint, code string) apperrors.AppError {
+	valid, appErr := s.VerifyCode(ctx, userID, code)
+	if appErr != nil {
+		return appErr
+	}
+	if !valid {
+		return apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "invalid verification code", nil)
+	}
+
+	if err := s.mfaRepo.DeleteTOTPFactor(userID); err != nil {
+		return apperrors.NewInternalError("Failed to delete TOTP factor", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Msg("TOTP disabled")
+	return nil
+}
+
+// Status reports the second factors the user has set up
+func (s *MFAServiceImpl) Status(ctx context.Context, userID uint) (*MFAStatus, apperrors.AppError) {
+	enabled, appErr := s.TOTPEnabled(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	status := &MFAStatus{TOTPEnabled: enabled}
+	if enabled {
+		remaining, err := s.mfaRepo.CountUnusedRecoveryCodes(userID)
+		if err != nil {
+			return nil, apperrors.NewInternalError("Failed to count recovery codes", err)
+		}
+		status.RecoveryCodesRemaining = remaining
+	}
+	return status, nil
+}
+
+// TOTPEnabled reports whether logins of the user need a second factor
+func (s *MFAServiceImpl) TOTPEnabled(userID uint) (bool, apperrors.AppError) {
+	factor, err := s.mfaRepo.FindTOTPFactor(userID)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, apperrors.NewInternalError("Failed to find TOTP factor", err)
+	}
+	return factor.ConfirmedAt != nil, nil
+}
+
+// VerifyCode checks a TOTP code or, failing that, a recovery code. Either is
+// accepted only once.
+func (s *MFAServiceImpl) VerifyCode(ctx context.Context, userID uint, code string) (bool, apperrors.AppError) {
+	factor, err := s.mfaRepo.FindTOTPFactor(userID)
+	if isNotFound(err) {
+		return false, apperrors.NewValidationErrors("TOTP is not enabled", nil)
+	}
+	if err != nil {
+		return false, apperrors.NewInternalError("Failed to find TOTP factor", err)
+	}
+	if factor.ConfirmedAt == nil {
+		return false, apperrors.NewValidationErrors("TOTP is not enabled", nil)
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok, appErr := s.checkTOTPCode(factor, code)
+		if appErr != nil || !ok {
+			return false, appErr
+		}
+		// A code seen before, or one older than the last accepted, is a replay
+		used, err := s.mfaRepo.UseTOTPStep(userID, step)
+		if err != nil {
+			return false, apperrors.NewInternalError("Failed to record TOTP code", err)
+		}
+		if !used {
+			s.logger.Warn().Uint("user_id", userID).Int64("step", step).Msg("TOTP code replayed")
+		}
+		return used, nil
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return false, apperrors.NewInternalError("Failed to use recovery code", err)
+	}
+	if used {
+		s.logger.Info().Uint("user_id", userID).Msg("Recovery code used")
+	}
+	return used, nil
+}
+
+func (s *MFAServiceImpl) checkTOTPCode(factor *database.TOTPFactor, code string) (int64, bool, apperrors.AppError) {
+	secret, err := s.secrets.Open(factor.Secret, secretOwner(factor.UserID))
+	if err != nil {
+		return 0, false, apperrors.NewInternalError("Failed to decrypt TOTP secret", err)
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), totpSkew)
+	return step, ok, nil
+}
+
+// replaceRecoveryCodes issues a new set of recovery codes, voiding the old ones
+func (s *MFAServiceImpl) replaceRecoveryCodes(userID uint) ([]string, apperrors.AppError) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		random := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(random); err != nil {
+			return nil, apperrors.NewInternalError("Failed to generate recovery code", err)
+		}
+		encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(random))
+		codes[i] = fmt.Sprintf("%s-%s-%s-%s", encoded[0:4], encoded[4:8], encoded[8:12], encoded[12:16])
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, apperrors.NewInternalError("Failed to store recovery codes", err)
+	}
+	return codes, nil
+}
+
+func (s *MFAServiceImpl) findUser(userID uint) (*database.User, apperrors.AppError) {
+	user, err := s.repo.FindUserByID(userID)
+	if isNotFound(err) {
+		return nil, apperrors.NewNotFoundError("User not found", err, "user", userID)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find user", err)
+	}
+	return user, nil
+}
+
+// hashRecoveryCode returns the key a recovery code is stored under. Codes
+// carry 80 random bits, so a fast hash is enough to protect them.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// secretOwner binds an encrypted TOTP secret to its user
+func secretOwner(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
@@ -0,0 +1,124 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"github.com/yourusername/user-management-api/pkg/totp"
+)
+
+// failedAttempts sums the failed login attempts recorded for the username
+func failedAttempts(t *testing.T, db *gorm.DB, username string) int64 {
+	var attempts int64
+	require.NoError(t, db.Model(&database.LoginAttempt{}).Where("username = ?", username).
+		Select("COALESCE(SUM(attempts), 0)").Scan(&attempts).Error)
+	return attempts
+}
+
+// totpCode returns the authenticator's code for the given offset from now
+func totpCode(t *testing.T, secret string, offset time.Duration) string {
+	code, err := totp.GenerateCode(secret, time.Now().Add(offset))
+	require.NoError(t, err)
+	return code
+}
+
+func TestMFALoginFlow(t *testing.T) {
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	tokenManager := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations)
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager,
+		repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, revocations, zerolog.Nop())
+
+	secrets, err := totp.NewSecretCipher("mfa-secret")
+	require.NoError(t, err)
+	mfa := services.NewMFAService(userRepo, repository.NewMFARepository(db, zerolog.Nop()), secrets, zerolog.Nop(),
+		services.WithMFAIssuer("Example"))
+	oneTimeTokens := services.NewOneTimeTokenService(userRepo, repository.NewOneTimeTokenRepository(db, zerolog.Nop()), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop(),
+		services.WithMFA(mfa, oneTimeTokens))
+	ctx := context.Background()
+
+	user, err := authService.RegisterUser(ctx, "mfauser", testPassword, "mfa@example.com")
+	require.NoError(t, err)
+
+	// Without a confirmed factor the login issues tokens directly
+	enrollment, appErr := mfa.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, appErr)
+	assert.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/Example:mfauser?")
+	tokens, challenge, appErr := authService.LoginUser(ctx, "mfauser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	assert.Nil(t, challenge)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	_, appErr = mfa.ConfirmTOTP(ctx, user.ID, "000000")
+	assert.Error(t, appErr)
+	recoveryCodes, appErr := mfa.ConfirmTOTP(ctx, user.ID, totpCode(t, enrollment.Secret, 0))
+	require.NoError(t, appErr)
+	assert.Len(t, recoveryCodes, 10)
+
+	tokens, challenge, appErr = authService.LoginUser(ctx, "mfauser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	assert.Nil(t, tokens)
+	require.NotNil(t, challenge)
+	assert.True(t, challenge.MFARequired)
+
+	// Wrong codes count as failed login attempts
+	_, appErr = authService.VerifyMFA(ctx, challenge.ChallengeToken, "000000", testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidCredentials, appErr.Code())
+	assert.Equal(t, int64(1), failedAttempts(t, db, "mfauser"))
+
+	// A code of the next step is within the allowed skew
+	code := totpCode(t, enrollment.Secret, totp.Period)
+	tokens, appErr = authService.VerifyMFA(ctx, challenge.ChallengeToken, code, testDevice)
+	require.NoError(t, appErr)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.Zero(t, failedAttempts(t, db, "mfauser"))
+
+	// The challenge is single-use
+	_, appErr = authService.VerifyMFA(ctx, challenge.ChallengeToken, code, testDevice)
+	assert.Error(t, appErr)
+
+	// An accepted code cannot be replayed with a new challenge
+	_, challenge, appErr = authService.LoginUser(ctx, "mfauser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	_, appErr = authService.VerifyMFA(ctx, challenge.ChallengeToken, code, testDevice)
+	assert.Error(t, appErr)
+
+	// Recovery codes work once
+	tokens, appErr = authService.VerifyMFA(ctx, challenge.ChallengeToken, recoveryCodes[0], testDevice)
+	require.NoError(t, appErr)
+	assert.NotEmpty(t, tokens.AccessToken)
+	_, challenge, appErr = authService.LoginUser(ctx, "mfauser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	_, appErr = authService.VerifyMFA(ctx, challenge.ChallengeToken, recoveryCodes[0], testDevice)
+	assert.Error(t, appErr)
+
+	status, appErr := mfa.Status(ctx, user.ID)
+	require.NoError(t, appErr)
+	assert.True(t, status.TOTPEnabled)
+	assert.Equal(t, int64(9), status.RecoveryCodesRemaining)
+
+	// Disabling needs a valid code and ends the challenge step
+	assert.Error(t, mfa.DisableTOTP(ctx, user.ID, "000000"))
+	require.NoError(t, mfa.DisableTOTP(ctx, user.ID, recoveryCodes[1]))
+	tokens, challenge, appErr = authService.LoginUser(ctx, "mfauser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	assert.Nil(t, challenge)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
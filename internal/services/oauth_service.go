@@ -0,0 +1,157 @@
+// internal/services/oauth_service.go
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// OAuthServiceImpl implements token introspection (RFC 7662) and token
+// revocation (RFC 7009) for resource servers and gateways
+type OAuthServiceImpl struct {
+	logger                zerolog.Logger
+	repo                  repository.UserRepository
+	tokenManager          token.TokenManager
+	authenticationManager *authentication.AuthenticationManagerImpl
+	refreshTokenRepo      repository.RefreshTokenRepository
+}
+
+func NewOAuthService(tokenManager token.TokenManager,
+	authenticationManager *authentication.AuthenticationManagerImpl,
+	repo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	logger zerolog.Logger) *OAuthServiceImpl {
+	return &OAuthServiceImpl{
+		logger:                logger.With().Str("service", "OAuthService").Logger(),
+		repo:                  repo,
+		tokenManager:          tokenManager,
+		authenticationManager: authenticationManager,
+		refreshTokenRepo:      refreshTokenRepo,
+	}
+}
+
+// IntrospectToken returns the claims of an active token. Tokens that are
+// invalid, expired, revoked or already rotated, or whose user could no longer
+// use them, yield nil claims and no error.
+// The hint only decides which token type is tried first.
+func (s *OAuthServiceImpl) IntrospectToken(ctx context.Context, tokenString string, hint token.TokenType) (*token.Claims, apperrors.AppError) {
+	return s.findActiveToken(tokenString, hint)
+}
+
+// RevokeToken revokes an access or refresh token. Revoking a refresh token
+// also revokes the rest of its family. Unknown or inactive tokens are not an
+// error, as the client's goal is already reached.
+func (s *OAuthServiceImpl) RevokeToken(ctx context.Context, tokenString string, hint token.TokenType) apperrors.AppError {
+	claims, err := s.findActiveToken(tokenString, hint)
+	if err != nil || claims == nil {
+		return err
+	}
+
+	if err := s.tokenManager.InvalidateToken(tokenString, claims.TokenType); err != nil {
+		return err
+	}
+
+	if claims.TokenType == token.RefreshToken && claims.FamilyID != "" {
+		if err := s.refreshTokenRepo.RevokeFamily(claims.FamilyID); err != nil {
+			return apperrors.NewInternalError("Failed to revoke refresh token family", err)
+		}
+	}
+
+	s.logger.Info().
+		Str("token_type", string(claims.TokenType)).
+		Str("jti", claims.ID).
+		Uint("user_id", claims.UserID).
+		Msg("Token revoked")
+
+	return nil
+}
+
+// findActiveToken validates the token as the hinted type first and falls back
+// to the other type, as RFC 7662 and RFC 7009 require
+func (s *OAuthServiceImpl) findActiveToken(tokenString string, hint token.TokenType) (*token.Claims, apperrors.AppError) {
+	tokenTypes := []token.TokenType{token.AccessToken, token.RefreshToken}
+	if hint == token.RefreshToken {
+		tokenTypes = []token.TokenType{token.RefreshToken, token.AccessToken}
+	}
+
+	for _, tokenType := range tokenTypes {
+		claims, err := s.tokenManager.ValidateToken(tokenString, tokenType)
+		if err != nil {
+			if err.Code() == apperrors.ErrCodeInternalError {
+				return nil, err
+			}
+			continue
+		}
+
+		if tokenType == token.RefreshToken {
+			active, err := s.isRefreshTokenActive(claims.ID)
+			if err != nil || !active {
+				return nil, err
+			}
+		}
+		active, err := s.isUserActive(claims)
+		if err != nil || !active {
+			return nil, err
+		}
+		return claims, nil
+	}
+
+	return nil, nil
+}
+
+// isUserActive applies the checks AuthMiddleware makes: the user, and the
+// actor of impersonation tokens, must still be allowed in and must not have
+// had their tokens invalidated since the token was issued
+func (s *OAuthServiceImpl) isUserActive(claims *token.Claims) (bool, apperrors.AppError) {
+	user, active, err := s.findActiveUser(claims.UserID, claims.TokenVersion)
+	if err != nil || !active {
+		return false, err
+	}
+	if user.Username != claims.Username {
+		return false, nil
+	}
+	if claims.Actor == nil {
+		return true, nil
+	}
+
+	actor, active, err := s.findActiveUser(claims.Actor.UserID, claims.Actor.TokenVersion)
+	if err != nil || !active {
+		return false, err
+	}
+	return actor.Username == claims.Actor.Username && actor.Role.HasPermission(database.PermissionImpersonate), nil
+}
+
+func (s *OAuthServiceImpl) findActiveUser(userID, tokenVersion uint) (*database.User, bool, apperrors.AppError) {
+	user, err := s.repo.FindUserByID(userID)
+	if isNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, apperrors.NewInternalError("Failed to find user", err)
+	}
+	if s.authenticationManager.CheckUserStatus(user) != nil || user.TokenInvalidated(tokenVersion) {
+		return nil, false, nil
+	}
+	return user, true, nil
+}
+
+// isRefreshTokenActive reports whether the refresh token can still be
+// exchanged. Unlike a refresh, this never triggers reuse detection.
+func (s *OAuthServiceImpl) isRefreshTokenActive(jti string) (bool, apperrors.AppError) {
+	record, err := s.refreshTokenRepo.FindRefreshTokenByJTI(jti)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, apperrors.NewInternalError("Failed to look up refresh token", err)
+	}
+
+	return record.ConsumedAt == nil && record.RevokedAt == nil && record.ExpiresAt.After(time.Now()), nil
+}
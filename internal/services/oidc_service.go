@@ -0,0 +1,647 @@
+// internal/services/oidc_service.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const (
+	// authorizationCodeTTL is how long a client has to exchange a code
+	authorizationCodeTTL = 5 * time.Minute
+	// oauthSecretSize is the number of random bytes in client secrets and codes
+	oauthSecretSize = 32
+	// maxNonceLength bounds the nonce stored with an authorization code
+	maxNonceLength = 255
+	// codeChallengeMethodS256 is the only PKCE method accepted
+	codeChallengeMethodS256 = "S256"
+)
+
+// Scopes with a meaning defined by OpenID Connect
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Scopes granting access to this API, the permissions of first-party access tokens
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// SupportedScopes are the scopes clients can be registered for
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeRead, ScopeWrite}
+
+// ConsentDecision is the user's answer on the consent screen
+type ConsentDecision string
+
+const (
+	// ConsentPending authorizes on an earlier consent or asks for one
+	ConsentPending  ConsentDecision = ""
+	ConsentApproved ConsentDecision = "approve"
+	ConsentDenied   ConsentDecision = "deny"
+)
+
+// AuthorizationRequest holds the parameters of an authorization request
+// (RFC 6749 section 4.1.1, RFC 7636 section 4.3 and OIDC Core 3.1.2.1)
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// AuthorizationResult tells the user agent how to continue an authorization
+// request: either follow RedirectTo back to the client, carrying a code or an
+// error, or show the consent screen for Client and Scopes
+type AuthorizationResult struct {
+	RedirectTo      string                `json:"redirect_to,omitempty"`
+	ConsentRequired bool                  `json:"consent_required,omitempty"`
+	Client          *database.OAuthClient `json:"client,omitempty"`
+	Scopes          []string              `json:"scopes,omitempty"`
+}
+
+// ClientRegistration describes a client application to register
+type ClientRegistration struct {
+	Name         string
+	RedirectURIs []string
+	Scopes       []string
+	Public       bool
+}
+
+// OIDCTokens are issued in exchange for an authorization code. IDToken is
+// only set when the openid scope was granted.
+type OIDCTokens struct {
+	AccessToken string
+	IDToken     string
+	ExpiresIn   int64
+	Scopes      []string
+}
+
+// UserInfo holds the claims about a user released for the granted scopes
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// ProviderMetadata describes what the authorization server supports, for
+// the OIDC discovery document
+type ProviderMetadata struct {
+	Issuer                 string
+	ScopesSupported        []string
+	IDTokenSigningAlgValue string
+}
+
+// OIDCServiceImpl makes this service an OAuth 2.0 authorization server and
+// OpenID Connect provider. Clients use the authorization code flow with PKCE
+// and receive a short-lived access token and an ID token, no refresh token.
+type OIDCServiceImpl struct {
+	logger                zerolog.Logger
+	repo                  repository.UserRepository
+	clientRepo            repository.OAuthClientRepository
+	tokenManager          token.TokenManager
+	idTokens              *token.IDTokenSigner
+	authenticationManager *authentication.AuthenticationManagerImpl
+	issuer                string
+}
+
+func NewOIDCService(repo repository.UserRepository,
+	clientRepo repository.OAuthClientRepository,
+	tokenManager token.TokenManager,
+	idTokens *token.IDTokenSigner,
+	authenticationManager *authentication.AuthenticationManagerImpl,
+	issuer string,
+	logger zerolog.Logger) *OIDCServiceImpl {
+	return &OIDCServiceImpl{
+		logger:                logger.With().Str("service", "OIDCService").Logger(),
+		repo:                  repo,
+		clientRepo:            clientRepo,
+		tokenManager:          tokenManager,
+		idTokens:              idTokens,
+		authenticationManager: authenticationManager,
+		issuer:                issuer,
+	}
+}
+
+// Metadata returns what the discovery document advertises
+func (s *OIDCServiceImpl) Metadata() ProviderMetadata {
+	return ProviderMetadata{
+		Issuer:                 s.issuer,
+		ScopesSupported:        SupportedScopes,
+		IDTokenSigningAlgValue: s.idTokens.Algorithm(),
+	}
+}
+
+// RegisterClient adds a client application. The secret of a confidential
+// client is only returned here; public clients get none.
+func (s *OIDCServiceImpl) RegisterClient(ctx context.Context, registration ClientRegistration) (*database.OAuthClient, string, apperrors.AppError) {
+	if len(registration.RedirectURIs) == 0 {
+		return nil, "", apperrors.NewValidationErrors("At least one redirect URI is required", nil)
+	}
+	for _, redirectURI := range registration.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return nil, "", apperrors.NewValidationErrors("Invalid redirect URI: "+redirectURI, nil)
+		}
+	}
+	if len(registration.Scopes) == 0 {
+		return nil, "", apperrors.NewValidationErrors("At least one scope is required", nil)
+	}
+	for _, scope := range registration.Scopes {
+		if !slices.Contains(SupportedScopes, scope) {
+			return nil, "", apperrors.NewValidationErrors("Unsupported scope: "+scope, nil)
+		}
+	}
+
+	client := &database.OAuthClient{
+		ClientID:     uuid.New().String(),
+		Name:         registration.Name,
+		RedirectURIs: strings.Join(registration.RedirectURIs, " "),
+		Scopes:       strings.Join(registration.Scopes, " "),
+		Public:       registration.Public,
+	}
+
+	var secret string
+	if !registration.Public {
+		var err apperrors.AppError
+		if secret, err = randomOAuthSecret(); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashOAuthSecret(secret)
+	}
+
+	if err := s.clientRepo.CreateClient(client); err != nil {
+		return nil, "", apperrors.NewInternalError("Failed to create OAuth client", err)
+	}
+
+	s.logger.Info().
+		Str("client_id", client.ClientID).
+		Str("name", client.Name).
+		Bool("public", client.Public).
+		Msg("OAuth client registered")
+
+	return client, secret, nil
+}
+
+// ListClients lists the registered client applications
+func (s *OIDCServiceImpl) ListClients(ctx context.Context) ([]database.OAuthClient, apperrors.AppError) {
+	clients, err := s.clientRepo.ListClients()
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to list OAuth clients", err)
+	}
+	return clients, nil
+}
+
+// DeleteClient removes a client along with the consents users gave it
+func (s *OIDCServiceImpl) DeleteClient(ctx context.Context, clientID string) apperrors.AppError {
+	err := s.clientRepo.DeleteClient(clientID)
+	if isNotFound(err) {
+		return apperrors.NewNotFoundError("OAuth client not found", err, "oauth_client", clientID)
+	}
+	if err != nil {
+		return apperrors.NewInternalError("Failed to delete OAuth client", err)
+	}
+
+	s.logger.Info().Str("client_id", clientID).Msg("OAuth client deleted")
+	return nil
+}
+
+// AuthenticateClient verifies client credentials at the token endpoint.
+// Public clients authenticate with their client ID alone.
+func (s *OIDCServiceImpl) AuthenticateClient(clientID, clientSecret string) bool {
+	client, err := s.clientRepo.FindClientByClientID(clientID)
+	if err != nil {
+		return false
+	}
+	if client.Public {
+		return clientSecret == ""
+	}
+	return subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashOAuthSecret(clientSecret))) == 1
+}
+
+// CheckAuthorizationRequest validates an authorization request before the
+// user has signed in. Errors are only returned when the client or redirect
+// URI is unknown, as the user must then not be sent back to it; every other
+// problem is reported to the client through RedirectTo.
+func (s *OIDCServiceImpl) CheckAuthorizationRequest(ctx context.Context, req *AuthorizationRequest) (*AuthorizationResult, apperrors.AppError) {
+	client, err := s.findClientForRedirect(req)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := checkAuthorizationParams(client, req)
+	if err != nil {
+		return &AuthorizationResult{RedirectTo: s.errorRedirect(req, err)}, nil
+	}
+	return &AuthorizationResult{Client: client, Scopes: scopes}, nil
+}
+
+// Authorize answers an authorization request for the signed-in user. Without
+// a decision it issues a code only if the user already consented to every
+// requested scope, and otherwise asks for consent.
+func (s *OIDCServiceImpl) Authorize(ctx context.Context, userID uint, req *AuthorizationRequest, decision ConsentDecision) (*AuthorizationResult, apperrors.AppError) {
+	result, err := s.CheckAuthorizationRequest(ctx, req)
+	if err != nil || result.RedirectTo != "" {
+		return result, err
+	}
+	client, scopes := result.Client, result.Scopes
+
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authenticationManager.CheckUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	switch decision {
+	case ConsentDenied:
+		s.logger.Info().Uint("user_id", user.ID).Str("client_id", client.ClientID).Msg("Authorization denied by user")
+		return &AuthorizationResult{
+			RedirectTo: s.errorRedirect(req, apperrors.NewOAuthError(apperrors.ErrCodeOAuthAccessDenied, "The user denied the request", nil)),
+		}, nil
+	case ConsentApproved:
+		if err := s.grantConsent(user.ID, client.ClientID, scopes); err != nil {
+			return nil, err
+		}
+	case ConsentPending:
+		consented, err := s.hasConsent(user.ID, client.ClientID, scopes)
+		if err != nil {
+			return nil, err
+		}
+		if !consented {
+			return &AuthorizationResult{ConsentRequired: true, Client: client, Scopes: scopes}, nil
+		}
+	default:
+		return nil, apperrors.NewValidationErrors("Unknown consent decision", nil)
+	}
+
+	code, err := randomOAuthSecret()
+	if err != nil {
+		return nil, err
+	}
+	authorizationCode := &database.OAuthAuthorizationCode{
+		CodeHash:      hashOAuthSecret(code),
+		ClientID:      client.ClientID,
+		UserID:        user.ID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		TokenVersion:  user.TokenVersion,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	}
+	if err := s.clientRepo.CreateAuthorizationCode(authorizationCode); err != nil {
+		return nil, apperrors.NewInternalError("Failed to store authorization code", err)
+	}
+
+	s.logger.Info().
+		Uint("user_id", user.ID).
+		Str("client_id", client.ClientID).
+		Strs("scopes", scopes).
+		Msg("Authorization code issued")
+
+	return &AuthorizationResult{
+		RedirectTo: s.redirect(req, url.Values{"code": {code}}),
+	}, nil
+}
+
+// ExchangeCode redeems an authorization code for the client that requested
+// it (RFC 6749 section 4.1.3). The code is used up even if the exchange fails.
+func (s *OIDCServiceImpl) ExchangeCode(ctx context.Context, clientID, code, redirectURI, codeVerifier string) (*OIDCTokens, apperrors.AppError) {
+	if code == "" {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidRequest, "code is required", nil)
+	}
+
+	authorizationCode, err := s.clientRepo.ConsumeAuthorizationCode(hashOAuthSecret(code))
+	if isNotFound(err) {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidGrant, "Authorization code is invalid or expired", nil)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to redeem authorization code", err)
+	}
+
+	if authorizationCode.ClientID != clientID {
+		s.logger.Warn().
+			Str("client_id", clientID).
+			Str("code_client_id", authorizationCode.ClientID).
+			Msg("Authorization code presented by another client")
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidGrant, "Authorization code was issued to another client", nil)
+	}
+	if authorizationCode.RedirectURI != redirectURI {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidGrant, "redirect_uri does not match the authorization request", nil)
+	}
+	if !verifyCodeChallenge(authorizationCode.CodeChallenge, codeVerifier) {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidGrant, "code_verifier does not match the code challenge", nil)
+	}
+
+	user, appErr := s.findUser(authorizationCode.UserID)
+	if appErr != nil {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidGrant, "Authorization code is invalid or expired", appErr)
+	}
+	// The user may have been locked or changed their password since authorizing
+	if appErr := s.authenticationManager.CheckUserStatus(user); appErr != nil || user.TokenInvalidated(authorizationCode.TokenVersion) {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidGrant, "Authorization code is invalid or expired", appErr)
+	}
+
+	scopes := strings.Fields(authorizationCode.Scopes)
+	accessToken, claims, appErr := s.tokenManager.IssueToken(user.ID, user.Username, token.AccessToken,
+		token.WithClientID(clientID),
+		token.WithPermissions(scopes),
+		token.WithTokenVersion(user.TokenVersion))
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	tokens := &OIDCTokens{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(time.Until(claims.ExpiresAt.Time).Seconds()),
+		Scopes:      scopes,
+	}
+
+	if slices.Contains(scopes, ScopeOpenID) {
+		info := userInfo(user, scopes)
+		tokens.IDToken, appErr = s.idTokens.SignIDToken(info.Subject, clientID, token.IDTokenClaims{
+			Nonce:             authorizationCode.Nonce,
+			AccessTokenHash:   s.idTokens.AccessTokenHash(accessToken),
+			AuthorizedParty:   clientID,
+			PreferredUsername: info.PreferredUsername,
+			Email:             info.Email,
+			EmailVerified:     info.EmailVerified,
+		})
+		if appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	s.logger.Info().
+		Uint("user_id", user.ID).
+		Str("client_id", clientID).
+		Str("jti", claims.ID).
+		Msg("Authorization code exchanged")
+
+	return tokens, nil
+}
+
+// UserInfo returns the claims about the user an access token issued with the
+// openid scope was granted for (OIDC Core 5.3)
+func (s *OIDCServiceImpl) UserInfo(ctx context.Context, accessToken string) (*UserInfo, apperrors.AppError) {
+	claims, err := s.tokenManager.ValidateToken(accessToken, token.AccessToken)
+	if err != nil {
+		if err.Code() == apperrors.ErrCodeInternalError {
+			return nil, err
+		}
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidToken, "Access token is invalid", err)
+	}
+	if claims.ClientID == "" || !slices.Contains(claims.Permissions, ScopeOpenID) {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInsufficientScope, "Access token was not granted the openid scope", nil)
+	}
+
+	user, err := s.findUser(claims.UserID)
+	if err != nil {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidToken, "Access token is invalid", err)
+	}
+	if err := s.authenticationManager.CheckUserStatus(user); err != nil || user.TokenInvalidated(claims.TokenVersion) {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidToken, "Access token is invalid", err)
+	}
+
+	return userInfo(user, claims.Permissions), nil
+}
+
+// ListConsents lists the clients the user has granted access to
+func (s *OIDCServiceImpl) ListConsents(ctx context.Context, userID uint) ([]database.OAuthConsent, apperrors.AppError) {
+	consents, err := s.clientRepo.ListConsents(userID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to list OAuth consents", err)
+	}
+	return consents, nil
+}
+
+// RevokeConsent withdraws the user's consent for a client, so its next
+// authorization request shows the consent screen again
+func (s *OIDCServiceImpl) RevokeConsent(ctx context.Context, userID uint, clientID string) apperrors.AppError {
+	err := s.clientRepo.DeleteConsent(userID, clientID)
+	if isNotFound(err) {
+		return apperrors.NewNotFoundError("OAuth consent not found", err, "oauth_consent", clientID)
+	}
+	if err != nil {
+		return apperrors.NewInternalError("Failed to revoke OAuth consent", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Str("client_id", clientID).Msg("OAuth consent revoked")
+	return nil
+}
+
+// findClientForRedirect looks up the client and checks the redirect URI is
+// one it registered, which must hold before any response goes to it
+func (s *OIDCServiceImpl) findClientForRedirect(req *AuthorizationRequest) (*database.OAuthClient, apperrors.AppError) {
+	if req.ClientID == "" {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidRequest, "client_id is required", nil)
+	}
+	client, err := s.clientRepo.FindClientByClientID(req.ClientID)
+	if isNotFound(err) {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidClient, "Unknown client", nil)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find OAuth client", err)
+	}
+
+	if req.RedirectURI == "" || !slices.Contains(strings.Fields(client.RedirectURIs), req.RedirectURI) {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidRequest, "redirect_uri is not registered for the client", nil)
+	}
+	return client, nil
+}
+
+// checkAuthorizationParams validates the rest of an authorization request and
+// returns the requested scopes
+func checkAuthorizationParams(client *database.OAuthClient, req *AuthorizationRequest) ([]string, apperrors.AppError) {
+	if req.ResponseType != "code" {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthUnsupportedResponseType, "Only the code response type is supported", nil)
+	}
+	// PKCE is required of every client, confidential ones included
+	if req.CodeChallenge == "" {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidRequest, "code_challenge is required", nil)
+	}
+	if req.CodeChallengeMethod != codeChallengeMethodS256 {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidRequest, "code_challenge_method must be S256", nil)
+	}
+	if len(req.Nonce) > maxNonceLength {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidRequest, "nonce is too long", nil)
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidScope, "scope is required", nil)
+	}
+	allowed := strings.Fields(client.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, apperrors.NewOAuthError(apperrors.ErrCodeOAuthInvalidScope, "Scope not allowed for the client: "+scope, nil)
+		}
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes), nil
+}
+
+// hasConsent reports whether the user already granted the client every scope
+func (s *OIDCServiceImpl) hasConsent(userID uint, clientID string, scopes []string) (bool, apperrors.AppError) {
+	consent, err := s.clientRepo.FindConsent(userID, clientID)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, apperrors.NewInternalError("Failed to find OAuth consent", err)
+	}
+
+	granted := strings.Fields(consent.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// grantConsent adds the scopes to what the user granted the client before
+func (s *OIDCServiceImpl) grantConsent(userID uint, clientID string, scopes []string) apperrors.AppError {
+	granted := slices.Clone(scopes)
+	consent, err := s.clientRepo.FindConsent(userID, clientID)
+	if err != nil && !isNotFound(err) {
+		return apperrors.NewInternalError("Failed to find OAuth consent", err)
+	}
+	if consent != nil {
+		granted = append(granted, strings.Fields(consent.Scopes)...)
+	}
+	slices.Sort(granted)
+
+	err = s.clientRepo.SaveConsent(&database.OAuthConsent{
+		UserID:   userID,
+		ClientID: clientID,
+		Scopes:   strings.Join(slices.Compact(granted), " "),
+	})
+	if err != nil {
+		return apperrors.NewInternalError("Failed to save OAuth consent", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Str("client_id", clientID).Strs("scopes", scopes).Msg("OAuth consent granted")
+	return nil
+}
+
+// redirect builds the redirect back to the client. The iss parameter lets
+// clients detect mix-up attacks (RFC 9207).
+func (s *OIDCServiceImpl) redirect(req *AuthorizationRequest, params url.Values) string {
+	redirectURI, _ := url.Parse(req.RedirectURI)
+	query := redirectURI.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	query.Set("iss", s.issuer)
+	redirectURI.RawQuery = query.Encode()
+	return redirectURI.String()
+}
+
+// errorRedirect reports an authorization error to the client (RFC 6749 section 4.1.2.1)
+func (s *OIDCServiceImpl) errorRedirect(req *AuthorizationRequest, err apperrors.AppError) string {
+	return s.redirect(req, url.Values{
+		"error":             {string(err.Code())},
+		"error_description": {err.Error()},
+	})
+}
+
+func (s *OIDCServiceImpl) findUser(userID uint) (*database.User, apperrors.AppError) {
+	user, err := s.repo.FindUserByID(userID)
+	if isNotFound(err) {
+		return nil, apperrors.NewNotFoundError("User not found", err, "user", userID)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find user", err)
+	}
+	return user, nil
+}
+
+// userInfo releases the user's claims for the granted scopes
+func userInfo(user *database.User, scopes []string) *UserInfo {
+	info := &UserInfo{Subject: strconv.FormatUint(uint64(user.ID), 10)}
+	if slices.Contains(scopes, ScopeProfile) {
+		info.PreferredUsername = user.Username
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		verified := user.EmailVerifiedAt != nil
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	return info
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against the S256 challenge
+// (RFC 7636 section 4.6)
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// validRedirectURI accepts absolute URIs without fragment that use https,
+// http on a loopback host, or a private-use scheme of a native app (RFC 8252)
+func validRedirectURI(redirectURI string) bool {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || !parsed.IsAbs() || parsed.Fragment != "" || strings.ContainsAny(redirectURI, " \t\n") {
+		return false
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		return parsed.Host != ""
+	case "http":
+		host := parsed.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		// Private-use schemes are reverse domain names
+		return strings.Contains(parsed.Scheme, ".")
+	}
+}
+
+// randomOAuthSecret generates a client secret or authorization code
+func randomOAuthSecret() (string, apperrors.AppError) {
+	random := make([]byte, oauthSecretSize)
+	if _, err := rand.Read(random); err != nil {
+		return "", apperrors.NewInternalError("Failed to generate secret", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// hashOAuthSecret returns the key client secrets and authorization codes are
+// stored under
+func hashOAuthSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
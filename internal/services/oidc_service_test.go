@@ -0,0 +1,224 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const (
+	oidcIssuer      = "https://id.example.com"
+	oidcRedirectURI = "https://app.example.com/callback"
+	codeVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// codeFromRedirect returns the code of a successful authorization redirect
+func codeFromRedirect(t *testing.T, redirectTo, state string) string {
+	redirect, err := url.Parse(redirectTo)
+	require.NoError(t, err)
+	assert.Equal(t, oidcRedirectURI, redirect.Scheme+"://"+redirect.Host+redirect.Path)
+	query := redirect.Query()
+	require.Empty(t, query.Get("error"), query.Get("error_description"))
+	assert.Equal(t, state, query.Get("state"))
+	assert.Equal(t, oidcIssuer, query.Get("iss"))
+	return query.Get("code")
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	signingKey, err := token.GenerateSigningKey(token.AlgorithmES256)
+	require.NoError(t, err)
+	tokenManager := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations,
+		token.WithAccessSigningKey(signingKey))
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager,
+		repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, revocations, zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop())
+
+	idTokens := token.NewIDTokenSigner(token.NewStaticKeyring(token.AccessToken, signingKey), oidcIssuer, 0)
+	oidc := services.NewOIDCService(userRepo, repository.NewOAuthClientRepository(db, zerolog.Nop()), tokenManager, idTokens,
+		authManager, oidcIssuer, zerolog.Nop())
+	ctx := context.Background()
+
+	user, err := authService.RegisterUser(ctx, "oidcuser", testPassword, "oidc@example.com")
+	require.NoError(t, err)
+
+	// Only well-formed redirect URIs can be registered
+	_, _, appErr := oidc.RegisterClient(ctx, services.ClientRegistration{
+		Name: "Insecure", RedirectURIs: []string{"http://app.example.com/callback"}, Scopes: []string{services.ScopeOpenID},
+	})
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeValidationError, appErr.Code())
+
+	client, secret, appErr := oidc.RegisterClient(ctx, services.ClientRegistration{
+		Name:         "Dashboard",
+		RedirectURIs: []string{oidcRedirectURI},
+		Scopes:       []string{services.ScopeOpenID, services.ScopeProfile, services.ScopeEmail},
+	})
+	require.NoError(t, appErr)
+	require.NotEmpty(t, secret)
+	assert.True(t, oidc.AuthenticateClient(client.ClientID, secret))
+	assert.False(t, oidc.AuthenticateClient(client.ClientID, "wrong"))
+	assert.False(t, oidc.AuthenticateClient(client.ClientID, ""))
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	req := &services.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            client.ClientID,
+		RedirectURI:         oidcRedirectURI,
+		Scope:               "openid profile email",
+		State:               "xyz",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(challenge[:]),
+		CodeChallengeMethod: "S256",
+	}
+
+	// An unregistered redirect URI is never redirected to
+	_, appErr = oidc.CheckAuthorizationRequest(ctx, &services.AuthorizationRequest{
+		ResponseType: "code", ClientID: client.ClientID, RedirectURI: "https://evil.example.com/callback",
+	})
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeOAuthInvalidRequest, appErr.Code())
+
+	// Other problems are reported back to the client
+	withoutPKCE := *req
+	withoutPKCE.CodeChallenge = ""
+	result, appErr := oidc.CheckAuthorizationRequest(ctx, &withoutPKCE)
+	require.NoError(t, appErr)
+	assert.Contains(t, result.RedirectTo, "error=invalid_request")
+	assert.Contains(t, result.RedirectTo, "state=xyz")
+
+	// The first authorization asks for consent
+	result, appErr = oidc.Authorize(ctx, user.ID, req, services.ConsentPending)
+	require.NoError(t, appErr)
+	assert.True(t, result.ConsentRequired)
+	assert.Equal(t, []string{"email", "openid", "profile"}, result.Scopes)
+
+	result, appErr = oidc.Authorize(ctx, user.ID, req, services.ConsentDenied)
+	require.NoError(t, appErr)
+	assert.Contains(t, result.RedirectTo, "error=access_denied")
+
+	result, appErr = oidc.Authorize(ctx, user.ID, req, services.ConsentApproved)
+	require.NoError(t, appErr)
+	code := codeFromRedirect(t, result.RedirectTo, "xyz")
+
+	// A wrong code verifier fails and uses up the code
+	_, appErr = oidc.ExchangeCode(ctx, client.ClientID, code, oidcRedirectURI, strings.Repeat("a", 43))
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeOAuthInvalidGrant, appErr.Code())
+	_, appErr = oidc.ExchangeCode(ctx, client.ClientID, code, oidcRedirectURI, codeVerifier)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeOAuthInvalidGrant, appErr.Code())
+
+	// Later authorizations reuse the consent
+	result, appErr = oidc.Authorize(ctx, user.ID, req, services.ConsentPending)
+	require.NoError(t, appErr)
+	code = codeFromRedirect(t, result.RedirectTo, "xyz")
+
+	_, appErr = oidc.ExchangeCode(ctx, client.ClientID, code, "https://app.example.com/other", codeVerifier)
+	require.Error(t, appErr)
+
+	result, appErr = oidc.Authorize(ctx, user.ID, req, services.ConsentPending)
+	require.NoError(t, appErr)
+	code = codeFromRedirect(t, result.RedirectTo, "xyz")
+	tokens, appErr := oidc.ExchangeCode(ctx, client.ClientID, code, oidcRedirectURI, codeVerifier)
+	require.NoError(t, appErr)
+	assert.Equal(t, []string{"email", "openid", "profile"}, tokens.Scopes)
+
+	// The ID token names the user, the client and the nonce, and binds the access token
+	idClaims, appErr := idTokens.ValidateIDToken(tokens.IDToken, client.ClientID)
+	require.NoError(t, appErr)
+	assert.Equal(t, strconv.FormatUint(uint64(user.ID), 10), idClaims.Subject)
+	assert.Equal(t, oidcIssuer, idClaims.Issuer)
+	assert.Equal(t, "n-0S6_WzA2Mj", idClaims.Nonce)
+	assert.Equal(t, "oidcuser", idClaims.PreferredUsername)
+	assert.Equal(t, idTokens.AccessTokenHash(tokens.AccessToken), idClaims.AccessTokenHash)
+	_, appErr = idTokens.ValidateIDToken(tokens.IDToken, "another-client")
+	assert.Error(t, appErr)
+
+	accessClaims, appErr := tokenManager.ValidateToken(tokens.AccessToken, token.AccessToken)
+	require.NoError(t, appErr)
+	assert.Equal(t, client.ClientID, accessClaims.ClientID)
+	assert.Equal(t, []string{"email", "openid", "profile"}, accessClaims.Permissions)
+
+	info, appErr := oidc.UserInfo(ctx, tokens.AccessToken)
+	require.NoError(t, appErr)
+	assert.Equal(t, "oidc@example.com", info.Email)
+	require.NotNil(t, info.EmailVerified)
+	assert.False(t, *info.EmailVerified)
+
+	// First-party tokens are not valid at the userinfo endpoint
+	firstParty, _, appErr := authService.LoginUser(ctx, "oidcuser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	_, appErr = oidc.UserInfo(ctx, firstParty.AccessToken)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeOAuthInsufficientScope, appErr.Code())
+
+	// Scopes beyond the client's registration are refused
+	greedy := *req
+	greedy.Scope = "openid write"
+	result, appErr = oidc.Authorize(ctx, user.ID, &greedy, services.ConsentPending)
+	require.NoError(t, appErr)
+	assert.Contains(t, result.RedirectTo, "error=invalid_scope")
+
+	// Revoking consent brings the consent screen back
+	require.NoError(t, oidc.RevokeConsent(ctx, user.ID, client.ClientID))
+	result, appErr = oidc.Authorize(ctx, user.ID, req, services.ConsentPending)
+	require.NoError(t, appErr)
+	assert.True(t, result.ConsentRequired)
+
+	require.NoError(t, oidc.DeleteClient(ctx, client.ClientID))
+	assert.False(t, oidc.AuthenticateClient(client.ClientID, secret))
+}
+
+func TestOIDCPublicClient(t *testing.T) {
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	tokenManager := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), nil)
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager,
+		repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	idTokens := token.NewIDTokenSigner(token.NewStaticKeyring(token.AccessToken, token.NewHMACSigningKey("secret_key")), oidcIssuer, 0)
+	oidc := services.NewOIDCService(userRepo, repository.NewOAuthClientRepository(db, zerolog.Nop()), tokenManager, idTokens,
+		authManager, oidcIssuer, zerolog.Nop())
+	ctx := context.Background()
+
+	client, secret, appErr := oidc.RegisterClient(ctx, services.ClientRegistration{
+		Name:         "CLI",
+		RedirectURIs: []string{"http://127.0.0.1:8400/callback"},
+		Scopes:       []string{"read"},
+		Public:       true,
+	})
+	require.NoError(t, appErr)
+	assert.Empty(t, secret)
+	assert.True(t, oidc.AuthenticateClient(client.ClientID, ""))
+	assert.False(t, oidc.AuthenticateClient(client.ClientID, "guess"))
+
+	// Public clients cannot skip PKCE, and plain challenges are refused
+	result, appErr := oidc.CheckAuthorizationRequest(ctx, &services.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            client.ClientID,
+		RedirectURI:         "http://127.0.0.1:8400/callback",
+		Scope:               "read",
+		CodeChallenge:       codeVerifier,
+		CodeChallengeMethod: "plain",
+	})
+	require.NoError(t, appErr)
+	assert.Contains(t, result.RedirectTo, "error=invalid_request")
+}
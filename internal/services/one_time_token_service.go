@@ -0,0 +1,216 @@
+// internal/services/one_time_token_service.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+)
+
+// oneTimeTokenSize is the number of random bytes in a one-time token
+const oneTimeTokenSize = 32
+
+// OneTimeTokenPurpose describes what a one-time token may be used for. A
+// token is bound to the value Binding returns for its user when it is issued
+// and stops working once that value changes.
+type OneTimeTokenPurpose struct {
+	Name    string
+	TTL     time.Duration
+	Binding func(user *database.User) string
+}
+
+var (
+	// PurposeEmailVerify tokens confirm the user's current email address
+	PurposeEmailVerify = OneTimeTokenPurpose{
+		Name:    "email_verify",
+		TTL:     24 * time.Hour,
+		Binding: func(user *database.User) string { return user.Email },
+	}
+	// PurposePasswordReset tokens are void once the password has changed
+	PurposePasswordReset = OneTimeTokenPurpose{
+		Name:    "password_reset",
+		TTL:     30 * time.Minute,
+		Binding: func(user *database.User) string { return user.Password },
+	}
+	// PurposeMFAChallenge tokens stand for a passed password check while the
+	// second factor is outstanding
+	PurposeMFAChallenge = OneTimeTokenPurpose{
+		Name:    "mfa_challenge",
+		TTL:     5 * time.Minute,
+		Binding: func(user *database.User) string { return user.Password },
+	}
+	// PurposeMagicLink tokens sign the user in and only work for the address
+	// they were mailed to
+	PurposeMagicLink = OneTimeTokenPurpose{
+		Name:    "magic_link",
+		TTL:     15 * time.Minute,
+		Binding: func(user *database.User) string { return user.Email },
+	}
+	// PurposeUnlock tokens lift the lock they were issued for
+	PurposeUnlock = OneTimeTokenPurpose{
+		Name:    "unlock",
+		TTL:     time.Hour,
+		Binding: func(user *database.User) string { return user.LockedUntil.UTC().Format(time.RFC3339Nano) },
+	}
+)
+
+// OneTimeTokenServiceImpl issues and redeems single-use tokens for account
+// flows. Tokens are random, stored only as hashes and consumed atomically.
+type OneTimeTokenServiceImpl struct {
+	logger    zerolog.Logger
+	repo      repository.UserRepository
+	tokenRepo repository.OneTimeTokenRepository
+	ttls      map[string]time.Duration
+}
+
+// OneTimeTokenServiceOption customizes a OneTimeTokenServiceImpl
+type OneTimeTokenServiceOption func(*OneTimeTokenServiceImpl)
+
+// WithOneTimeTokenTTL overrides the lifetime of tokens issued for a purpose
+func WithOneTimeTokenTTL(purpose OneTimeTokenPurpose, ttl time.Duration) OneTimeTokenServiceOption {
+	return func(s *OneTimeTokenServiceImpl) {
+		s.ttls[purpose.Name] = ttl
+	}
+}
+
+func NewOneTimeTokenService(repo repository.UserRepository,
+	tokenRepo repository.OneTimeTokenRepository,
+	logger zerolog.Logger,
+	opts ...OneTimeTokenServiceOption) *OneTimeTokenServiceImpl {
+	s := &OneTimeTokenServiceImpl{
+		logger:    logger.With().Str("service", "OneTimeTokenService").Logger(),
+		repo:      repo,
+		tokenRepo: tokenRepo,
+		ttls:      make(map[string]time.Duration),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// IssueToken creates a token for the purpose and voids the user's earlier
+// unused tokens for it, so only the latest one sent out works
+func (s *OneTimeTokenServiceImpl) IssueToken(ctx context.Context, purpose OneTimeTokenPurpose, user *database.User) (string, apperrors.AppError) {
+	random := make([]byte, oneTimeTokenSize)
+	if _, err := rand.Read(random); err != nil {
+		return "", apperrors.NewInternalError("Failed to generate one-time token", err)
+	}
+	tokenString := base64.RawURLEncoding.EncodeToString(random)
+
+	if err := s.tokenRepo.ConsumeOutstandingTokens(user.ID, purpose.Name); err != nil {
+		return "", apperrors.NewInternalError("Failed to void earlier one-time tokens", err)
+	}
+
+	oneTimeToken := &database.OneTimeToken{
+		TokenHash:   hashOneTimeToken(tokenString),
+		Purpose:     purpose.Name,
+		UserID:      user.ID,
+		BindingHash: bindingHash(purpose, user),
+		ExpiresAt:   time.Now().Add(s.ttl(purpose)),
+	}
+	if err := s.tokenRepo.CreateOneTimeToken(oneTimeToken); err != nil {
+		return "", apperrors.NewInternalError("Failed to store one-time token", err)
+	}
+
+	s.logger.Info().
+		Uint("user_id", user.ID).
+		Str("purpose", purpose.Name).
+		Time("expires_at", oneTimeToken.ExpiresAt).
+		Msg("One-time token issued")
+
+	return tokenString, nil
+}
+
+// ConsumeToken redeems a token for the purpose and returns the user it was
+// issued to. A token can only be consumed once.
+func (s *OneTimeTokenServiceImpl) ConsumeToken(ctx context.Context, purpose OneTimeTokenPurpose, tokenString string) (*database.User, apperrors.AppError) {
+	oneTimeToken, user, appErr := s.findValidToken(purpose, tokenString)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	consumed, err := s.tokenRepo.ConsumeOneTimeToken(oneTimeToken.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to consume one-time token", err)
+	}
+	if !consumed {
+		// Another request redeemed the token between the lookup and now
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenAlreadyUsed, "Token has already been used", nil)
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Str("purpose", purpose.Name).Msg("One-time token consumed")
+	return user, nil
+}
+
+// PeekToken checks a token for the purpose like ConsumeToken but leaves it
+// unused, for flows that redeem it only after a further check succeeds
+func (s *OneTimeTokenServiceImpl) PeekToken(ctx context.Context, purpose OneTimeTokenPurpose, tokenString string) (*database.User, apperrors.AppError) {
+	_, user, err := s.findValidToken(purpose, tokenString)
+	return user, err
+}
+
+// findValidToken looks up an unused, unexpired token for the purpose whose
+// binding still matches its user
+func (s *OneTimeTokenServiceImpl) findValidToken(purpose OneTimeTokenPurpose, tokenString string) (*database.OneTimeToken, *database.User, apperrors.AppError) {
+	oneTimeToken, err := s.tokenRepo.FindOneTimeTokenByHash(hashOneTimeToken(tokenString))
+	if isNotFound(err) {
+		return nil, nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidToken, "Token is not valid", nil)
+	}
+	if err != nil {
+		return nil, nil, apperrors.NewInternalError("Failed to find one-time token", err)
+	}
+	// Tokens of other purposes are reported as unknown
+	if oneTimeToken.Purpose != purpose.Name {
+		return nil, nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidToken, "Token is not valid", nil)
+	}
+	if oneTimeToken.ConsumedAt != nil {
+		return nil, nil, apperrors.NewTokenError(apperrors.ErrCodeTokenAlreadyUsed, "Token has already been used", nil)
+	}
+	if !time.Now().Before(oneTimeToken.ExpiresAt) {
+		return nil, nil, apperrors.NewTokenError(apperrors.ErrCodeTokenExpired, "Token has expired", nil)
+	}
+
+	user, err := s.repo.FindUserByID(oneTimeToken.UserID)
+	if isNotFound(err) {
+		return nil, nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidToken, "Token is not valid", nil)
+	}
+	if err != nil {
+		return nil, nil, apperrors.NewInternalError("Failed to find user", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(oneTimeToken.BindingHash), []byte(bindingHash(purpose, user))) != 1 {
+		return nil, nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidToken, "Token is no longer valid", nil)
+	}
+
+	return oneTimeToken, user, nil
+}
+
+func (s *OneTimeTokenServiceImpl) ttl(purpose OneTimeTokenPurpose) time.Duration {
+	if ttl, ok := s.ttls[purpose.Name]; ok {
+		return ttl
+	}
+	return purpose.TTL
+}
+
+// hashOneTimeToken returns the key a one-time token is stored under
+func hashOneTimeToken(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return hex.EncodeToString(sum[:])
+}
+
+// bindingHash hashes the user field the purpose binds its tokens to
+func bindingHash(purpose OneTimeTokenPurpose, user *database.User) string {
+	sum := sha256.Sum256([]byte(purpose.Name + ":" + purpose.Binding(user)))
+	return hex.EncodeToString(sum[:])
+}
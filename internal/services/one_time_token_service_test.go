@@ -0,0 +1,92 @@
+package services_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+)
+
+func TestOneTimeTokens(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	user, err := ts.auth.RegisterUser(ctx, "onetimeuser", testPassword, "onetime@example.com")
+	require.NoError(t, err)
+	oneTimeTokens := services.NewOneTimeTokenService(ts.userRepo, repository.NewOneTimeTokenRepository(ts.db, zerolog.Nop()), zerolog.Nop())
+
+	tokenString, appErr := oneTimeTokens.IssueToken(ctx, services.PurposeEmailVerify, user)
+	require.NoError(t, appErr)
+
+	// Tokens only work for the purpose they were issued for
+	_, appErr = oneTimeTokens.ConsumeToken(ctx, services.PurposePasswordReset, tokenString)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidToken, appErr.Code())
+
+	consumer, appErr := oneTimeTokens.ConsumeToken(ctx, services.PurposeEmailVerify, tokenString)
+	require.NoError(t, appErr)
+	assert.Equal(t, user.ID, consumer.ID)
+
+	_, appErr = oneTimeTokens.ConsumeToken(ctx, services.PurposeEmailVerify, tokenString)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeTokenAlreadyUsed, appErr.Code())
+
+	// Issuing a new token voids the previous one
+	first, appErr := oneTimeTokens.IssueToken(ctx, services.PurposeEmailVerify, user)
+	require.NoError(t, appErr)
+	second, appErr := oneTimeTokens.IssueToken(ctx, services.PurposeEmailVerify, user)
+	require.NoError(t, appErr)
+	_, appErr = oneTimeTokens.ConsumeToken(ctx, services.PurposeEmailVerify, first)
+	assert.Error(t, appErr)
+
+	// Changing the bound field voids outstanding tokens
+	user.Email = "changed@example.com"
+	require.NoError(t, ts.userRepo.UpdateUser(user))
+	_, appErr = oneTimeTokens.ConsumeToken(ctx, services.PurposeEmailVerify, second)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidToken, appErr.Code())
+}
+
+func TestOneTimeTokenExpiryAndConcurrentConsumption(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	user, err := ts.auth.RegisterUser(ctx, "onetimeraceuser", testPassword, "onetimerace@example.com")
+	require.NoError(t, err)
+	tokenRepo := repository.NewOneTimeTokenRepository(ts.db, zerolog.Nop())
+
+	expiring := services.NewOneTimeTokenService(ts.userRepo, tokenRepo, zerolog.Nop(),
+		services.WithOneTimeTokenTTL(services.PurposeUnlock, -time.Minute))
+	expired, appErr := expiring.IssueToken(ctx, services.PurposeUnlock, user)
+	require.NoError(t, appErr)
+	_, appErr = expiring.ConsumeToken(ctx, services.PurposeUnlock, expired)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeTokenExpired, appErr.Code())
+
+	oneTimeTokens := services.NewOneTimeTokenService(ts.userRepo, tokenRepo, zerolog.Nop())
+	tokenString, appErr := oneTimeTokens.IssueToken(ctx, services.PurposePasswordReset, user)
+	require.NoError(t, appErr)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := oneTimeTokens.ConsumeToken(ctx, services.PurposePasswordReset, tokenString); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, succeeded)
+}
@@ -0,0 +1,148 @@
+// internal/services/password_reset_service.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/mailer"
+	"github.com/yourusername/user-management-api/pkg/utils"
+)
+
+// PasswordResetServiceImpl lets users who forgot their password set a new one
+// through a one-time token mailed to their address
+type PasswordResetServiceImpl struct {
+	logger           zerolog.Logger
+	repo             repository.UserRepository
+	loginAttemptRepo repository.LoginAttemptRepository
+	sessions         *SessionServiceImpl
+	oneTimeTokens    *OneTimeTokenServiceImpl
+	mailer           mailer.Mailer
+	resetURL         string
+}
+
+// PasswordResetServiceOption customizes a PasswordResetServiceImpl
+type PasswordResetServiceOption func(*PasswordResetServiceImpl)
+
+// WithPasswordResetURL sets the page password reset emails link to. The token
+// is appended as the token query parameter.
+func WithPasswordResetURL(resetURL string) PasswordResetServiceOption {
+	return func(s *PasswordResetServiceImpl) {
+		s.resetURL = resetURL
+	}
+}
+
+func NewPasswordResetService(oneTimeTokens *OneTimeTokenServiceImpl,
+	mailer mailer.Mailer,
+	repo repository.UserRepository,
+	loginAttemptRepo repository.LoginAttemptRepository,
+	sessions *SessionServiceImpl,
+	logger zerolog.Logger,
+	opts ...PasswordResetServiceOption) *PasswordResetServiceImpl {
+	s := &PasswordResetServiceImpl{
+		logger:           logger.With().Str("service", "PasswordResetService").Logger(),
+		repo:             repo,
+		loginAttemptRepo: loginAttemptRepo,
+		sessions:         sessions,
+		oneTimeTokens:    oneTimeTokens,
+		mailer:           mailer,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// RequestPasswordReset mails a reset token to the user registered with the
+// email address. Unknown addresses are silently ignored so callers cannot
+// find out which addresses are registered.
+func (s *PasswordResetServiceImpl) RequestPasswordReset(ctx context.Context, email string) apperrors.AppError {
+	user, err := s.repo.FindUserByEmail(email)
+	if isNotFound(err) {
+		s.logger.Debug().Msg("Password reset requested for unknown email address")
+		return nil
+	}
+	if err != nil {
+		return apperrors.NewInternalError("Failed to find user", err)
+	}
+
+	tokenString, appErr := s.oneTimeTokens.IssueToken(ctx, PurposePasswordReset, user)
+	if appErr != nil {
+		return appErr
+	}
+
+	if sendErr := s.mailer.Send(ctx, s.resetMessage(user, tokenString)); sendErr != nil {
+		s.logger.Error().Err(sendErr).Uint("user_id", user.ID).Msg("Failed to send password reset email")
+		return apperrors.NewInternalError("Failed to send password reset email", sendErr)
+	}
+
+	s.logger.Info().Uint("user_id", user.ID).Msg("Password reset email sent")
+	return nil
+}
+
+// ResetPassword redeems a reset token and sets the new password. Every token
+// and session of the user is revoked and their failed logins are forgotten.
+func (s *PasswordResetServiceImpl) ResetPassword(ctx context.Context, tokenString, newPassword string) apperrors.AppError {
+	// Check the password first so a rejected one does not use up the token
+	validator := &utils.PasswordValidatorImpl{}
+	sanitizedPassword := validator.SanitizePassword(newPassword)
+	if result := validator.ValidatePassword(sanitizedPassword); !result.IsValid {
+		return apperrors.NewValidationErrors(
+			"Password does not meet complexity requirements: "+strings.Join(result.Errors, ", "), nil)
+	}
+
+	user, appErr := s.oneTimeTokens.ConsumeToken(ctx, PurposePasswordReset, tokenString)
+	if appErr != nil {
+		return appErr
+	}
+
+	update := &database.User{ID: user.ID, Password: sanitizedPassword, TokenVersion: user.TokenVersion}
+	if err := update.HashPassword(); err != nil {
+		return apperrors.NewInternalError("Failed to hash password", err)
+	}
+	// Tokens issued with the old password must not outlive it
+	update.InvalidateTokens()
+	if err := s.repo.UpdateUser(update); err != nil {
+		return apperrors.NewInternalError("Failed to update password", err)
+	}
+
+	revoked, appErr := s.sessions.RevokeAllSessions(ctx, user.ID)
+	if appErr != nil {
+		return appErr
+	}
+
+	if err := s.loginAttemptRepo.ClearLoginAttempts(user.Username); err != nil {
+		return apperrors.NewInternalError("Failed to clear login attempts", err)
+	}
+
+	s.logger.Info().
+		Uint("user_id", user.ID).
+		Str("username", user.Username).
+		Int("sessions_revoked", revoked).
+		Msg("Password reset")
+	return nil
+}
+
+func (s *PasswordResetServiceImpl) resetMessage(user *database.User, tokenString string) mailer.Message {
+	body := fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password of your account", user.Username)
+	if s.resetURL != "" {
+		body += fmt.Sprintf(". Open the link below to choose a new one:\n\n%s\n", withQueryParam(s.resetURL, "token", tokenString))
+	} else {
+		body += fmt.Sprintf(". Use this code to choose a new one:\n\n%s\n", tokenString)
+	}
+	body += fmt.Sprintf("\nIt expires in %s. If you did not ask for this, you can ignore this email and your password stays the same.\n",
+		s.oneTimeTokens.ttl(PurposePasswordReset))
+
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	}
+}
@@ -0,0 +1,41 @@
+// internal/services/security_event_service.go
+package services
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+)
+
+// maxSecurityEvents caps the events returned by one query
+const maxSecurityEvents = 1000
+
+// SecurityEventServiceImpl lets admins look into the attack patterns the
+// login detector recorded
+type SecurityEventServiceImpl struct {
+	events repository.SecurityEventRepository
+	logger zerolog.Logger
+}
+
+func NewSecurityEventService(events repository.SecurityEventRepository, logger zerolog.Logger) *SecurityEventServiceImpl {
+	return &SecurityEventServiceImpl{
+		events: events,
+		logger: logger.With().Str("service", "SecurityEventService").Logger(),
+	}
+}
+
+// ListEvents returns the security events matching the filter, newest first
+func (s *SecurityEventServiceImpl) ListEvents(ctx context.Context, filter repository.SecurityEventFilter) ([]database.SecurityEvent, apperrors.AppError) {
+	if filter.Limit < 0 || filter.Limit > maxSecurityEvents {
+		return nil, apperrors.NewValidationErrors("Limit must be between 1 and 1000", nil)
+	}
+
+	events, err := s.events.FindEvents(filter)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to list security events", err)
+	}
+	return events, nil
+}
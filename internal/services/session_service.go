@@ -0,0 +1,248 @@
+// internal/services/session_service.go
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// SessionServiceImpl keeps the registry of logged-in devices. A session lives
+// as long as its refresh token family and is identified by the family ID.
+type SessionServiceImpl struct {
+	logger           zerolog.Logger
+	sessionRepo      repository.SessionRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	revocations      token.RevocationStore
+	maxSessions      int
+}
+
+// SessionServiceOption customizes a SessionServiceImpl
+type SessionServiceOption func(*SessionServiceImpl)
+
+// WithMaxSessions caps the concurrent sessions per user. Starting a session
+// beyond the cap revokes the oldest ones. Zero means no cap.
+func WithMaxSessions(maxSessions int) SessionServiceOption {
+	return func(s *SessionServiceImpl) {
+		s.maxSessions = maxSessions
+	}
+}
+
+func NewSessionService(sessionRepo repository.SessionRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	revocations token.RevocationStore,
+	logger zerolog.Logger,
+	opts ...SessionServiceOption) *SessionServiceImpl {
+	s := &SessionServiceImpl{
+		logger:           logger.With().Str("service", "SessionService").Logger(),
+		sessionRepo:      sessionRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		revocations:      revocations,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// StartSession records a new login and enforces the session cap
+func (s *SessionServiceImpl) StartSession(device token.Device, accessClaims, refreshClaims *token.Claims) apperrors.AppError {
+	if s.maxSessions > 0 {
+		if err := s.evictOldestSessions(refreshClaims.UserID, s.maxSessions-1); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	session := &database.Session{
+		ID:         refreshClaims.FamilyID,
+		UserID:     refreshClaims.UserID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	applySessionTokens(session, device, accessClaims, refreshClaims)
+
+	if err := s.sessionRepo.CreateSession(session); err != nil {
+		return apperrors.NewInternalError("Failed to create session", err)
+	}
+	return nil
+}
+
+// TouchSession records a refresh of the session and links it to the new tokens
+func (s *SessionServiceImpl) TouchSession(device token.Device, accessClaims, refreshClaims *token.Claims) apperrors.AppError {
+	session, err := s.sessionRepo.FindSessionByID(refreshClaims.FamilyID)
+	if isNotFound(err) {
+		// Token families from before sessions were tracked
+		return s.StartSession(device, accessClaims, refreshClaims)
+	}
+	if err != nil {
+		return apperrors.NewInternalError("Failed to find session", err)
+	}
+
+	session.LastSeenAt = time.Now()
+	applySessionTokens(session, device, accessClaims, refreshClaims)
+
+	if err := s.sessionRepo.UpdateSession(session); err != nil {
+		return apperrors.NewInternalError("Failed to update session", err)
+	}
+	return nil
+}
+
+// ListSessions returns the user's active sessions, oldest first
+func (s *SessionServiceImpl) ListSessions(ctx context.Context, userID uint) ([]database.Session, apperrors.AppError) {
+	sessions, err := s.sessionRepo.FindActiveSessionsByUserID(userID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to list sessions", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs out one of the user's sessions
+func (s *SessionServiceImpl) RevokeSession(ctx context.Context, userID uint, sessionID string) apperrors.AppError {
+	session, err := s.sessionRepo.FindSessionByID(sessionID)
+	if err != nil && !isNotFound(err) {
+		return apperrors.NewInternalError("Failed to find session", err)
+	}
+	// Other users' sessions are reported as missing so their IDs cannot be probed
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return apperrors.NewNotFoundError("Session not found", nil, "session", sessionID)
+	}
+
+	return s.revokeSession(session, "user_logout")
+}
+
+// RevokeOtherSessions logs out every session of the user except the current one
+func (s *SessionServiceImpl) RevokeOtherSessions(ctx context.Context, userID uint, currentSessionID string) (int, apperrors.AppError) {
+	sessions, err := s.sessionRepo.FindActiveSessionsByUserID(userID)
+	if err != nil {
+		return 0, apperrors.NewInternalError("Failed to list sessions", err)
+	}
+
+	revoked := 0
+	for i := range sessions {
+		if sessions[i].ID == currentSessionID {
+			continue
+		}
+		if err := s.revokeSession(&sessions[i], "logout_other_sessions"); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// RevokeAllSessions logs out every session of the user, including refresh
+// token families that were never recorded as sessions
+func (s *SessionServiceImpl) RevokeAllSessions(ctx context.Context, userID uint) (int, apperrors.AppError) {
+	sessions, err := s.sessionRepo.FindActiveSessionsByUserID(userID)
+	if err != nil {
+		return 0, apperrors.NewInternalError("Failed to list sessions", err)
+	}
+
+	for i := range sessions {
+		if err := s.revokeSession(&sessions[i], "logout_all"); err != nil {
+			return i, err
+		}
+	}
+
+	if err := s.refreshTokenRepo.RevokeUserRefreshTokens(userID); err != nil {
+		return len(sessions), apperrors.NewInternalError("Failed to revoke refresh tokens", err)
+	}
+	return len(sessions), nil
+}
+
+// EndSession logs out the session a token belongs to. Unlike RevokeSession it
+// tolerates token families that were never recorded as sessions.
+func (s *SessionServiceImpl) EndSession(ctx context.Context, userID uint, sessionID string) apperrors.AppError {
+	return s.endSession(userID, sessionID, "logout")
+}
+
+// EndCompromisedSession cuts off the session of a replayed refresh token,
+// including the access tokens already issued to it
+func (s *SessionServiceImpl) EndCompromisedSession(ctx context.Context, userID uint, sessionID string) apperrors.AppError {
+	return s.endSession(userID, sessionID, "refresh_token_reuse")
+}
+
+func (s *SessionServiceImpl) endSession(userID uint, sessionID, reason string) apperrors.AppError {
+	session, err := s.sessionRepo.FindSessionByID(sessionID)
+	if err != nil && !isNotFound(err) {
+		return apperrors.NewInternalError("Failed to find session", err)
+	}
+	if session != nil {
+		if session.UserID != userID {
+			return apperrors.NewNotFoundError("Session not found", nil, "session", sessionID)
+		}
+		return s.revokeSession(session, reason)
+	}
+
+	if err := s.refreshTokenRepo.RevokeFamily(sessionID); err != nil {
+		return apperrors.NewInternalError("Failed to revoke refresh token family", err)
+	}
+	return nil
+}
+
+// evictOldestSessions revokes the user's oldest sessions until at most keep remain
+func (s *SessionServiceImpl) evictOldestSessions(userID uint, keep int) apperrors.AppError {
+	sessions, err := s.sessionRepo.FindActiveSessionsByUserID(userID)
+	if err != nil {
+		return apperrors.NewInternalError("Failed to list sessions", err)
+	}
+
+	for i := 0; i < len(sessions)-keep; i++ {
+		if err := s.revokeSession(&sessions[i], "session_limit"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeSession revokes the session, its refresh token family and every access
+// token issued to it. The latest access token expires last, so the revocation
+// lasts as long as it does.
+func (s *SessionServiceImpl) revokeSession(session *database.Session, reason string) apperrors.AppError {
+	if _, err := s.sessionRepo.RevokeSession(session.ID); err != nil {
+		return apperrors.NewInternalError("Failed to revoke session", err)
+	}
+
+	if err := s.refreshTokenRepo.RevokeFamily(session.ID); err != nil {
+		return apperrors.NewInternalError("Failed to revoke refresh token family", err)
+	}
+
+	if session.AccessTokenJTI != "" && session.AccessTokenExpiresAt.After(time.Now()) {
+		if err := token.RevokeFamily(s.revocations, session.ID, session.AccessTokenExpiresAt); err != nil {
+			return apperrors.NewInternalError("Failed to revoke access tokens", err)
+		}
+	}
+
+	s.logger.Info().
+		Str("session_id", session.ID).
+		Uint("user_id", session.UserID).
+		Str("reason", reason).
+		Msg("Session revoked")
+
+	return nil
+}
+
+// applySessionTokens links the session to the latest tokens and the device they were issued to
+func applySessionTokens(session *database.Session, device token.Device, accessClaims, refreshClaims *token.Claims) {
+	session.IPAddress = device.IP
+	session.UserAgent = device.UserAgent
+	session.DeviceID = device.DeviceID
+	session.DeviceFingerprint = refreshClaims.DeviceFingerprint
+	session.ExpiresAt = refreshClaims.ExpiresAt.Time
+	session.AccessTokenJTI = accessClaims.ID
+	session.AccessTokenExpiresAt = accessClaims.ExpiresAt.Time
+}
+
+// isNotFound reports whether a repository error means the record does not exist
+func isNotFound(err error) bool {
+	appErr, ok := err.(apperrors.AppError)
+	return ok && appErr.Code() == apperrors.ErrCodeNotFound
+}
@@ -0,0 +1,135 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func TestLoginRecordsSession(t *testing.T) {
+	ts := newTestServices(t, nil)
+	authService, sessionService := ts.auth, ts.sessions
+	ctx := context.Background()
+	refreshToken := loginTestUser(t, authService, "sessionuser")
+
+	claims, err := authService.ValidateRefreshToken(ctx, refreshToken)
+	require.NoError(t, err)
+
+	sessions, err := sessionService.ListSessions(ctx, claims.UserID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, claims.FamilyID, sessions[0].ID)
+	assert.Equal(t, testDevice.IP, sessions[0].IPAddress)
+	assert.Equal(t, testDevice.UserAgent, sessions[0].UserAgent)
+	firstSeen := sessions[0].LastSeenAt
+
+	// Refreshing keeps the session and updates when it was last seen
+	_, err = authService.RefreshTokens(ctx, refreshToken, testDevice)
+	require.NoError(t, err)
+	sessions, err = sessionService.ListSessions(ctx, claims.UserID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].LastSeenAt.After(firstSeen))
+}
+
+func TestRevokeSessionRevokesItsTokens(t *testing.T) {
+	ts := newTestServices(t, nil)
+	authService, sessionService, tokenManager := ts.auth, ts.sessions, ts.tokenManager
+	ctx := context.Background()
+	loginTestUser(t, authService, "revokesessionuser")
+
+	// A second login from another device
+	otherDevice := token.Device{IP: "203.0.113.7", UserAgent: "curl/8.0"}
+	other, _, err := authService.LoginUser(ctx, "revokesessionuser", testPassword, otherDevice)
+	require.NoError(t, err)
+	otherClaims, err := tokenManager.ValidateToken(other.AccessToken, token.AccessToken)
+	require.NoError(t, err)
+
+	sessions, err := sessionService.ListSessions(ctx, otherClaims.UserID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	current := sessions[0].ID
+
+	// Sessions of other users cannot be revoked
+	err = sessionService.RevokeSession(ctx, otherClaims.UserID+1, otherClaims.FamilyID)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeNotFound, err.Code())
+
+	revoked, err := sessionService.RevokeOtherSessions(ctx, otherClaims.UserID, current)
+	require.NoError(t, err)
+	assert.Equal(t, 1, revoked)
+
+	// Both the access and the refresh token of the revoked session stop working
+	_, err = tokenManager.ValidateToken(other.AccessToken, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenBlacklisted, err.Code())
+	_, err = authService.RefreshTokens(ctx, other.RefreshToken, otherDevice)
+	assert.Error(t, err)
+
+	sessions, err = sessionService.ListSessions(ctx, otherClaims.UserID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current, sessions[0].ID)
+}
+
+func TestSessionCapEvictsOldestSession(t *testing.T) {
+	ts := newTestServices(t, []services.SessionServiceOption{services.WithMaxSessions(2)})
+	authService, sessionService := ts.auth, ts.sessions
+	ctx := context.Background()
+	oldest := loginTestUser(t, authService, "capuser")
+
+	var newest string
+	for i := 0; i < 2; i++ {
+		tokens, _, err := authService.LoginUser(ctx, "capuser", testPassword, testDevice)
+		require.NoError(t, err)
+		newest = tokens.RefreshToken
+	}
+
+	claims, err := authService.ValidateRefreshToken(ctx, newest)
+	require.NoError(t, err)
+	sessions, err := sessionService.ListSessions(ctx, claims.UserID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	_, err = authService.RefreshTokens(ctx, oldest, testDevice)
+	assert.Error(t, err)
+}
+
+func TestRevokeSessionRevokesEarlierAccessTokens(t *testing.T) {
+	ts := newTestServices(t, nil)
+	authService, sessionService, tokenManager := ts.auth, ts.sessions, ts.tokenManager
+	ctx := context.Background()
+	loginTestUser(t, authService, "refreshedsessionuser")
+
+	first, _, err := authService.LoginUser(ctx, "refreshedsessionuser", testPassword, testDevice)
+	require.NoError(t, err)
+	claims, err := tokenManager.ValidateToken(first.AccessToken, token.AccessToken)
+	require.NoError(t, err)
+
+	// Refreshing leaves the first access token valid until it expires
+	refreshed, err := authService.RefreshTokens(ctx, first.RefreshToken, testDevice)
+	require.NoError(t, err)
+	_, err = tokenManager.ValidateToken(first.AccessToken, token.AccessToken)
+	require.NoError(t, err)
+
+	require.NoError(t, sessionService.RevokeSession(ctx, claims.UserID, claims.FamilyID))
+
+	// Every access token issued to the session is rejected, not only the latest
+	for _, accessToken := range []string{first.AccessToken, refreshed.AccessToken} {
+		_, err = tokenManager.ValidateToken(accessToken, token.AccessToken)
+		require.Error(t, err)
+		assert.Equal(t, apperrors.ErrCodeTokenBlacklisted, err.Code())
+	}
+
+	// Other sessions of the user keep working
+	other, _, err := authService.LoginUser(ctx, "refreshedsessionuser", testPassword, testDevice)
+	require.NoError(t, err)
+	_, err = tokenManager.ValidateToken(other.AccessToken, token.AccessToken)
+	assert.NoError(t, err)
+}
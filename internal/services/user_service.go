@@ -5,17 +5,31 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/yourusername/user-management-api/internal/database"
 	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/passwordhash"
 )
 
+// PepperReport counts the password hashes per pepper, to tell when an old
+// pepper can be dropped after a rotation
+type PepperReport struct {
+	CurrentKeyID string
+	// Hashes counts the hashes per pepper key ID; hashes without a pepper
+	// count under ""
+	Hashes map[string]int64
+	// Outdated counts the hashes not made with the current pepper
+	Outdated int64
+}
+
 type UserServiceImpl struct {
-	repo   repository.UserRepository
-	logger zerolog.Logger
+	repo             repository.UserRepository
+	loginAttemptRepo repository.LoginAttemptRepository
+	logger           zerolog.Logger
 }
 
-func NewUserService(repo repository.UserRepository, logger zerolog.Logger) *UserServiceImpl {
+func NewUserService(repo repository.UserRepository, loginAttemptRepo repository.LoginAttemptRepository, logger zerolog.Logger) *UserServiceImpl {
 	return &UserServiceImpl{
-		repo:   repo,
-		logger: logger.With().Str("service", "UserService").Logger(),
+		repo:             repo,
+		loginAttemptRepo: loginAttemptRepo,
+		logger:           logger.With().Str("service", "UserService").Logger(),
 	}
 }
 
@@ -39,6 +53,46 @@ func (s *UserServiceImpl) DeleteUser(userID uint) error {
 	return s.repo.DeleteUser(userID)
 }
 
+// UnlockUser lifts an administrator's lock of the user and the lockouts
+// their failed logins caused from any IP address, including permanent ones
+func (s *UserServiceImpl) UnlockUser(userID uint) error {
+	user, err := s.repo.FindUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Status == database.UserStatusLocked {
+		if err := s.repo.UnlockUser(userID); err != nil {
+			return err
+		}
+	}
+	if err := s.loginAttemptRepo.ClearLoginAttempts(user.Username); err != nil {
+		return err
+	}
+
+	s.logger.Info().Uint("user_id", userID).Str("username", user.Username).Msg("User unlocked")
+	return nil
+}
+
+// PepperReport counts how many password hashes are still on peppers other
+// than currentKeyID. They are re-peppered as their users log in.
+func (s *UserServiceImpl) PepperReport(currentKeyID string) (*PepperReport, error) {
+	hashes, err := s.repo.FindPasswordHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PepperReport{CurrentKeyID: currentKeyID, Hashes: make(map[string]int64)}
+	for _, hash := range hashes {
+		keyID := passwordhash.PepperKeyID(hash)
+		report.Hashes[keyID]++
+		if keyID != currentKeyID {
+			report.Outdated++
+		}
+	}
+	return report, nil
+}
+
 func (s *UserServiceImpl) validateUser(user *database.User) error {
 	_, err := s.repo.FindUserByID(user.ID)
 	if err != nil {
@@ -0,0 +1,362 @@
+// internal/services/webauthn_service.go
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+)
+
+// WebAuthn ceremonies. Each challenge is only accepted by the ceremony it was
+// issued for, so a second factor assertion cannot stand in for a passwordless
+// login that requires user verification.
+const (
+	ceremonyRegistration = "registration"
+	ceremonyPasswordless = "passwordless"
+	ceremonySecondFactor = "second_factor"
+
+	defaultPasskeyName = "Passkey"
+)
+
+// WebAuthnServiceImpl registers passkeys and verifies them for passwordless
+// logins and as a second factor
+type WebAuthnServiceImpl struct {
+	logger      zerolog.Logger
+	repo        repository.UserRepository
+	credentials repository.WebAuthnRepository
+	rp          *webauthn.RelyingParty
+}
+
+func NewWebAuthnService(rp *webauthn.RelyingParty,
+	repo repository.UserRepository,
+	credentials repository.WebAuthnRepository,
+	logger zerolog.Logger) *WebAuthnServiceImpl {
+	return &WebAuthnServiceImpl{
+		logger:      logger.With().Str("service", "WebAuthnService").Logger(),
+		repo:        repo,
+		credentials: credentials,
+		rp:          rp,
+	}
+}
+
+// BeginRegistration starts registering a new passkey for the user
+func (s *WebAuthnServiceImpl) BeginRegistration(ctx context.Context, userID uint) (*webauthn.CreationOptions, apperrors.AppError) {
+	user, appErr := s.findUser(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	// Authenticators refuse to register a second credential for the account
+	exclude, appErr := s.descriptors(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	options, err := s.rp.BeginRegistration(webauthn.User{
+		Handle:      userHandle(user.ID),
+		Name:        user.Username,
+		DisplayName: user.Username,
+	}, exclude, webauthn.VerificationPreferred)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to start passkey registration", err)
+	}
+
+	if appErr := s.saveChallenge(options.Challenge, ceremonyRegistration, user.ID, webauthn.VerificationPreferred); appErr != nil {
+		return nil, appErr
+	}
+	return options, nil
+}
+
+// FinishRegistration verifies the authenticator's response and stores the
+// new credential under the given name
+func (s *WebAuthnServiceImpl) FinishRegistration(ctx context.Context, userID uint, name string, response *webauthn.RegistrationCredential) (*database.WebAuthnCredential, apperrors.AppError) {
+	registration, err := s.rp.ParseRegistration(response)
+	if err != nil {
+		return nil, apperrors.NewValidationErrors("Invalid passkey registration", err)
+	}
+
+	challenge, appErr := s.consumeChallenge(registration.Challenge, ceremonyRegistration)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if challenge.UserID != userID {
+		return nil, apperrors.NewValidationErrors("Passkey registration was started by another user", nil)
+	}
+
+	credential, err := s.rp.VerifyRegistration(registration, registration.Challenge, webauthn.UserVerification(challenge.UserVerification))
+	if err != nil {
+		s.logger.Warn().Err(err).Uint("user_id", userID).Msg("Passkey registration failed verification")
+		return nil, apperrors.NewValidationErrors("Invalid passkey registration", err)
+	}
+
+	credentialID := webauthn.Base64URL(credential.ID).String()
+	if _, err := s.credentials.FindCredentialByCredentialID(credentialID); err == nil {
+		return nil, apperrors.NewValidationErrors("Passkey is already registered", nil)
+	} else if !isNotFound(err) {
+		return nil, apperrors.NewInternalError("Failed to find passkey", err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	record := &database.WebAuthnCredential{
+		UserID:         userID,
+		CredentialID:   credentialID,
+		PublicKey:      credential.PublicKey,
+		Algorithm:      int64(credential.Algorithm),
+		SignCount:      credential.SignCount,
+		AAGUID:         formatAAGUID(credential.AAGUID),
+		Transports:     strings.Join(credential.Transports, ","),
+		Name:           name,
+		BackupEligible: credential.BackupEligible,
+	}
+	if err := s.credentials.CreateCredential(record); err != nil {
+		return nil, apperrors.NewInternalError("Failed to store passkey", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Uint("credential", record.ID).Msg("Passkey registered")
+	return record, nil
+}
+
+// ListCredentials lists the user's passkeys
+func (s *WebAuthnServiceImpl) ListCredentials(ctx context.Context, userID uint) ([]database.WebAuthnCredential, apperrors.AppError) {
+	credentials, err := s.credentials.FindCredentialsByUserID(userID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to list passkeys", err)
+	}
+	return credentials, nil
+}
+
+// DeleteCredential removes one of the user's passkeys
+func (s *WebAuthnServiceImpl) DeleteCredential(ctx context.Context, userID, id uint) apperrors.AppError {
+	err := s.credentials.DeleteCredential(userID, id)
+	if isNotFound(err) {
+		return apperrors.NewNotFoundError("Passkey not found", err, "passkey", id)
+	}
+	if err != nil {
+		return apperrors.NewInternalError("Failed to delete passkey", err)
+	}
+
+	s.logger.Info().Uint("user_id", userID).Uint("credential", id).Msg("Passkey deleted")
+	return nil
+}
+
+// HasCredentials reports whether the user registered any passkey
+func (s *WebAuthnServiceImpl) HasCredentials(userID uint) (bool, apperrors.AppError) {
+	count, err := s.credentials.CountCredentials(userID)
+	if err != nil {
+		return false, apperrors.NewInternalError("Failed to count passkeys", err)
+	}
+	return count > 0, nil
+}
+
+// BeginLogin starts a passwordless login. With a user ID the user's passkeys
+// are offered, otherwise the authenticator picks a discoverable credential.
+// Passwordless logins require user verification, so the passkey stands for
+// two factors on its own.
+func (s *WebAuthnServiceImpl) BeginLogin(ctx context.Context, userID uint) (*webauthn.RequestOptions, apperrors.AppError) {
+	return s.beginAssertion(userID, ceremonyPasswordless, webauthn.VerificationRequired)
+}
+
+// FinishLogin verifies a passwordless login and returns the user it is for
+func (s *WebAuthnServiceImpl) FinishLogin(ctx context.Context, response *webauthn.AssertionCredential) (*database.User, apperrors.AppError) {
+	return s.authenticate(response, ceremonyPasswordless)
+}
+
+// BeginSecondFactor asks for one of the user's passkeys after the password
+func (s *WebAuthnServiceImpl) BeginSecondFactor(ctx context.Context, userID uint) (*webauthn.RequestOptions, apperrors.AppError) {
+	return s.beginAssertion(userID, ceremonySecondFactor, webauthn.VerificationPreferred)
+}
+
+// VerifySecondFactor reports whether the response is a valid assertion of
+// one of the user's passkeys
+func (s *WebAuthnServiceImpl) VerifySecondFactor(ctx context.Context, userID uint, response *webauthn.AssertionCredential) (bool, apperrors.AppError) {
+	user, appErr := s.authenticate(response, ceremonySecondFactor)
+	if appErr != nil {
+		if appErr.Code() == apperrors.ErrCodeInvalidCredentials {
+			return false, nil
+		}
+		return false, appErr
+	}
+	return user.ID == userID, nil
+}
+
+func (s *WebAuthnServiceImpl) beginAssertion(userID uint, ceremony string, verification webauthn.UserVerification) (*webauthn.RequestOptions, apperrors.AppError) {
+	var allow []webauthn.CredentialDescriptor
+	if userID != 0 {
+		descriptors, appErr := s.descriptors(userID)
+		if appErr != nil {
+			return nil, appErr
+		}
+		allow = descriptors
+	}
+
+	options, err := s.rp.BeginLogin(allow, verification)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to start passkey login", err)
+	}
+
+	if appErr := s.saveChallenge(options.Challenge, ceremony, userID, verification); appErr != nil {
+		return nil, appErr
+	}
+	return options, nil
+}
+
+// authenticate verifies an assertion against its challenge and the stored
+// credential. Every verification failure is reported as invalid credentials.
+func (s *WebAuthnServiceImpl) authenticate(response *webauthn.AssertionCredential, ceremony string) (*database.User, apperrors.AppError) {
+	invalid := apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "invalid passkey", nil)
+
+	assertion, err := s.rp.ParseAssertion(response)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("ceremony", ceremony).Msg("Malformed passkey assertion")
+		return nil, invalid
+	}
+
+	challenge, appErr := s.consumeChallenge(assertion.Challenge, ceremony)
+	if appErr != nil {
+		if appErr.Code() == apperrors.ErrCodeValidationError {
+			return nil, invalid
+		}
+		return nil, appErr
+	}
+
+	stored, err := s.credentials.FindCredentialByCredentialID(webauthn.Base64URL(assertion.CredentialID).String())
+	if isNotFound(err) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find passkey", err)
+	}
+
+	// The ceremony may be bound to a user, and discoverable credentials name theirs
+	if challenge.UserID != 0 && challenge.UserID != stored.UserID {
+		return nil, invalid
+	}
+	if challenge.UserID == 0 && len(assertion.UserHandle) == 0 {
+		return nil, invalid
+	}
+	if len(assertion.UserHandle) > 0 && !bytes.Equal(assertion.UserHandle, userHandle(stored.UserID)) {
+		return nil, invalid
+	}
+
+	authData, err := s.rp.VerifyAssertion(assertion, assertion.Challenge,
+		webauthn.UserVerification(challenge.UserVerification), toWebAuthnCredential(stored))
+	if errors.Is(err, webauthn.ErrSignCountRegression) {
+		s.logger.Warn().
+			Str("event", "webauthn_sign_count_regression").
+			Uint("user_id", stored.UserID).
+			Uint("credential", stored.ID).
+			Msg("Passkey signature counter went backwards, the credential may be cloned")
+		return nil, invalid
+	}
+	if err != nil {
+		s.logger.Warn().Err(err).Uint("user_id", stored.UserID).Str("ceremony", ceremony).Msg("Passkey assertion failed verification")
+		return nil, invalid
+	}
+
+	updated, err := s.credentials.UpdateCredentialUsage(stored.ID, stored.SignCount, authData.SignCount)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to update passkey", err)
+	}
+	if !updated {
+		// Another login with the same counter value won the race
+		return nil, invalid
+	}
+
+	return s.findUser(stored.UserID)
+}
+
+func (s *WebAuthnServiceImpl) saveChallenge(challenge []byte, ceremony string, userID uint, verification webauthn.UserVerification) apperrors.AppError {
+	if err := s.credentials.CreateChallenge(&database.WebAuthnChallenge{
+		Challenge:        webauthn.Base64URL(challenge).String(),
+		Ceremony:         ceremony,
+		UserID:           userID,
+		UserVerification: string(verification),
+		ExpiresAt:        time.Now().Add(s.rp.Timeout()),
+	}); err != nil {
+		return apperrors.NewInternalError("Failed to store passkey challenge", err)
+	}
+	return nil
+}
+
+func (s *WebAuthnServiceImpl) consumeChallenge(challenge []byte, ceremony string) (*database.WebAuthnChallenge, apperrors.AppError) {
+	record, err := s.credentials.ConsumeChallenge(webauthn.Base64URL(challenge).String(), ceremony)
+	if isNotFound(err) {
+		return nil, apperrors.NewValidationErrors("Passkey challenge is not valid or has expired", err)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to consume passkey challenge", err)
+	}
+	return record, nil
+}
+
+func (s *WebAuthnServiceImpl) descriptors(userID uint) ([]webauthn.CredentialDescriptor, apperrors.AppError) {
+	credentials, err := s.credentials.FindCredentialsByUserID(userID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to list passkeys", err)
+	}
+
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for i := range credentials {
+		descriptors = append(descriptors, toWebAuthnCredential(&credentials[i]).Descriptor())
+	}
+	return descriptors, nil
+}
+
+func (s *WebAuthnServiceImpl) findUser(userID uint) (*database.User, apperrors.AppError) {
+	user, err := s.repo.FindUserByID(userID)
+	if isNotFound(err) {
+		return nil, apperrors.NewNotFoundError("User not found", err, "user", userID)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("Failed to find user", err)
+	}
+	return user, nil
+}
+
+func toWebAuthnCredential(record *database.WebAuthnCredential) *webauthn.Credential {
+	// Credential IDs are stored in unpadded base64url, which always decodes
+	id, _ := base64.RawURLEncoding.DecodeString(record.CredentialID)
+
+	var transports []string
+	if record.Transports != "" {
+		transports = strings.Split(record.Transports, ",")
+	}
+
+	return &webauthn.Credential{
+		ID:             id,
+		PublicKey:      record.PublicKey,
+		Algorithm:      webauthn.COSEAlgorithm(record.Algorithm),
+		SignCount:      record.SignCount,
+		Transports:     transports,
+		BackupEligible: record.BackupEligible,
+	}
+}
+
+// userHandle is the WebAuthn user handle of a user. It carries no personal
+// information, only the user's ID.
+func userHandle(userID uint) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+// formatAAGUID renders the authenticator model ID in UUID form
+func formatAAGUID(aaguid []byte) string {
+	id, err := uuid.FromBytes(aaguid)
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,114 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/internal/services"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+	"github.com/yourusername/user-management-api/pkg/webauthn/webauthntest"
+)
+
+const passkeyOrigin = "https://app.example.com"
+
+func TestPasskeyFlows(t *testing.T) {
+	db := newTestDatabase(t)
+	userRepo := repository.NewUserRepository(db, zerolog.Nop())
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, zerolog.Nop())
+	revocations := token.NewMemoryRevocationStore()
+	tokenManager := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), revocations)
+	authManager := authentication.NewAuthenticationManager(userRepo, tokenManager,
+		repository.NewLoginAttemptRepository(db, zerolog.Nop()), zerolog.Nop())
+	sessionService := services.NewSessionService(repository.NewSessionRepository(db, zerolog.Nop()), refreshTokenRepo, revocations, zerolog.Nop())
+
+	rp, err := webauthn.NewRelyingParty(webauthn.Config{RPID: "example.com", RPName: "Example", Origins: []string{passkeyOrigin}})
+	require.NoError(t, err)
+	passkeys := services.NewWebAuthnService(rp, userRepo, repository.NewWebAuthnRepository(db, zerolog.Nop()), zerolog.Nop())
+	oneTimeTokens := services.NewOneTimeTokenService(userRepo, repository.NewOneTimeTokenRepository(db, zerolog.Nop()), zerolog.Nop())
+	authService := services.NewAuthService(tokenManager, authManager, userRepo, refreshTokenRepo, sessionService, zerolog.Nop(),
+		services.WithPasskeys(passkeys, oneTimeTokens))
+	ctx := context.Background()
+
+	user, err := authService.RegisterUser(ctx, "passkeyuser", testPassword, "passkey@example.com")
+	require.NoError(t, err)
+	authenticator := webauthntest.NewAuthenticator()
+
+	// Registration
+	creationOptions, appErr := passkeys.BeginRegistration(ctx, user.ID)
+	require.NoError(t, appErr)
+	registration, err := authenticator.Register(creationOptions, passkeyOrigin)
+	require.NoError(t, err)
+	stored, appErr := passkeys.FinishRegistration(ctx, user.ID, "Laptop", registration)
+	require.NoError(t, appErr)
+	assert.Equal(t, "Laptop", stored.Name)
+
+	// The challenge cannot be answered twice
+	_, appErr = passkeys.FinishRegistration(ctx, user.ID, "Laptop", registration)
+	assert.Error(t, appErr)
+
+	// Passwordless login with a discoverable credential
+	requestOptions, appErr := authService.BeginPasskeyLogin(ctx, "")
+	require.NoError(t, appErr)
+	assert.Empty(t, requestOptions.AllowCredentials)
+	assert.Equal(t, webauthn.VerificationRequired, requestOptions.UserVerification)
+	assertion, err := authenticator.Assert(requestOptions, passkeyOrigin)
+	require.NoError(t, err)
+	tokens, appErr := authService.LoginWithPasskey(ctx, assertion, testDevice)
+	require.NoError(t, appErr)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	// Replaying the assertion fails, its challenge is used up
+	_, appErr = authService.LoginWithPasskey(ctx, assertion, testDevice)
+	require.Error(t, appErr)
+	assert.Equal(t, apperrors.ErrCodeInvalidCredentials, appErr.Code())
+
+	// With a passkey registered the password alone is not enough
+	tokens, challenge, appErr := authService.LoginUser(ctx, "passkeyuser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	assert.Nil(t, tokens)
+	require.NotNil(t, challenge)
+	assert.Equal(t, []string{"webauthn"}, challenge.Methods)
+
+	requestOptions, appErr = authService.BeginPasskeyMFA(ctx, challenge.ChallengeToken)
+	require.NoError(t, appErr)
+	require.Len(t, requestOptions.AllowCredentials, 1)
+
+	// A second factor challenge does not work for a passwordless login
+	assertion, err = authenticator.Assert(requestOptions, passkeyOrigin)
+	require.NoError(t, err)
+	_, appErr = authService.LoginWithPasskey(ctx, assertion, testDevice)
+	assert.Error(t, appErr)
+
+	requestOptions, appErr = authService.BeginPasskeyMFA(ctx, challenge.ChallengeToken)
+	require.NoError(t, appErr)
+	assertion, err = authenticator.Assert(requestOptions, passkeyOrigin)
+	require.NoError(t, err)
+	tokens, appErr = authService.VerifyMFAWithPasskey(ctx, challenge.ChallengeToken, assertion, testDevice)
+	require.NoError(t, appErr)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	// A cloned authenticator is refused once the original has been used
+	authenticator.SetSignCount(registration.RawID, 1)
+	requestOptions, appErr = authService.BeginPasskeyLogin(ctx, "passkeyuser")
+	require.NoError(t, appErr)
+	require.Len(t, requestOptions.AllowCredentials, 1)
+	assertion, err = authenticator.Assert(requestOptions, passkeyOrigin)
+	require.NoError(t, err)
+	_, appErr = authService.LoginWithPasskey(ctx, assertion, testDevice)
+	assert.Error(t, appErr)
+
+	// Deleting the passkey brings back password only logins
+	require.NoError(t, passkeys.DeleteCredential(ctx, user.ID, stored.ID))
+	tokens, challenge, appErr = authService.LoginUser(ctx, "passkeyuser", testPassword, testDevice)
+	require.NoError(t, appErr)
+	assert.Nil(t, challenge)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
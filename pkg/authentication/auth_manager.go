@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"math/rand"
-
 	"github.com/rs/zerolog"
 	"github.com/yourusername/user-management-api/internal/database"
 	"github.com/yourusername/user-management-api/internal/repository"
@@ -14,14 +12,11 @@ import (
 	"github.com/yourusername/user-management-api/pkg/token"
 )
 
-const MAX_LOGIN_ATTEMPTS = 5
-
 type AuthenticationManager interface {
 	CheckUserStatus(user *database.User) apperrors.AppError
 	CalculateLockDelay(attempts int) time.Duration
 	CheckLoginAttempts(
 		username string,
-		userID uint,
 		ipAddress string,
 	) apperrors.AppError
 	ValidateToken(tokenString string, tokenType token.TokenType) (*token.Claims, apperrors.AppError)
@@ -32,6 +27,45 @@ type AuthenticationManagerImpl struct {
 	tokenManager     token.TokenManager
 	loginAttemptRepo repository.LoginAttemptRepository
 	logger           zerolog.Logger
+	// requireEmailVerification refuses users who have not verified their email
+	requireEmailVerification bool
+	lockoutPolicy            LockoutPolicy
+	// stuffingDetector screens logins for credential stuffing and password spraying
+	stuffingDetector  *StuffingDetector
+	challengeVerifier ChallengeVerifier
+}
+
+// AuthenticationManagerOption customizes an AuthenticationManagerImpl
+type AuthenticationManagerOption func(*AuthenticationManagerImpl)
+
+// WithEmailVerificationRequired refuses login until the user's email is verified
+func WithEmailVerificationRequired(required bool) AuthenticationManagerOption {
+	return func(am *AuthenticationManagerImpl) {
+		am.requireEmailVerification = required
+	}
+}
+
+// WithLockoutPolicy sets when failed logins lock accounts
+func WithLockoutPolicy(policy LockoutPolicy) AuthenticationManagerOption {
+	return func(am *AuthenticationManagerImpl) {
+		am.lockoutPolicy = policy
+	}
+}
+
+// WithStuffingDetector delays, challenges and blocks logins from sources
+// failing for many accounts, and accounts failing from many sources
+func WithStuffingDetector(detector *StuffingDetector) AuthenticationManagerOption {
+	return func(am *AuthenticationManagerImpl) {
+		am.stuffingDetector = detector
+	}
+}
+
+// WithChallengeVerifier checks the challenges asked of suspicious sources.
+// Without one, challenged sources are only delayed.
+func WithChallengeVerifier(verifier ChallengeVerifier) AuthenticationManagerOption {
+	return func(am *AuthenticationManagerImpl) {
+		am.challengeVerifier = verifier
+	}
 }
 
 func NewAuthenticationManager(
@@ -39,13 +73,21 @@ func NewAuthenticationManager(
 	tokenManager token.TokenManager,
 	loginAttemptRepo repository.LoginAttemptRepository,
 	logger zerolog.Logger,
+	opts ...AuthenticationManagerOption,
 ) *AuthenticationManagerImpl {
-	return &AuthenticationManagerImpl{
+	am := &AuthenticationManagerImpl{
 		userRepo:         userRepo,
 		tokenManager:     tokenManager,
 		loginAttemptRepo: loginAttemptRepo,
 		logger:           logger,
+		lockoutPolicy:    DefaultLockoutPolicy(),
+	}
+
+	for _, opt := range opts {
+		opt(am)
 	}
+
+	return am
 }
 
 func (am *AuthenticationManagerImpl) ValidateUserAuthentication(
@@ -54,9 +96,35 @@ func (am *AuthenticationManagerImpl) ValidateUserAuthentication(
 	password string,
 	ipAddress string,
 ) (*database.User, apperrors.AppError) {
+	user, err := am.ValidatePassword(ctx, username, password, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reset successful login attempts
+	am.ResetLoginAttempts(username, ipAddress)
+
+	return user, nil
+}
+
+// ValidatePassword is ValidateUserAuthentication without clearing the failed
+// attempts, for logins that still have to pass a second factor
+func (am *AuthenticationManagerImpl) ValidatePassword(
+	ctx context.Context,
+	username string,
+	password string,
+	ipAddress string,
+) (*database.User, apperrors.AppError) {
+	// Screen the source before anything about the account is revealed
+	if err := am.screenLogin(ctx, username, ipAddress); err != nil {
+		return nil, err
+	}
+
 	// Consolidated validation logic
 	user, err := am.FindUserByUsername(username)
 	if err != nil {
+		// Stuffing lists are full of usernames that do not exist
+		am.recordStuffingFailure(username, ipAddress)
 		return nil, apperrors.NewNotFoundError("User not found", err, "user", username)
 	}
 
@@ -65,24 +133,55 @@ func (am *AuthenticationManagerImpl) ValidateUserAuthentication(
 		return nil, err
 	}
 
-	// 2. Validate Password
+	// 2. Check Login Attempts, before the password so locked out clients cannot keep guessing
+	if err := am.CheckLoginAttempts(username, ipAddress); err != nil {
+		return nil, err
+	}
+
+	// 3. Validate Password
 	if !user.CheckPasswordHash(password) {
-		// Record failed login attempt
-		if err := am.recordFailedLoginAttempt(username, ipAddress); err != nil {
-			return nil, apperrors.NewInternalError("Failed to record login attempt", err)
+		am.recordStuffingFailure(username, ipAddress)
+		if err := am.recordFailedLoginAttempt(user, ipAddress); err != nil {
+			return nil, err
 		}
 		return nil, apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "invalid credentials", nil)
 	}
 
-	// 3. Check Login Attempts
-	if err := am.CheckLoginAttempts(username, user.ID, ipAddress); err != nil {
-		return nil, err
+	// 4. Upgrade outdated hashes while the password is at hand
+	am.upgradePasswordHash(user, password)
+
+	return user, nil
+}
+
+// ValidateSecondFactor checks a second factor of a user who already passed the
+// password check. Failures count as failed login attempts, so guessing codes
+// locks the account just like guessing passwords.
+func (am *AuthenticationManagerImpl) ValidateSecondFactor(
+	user *database.User,
+	ipAddress string,
+	verify func() (bool, apperrors.AppError),
+) apperrors.AppError {
+	if err := am.CheckUserStatus(user); err != nil {
+		return err
 	}
 
-	// Reset successful login attempts
-	am.resetLoginAttempts(username, ipAddress)
+	if err := am.CheckLoginAttempts(user.Username, ipAddress); err != nil {
+		return err
+	}
 
-	return user, nil
+	valid, err := verify()
+	if err != nil {
+		return err
+	}
+	if !valid {
+		if err := am.recordFailedLoginAttempt(user, ipAddress); err != nil {
+			return err
+		}
+		return apperrors.NewAuthenticationError(apperrors.ErrCodeInvalidCredentials, "invalid verification code", nil)
+	}
+
+	am.ResetLoginAttempts(user.Username, ipAddress)
+	return nil
 }
 
 func (am *AuthenticationManagerImpl) FindUserByUsername(username string) (*database.User, apperrors.AppError) {
@@ -97,114 +196,180 @@ func (am *AuthenticationManagerImpl) FindUserByUsername(username string) (*datab
 func (am *AuthenticationManagerImpl) CheckUserStatus(user *database.User) apperrors.AppError {
 	switch user.Status {
 	case database.UserStatusLocked:
+		// Locks without an end stay until they are lifted
+		if user.LockedUntil.IsZero() {
+			return apperrors.NewAccountLockedError(fmt.Sprintf("account locked. Reason: %s", user.LockReason), time.Time{})
+		}
 		if user.LockedUntil.After(time.Now()) {
-			return apperrors.NewAuthenticationError(apperrors.ErrCodeUserLocked, fmt.Sprintf("account locked until %s. Reason: %s",
+			return apperrors.NewAccountLockedError(fmt.Sprintf("account locked until %s. Reason: %s",
 				user.LockedUntil.Format(time.RFC3339),
-				user.LockReason), nil)
+				user.LockReason), user.LockedUntil)
 		}
 	case database.UserStatusInactive, database.UserStatusDeleted:
 		return apperrors.NewAuthenticationError(apperrors.ErrCodeUserInactive, "account inactive", nil)
+	case database.UserStatusPendingVerification:
+		if am.requireEmailVerification {
+			return apperrors.NewAuthenticationError(apperrors.ErrCodeEmailNotVerified, "email address not verified", nil)
+		}
 	}
 	return nil
 }
 
+// CalculateLockDelay returns how long the given number of failed attempts
+// lock an account for under the lockout policy
 func (am *AuthenticationManagerImpl) CalculateLockDelay(attempts int) time.Duration {
-	// Exponential backoff with randomization
-	maxDelay := 1 * time.Hour
-
-	// Ensure attempts starts from 1
-	if attempts < 1 {
-		attempts = 1
-	}
-
-	// Predefined delay steps to ensure precise control
-	delaySteps := []time.Duration{
-		1 * time.Second,  // 1st attempt
-		2 * time.Second,  // 2nd attempt
-		4 * time.Second,  // 3rd attempt
-		8 * time.Second,  // 4th attempt
-		16 * time.Second, // 5th attempt
-		32 * time.Second, // 6th attempt
-		1 * time.Minute,  // 7th attempt
-		2 * time.Minute,  // 8th attempt
-		4 * time.Minute,  // 9th attempt
-		1 * time.Hour,    // 10th and subsequent attempts
-	}
-
-	// Select the appropriate delay step
-	var rawDelay time.Duration
-	if attempts-1 < len(delaySteps) {
-		rawDelay = delaySteps[attempts-1]
-	} else {
-		rawDelay = maxDelay
-	}
-
-	// Randomization (limited to 10% of the current delay)
-	jitterMax := rawDelay / 10
-	jitter := time.Duration(rand.Intn(int(jitterMax.Milliseconds()))) * time.Millisecond
-	delay := rawDelay + jitter
-
-	// Final cap to ensure we don't exceed maxDelay
-	if delay > maxDelay {
-		delay = maxDelay
-	}
-
-	return delay
+	return am.lockoutPolicy.LockDuration(attempts)
 }
 
+// CheckLoginAttempts refuses logins while the failed attempts from the IP
+// address keep the account locked out for that address
 func (am *AuthenticationManagerImpl) CheckLoginAttempts(
 	username string,
-	userID uint,
 	ipAddress string,
 ) apperrors.AppError {
-	attempts, _, err := am.loginAttemptRepo.GetLoginAttempts(username, ipAddress)
-	if err != nil {
+	attempts, lastAttempt, err := am.loginAttemptRepo.GetLoginAttempts(username, ipAddress)
+	if err != nil && !isNotFound(err) {
 		return apperrors.NewInternalError("Failed to get login attempts", err)
 	}
 
-	if attempts >= MAX_LOGIN_ATTEMPTS {
-		lockDuration := am.CalculateLockDelay(attempts)
-		// Automatically lock the user
-		err := am.userRepo.LockUser(
-			userID,
-			"Exceeded maximum login attempts",
-			lockDuration,
-		)
-		if err != nil {
-			am.logger.Error().Err(err).Msg("Failed to lock user")
-			return apperrors.NewInternalError("Failed to lock user", err)
-		}
-		return apperrors.NewAuthenticationError(apperrors.ErrCodeUserLocked, "too many login attempts. Account locked", nil)
+	lockedUntil, locked := am.lockoutPolicy.LockedUntil(attempts, lastAttempt, time.Now())
+	if !locked {
+		return nil
 	}
-
-	return nil
+	return apperrors.NewAccountLockedError("too many login attempts. Account locked", lockedUntil)
 }
 
+// recordFailedLoginAttempt counts a failed attempt and locks the account out
+// for the IP address once the attempts reach the policy's limit
 func (am *AuthenticationManagerImpl) recordFailedLoginAttempt(
-	username string,
+	user *database.User,
 	ipAddress string,
 ) apperrors.AppError {
-	attempts, _, _ := am.loginAttemptRepo.GetLoginAttempts(username, ipAddress)
-	possibleLockDuration := am.CalculateLockDelay(attempts + 1)
-
-	am.logger.Warn().
-		Str("username", username).
-		Str("ipAddress", ipAddress).
-		Int("attempts", int(attempts)+1).
-		Dur("next_attempt_delay", possibleLockDuration).
-		Msg("Progressive login delay applied")
+	now := time.Now()
+	attempts, lastAttempt, err := am.loginAttemptRepo.GetLoginAttempts(user.Username, ipAddress)
+	if err != nil && !isNotFound(err) {
+		return apperrors.NewInternalError("Failed to get login attempts", err)
+	}
+	// Attempts outside the window no longer count, so start over
+	if attempts > 0 && !am.lockoutPolicy.Counts(lastAttempt, now) {
+		if err := am.loginAttemptRepo.ResetLoginAttempts(user.Username, ipAddress); err != nil {
+			am.logger.Error().Err(err).Msg("Failed to reset expired login attempts")
+			return apperrors.NewInternalError("Failed to reset login attempts", err)
+		}
+		attempts = 0
+	}
 
 	// Record the failed login attempt
-	err := am.loginAttemptRepo.IncrementLoginAttempts(username, ipAddress, false)
-	if err != nil {
+	if err := am.loginAttemptRepo.IncrementLoginAttempts(user.Username, ipAddress, false); err != nil {
 		am.logger.Error().Err(err).Msg("Failed to record login attempt")
 		return apperrors.NewInternalError("Failed to record login attempt", err)
 	}
+	attempts++
 
+	am.logger.Warn().
+		Str("username", user.Username).
+		Str("ipAddress", ipAddress).
+		Int("attempts", attempts).
+		Int("max_attempts", am.lockoutPolicy.MaxAttempts).
+		Msg("Failed login attempt recorded")
+
+	lockedUntil, locked := am.lockoutPolicy.LockedUntil(attempts, now, now)
+	if !locked {
+		return nil
+	}
+
+	// The lockout only refuses logins from this address. Locking the account
+	// itself would let anyone who knows the username lock its owner out and
+	// end their sessions.
+	am.logger.Warn().
+		Uint("user_id", user.ID).
+		Str("username", user.Username).
+		Str("ipAddress", ipAddress).
+		Int("attempts", attempts).
+		Time("locked_until", lockedUntil).
+		Msg("Account locked out for the IP address after failed login attempts")
+	return apperrors.NewAccountLockedError("too many login attempts. Account locked", lockedUntil)
+}
+
+// upgradePasswordHash rehashes the verified password when its stored hash
+// was made with an outdated algorithm or parameters. Failures are only
+// logged, as the old hash still works.
+func (am *AuthenticationManagerImpl) upgradePasswordHash(user *database.User, password string) {
+	if !user.PasswordNeedsRehash() {
+		return
+	}
+
+	upgraded := &database.User{Password: password}
+	if err := upgraded.HashPassword(); err != nil {
+		am.logger.Error().Err(err).Uint("user_id", user.ID).Msg("Failed to rehash password")
+		return
+	}
+	if err := am.userRepo.UpdatePasswordHash(user.ID, upgraded.Password); err != nil {
+		am.logger.Error().Err(err).Uint("user_id", user.ID).Msg("Failed to store rehashed password")
+		return
+	}
+	user.Password = upgraded.Password
+	am.logger.Info().Uint("user_id", user.ID).Msg("Password hash upgraded")
+}
+
+// screenLogin delays, challenges or refuses logins from the sources the
+// stuffing detector flagged
+func (am *AuthenticationManagerImpl) screenLogin(ctx context.Context, username, ipAddress string) apperrors.AppError {
+	if am.stuffingDetector == nil {
+		return nil
+	}
+
+	assessment := am.stuffingDetector.Assess(username, ipAddress)
+	switch assessment.Action {
+	case LoginAllow:
+		return nil
+	case LoginBlock:
+		am.logger.Warn().
+			Str("username", username).
+			Str("ipAddress", ipAddress).
+			Str("reason", assessment.Reason).
+			Time("blocked_until", assessment.BlockedUntil).
+			Msg("Login refused from blocked source")
+		return apperrors.NewBlockedError("too many failed logins. Try again later", assessment.BlockedUntil)
+	case LoginChallenge:
+		if am.challengeVerifier != nil {
+			return am.verifyChallenge(ctx, ipAddress)
+		}
+	}
+
+	// Without a challenge to ask, challenged sources are delayed as well
+	timer := time.NewTimer(am.stuffingDetector.Delay())
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return apperrors.New(apperrors.ErrCodeTimeoutError, "login timed out", ctx.Err())
+	}
+}
+
+// verifyChallenge checks the challenge response attached to ctx
+func (am *AuthenticationManagerImpl) verifyChallenge(ctx context.Context, ipAddress string) apperrors.AppError {
+	solved, err := am.challengeVerifier.VerifyChallenge(ctx, ChallengeResponse(ctx), ipAddress)
+	if err != nil {
+		am.logger.Error().Err(err).Str("ipAddress", ipAddress).Msg("Failed to verify login challenge")
+		return apperrors.NewInternalError("Failed to verify challenge", err)
+	}
+	if !solved {
+		return apperrors.NewAuthenticationError(apperrors.ErrCodeChallengeRequired, "challenge required", nil)
+	}
 	return nil
 }
 
-func (am *AuthenticationManagerImpl) resetLoginAttempts(
+// recordStuffingFailure counts a failed login towards the stuffing detector
+func (am *AuthenticationManagerImpl) recordStuffingFailure(username, ipAddress string) {
+	if am.stuffingDetector != nil {
+		am.stuffingDetector.RecordFailure(username, ipAddress)
+	}
+}
+
+// ResetLoginAttempts clears the failed attempts of a completed login
+func (am *AuthenticationManagerImpl) ResetLoginAttempts(
 	username string,
 	ipAddress string,
 ) apperrors.AppError {
@@ -225,4 +390,10 @@ func (am *AuthenticationManagerImpl) ValidateToken(tokenString string, tokenType
 
 }
 
+// isNotFound reports whether err is an apperrors not found error
+func isNotFound(err error) bool {
+	appErr, ok := err.(apperrors.AppError)
+	return ok && appErr.Code() == apperrors.ErrCodeNotFound
+}
+
 var _ AuthenticationManager = (*AuthenticationManagerImpl)(nil)
@@ -12,6 +12,7 @@ import (
 
 	"github.com/yourusername/user-management-api/internal/database"
 	"github.com/yourusername/user-management-api/pkg/authentication"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
 	"github.com/yourusername/user-management-api/pkg/token"
 )
 
@@ -30,6 +31,11 @@ func (m *MockUserRepository) FindUserByID(userID uint) (*database.User, error) {
 	panic("unimplemented")
 }
 
+// FindUserByEmail implements repository.UserRepository.
+func (m *MockUserRepository) FindUserByEmail(email string) (*database.User, error) {
+	panic("unimplemented")
+}
+
 // GetAllUsers implements repository.UserRepository.
 func (m *MockUserRepository) GetAllUsers() ([]database.User, error) {
 	panic("unimplemented")
@@ -50,6 +56,16 @@ func (m *MockUserRepository) LockSecurityViolationUsers() error {
 	panic("unimplemented")
 }
 
+// UpdatePasswordHash implements repository.UserRepository.
+func (m *MockUserRepository) UpdatePasswordHash(userID uint, passwordHash string) error {
+	panic("unimplemented")
+}
+
+// FindPasswordHashes implements repository.UserRepository.
+func (m *MockUserRepository) FindPasswordHashes() ([]string, error) {
+	panic("unimplemented")
+}
+
 // MarkInactiveUsers implements repository.UserRepository.
 func (m *MockUserRepository) MarkInactiveUsers() error {
 	panic("unimplemented")
@@ -104,6 +120,11 @@ func (m *MockLoginAttemptRepository) ResetLoginAttempts(username, ipAddress stri
 	return args.Error(0)
 }
 
+func (m *MockLoginAttemptRepository) ClearLoginAttempts(username string) error {
+	args := m.Called(username)
+	return args.Error(0)
+}
+
 // MockTokenManager implements token.TokenManager interface
 type MockTokenManager struct {
 	mock.Mock
@@ -113,25 +134,55 @@ func (m *MockTokenManager) GenerateToken(
 	userID uint,
 	username string,
 	tokenType token.TokenType,
-) (string, error) {
+	opts ...token.TokenOption,
+) (string, apperrors.AppError) {
+	args := m.Called(userID, username, tokenType)
+	return args.String(0), appError(args.Error(1))
+}
+
+func (m *MockTokenManager) IssueToken(
+	userID uint,
+	username string,
+	tokenType token.TokenType,
+	opts ...token.TokenOption,
+) (string, *token.Claims, apperrors.AppError) {
 	args := m.Called(userID, username, tokenType)
-	return args.String(0), args.Error(1)
+	if args.Get(1) == nil {
+		return args.String(0), nil, appError(args.Error(2))
+	}
+	return args.String(0), args.Get(1).(*token.Claims), appError(args.Error(2))
 }
 
 func (m *MockTokenManager) ValidateToken(
 	tokenString string,
 	tokenType token.TokenType,
-) (*token.Claims, error) {
+) (*token.Claims, apperrors.AppError) {
 	args := m.Called(tokenString, tokenType)
 	if args.Get(0) == nil {
-		return nil, args.Error(1)
+		return nil, appError(args.Error(1))
 	}
-	return args.Get(0).(*token.Claims), args.Error(1)
+	return args.Get(0).(*token.Claims), appError(args.Error(1))
 }
 
-func (m *MockTokenManager) InvalidateToken(tokenString string) error {
-	args := m.Called(tokenString)
-	return args.Error(0)
+func (m *MockTokenManager) InvalidateToken(tokenString string, tokenType token.TokenType) apperrors.AppError {
+	args := m.Called(tokenString, tokenType)
+	return appError(args.Error(0))
+}
+
+func (m *MockTokenManager) RevokeClaims(claims *token.Claims) apperrors.AppError {
+	args := m.Called(claims)
+	return appError(args.Error(0))
+}
+
+// appError converts a mocked error into an apperrors.AppError
+func appError(err error) apperrors.AppError {
+	if err == nil {
+		return nil
+	}
+	if appErr, ok := err.(apperrors.AppError); ok {
+		return appErr
+	}
+	return apperrors.NewTokenError(apperrors.ErrCodeInvalidToken, err.Error(), err)
 }
 
 // Ensure MockTokenManager implements the TokenManager interface
@@ -279,33 +330,79 @@ func TestCalculateLockDelay(t *testing.T) {
 
 	testCases := []struct {
 		attempts int
-		minDelay time.Duration
-		maxDelay time.Duration
+		delay    time.Duration
 	}{
-		{1, 1 * time.Second, 2 * time.Second},
-		{2, 2 * time.Second, 4 * time.Second},
-		{3, 4 * time.Second, 8 * time.Second},
-		{4, 8 * time.Second, 16 * time.Second},
-		{5, 16 * time.Second, 32 * time.Second},
-		{6, 32 * time.Second, 1 * time.Minute},
-		{7, 1 * time.Minute, 2 * time.Minute},
-		{8, 2 * time.Minute, 4 * time.Minute},
-		{9, 4 * time.Minute, 1 * time.Hour},
-		{10, 1 * time.Hour, 1 * time.Hour},
-		{11, 1 * time.Hour, 1 * time.Hour}, // Test beyond 10 attempts
+		{1, 0},
+		{4, 0},
+		{5, 30 * time.Minute},
+		{6, 1 * time.Hour},
+		{7, 2 * time.Hour},
+		{9, 8 * time.Hour},
+		{10, 16 * time.Hour},
+		{11, 24 * time.Hour}, // Capped at the policy's max duration
+		{100, 24 * time.Hour},
 	}
 
 	for _, tc := range testCases {
 		t.Run(fmt.Sprintf("Attempts_%d", tc.attempts), func(t *testing.T) {
-			delay := authManager.CalculateLockDelay(tc.attempts)
-
-			// Ensure delay is within expected range
-			assert.GreaterOrEqual(t, delay, tc.minDelay)
-			assert.LessOrEqual(t, delay, tc.maxDelay)
+			assert.Equal(t, tc.delay, authManager.CalculateLockDelay(tc.attempts))
 		})
 	}
 }
 
+func TestLockoutPolicy(t *testing.T) {
+	now := time.Now()
+	policy := authentication.LockoutPolicy{
+		MaxAttempts:   3,
+		Window:        time.Hour,
+		Duration:      10 * time.Minute,
+		BackoffFactor: 3,
+	}
+
+	// Locks grow without a cap
+	assert.Equal(t, 90*time.Minute, policy.LockDuration(5))
+
+	// Locks end and attempts stop counting after the window
+	_, locked := policy.LockedUntil(2, now, now)
+	assert.False(t, locked)
+	until, locked := policy.LockedUntil(3, now.Add(-5*time.Minute), now)
+	assert.True(t, locked)
+	assert.Equal(t, now.Add(5*time.Minute), until)
+	_, locked = policy.LockedUntil(3, now.Add(-11*time.Minute), now)
+	assert.False(t, locked)
+	_, locked = policy.LockedUntil(20, now.Add(-2*time.Hour), now)
+	assert.False(t, locked)
+
+	// Permanent locks have no end
+	policy.Permanent = true
+	assert.Zero(t, policy.LockDuration(5))
+	until, locked = policy.LockedUntil(3, now.Add(-30*time.Minute), now)
+	assert.True(t, locked)
+	assert.True(t, until.IsZero())
+
+	// Not even once the window has passed; only unlocking clears them
+	until, locked = policy.LockedUntil(3, now.Add(-48*time.Hour), now)
+	assert.True(t, locked)
+	assert.True(t, until.IsZero())
+}
+
+func TestCheckUserStatusEmailVerification(t *testing.T) {
+	pending := &database.User{Username: "pending", Status: database.UserStatusPendingVerification}
+
+	optional := authentication.NewAuthenticationManager(nil, nil, nil, zerolog.Nop())
+	assert.Nil(t, optional.CheckUserStatus(pending))
+
+	required := authentication.NewAuthenticationManager(nil, nil, nil, zerolog.Nop(),
+		authentication.WithEmailVerificationRequired(true))
+	err := required.CheckUserStatus(pending)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, apperrors.ErrCodeEmailNotVerified, err.Code())
+	}
+
+	active := &database.User{Username: "active", Status: database.UserStatusActive}
+	assert.Nil(t, required.CheckUserStatus(active))
+}
+
 func TestCheckLoginAttempts(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -338,14 +435,6 @@ func TestCheckLoginAttempts(t *testing.T) {
 			loginAttemptRepo.On("GetLoginAttempts", "testuser", "127.0.0.1").
 				Return(tc.attempts, time.Now(), nil)
 
-			if tc.expectedError {
-				userRepo.On("LockUser",
-					mock.AnythingOfType("uint"),
-					mock.AnythingOfType("string"),
-					mock.AnythingOfType("time.Duration"),
-				).Return(nil)
-			}
-
 			authManager := authentication.NewAuthenticationManager(
 				userRepo,
 				tokenManager,
@@ -353,7 +442,7 @@ func TestCheckLoginAttempts(t *testing.T) {
 				logger,
 			)
 
-			err := authManager.CheckLoginAttempts("testuser", 1, "127.0.0.1")
+			err := authManager.CheckLoginAttempts("testuser", "127.0.0.1")
 
 			if tc.expectedError {
 				assert.Error(t, err)
@@ -0,0 +1,81 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ChallengeVerifier checks the answer to a challenge, such as a CAPTCHA,
+// that logins from suspicious sources have to solve
+type ChallengeVerifier interface {
+	VerifyChallenge(ctx context.Context, response, ipAddress string) (bool, error)
+}
+
+// ChallengeVerifierFunc adapts a function to a ChallengeVerifier
+type ChallengeVerifierFunc func(ctx context.Context, response, ipAddress string) (bool, error)
+
+func (f ChallengeVerifierFunc) VerifyChallenge(ctx context.Context, response, ipAddress string) (bool, error) {
+	return f(ctx, response, ipAddress)
+}
+
+type challengeResponseKey struct{}
+
+// WithChallengeResponse attaches the client's answer to a login challenge
+func WithChallengeResponse(ctx context.Context, response string) context.Context {
+	return context.WithValue(ctx, challengeResponseKey{}, response)
+}
+
+// ChallengeResponse returns the answer attached with WithChallengeResponse
+func ChallengeResponse(ctx context.Context) string {
+	response, _ := ctx.Value(challengeResponseKey{}).(string)
+	return response
+}
+
+// SiteVerifyChallenge verifies CAPTCHA responses with a siteverify endpoint,
+// the API shared by reCAPTCHA, hCaptcha and Turnstile
+type SiteVerifyChallenge struct {
+	verifyURL string
+	secret    string
+	client    *http.Client
+}
+
+// NewSiteVerifyChallenge creates a verifier posting responses to verifyURL
+func NewSiteVerifyChallenge(verifyURL, secret string, client *http.Client) *SiteVerifyChallenge {
+	return &SiteVerifyChallenge{verifyURL: verifyURL, secret: secret, client: client}
+}
+
+func (v *SiteVerifyChallenge) VerifyChallenge(ctx context.Context, response, ipAddress string) (bool, error) {
+	if response == "" {
+		return false, nil
+	}
+
+	form := url.Values{"secret": {v.secret}, "response": {response}, "remoteip": {ipAddress}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("challenge verification returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.Success, nil
+}
+
+var _ ChallengeVerifier = (*SiteVerifyChallenge)(nil)
@@ -0,0 +1,79 @@
+package authentication
+
+import (
+	"math"
+	"time"
+)
+
+// LockoutPolicy decides when failed logins lock an account and for how
+// long. Failed attempts are counted per username and IP address.
+type LockoutPolicy struct {
+	// MaxAttempts failed attempts lock the account
+	MaxAttempts int
+	// Window is how long a failed attempt counts after the latest one; zero
+	// keeps counting until the next successful login
+	Window time.Duration
+	// Duration is the length of the first lock
+	Duration time.Duration
+	// BackoffFactor multiplies the lock for every failed attempt beyond
+	// MaxAttempts; 1 keeps every lock the same length
+	BackoffFactor float64
+	// MaxDuration caps the growing locks; zero leaves them uncapped
+	MaxDuration time.Duration
+	// Permanent locks do not end by themselves and have to be lifted
+	Permanent bool
+}
+
+// DefaultLockoutPolicy locks for 30 minutes after 5 failed attempts within a
+// day, doubling the lock for every further failure up to a day
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxAttempts:   5,
+		Window:        24 * time.Hour,
+		Duration:      30 * time.Minute,
+		BackoffFactor: 2,
+		MaxDuration:   24 * time.Hour,
+	}
+}
+
+// LockDuration returns how long the given number of failed attempts lock the
+// account for. It is zero below MaxAttempts and for permanent locks.
+func (p LockoutPolicy) LockDuration(attempts int) time.Duration {
+	if attempts < p.MaxAttempts || p.Permanent {
+		return 0
+	}
+
+	factor := math.Pow(math.Max(p.BackoffFactor, 1), float64(attempts-p.MaxAttempts))
+	duration := float64(p.Duration) * factor
+	if p.MaxDuration > 0 && duration > float64(p.MaxDuration) {
+		return p.MaxDuration
+	}
+	if duration > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(duration)
+}
+
+// Counts reports whether a failed attempt at lastAttempt still counts at now
+func (p LockoutPolicy) Counts(lastAttempt, now time.Time) bool {
+	return p.Window == 0 || now.Sub(lastAttempt) < p.Window
+}
+
+// LockedUntil reports whether the failed attempts, the latest at
+// lastAttempt, keep the account locked at now, and until when. The time is
+// zero for permanent locks, which outlast the window until the attempts are
+// cleared by unlocking the account.
+func (p LockoutPolicy) LockedUntil(attempts int, lastAttempt, now time.Time) (time.Time, bool) {
+	if attempts < p.MaxAttempts {
+		return time.Time{}, false
+	}
+	if p.Permanent {
+		return time.Time{}, true
+	}
+	if !p.Counts(lastAttempt, now) {
+		return time.Time{}, false
+	}
+
+	until := lastAttempt.Add(p.LockDuration(attempts))
+	return until, now.Before(until)
+}
@@ -0,0 +1,339 @@
+package authentication
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+)
+
+// Security event types recorded by the StuffingDetector
+const (
+	// EventManyUsersFromIP is one address failing logins for many usernames
+	EventManyUsersFromIP = "many_users_from_ip"
+	// EventManyUsersFromSubnet is one network failing logins for many usernames
+	EventManyUsersFromSubnet = "many_users_from_subnet"
+	// EventManySourcesForUser is one username failing logins from many addresses
+	EventManySourcesForUser = "many_sources_for_user"
+)
+
+// Failed logins are grouped into networks of these sizes
+const (
+	ipv4SubnetBits = 24
+	ipv6SubnetBits = 64
+)
+
+// maxTrackedMembers bounds the usernames or addresses remembered per counter,
+// so a single source cannot grow the detector without limit
+const maxTrackedMembers = 10000
+
+// LoginAction is how the detector responds to logins from a source
+type LoginAction int
+
+const (
+	LoginAllow LoginAction = iota
+	LoginDelay
+	LoginChallenge
+	LoginBlock
+)
+
+func (a LoginAction) String() string {
+	switch a {
+	case LoginDelay:
+		return "delay"
+	case LoginChallenge:
+		return "challenge"
+	case LoginBlock:
+		return "block"
+	default:
+		return "allow"
+	}
+}
+
+// StuffingThresholds are the distinct counts within the window at which
+// logins are delayed, challenged and blocked. Zero leaves a step out.
+type StuffingThresholds struct {
+	Delay     int
+	Challenge int
+	Block     int
+}
+
+func (t StuffingThresholds) action(count int) LoginAction {
+	switch {
+	case t.Block > 0 && count >= t.Block:
+		return LoginBlock
+	case t.Challenge > 0 && count >= t.Challenge:
+		return LoginChallenge
+	case t.Delay > 0 && count >= t.Delay:
+		return LoginDelay
+	default:
+		return LoginAllow
+	}
+}
+
+// StuffingPolicy decides when failed logins spread over many accounts or
+// many sources look like credential stuffing or password spraying
+type StuffingPolicy struct {
+	// Window is how long a failed login counts
+	Window time.Duration
+	// IP counts the distinct usernames failing from one address
+	IP StuffingThresholds
+	// Subnet counts the distinct usernames failing from one /24 or /64 network
+	Subnet StuffingThresholds
+	// User counts the distinct addresses failing for one username. Blocking
+	// refuses the username from everywhere, so it is best left out.
+	User StuffingThresholds
+	// Delay slows down every login from a delayed or challenged source
+	Delay time.Duration
+	// BlockDuration is how long a source is refused once it is blocked
+	BlockDuration time.Duration
+}
+
+// DefaultStuffingPolicy delays an address after failures for 5 usernames
+// within 15 minutes, challenges it at 10 and blocks it for an hour at 20.
+// Networks get three times the room, and usernames failing from 5 and 10
+// addresses are delayed and challenged.
+func DefaultStuffingPolicy() StuffingPolicy {
+	return StuffingPolicy{
+		Window:        15 * time.Minute,
+		IP:            StuffingThresholds{Delay: 5, Challenge: 10, Block: 20},
+		Subnet:        StuffingThresholds{Delay: 15, Challenge: 30, Block: 60},
+		User:          StuffingThresholds{Delay: 5, Challenge: 10},
+		Delay:         2 * time.Second,
+		BlockDuration: time.Hour,
+	}
+}
+
+// LoginAssessment is the detector's response to a login
+type LoginAssessment struct {
+	Action LoginAction
+	// Reason is the security event type that triggered the action
+	Reason string
+	// BlockedUntil is when a block ends
+	BlockedUntil time.Time
+}
+
+// counterKey identifies a sliding window counter by the event it detects
+// and the address, network or username it counts for
+type counterKey struct {
+	kind  string
+	value string
+}
+
+// counter remembers when each distinct member last failed
+type counter struct {
+	members map[string]time.Time
+	// level is the highest action already recorded as a security event
+	level LoginAction
+}
+
+// StuffingDetector keeps sliding window counters of failed logins per
+// address, per network and per username. Unlike the per username and
+// address lockout it notices sources trying many accounts once each, and
+// accounts tried from many sources.
+type StuffingDetector struct {
+	mu       sync.Mutex
+	policy   StuffingPolicy
+	events   repository.SecurityEventRepository
+	logger   zerolog.Logger
+	counters map[counterKey]*counter
+	blocks   map[counterKey]time.Time
+	now      func() time.Time
+}
+
+// NewStuffingDetector creates a detector recording its detections to events
+func NewStuffingDetector(policy StuffingPolicy, events repository.SecurityEventRepository, logger zerolog.Logger) *StuffingDetector {
+	return &StuffingDetector{
+		policy:   policy,
+		events:   events,
+		logger:   logger.With().Str("component", "StuffingDetector").Logger(),
+		counters: make(map[counterKey]*counter),
+		blocks:   make(map[counterKey]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Delay returns how long delayed logins wait
+func (d *StuffingDetector) Delay() time.Duration {
+	return d.policy.Delay
+}
+
+// Assess returns how to respond to a login for username from ip, based on
+// the failures seen so far
+func (d *StuffingDetector) Assess(username, ip string) LoginAssessment {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	var assessment LoginAssessment
+	for _, track := range d.tracks(username, ip) {
+		if until, ok := d.blocks[track.key]; ok {
+			if now.Before(until) {
+				if assessment.Action < LoginBlock || until.After(assessment.BlockedUntil) {
+					assessment = LoginAssessment{Action: LoginBlock, Reason: track.key.kind, BlockedUntil: until}
+				}
+				continue
+			}
+			delete(d.blocks, track.key)
+		}
+
+		c, ok := d.counters[track.key]
+		if !ok {
+			continue
+		}
+		// Blocks only start on a new failure, so an expired block steps back
+		// to a challenge
+		action := min(track.thresholds.action(c.count(now, d.policy.Window)), LoginChallenge)
+		if action > assessment.Action {
+			assessment = LoginAssessment{Action: action, Reason: track.key.kind}
+		}
+	}
+	return assessment
+}
+
+// RecordFailure counts a failed login for username from ip, and records a
+// security event whenever a counter escalates to a stronger response
+func (d *StuffingDetector) RecordFailure(username, ip string) {
+	d.mu.Lock()
+	now := d.now()
+	var events []database.SecurityEvent
+	for _, track := range d.tracks(username, ip) {
+		c, ok := d.counters[track.key]
+		if !ok {
+			c = &counter{members: make(map[string]time.Time)}
+			d.counters[track.key] = c
+		}
+		count := c.add(track.member, now, d.policy.Window)
+
+		action := track.thresholds.action(count)
+		until, ok := d.blocks[track.key]
+		blocked := ok && now.Before(until)
+		if action < c.level {
+			c.level = action
+		}
+		if action == LoginAllow || (action == c.level && (action != LoginBlock || blocked)) {
+			continue
+		}
+
+		c.level = action
+		if action == LoginBlock {
+			d.blocks[track.key] = now.Add(d.policy.BlockDuration)
+		}
+		events = append(events, track.event(action, count, ip))
+	}
+	d.mu.Unlock()
+
+	d.recordEvents(events)
+}
+
+// PurgeExpired forgets failures outside the window and blocks that ended.
+// It satisfies token.ExpiryPurger so a RevocationSweeper can drive it.
+func (d *StuffingDetector) PurgeExpired() (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	var purged int64
+	for key, c := range d.counters {
+		if c.count(now, d.policy.Window) == 0 {
+			delete(d.counters, key)
+			purged++
+		}
+	}
+	for key, until := range d.blocks {
+		if !now.Before(until) {
+			delete(d.blocks, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (d *StuffingDetector) recordEvents(events []database.SecurityEvent) {
+	for i := range events {
+		event := &events[i]
+		d.logger.Warn().
+			Str("type", event.Type).
+			Str("action", event.Action).
+			Str("ip", event.IPAddress).
+			Str("subnet", event.Subnet).
+			Str("username", event.Username).
+			Int("count", event.Count).
+			Msg("Suspicious login pattern detected")
+		// Failing to record an event must not let the login through or fail it
+		if err := d.events.CreateEvent(event); err != nil {
+			d.logger.Error().Err(err).Str("type", event.Type).Msg("Failed to record security event")
+		}
+	}
+}
+
+// track is one counter a failed login counts towards
+type track struct {
+	key        counterKey
+	member     string
+	thresholds StuffingThresholds
+}
+
+func (d *StuffingDetector) tracks(username, ip string) []track {
+	return []track{
+		{key: counterKey{EventManyUsersFromIP, ip}, member: username, thresholds: d.policy.IP},
+		{key: counterKey{EventManyUsersFromSubnet, subnetOf(ip)}, member: username, thresholds: d.policy.Subnet},
+		{key: counterKey{EventManySourcesForUser, username}, member: ip, thresholds: d.policy.User},
+	}
+}
+
+func (t track) event(action LoginAction, count int, ip string) database.SecurityEvent {
+	event := database.SecurityEvent{Type: t.key.kind, Action: action.String(), Count: count}
+	switch t.key.kind {
+	case EventManyUsersFromIP:
+		event.IPAddress = t.key.value
+	case EventManyUsersFromSubnet:
+		event.Subnet = t.key.value
+		event.IPAddress = ip
+	case EventManySourcesForUser:
+		event.Username = t.key.value
+		event.IPAddress = ip
+	}
+	return event
+}
+
+// add records a failure of member at now and returns the distinct members
+// failing within the window
+func (c *counter) add(member string, now time.Time, window time.Duration) int {
+	if _, ok := c.members[member]; ok || len(c.members) < maxTrackedMembers {
+		c.members[member] = now
+	}
+	return c.count(now, window)
+}
+
+// count forgets members outside the window and returns the rest
+func (c *counter) count(now time.Time, window time.Duration) int {
+	for member, last := range c.members {
+		if now.Sub(last) >= window {
+			delete(c.members, member)
+		}
+	}
+	return len(c.members)
+}
+
+// subnetOf returns the /24 or /64 network of ip, or ip itself if it cannot
+// be parsed
+func subnetOf(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	bits := ipv6SubnetBits
+	if addr.Is4() {
+		bits = ipv4SubnetBits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
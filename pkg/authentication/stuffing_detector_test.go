@@ -0,0 +1,131 @@
+package authentication_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/internal/database"
+	"github.com/yourusername/user-management-api/internal/repository"
+	"github.com/yourusername/user-management-api/pkg/authentication"
+)
+
+// memorySecurityEvents keeps recorded security events in memory
+type memorySecurityEvents struct {
+	mu     sync.Mutex
+	events []database.SecurityEvent
+}
+
+func (m *memorySecurityEvents) CreateEvent(event *database.SecurityEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *memorySecurityEvents) FindEvents(filter repository.SecurityEventFilter) ([]database.SecurityEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]database.SecurityEvent(nil), m.events...), nil
+}
+
+func (m *memorySecurityEvents) PurgeExpired() (int64, error) { return 0, nil }
+
+func TestStuffingDetector(t *testing.T) {
+	policy := authentication.StuffingPolicy{
+		Window:        time.Minute,
+		IP:            authentication.StuffingThresholds{Delay: 2, Challenge: 3, Block: 4},
+		Subnet:        authentication.StuffingThresholds{Delay: 6},
+		User:          authentication.StuffingThresholds{Delay: 2, Challenge: 3},
+		BlockDuration: time.Hour,
+	}
+
+	t.Run("many users from one address", func(t *testing.T) {
+		events := &memorySecurityEvents{}
+		detector := authentication.NewStuffingDetector(policy, events, zerolog.Nop())
+
+		// Retrying the same username is the lockout's business
+		detector.RecordFailure("user1", "10.0.0.1")
+		detector.RecordFailure("user1", "10.0.0.1")
+		assert.Equal(t, authentication.LoginAllow, detector.Assess("anyone", "10.0.0.1").Action)
+
+		for i, expected := range []authentication.LoginAction{authentication.LoginDelay, authentication.LoginChallenge, authentication.LoginBlock} {
+			detector.RecordFailure(fmt.Sprintf("user%d", i+2), "10.0.0.1")
+			assessment := detector.Assess("anyone", "10.0.0.1")
+			assert.Equal(t, expected, assessment.Action)
+			assert.Equal(t, authentication.EventManyUsersFromIP, assessment.Reason)
+		}
+		assert.WithinDuration(t, time.Now().Add(time.Hour), detector.Assess("anyone", "10.0.0.1").BlockedUntil, time.Second)
+		assert.Equal(t, authentication.LoginAllow, detector.Assess("anyone", "10.0.0.2").Action)
+
+		require.Len(t, events.events, 3)
+		for i, action := range []string{"delay", "challenge", "block"} {
+			assert.Equal(t, authentication.EventManyUsersFromIP, events.events[i].Type)
+			assert.Equal(t, action, events.events[i].Action)
+			assert.Equal(t, "10.0.0.1", events.events[i].IPAddress)
+			assert.Equal(t, i+2, events.events[i].Count)
+		}
+	})
+
+	t.Run("many users from one network", func(t *testing.T) {
+		events := &memorySecurityEvents{}
+		detector := authentication.NewStuffingDetector(policy, events, zerolog.Nop())
+
+		// Spread over addresses so that no single one stands out
+		for i := range 6 {
+			detector.RecordFailure(fmt.Sprintf("user%d", i), fmt.Sprintf("192.168.7.%d", i+1))
+		}
+		assessment := detector.Assess("anyone", "192.168.7.200")
+		assert.Equal(t, authentication.LoginDelay, assessment.Action)
+		assert.Equal(t, authentication.EventManyUsersFromSubnet, assessment.Reason)
+		assert.Equal(t, authentication.LoginAllow, detector.Assess("anyone", "192.168.8.1").Action)
+
+		require.Len(t, events.events, 1)
+		assert.Equal(t, "192.168.7.0/24", events.events[0].Subnet)
+
+		// IPv6 clients are grouped by /64
+		for i := range 6 {
+			detector.RecordFailure(fmt.Sprintf("user%d", i), fmt.Sprintf("2001:db8:1:2::%d", i+1))
+		}
+		assert.Equal(t, authentication.LoginDelay, detector.Assess("anyone", "2001:db8:1:2::ffff").Action)
+		assert.Equal(t, authentication.LoginAllow, detector.Assess("anyone", "2001:db8:1:3::1").Action)
+	})
+
+	t.Run("one user from many addresses", func(t *testing.T) {
+		events := &memorySecurityEvents{}
+		detector := authentication.NewStuffingDetector(policy, events, zerolog.Nop())
+
+		for _, ip := range []string{"10.1.0.1", "10.2.0.1", "10.3.0.1"} {
+			detector.RecordFailure("victim", ip)
+		}
+		assessment := detector.Assess("victim", "10.4.0.1")
+		assert.Equal(t, authentication.LoginChallenge, assessment.Action)
+		assert.Equal(t, authentication.EventManySourcesForUser, assessment.Reason)
+		assert.Equal(t, authentication.LoginAllow, detector.Assess("bystander", "10.4.0.1").Action)
+
+		require.Len(t, events.events, 2)
+		assert.Equal(t, "victim", events.events[1].Username)
+		assert.Equal(t, "challenge", events.events[1].Action)
+	})
+
+	t.Run("failures leave the window", func(t *testing.T) {
+		short := policy
+		short.Window = 50 * time.Millisecond
+		detector := authentication.NewStuffingDetector(short, &memorySecurityEvents{}, zerolog.Nop())
+
+		detector.RecordFailure("user1", "10.0.0.1")
+		detector.RecordFailure("user2", "10.0.0.1")
+		assert.Equal(t, authentication.LoginDelay, detector.Assess("anyone", "10.0.0.1").Action)
+
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, authentication.LoginAllow, detector.Assess("anyone", "10.0.0.1").Action)
+		purged, err := detector.PurgeExpired()
+		require.NoError(t, err)
+		assert.Positive(t, purged)
+	})
+}
@@ -0,0 +1,21 @@
+package apperrors
+
+import "time"
+
+// BlockedError is returned for requests from sources that are blocked for a
+// while, such as addresses trying many accounts, with the time the block ends
+type BlockedError struct {
+	AuthenticationError
+	blockedUntil time.Time
+}
+
+// NewBlockedError creates a new BlockedError.
+func NewBlockedError(message string, blockedUntil time.Time) BlockedError {
+	return BlockedError{
+		AuthenticationError: NewAuthenticationError(ErrCodeTooManyRequests, message, nil),
+		blockedUntil:        blockedUntil,
+	}
+}
+
+// BlockedUntil returns when the block ends.
+func (e BlockedError) BlockedUntil() time.Time { return e.blockedUntil }
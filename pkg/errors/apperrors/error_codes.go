@@ -26,6 +26,12 @@ const (
 	ErrCodeInvalidTokenSignature ErrorCode = "INVALID_TOKEN_SIGNATURE"
 	ErrCodeTokenSigningError     ErrorCode = "TOKEN_SIGNING_ERROR"
 	ErrCodeParseError            ErrorCode = "PARSE_ERROR"
+	ErrCodeRefreshTokenReused    ErrorCode = "REFRESH_TOKEN_REUSED"
+	ErrCodeTokenInvalidIssuer    ErrorCode = "TOKEN_INVALID_ISSUER"
+	ErrCodeTokenInvalidAudience  ErrorCode = "TOKEN_INVALID_AUDIENCE"
+	ErrCodeTokenNotYetValid      ErrorCode = "TOKEN_NOT_YET_VALID"
+	ErrCodeDeviceMismatch        ErrorCode = "DEVICE_MISMATCH"
+	ErrCodeTokenAlreadyUsed      ErrorCode = "TOKEN_ALREADY_USED"
 
 	// Database Errors
 	ErrCodeDatabaseError ErrorCode = "DATABASE_ERROR"
@@ -38,8 +44,29 @@ const (
 	ErrCodeUserLocked         ErrorCode = "USER_LOCKED"
 	ErrCodeUserInactive       ErrorCode = "USER_INACTIVE"
 	ErrCodeUserDeleted        ErrorCode = "USER_DELETED"
+	ErrCodeEmailNotVerified   ErrorCode = "EMAIL_NOT_VERIFIED"
 	ErrCodeUnauthorized       ErrorCode = "UNAUTHORIZED"
 	ErrCodeInvalidCSRFToken   ErrorCode = "INVALID_CSRF_TOKEN"
+	// ErrCodeChallengeRequired asks logins from suspicious sources to solve a challenge first
+	ErrCodeChallengeRequired ErrorCode = "CHALLENGE_REQUIRED"
+
+	// Federated Login Errors
+	ErrCodeIdentityProviderError ErrorCode = "IDENTITY_PROVIDER_ERROR"
+	ErrCodeIdentityAlreadyLinked ErrorCode = "IDENTITY_ALREADY_LINKED"
+	ErrCodeAccountExists         ErrorCode = "ACCOUNT_EXISTS"
+
+	// OAuth 2.0 Errors, valued as the error parameter of RFC 6749
+	ErrCodeOAuthInvalidRequest          ErrorCode = "invalid_request"
+	ErrCodeOAuthInvalidClient           ErrorCode = "invalid_client"
+	ErrCodeOAuthInvalidGrant            ErrorCode = "invalid_grant"
+	ErrCodeOAuthUnauthorizedClient      ErrorCode = "unauthorized_client"
+	ErrCodeOAuthUnsupportedGrantType    ErrorCode = "unsupported_grant_type"
+	ErrCodeOAuthUnsupportedResponseType ErrorCode = "unsupported_response_type"
+	ErrCodeOAuthInvalidScope            ErrorCode = "invalid_scope"
+	ErrCodeOAuthAccessDenied            ErrorCode = "access_denied"
+	// Bearer token errors of RFC 6750
+	ErrCodeOAuthInvalidToken      ErrorCode = "invalid_token"
+	ErrCodeOAuthInsufficientScope ErrorCode = "insufficient_scope"
 
 	// General Errors
 	ErrCodeUnknownError  ErrorCode = "UNKNOWN_ERROR"
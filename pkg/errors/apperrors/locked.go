@@ -0,0 +1,22 @@
+package apperrors
+
+import "time"
+
+// AccountLockedError is returned for locked accounts, with the time the lock
+// ends. The time is zero for locks that only an administrator can lift.
+type AccountLockedError struct {
+	AuthenticationError
+	lockedUntil time.Time
+}
+
+// NewAccountLockedError creates a new AccountLockedError.
+func NewAccountLockedError(message string, lockedUntil time.Time) AccountLockedError {
+	return AccountLockedError{
+		AuthenticationError: NewAuthenticationError(ErrCodeUserLocked, message, nil),
+		lockedUntil:         lockedUntil,
+	}
+}
+
+// LockedUntil returns when the lock ends, or the zero time if it does not
+// end by itself.
+func (e AccountLockedError) LockedUntil() time.Time { return e.lockedUntil }
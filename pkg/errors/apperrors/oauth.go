@@ -0,0 +1,22 @@
+package apperrors
+
+// OAuth 2.0 protocol errors. The code is returned to the client as the error
+// parameter and the message as error_description.
+
+type OAuthError struct {
+	baseError
+}
+
+func NewOAuthError(code ErrorCode, message string, cause error) OAuthError {
+	return OAuthError{
+		baseError: baseError{
+			message: message,
+			code:    code,
+			cause:   cause,
+		},
+	}
+}
+
+func (e OAuthError) Code() ErrorCode { return e.code }
+func (e OAuthError) Error() string   { return e.message }
+func (e OAuthError) Unwrap() error   { return e.cause }
@@ -0,0 +1,60 @@
+// pkg/mailer/file.go
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes messages to a maildir for local development, so they can
+// be read with any maildir capable mail client instead of being delivered
+type FileMailer struct {
+	dir      string
+	from     string
+	sequence atomic.Uint64
+}
+
+// NewFileMailer creates a FileMailer writing to the maildir at dir, creating
+// its tmp, new and cur folders if needed
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create maildir: %w", err)
+		}
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message to tmp and moves it to new once complete, as the
+// maildir format requires, so readers never see a partial message
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s",
+		time.Now().Unix(), time.Now().Nanosecond()/1000, os.Getpid(), m.sequence.Add(1), hostname)
+
+	tmpPath := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, message.format(m.from), 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(m.dir, "new", name)); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to deliver mail: %w", err)
+	}
+	return nil
+}
+
+var _ Mailer = (*FileMailer)(nil)
@@ -0,0 +1,154 @@
+package mailer_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/mailer"
+)
+
+func TestFileMailerWritesMaildir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	fileMailer, err := mailer.NewFileMailer(dir, "noreply@example.com")
+	require.NoError(t, err)
+
+	err = fileMailer.Send(context.Background(), mailer.Message{
+		To:      "alice@example.com",
+		Subject: "Verify your email",
+		Body:    "first line\nsecond line",
+	})
+	require.NoError(t, err)
+
+	pending, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	delivered, err := os.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	require.Len(t, delivered, 1)
+
+	raw, err := os.ReadFile(filepath.Join(dir, "new", delivered[0].Name()))
+	require.NoError(t, err)
+	content := string(raw)
+	assert.Contains(t, content, "From: noreply@example.com\r\n")
+	assert.Contains(t, content, "To: alice@example.com\r\n")
+	assert.Contains(t, content, "Subject: Verify your email\r\n")
+	assert.Contains(t, content, "\r\n\r\nfirst line\r\nsecond line\r\n")
+}
+
+func TestMailersRejectHeaderInjection(t *testing.T) {
+	memoryMailer := mailer.NewMemoryMailer()
+
+	err := memoryMailer.Send(context.Background(), mailer.Message{
+		To:      "alice@example.com\r\nBcc: mallory@example.com",
+		Subject: "Hello",
+	})
+	assert.Error(t, err)
+	assert.Empty(t, memoryMailer.Messages())
+}
+
+func TestMemoryMailerCapturesMessages(t *testing.T) {
+	memoryMailer := mailer.NewMemoryMailer()
+	ctx := context.Background()
+
+	require.NoError(t, memoryMailer.Send(ctx, mailer.Message{To: "alice@example.com", Subject: "one"}))
+	require.NoError(t, memoryMailer.Send(ctx, mailer.Message{To: "bob@example.com", Subject: "two"}))
+	require.NoError(t, memoryMailer.Send(ctx, mailer.Message{To: "alice@example.com", Subject: "three"}))
+
+	assert.Len(t, memoryMailer.Messages(), 3)
+
+	last, ok := memoryMailer.LastMessageTo("alice@example.com")
+	require.True(t, ok)
+	assert.Equal(t, "three", last.Subject)
+
+	_, ok = memoryMailer.LastMessageTo("carol@example.com")
+	assert.False(t, ok)
+}
+
+func TestSMTPMailerDeliversToRelay(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go serveSMTPOnce(listener, received)
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	smtpMailer := mailer.NewSMTPMailer(mailer.SMTPConfig{
+		Host: host,
+		Port: portNumber,
+		From: "Accounts <noreply@example.com>",
+	})
+	err = smtpMailer.Send(context.Background(), mailer.Message{
+		To:      "alice@example.com",
+		Subject: "Verify your email",
+		Body:    "hello",
+	})
+	require.NoError(t, err)
+
+	transcript := <-received
+	assert.Contains(t, transcript, "MAIL FROM:<noreply@example.com>")
+	assert.Contains(t, transcript, "RCPT TO:<alice@example.com>")
+	assert.Contains(t, transcript, "Subject: Verify your email")
+	assert.Contains(t, transcript, "hello")
+}
+
+// serveSMTPOnce accepts one connection and speaks just enough SMTP to
+// receive a single message, reporting every line the client sent
+func serveSMTPOnce(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var transcript []string
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		transcript = append(transcript, line)
+
+		if inData {
+			if line == "." {
+				inData = false
+				reply("250 OK")
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "EHLO"):
+			reply("250 localhost")
+		case line == "DATA":
+			inData = true
+			reply("354 End data with <CR><LF>.<CR><LF>")
+		case line == "QUIT":
+			reply("221 Bye")
+			received <- transcript
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+	received <- transcript
+}
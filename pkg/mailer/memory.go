@@ -0,0 +1,50 @@
+// pkg/mailer/memory.go
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryMailer keeps sent messages in memory so tests can inspect them
+type MemoryMailer struct {
+	messages []Message
+	mu       sync.RWMutex
+}
+
+// NewMemoryMailer creates an empty MemoryMailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// LastMessageTo returns the latest message sent to the recipient
+func (m *MemoryMailer) LastMessageTo(to string) (Message, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+var _ Mailer = (*MemoryMailer)(nil)
@@ -0,0 +1,391 @@
+// pkg/oidc/oidc.go
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const (
+	// DefaultLeeway tolerates clock skew between the provider and this service
+	DefaultLeeway = time.Minute
+
+	// jwksRefreshInterval limits how often an unknown key ID makes the
+	// provider's key set be fetched again
+	jwksRefreshInterval = time.Minute
+	// maxResponseSize bounds the documents read from the provider
+	maxResponseSize = 1 << 20
+
+	scopeOpenID             = "openid"
+	codeChallengeMethodS256 = "S256"
+)
+
+// signingAlgorithms are the ID token algorithms accepted from providers. Keys
+// are taken from the provider's JWKS only, so shared secrets are never used.
+var signingAlgorithms = []string{token.AlgorithmRS256, token.AlgorithmES256, token.AlgorithmEdDSA}
+
+// ErrInvalidIDToken means the ID token returned by the provider failed verification
+var ErrInvalidIDToken = errors.New("oidc: invalid ID token")
+
+// Error is an error response of the provider's token endpoint (RFC 6749 section 5.2)
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return "oidc: " + e.Code
+	}
+	return "oidc: " + e.Code + ": " + e.Description
+}
+
+// Config is the client registration at the provider. RedirectURL is the
+// callback registered with it; openid is always requested on top of Scopes.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Leeway       time.Duration
+}
+
+// Metadata is the provider's discovery document (OpenID Connect Discovery 1.0
+// section 3)
+type Metadata struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserInfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported,omitempty"`
+}
+
+// Tokens is a successful token endpoint response
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in,omitempty"`
+}
+
+// Provider signs users in at an upstream OpenID Connect provider with the
+// authorization code flow and PKCE, and verifies the ID tokens it returns
+// (https://openid.net/specs/openid-connect-core-1_0.html). Discovery happens
+// on first use and is cached, as are the provider's keys.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          []token.JSONWebKey
+	keysFetchedAt time.Time
+}
+
+// NewProvider validates the configuration. The issuer must use https unless
+// it runs on the loopback interface. A nil client uses http.DefaultClient.
+func NewProvider(config Config, client *http.Client) (*Provider, error) {
+	if config.ClientID == "" {
+		return nil, errors.New("oidc: client ID is required")
+	}
+	if !secureURL(config.Issuer) {
+		return nil, fmt.Errorf("oidc: issuer %q must be an https URL", config.Issuer)
+	}
+	if redirect, err := url.Parse(config.RedirectURL); err != nil || redirect.Scheme == "" || redirect.Host == "" {
+		return nil, fmt.Errorf("oidc: invalid redirect URL %q", config.RedirectURL)
+	}
+	if config.Leeway <= 0 {
+		config.Leeway = DefaultLeeway
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Provider{config: config, client: client}, nil
+}
+
+// Discover fetches the provider's discovery document. The document must be
+// published by the configured issuer and support PKCE with S256.
+func (p *Provider) Discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := &Metadata{}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", metadata); err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed: %w", err)
+	}
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: discovery document is for issuer %q, not %q", metadata.Issuer, p.config.Issuer)
+	}
+	for _, endpoint := range []string{metadata.AuthorizationEndpoint, metadata.TokenEndpoint, metadata.JWKSURI} {
+		if !secureURL(endpoint) {
+			return nil, fmt.Errorf("oidc: discovery document has invalid endpoint %q", endpoint)
+		}
+	}
+	// Providers that do not list their methods are sent S256 regardless
+	if len(metadata.CodeChallengeMethodsSupported) > 0 &&
+		!slices.Contains(metadata.CodeChallengeMethodsSupported, codeChallengeMethodS256) {
+		return nil, errors.New("oidc: provider does not support PKCE with S256")
+	}
+
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// AuthCodeURL returns the authorization endpoint URL to send the user to. The
+// state, nonce and code verifier must be kept to complete the login.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := []string{scopeOpenID}
+	for _, scope := range p.config.Scopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {codeChallengeMethodS256},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Authenticate redeems the authorization code and verifies the ID token
+// returned with it, which must carry the nonce of the authorization request
+func (p *Provider) Authenticate(ctx context.Context, code, codeVerifier, nonce string) (*token.IDTokenClaims, error) {
+	tokens, err := p.Exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	return p.VerifyIDToken(ctx, tokens, nonce)
+}
+
+// Exchange redeems an authorization code at the token endpoint
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Tokens, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		// client_secret_basic encodes the credentials first (RFC 6749 section 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &Error{}
+		if json.Unmarshal(body, tokenErr) != nil || tokenErr.Code == "" {
+			return nil, fmt.Errorf("oidc: token endpoint returned status %d", resp.StatusCode)
+		}
+		return nil, tokenErr
+	}
+
+	tokens := &Tokens{}
+	if err := json.Unmarshal(body, tokens); err != nil {
+		return nil, fmt.Errorf("oidc: invalid token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no ID token", ErrInvalidIDToken)
+	}
+	return tokens, nil
+}
+
+// VerifyIDToken validates the ID token of a token response as required by
+// OpenID Connect Core section 3.1.3.7: signature, issuer, audience,
+// authorized party, lifetime, nonce and, when present, the access token hash
+func (p *Provider) VerifyIDToken(ctx context.Context, tokens *Tokens, nonce string) (*token.IDTokenClaims, error) {
+	claims := &token.IDTokenClaims{}
+	parsed, err := jwt.ParseWithClaims(tokens.IDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		return p.verificationKey(ctx, t)
+	},
+		jwt.WithValidMethods(signingAlgorithms),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(p.config.Leeway))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	if claims.IssuedAt == nil {
+		return nil, fmt.Errorf("%w: missing issued at", ErrInvalidIDToken)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty == "" {
+		return nil, fmt.Errorf("%w: missing authorized party", ErrInvalidIDToken)
+	}
+	if claims.AuthorizedParty != "" && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%w: issued to another party", ErrInvalidIDToken)
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claims.AccessTokenHash != "" &&
+		claims.AccessTokenHash != token.AccessTokenHash(parsed.Method.Alg(), tokens.AccessToken) {
+		return nil, fmt.Errorf("%w: access token hash mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// verificationKey finds the provider key that signed the token. An unknown
+// key ID refreshes the key set, as the provider may have rotated its keys.
+func (p *Provider) verificationKey(ctx context.Context, t *jwt.Token) (crypto.PublicKey, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	jwk := findKey(p.keys, kid)
+	if jwk == nil && time.Since(p.keysFetchedAt) >= jwksRefreshInterval {
+		if err := p.fetchKeys(ctx); err != nil {
+			return nil, err
+		}
+		jwk = findKey(p.keys, kid)
+	}
+	if jwk == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if jwk.Alg != "" && jwk.Alg != t.Method.Alg() {
+		return nil, fmt.Errorf("signing key %q is not for %s", kid, t.Method.Alg())
+	}
+	return jwk.PublicKey()
+}
+
+// fetchKeys replaces the cached key set. The caller must hold p.mu.
+func (p *Provider) fetchKeys(ctx context.Context) error {
+	if p.metadata == nil {
+		return errors.New("provider has not been discovered")
+	}
+	keySet := &token.JSONWebKeySet{}
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, keySet); err != nil {
+		return fmt.Errorf("fetching provider keys: %w", err)
+	}
+
+	p.keys = p.keys[:0]
+	for _, key := range keySet.Keys {
+		// Keys published for encryption cannot sign ID tokens
+		if key.Use == "" || key.Use == "sig" {
+			p.keys = append(p.keys, key)
+		}
+	}
+	p.keysFetchedAt = time.Now()
+	return nil
+}
+
+// findKey returns the key with the ID, or the only key for tokens without one
+func findKey(keys []token.JSONWebKey, kid string) *token.JSONWebKey {
+	if kid == "" {
+		if len(keys) == 1 {
+			return &keys[0]
+		}
+		return nil
+	}
+	for i := range keys {
+		if keys[i].Kid == kid {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// secureURL accepts https URLs, and http URLs on the loopback interface for
+// development and tests
+func secureURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || parsed.Fragment != "" {
+		return false
+	}
+	switch parsed.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := parsed.Hostname()
+		ip := net.ParseIP(host)
+		return host == "localhost" || (ip != nil && ip.IsLoopback())
+	default:
+		return false
+	}
+}
@@ -0,0 +1,122 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/oidc"
+	"github.com/yourusername/user-management-api/pkg/oidc/oidctest"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+const (
+	clientID     = "user-management-api"
+	clientSecret = "s3cr3t/+"
+	redirectURL  = "https://app.example.com/callback"
+	codeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// authorize runs an authorization at the test provider and returns the code
+func authorize(t *testing.T, idp *oidctest.Provider, provider *oidc.Provider, state, nonce string) string {
+	authorizationURL, err := provider.AuthCodeURL(context.Background(), state, nonce, codeVerifier)
+	require.NoError(t, err)
+	callback, err := idp.Authorize(authorizationURL)
+	require.NoError(t, err)
+	assert.Equal(t, state, callback.Query().Get("state"))
+	require.NotEmpty(t, callback.Query().Get("code"), callback.Query().Get("error"))
+	return callback.Query().Get("code")
+}
+
+func TestProviderAuthenticate(t *testing.T) {
+	idp, err := oidctest.NewProvider()
+	require.NoError(t, err)
+	defer idp.Close()
+	idp.RegisterClient(clientID, clientSecret, redirectURL)
+	idp.User = oidctest.User{Subject: "248289761001", Email: "jane@example.com", EmailVerified: true, PreferredUsername: "jane"}
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email"},
+	}, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	authorizationURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", codeVerifier)
+	require.NoError(t, err)
+	query, err := url.Parse(authorizationURL)
+	require.NoError(t, err)
+	assert.Equal(t, "openid email", query.Query().Get("scope"))
+	assert.Equal(t, "S256", query.Query().Get("code_challenge_method"))
+
+	code := authorize(t, idp, provider, "state-1", "nonce-1")
+	claims, err := provider.Authenticate(ctx, code, codeVerifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "248289761001", claims.Subject)
+	assert.Equal(t, "jane@example.com", claims.Email)
+	require.NotNil(t, claims.EmailVerified)
+	assert.True(t, *claims.EmailVerified)
+
+	// Codes are single use
+	_, err = provider.Authenticate(ctx, code, codeVerifier, "nonce-1")
+	var providerErr *oidc.Error
+	require.True(t, errors.As(err, &providerErr))
+	assert.Equal(t, "invalid_grant", providerErr.Code)
+
+	// The verifier must answer the challenge
+	code = authorize(t, idp, provider, "state-2", "nonce-2")
+	_, err = provider.Authenticate(ctx, code, "wrong-verifier-wrong-verifier-wrong-verifier", "nonce-2")
+	require.True(t, errors.As(err, &providerErr))
+
+	// ID tokens must carry the nonce of the authorization request
+	code = authorize(t, idp, provider, "state-3", "nonce-3")
+	_, err = provider.Authenticate(ctx, code, codeVerifier, "another-nonce")
+	assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+
+	// and be bound to the access token issued with them
+	idp.ModifyIDToken = func(claims *token.IDTokenClaims) { claims.AccessTokenHash = "tampered" }
+	code = authorize(t, idp, provider, "state-4", "nonce-4")
+	_, err = provider.Authenticate(ctx, code, codeVerifier, "nonce-4")
+	assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+}
+
+func TestProviderRejectsForeignTokens(t *testing.T) {
+	idp, err := oidctest.NewProvider()
+	require.NoError(t, err)
+	defer idp.Close()
+	idp.RegisterClient(clientID, clientSecret, redirectURL)
+	idp.RegisterClient("another-client", "", redirectURL)
+	idp.User = oidctest.User{Subject: "248289761001"}
+
+	provider, err := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer(), ClientID: clientID, ClientSecret: clientSecret, RedirectURL: redirectURL}, nil)
+	require.NoError(t, err)
+	other, err := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer(), ClientID: "another-client", RedirectURL: redirectURL}, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// An ID token issued to another client is not accepted
+	code := authorize(t, idp, other, "state", "nonce")
+	tokens, err := other.Exchange(ctx, code, codeVerifier)
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, tokens, "nonce")
+	assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+	_, err = other.VerifyIDToken(ctx, tokens, "nonce")
+	assert.NoError(t, err)
+
+	// Discovery documents must name the configured issuer
+	mismatched, err := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer() + "/", ClientID: clientID, RedirectURL: redirectURL}, nil)
+	require.NoError(t, err)
+	_, err = mismatched.Discover(ctx)
+	assert.Error(t, err)
+
+	// Only https issuers are accepted outside the loopback interface
+	_, err = oidc.NewProvider(oidc.Config{Issuer: "http://idp.example.com", ClientID: clientID, RedirectURL: redirectURL}, nil)
+	assert.Error(t, err)
+}
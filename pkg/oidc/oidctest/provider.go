@@ -0,0 +1,260 @@
+// Package oidctest runs an OpenID Connect provider on a local test server, so
+// federated logins can be exercised without a live identity provider.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// User is an account at the provider
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+// Provider is an in-memory provider supporting the authorization code flow
+// with PKCE. Every authorization request is approved for the current User.
+type Provider struct {
+	// User is the account the next authorization signs in
+	User User
+	// ModifyIDToken may change the claims of ID tokens before they are signed
+	ModifyIDToken func(claims *token.IDTokenClaims)
+
+	server *httptest.Server
+	signer *token.IDTokenSigner
+
+	mu      sync.Mutex
+	clients map[string]client
+	codes   map[string]*grant
+}
+
+type client struct {
+	secret      string
+	redirectURI string
+}
+
+type grant struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	user          User
+}
+
+// NewProvider starts a provider signing ID tokens with a new RS256 key.
+// Close it when done.
+func NewProvider() (*Provider, error) {
+	key, err := token.GenerateSigningKey(token.AlgorithmRS256)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{
+		clients: make(map[string]client),
+		codes:   make(map[string]*grant),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	p.server = httptest.NewServer(mux)
+	p.signer = token.NewIDTokenSigner(token.NewStaticKeyring(token.AccessToken, key), p.server.URL, 0)
+	return p, nil
+}
+
+// Issuer is the provider's issuer identifier
+func (p *Provider) Issuer() string {
+	return p.server.URL
+}
+
+// Close shuts the server down
+func (p *Provider) Close() {
+	p.server.Close()
+}
+
+// RegisterClient allows the client to sign users in. Clients without a
+// secret are public.
+func (p *Provider) RegisterClient(clientID, secret, redirectURI string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clients[clientID] = client{secret: secret, redirectURI: redirectURI}
+}
+
+// Authorize follows an authorization URL like a browser whose user approves
+// the request, and returns the URL the provider redirects back to
+func (p *Provider) Authorize(authorizationURL string) (*url.URL, error) {
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := browser.Get(authorizationURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("oidctest: authorization failed with status %d", resp.StatusCode)
+	}
+	return resp.Location()
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer(),
+		"authorization_endpoint":                p.Issuer() + "/authorize",
+		"token_endpoint":                        p.Issuer() + "/token",
+		"jwks_uri":                              p.Issuer() + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{p.signer.Algorithm()},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, p.signer.JWKS())
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	registered, ok := p.clients[query.Get("client_id")]
+	if !ok || registered.redirectURI != query.Get("redirect_uri") {
+		http.Error(w, "unknown client or redirect URI", http.StatusBadRequest)
+		return
+	}
+
+	redirect, _ := url.Parse(registered.redirectURI)
+	params := redirect.Query()
+	params.Set("state", query.Get("state"))
+	switch {
+	case query.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+		params.Set("error", "invalid_request")
+	default:
+		code := randomValue()
+		p.codes[code] = &grant{
+			clientID:      query.Get("client_id"),
+			redirectURI:   registered.redirectURI,
+			nonce:         query.Get("nonce"),
+			codeChallenge: query.Get("code_challenge"),
+			user:          p.User,
+		}
+		params.Set("code", code)
+	}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clientID, ok := p.authenticateClient(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// Codes are single use, whether or not the exchange succeeds
+	code := r.PostForm.Get("code")
+	granted, ok := p.codes[code]
+	delete(p.codes, code)
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || granted.clientID != clientID || granted.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != granted.codeChallenge {
+		writeError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	accessToken := randomValue()
+	verified := granted.user.EmailVerified
+	claims := token.IDTokenClaims{
+		Nonce:             granted.nonce,
+		AccessTokenHash:   p.signer.AccessTokenHash(accessToken),
+		PreferredUsername: granted.user.PreferredUsername,
+		Email:             granted.user.Email,
+		EmailVerified:     &verified,
+	}
+	if p.ModifyIDToken != nil {
+		p.ModifyIDToken(&claims)
+	}
+	idToken, err := p.signer.SignIDToken(granted.user.Subject, clientID, claims)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// authenticateClient accepts client_secret_basic for confidential clients and
+// a client_id parameter for public ones. The caller must hold p.mu.
+func (p *Provider) authenticateClient(r *http.Request) (string, bool) {
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		var idErr, secretErr error
+		clientID, idErr = url.QueryUnescape(clientID)
+		secret, secretErr = url.QueryUnescape(secret)
+		if idErr != nil || secretErr != nil {
+			return "", false
+		}
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+
+	registered, ok := p.clients[clientID]
+	if !ok || subtle.ConstantTimeCompare([]byte(registered.secret), []byte(secret)) != 1 {
+		return "", false
+	}
+	return clientID, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func randomValue() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,61 @@
+// Package passwordhash hashes passwords into self-describing strings in the
+// PHC string format, so the algorithm and parameters of every stored hash
+// are known when verifying it.
+package passwordhash
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedHash is returned for hashes of an unknown algorithm
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// ErrMalformedHash is returned for hashes that cannot be decoded
+var ErrMalformedHash = errors.New("malformed password hash")
+
+// PasswordHasher hashes new passwords and verifies passwords against stored
+// hashes
+type PasswordHasher interface {
+	// Hash returns a new hash of password with a random salt
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash, whichever
+	// supported algorithm made it
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was made with another algorithm
+	// or other parameters than Hash uses
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultHasher PasswordHasher = NewArgon2idHasher(DefaultArgon2idParams())
+)
+
+// Default returns the hasher user passwords are hashed with
+func Default() PasswordHasher {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultHasher
+}
+
+// SetDefault replaces the hasher user passwords are hashed with. It is meant
+// to be called once at startup.
+func SetDefault(hasher PasswordHasher) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultHasher = hasher
+}
+
+// Verify checks password against a hash made by any supported algorithm
+func Verify(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, argon2idPrefix):
+		return verifyArgon2id(password, encoded)
+	case isBcrypt(encoded):
+		return verifyBcrypt(password, encoded)
+	default:
+		return false, ErrUnsupportedHash
+	}
+}
@@ -0,0 +1,86 @@
+package passwordhash_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/passwordhash"
+)
+
+// fastArgon2id keeps the tests quick; production uses far higher costs
+var fastArgon2id = passwordhash.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := passwordhash.NewArgon2idHasher(fastArgon2id)
+
+	encoded, err := hasher.Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$"), encoded)
+	assert.Len(t, strings.Split(encoded, "$"), 6)
+
+	valid, err := hasher.Verify("StrongP@ssw0rd2024!", encoded)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = hasher.Verify("Wr0ng!Password", encoded)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// Salts are random
+	again, err := hasher.Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+	assert.NotEqual(t, encoded, again)
+
+	assert.False(t, hasher.NeedsRehash(encoded))
+	stronger := fastArgon2id
+	stronger.Iterations = 2
+	assert.True(t, passwordhash.NewArgon2idHasher(stronger).NeedsRehash(encoded))
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher := passwordhash.NewBcryptHasher(4)
+
+	encoded, err := hasher.Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$2a$04$"), encoded)
+
+	valid, err := hasher.Verify("StrongP@ssw0rd2024!", encoded)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = hasher.Verify("Wr0ng!Password", encoded)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	assert.False(t, hasher.NeedsRehash(encoded))
+	assert.True(t, passwordhash.NewBcryptHasher(5).NeedsRehash(encoded))
+}
+
+func TestVerifyAcrossAlgorithms(t *testing.T) {
+	argon2id := passwordhash.NewArgon2idHasher(fastArgon2id)
+	bcrypt := passwordhash.NewBcryptHasher(4)
+
+	bcryptHash, err := bcrypt.Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+	argon2idHash, err := argon2id.Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+
+	// Either hasher verifies the other's hashes, and wants to replace them
+	valid, err := argon2id.Verify("StrongP@ssw0rd2024!", bcryptHash)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.True(t, argon2id.NeedsRehash(bcryptHash))
+
+	valid, err = bcrypt.Verify("StrongP@ssw0rd2024!", argon2idHash)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.True(t, bcrypt.NeedsRehash(argon2idHash))
+
+	for _, encoded := range []string{"", "plaintext", "$md5$abc", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA"} {
+		valid, err := passwordhash.Verify("password", encoded)
+		assert.Error(t, err, encoded)
+		assert.False(t, valid)
+		assert.True(t, argon2id.NeedsRehash(encoded))
+	}
+}
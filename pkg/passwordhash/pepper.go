@@ -0,0 +1,122 @@
+package passwordhash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const pepperPrefix = "$hmac-sha256$k="
+
+// ErrUnknownPepper is returned for hashes peppered with a key that is no
+// longer configured
+var ErrUnknownPepper = errors.New("unknown password pepper")
+
+// PepperedHasher runs passwords through HMAC-SHA256 keyed with a server-side
+// pepper before hashing them, so hashes from a leaked database cannot be
+// cracked without the pepper. The ID of the pepper is stored in front of the
+// hash, as in $hmac-sha256$k=<key id>$argon2id$v=19$..., so peppers can be
+// rotated. Hashes without a pepper still verify.
+type PepperedHasher struct {
+	inner        PasswordHasher
+	peppers      map[string][]byte
+	currentKeyID string
+}
+
+// NewPepperedHasher creates a hasher peppering new hashes with the pepper
+// of currentKeyID. The other peppers verify hashes made before a rotation.
+func NewPepperedHasher(inner PasswordHasher, peppers map[string][]byte, currentKeyID string) (*PepperedHasher, error) {
+	for keyID, pepper := range peppers {
+		if !ValidPepperKeyID(keyID) {
+			return nil, fmt.Errorf("invalid pepper key ID %q", keyID)
+		}
+		if len(pepper) == 0 {
+			return nil, fmt.Errorf("pepper %q is empty", keyID)
+		}
+	}
+	if _, ok := peppers[currentKeyID]; !ok {
+		return nil, fmt.Errorf("pepper %q is not configured", currentKeyID)
+	}
+	return &PepperedHasher{inner: inner, peppers: peppers, currentKeyID: currentKeyID}, nil
+}
+
+// CurrentKeyID returns the ID of the pepper new hashes are made with
+func (h *PepperedHasher) CurrentKeyID() string {
+	return h.currentKeyID
+}
+
+func (h *PepperedHasher) Hash(password string) (string, error) {
+	hashed, err := h.inner.Hash(pepper(h.peppers[h.currentKeyID], password))
+	if err != nil {
+		return "", err
+	}
+	return pepperPrefix + h.currentKeyID + hashed, nil
+}
+
+func (h *PepperedHasher) Verify(password, encoded string) (bool, error) {
+	keyID, hashed, ok := splitPeppered(encoded)
+	if !ok {
+		return Verify(password, encoded)
+	}
+
+	key, ok := h.peppers[keyID]
+	if !ok {
+		return false, ErrUnknownPepper
+	}
+	return Verify(pepper(key, password), hashed)
+}
+
+// NeedsRehash also asks for hashes without a pepper, or with another pepper
+// than the current one
+func (h *PepperedHasher) NeedsRehash(encoded string) bool {
+	keyID, hashed, ok := splitPeppered(encoded)
+	if !ok || keyID != h.currentKeyID {
+		return true
+	}
+	return h.inner.NeedsRehash(hashed)
+}
+
+// PepperKeyID returns the ID of the pepper a hash was made with, or "" if
+// it has none
+func PepperKeyID(encoded string) string {
+	keyID, _, _ := splitPeppered(encoded)
+	return keyID
+}
+
+// ValidPepperKeyID accepts letters, digits, dashes and underscores, so key
+// IDs cannot be confused with the rest of the hash
+func ValidPepperKeyID(keyID string) bool {
+	if keyID == "" || len(keyID) > 32 {
+		return false
+	}
+	for _, r := range keyID {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// pepper returns the HMAC of the password, encoded so that it stays within
+// bcrypt's 72 byte limit
+func pepper(key []byte, password string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func splitPeppered(encoded string) (string, string, bool) {
+	if !strings.HasPrefix(encoded, pepperPrefix) {
+		return "", "", false
+	}
+	keyID, hashed, ok := strings.Cut(strings.TrimPrefix(encoded, pepperPrefix), "$")
+	if !ok || keyID == "" {
+		return "", "", false
+	}
+	return keyID, "$" + hashed, true
+}
+
+var _ PasswordHasher = (*PepperedHasher)(nil)
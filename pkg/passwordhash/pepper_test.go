@@ -0,0 +1,111 @@
+package passwordhash_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/passwordhash"
+)
+
+var testPeppers = map[string][]byte{
+	"1": []byte("first-pepper-0123456789abcdefghij"),
+	"2": []byte("second-pepper-0123456789abcdefghi"),
+}
+
+func TestPepperedHasher(t *testing.T) {
+	inner := passwordhash.NewArgon2idHasher(fastArgon2id)
+	hasher, err := passwordhash.NewPepperedHasher(inner, testPeppers, "1")
+	require.NoError(t, err)
+
+	encoded, err := hasher.Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$hmac-sha256$k=1$argon2id$v=19$"), encoded)
+	assert.Equal(t, "1", passwordhash.PepperKeyID(encoded))
+
+	valid, err := hasher.Verify("StrongP@ssw0rd2024!", encoded)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = hasher.Verify("Wr0ng!Password", encoded)
+	require.NoError(t, err)
+	assert.False(t, valid)
+	assert.False(t, hasher.NeedsRehash(encoded))
+
+	// The inner hash alone is useless without the pepper
+	valid, err = passwordhash.Verify("StrongP@ssw0rd2024!", strings.TrimPrefix(encoded, "$hmac-sha256$k=1"))
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// The same key ID with another secret does not verify
+	wrong, err := passwordhash.NewPepperedHasher(inner, map[string][]byte{"1": []byte("another-pepper-0123456789abcdefg")}, "1")
+	require.NoError(t, err)
+	valid, err = wrong.Verify("StrongP@ssw0rd2024!", encoded)
+	require.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestPepperedHasherRotation(t *testing.T) {
+	inner := passwordhash.NewArgon2idHasher(fastArgon2id)
+	old, err := passwordhash.NewPepperedHasher(inner, testPeppers, "1")
+	require.NoError(t, err)
+	rotated, err := passwordhash.NewPepperedHasher(inner, testPeppers, "2")
+	require.NoError(t, err)
+
+	encoded, err := old.Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+
+	// Hashes under the old pepper still verify, but want re-peppering
+	valid, err := rotated.Verify("StrongP@ssw0rd2024!", encoded)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.True(t, rotated.NeedsRehash(encoded))
+
+	repeppered, err := rotated.Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+	assert.Equal(t, "2", passwordhash.PepperKeyID(repeppered))
+	assert.False(t, rotated.NeedsRehash(repeppered))
+
+	// Once the old pepper is dropped its hashes no longer verify
+	dropped, err := passwordhash.NewPepperedHasher(inner, map[string][]byte{"2": testPeppers["2"]}, "2")
+	require.NoError(t, err)
+	valid, err = dropped.Verify("StrongP@ssw0rd2024!", encoded)
+	assert.ErrorIs(t, err, passwordhash.ErrUnknownPepper)
+	assert.False(t, valid)
+}
+
+func TestPepperedHasherUnpepperedHashes(t *testing.T) {
+	legacy, err := passwordhash.NewBcryptHasher(4).Hash("StrongP@ssw0rd2024!")
+	require.NoError(t, err)
+	assert.Equal(t, "", passwordhash.PepperKeyID(legacy))
+
+	hasher, err := passwordhash.NewPepperedHasher(passwordhash.NewBcryptHasher(4), testPeppers, "1")
+	require.NoError(t, err)
+	valid, err := hasher.Verify("StrongP@ssw0rd2024!", legacy)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.True(t, hasher.NeedsRehash(legacy))
+
+	// Peppered bcrypt hashes stay within bcrypt's input limit
+	encoded, err := hasher.Hash(strings.Repeat("a", 100))
+	require.NoError(t, err)
+	valid, err = hasher.Verify(strings.Repeat("a", 100)+"b", encoded)
+	require.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestNewPepperedHasher(t *testing.T) {
+	inner := passwordhash.NewArgon2idHasher(fastArgon2id)
+
+	_, err := passwordhash.NewPepperedHasher(inner, testPeppers, "3")
+	assert.Error(t, err)
+	_, err = passwordhash.NewPepperedHasher(inner, map[string][]byte{"bad$id": testPeppers["1"]}, "bad$id")
+	assert.Error(t, err)
+	_, err = passwordhash.NewPepperedHasher(inner, map[string][]byte{"1": nil}, "1")
+	assert.Error(t, err)
+
+	assert.True(t, passwordhash.ValidPepperKeyID("2026-10_a"))
+	assert.False(t, passwordhash.ValidPepperKeyID(""))
+	assert.False(t, passwordhash.ValidPepperKeyID(strings.Repeat("a", 33)))
+}
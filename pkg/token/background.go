@@ -0,0 +1,63 @@
+// pkg/token/background.go
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// periodicTask runs a function on a fixed interval until stopped
+type periodicTask struct {
+	interval time.Duration
+	run      func()
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+}
+
+// start launches the loop. Starting a running task is a no-op.
+func (p *periodicTask) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go p.loop(ctx, p.done)
+}
+
+// stop signals the loop to exit and waits for it to finish
+func (p *periodicTask) stop() bool {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.mu.Unlock()
+
+	if cancel == nil {
+		return false
+	}
+	cancel()
+	<-done
+	return true
+}
+
+func (p *periodicTask) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.run()
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package token
+
+// Exported for the PASETO test vectors, which need fixed nonces and explicit footers
+var (
+	PasetoEncryptWithNonce = pasetoEncryptWithNonce
+	PasetoDecrypt          = pasetoDecrypt
+	PasetoSign             = pasetoSign
+	PasetoVerify           = pasetoVerify
+)
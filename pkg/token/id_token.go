@@ -0,0 +1,134 @@
+// pkg/token/id_token.go
+package token
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+)
+
+// DefaultIDTokenTTL is the lifetime of ID tokens when none is configured
+const DefaultIDTokenTTL = time.Hour
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. The subject is
+// the user and the audience the client the token was issued to.
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	AccessTokenHash   string `json:"at_hash,omitempty"`
+	AuthorizedParty   string `json:"azp,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenSigner signs ID tokens with the access token keyring, so clients
+// verify them with the keys published at /.well-known/jwks.json. With an
+// HS256 keyring clients cannot check the signature and rely on receiving the
+// ID token directly from the token endpoint instead (OIDC Core 3.1.3.7).
+type IDTokenSigner struct {
+	keys   *Keyring
+	issuer string
+	ttl    time.Duration
+}
+
+// NewIDTokenSigner creates an IDTokenSigner issuing tokens as issuer
+func NewIDTokenSigner(keys *Keyring, issuer string, ttl time.Duration) *IDTokenSigner {
+	if ttl <= 0 {
+		ttl = DefaultIDTokenTTL
+	}
+	return &IDTokenSigner{
+		keys:   keys,
+		issuer: issuer,
+		ttl:    ttl,
+	}
+}
+
+// Algorithm returns the JWS algorithm ID tokens are currently signed with
+func (s *IDTokenSigner) Algorithm() string {
+	if key := s.keys.SigningKey(); key != nil {
+		return key.Method.Alg()
+	}
+	return ""
+}
+
+// SignIDToken fills in the registered claims and signs the ID token
+func (s *IDTokenSigner) SignIDToken(subject, audience string, claims IDTokenClaims) (string, apperrors.AppError) {
+	signingKey := s.keys.SigningKey()
+	if signingKey == nil {
+		return "", apperrors.NewTokenError(apperrors.ErrCodeTokenSigningError, "No active signing key", nil)
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.New().String(),
+		Issuer:    s.issuer,
+		Subject:   subject,
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+
+	idToken := jwt.NewWithClaims(signingKey.Method, claims)
+	idToken.Header["kid"] = signingKey.ID
+	signed, err := idToken.SignedString(signingKey.signKey)
+	if err != nil {
+		return "", apperrors.NewTokenError(apperrors.ErrCodeTokenSigningError, "Failed to sign ID token", err)
+	}
+	return signed, nil
+}
+
+// ValidateIDToken verifies an ID token issued to audience and returns its claims
+func (s *IDTokenSigner) ValidateIDToken(tokenString, audience string) (*IDTokenClaims, apperrors.AppError) {
+	opts := []jwt.ParserOption{jwt.WithIssuedAt(), jwt.WithExpirationRequired(), jwt.WithAudience(audience)}
+	if s.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(s.issuer))
+	}
+
+	parsed, err := jwt.ParseWithClaims(tokenString, &IDTokenClaims{}, func(t *jwt.Token) (interface{}, error) {
+		return verificationKeyFor(s.keys, t)
+	}, opts...)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	claims, ok := parsed.Claims.(*IDTokenClaims)
+	if !ok {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidClaim, "Invalid token claims", nil)
+	}
+	return claims, nil
+}
+
+// JWKS returns the public keys that verify ID tokens
+func (s *IDTokenSigner) JWKS() JSONWebKeySet {
+	return JSONWebKeySet{Keys: append([]JSONWebKey{}, s.keys.publicKeys()...)}
+}
+
+// AccessTokenHash computes the at_hash claim for an access token issued with
+// the signer's ID tokens
+func (s *IDTokenSigner) AccessTokenHash(accessToken string) string {
+	return AccessTokenHash(s.Algorithm(), accessToken)
+}
+
+// AccessTokenHash computes the at_hash claim binding an ID token to the access
+// token issued with it: the left half of the access token's hash, using the
+// hash function of the ID token's signing algorithm
+func AccessTokenHash(algorithm, accessToken string) string {
+	var h hash.Hash
+	if algorithm == AlgorithmEdDSA {
+		h = sha512.New()
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(accessToken))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+var _ KeySetProvider = (*IDTokenSigner)(nil)
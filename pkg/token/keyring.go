@@ -0,0 +1,486 @@
+// pkg/token/keyring.go
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// StoredSigningKey is the persisted form of a keyring entry. Material is
+// encrypted by the keyring before it reaches the store and is cleared once
+// the key is retired.
+type StoredSigningKey struct {
+	ID        string
+	TokenType TokenType
+	Algorithm string
+	Material  []byte
+	Active    bool
+	CreatedAt time.Time
+	RetireAt  *time.Time
+	Retired   bool
+}
+
+// KeyringStore persists keyring state across restarts
+type KeyringStore interface {
+	LoadSigningKeys(tokenType TokenType) ([]StoredSigningKey, error)
+	SaveSigningKey(key StoredSigningKey) error
+}
+
+// KeyInfo describes a keyring entry without exposing key material
+type KeyInfo struct {
+	ID        string     `json:"kid"`
+	Algorithm string     `json:"alg"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetireAt  *time.Time `json:"retire_at,omitempty"`
+}
+
+type keyringEntry struct {
+	key       *SigningKey
+	createdAt time.Time
+	retireAt  *time.Time
+	retired   bool
+}
+
+// Keyring holds the key used to sign new tokens of one type and the older keys
+// that are still accepted for verification during their grace period
+type Keyring struct {
+	tokenType   TokenType
+	algorithm   string
+	gracePeriod time.Duration
+	store       KeyringStore
+	aead        cipher.AEAD
+	logger      zerolog.Logger
+
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*keyringEntry
+	// reloadedAt and unknownKids throttle the reloads tokens with an unknown
+	// kid trigger, which anyone can forge
+	reloadedAt  time.Time
+	unknownKids map[string]time.Time
+}
+
+const (
+	// keyReloadInterval is the least time between reloads for unknown kids
+	keyReloadInterval = 5 * time.Second
+	// unknownKidTTL is how long a kid the store did not have is refused
+	// without reloading again
+	unknownKidTTL = time.Minute
+)
+
+// NewKeyring creates a keyring and loads any persisted keys. Rotated-out keys
+// stay valid for verification for gracePeriod, which should be at least the
+// longest token TTL. A nil store keeps the keyring in memory only.
+func NewKeyring(
+	tokenType TokenType,
+	algorithm string,
+	gracePeriod time.Duration,
+	store KeyringStore,
+	encryptionSecret string,
+	logger zerolog.Logger,
+) (*Keyring, error) {
+	sum := sha256.Sum256([]byte(encryptionSecret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("error creating keyring cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating keyring cipher: %w", err)
+	}
+
+	kr := &Keyring{
+		tokenType:   tokenType,
+		algorithm:   algorithm,
+		gracePeriod: gracePeriod,
+		store:       store,
+		aead:        aead,
+		logger:      logger.With().Str("component", "Keyring").Str("token_type", string(tokenType)).Logger(),
+		keys:        make(map[string]*keyringEntry),
+		unknownKids: make(map[string]time.Time),
+	}
+
+	if err := kr.load(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// NewStaticKeyring creates an in-memory keyring with a single key
+func NewStaticKeyring(tokenType TokenType, key *SigningKey) *Keyring {
+	return &Keyring{
+		tokenType: tokenType,
+		algorithm: key.Method.Alg(),
+		logger:    zerolog.Nop(),
+		active:    key,
+		keys: map[string]*keyringEntry{
+			key.ID: {key: key, createdAt: time.Now()},
+		},
+	}
+}
+
+// load merges the persisted keys into the keyring, so that keys rotated in or
+// retired by other replicas sharing the store take effect here. Callers must
+// hold kr.mu, except during construction.
+func (kr *Keyring) load() error {
+	if kr.store == nil {
+		return nil
+	}
+
+	stored, err := kr.store.LoadSigningKeys(kr.tokenType)
+	if err != nil {
+		return fmt.Errorf("error loading signing keys: %w", err)
+	}
+	kr.merge(stored)
+	return nil
+}
+
+// merge adopts persisted keys. Callers must hold kr.mu.
+func (kr *Keyring) merge(stored []StoredSigningKey) {
+	var activeEntry *keyringEntry
+	for _, record := range stored {
+		entry, known := kr.keys[record.ID]
+		if !known {
+			entry = &keyringEntry{createdAt: record.CreatedAt}
+		}
+
+		if record.Retired {
+			entry.key = nil
+		} else if entry.key == nil {
+			material, err := kr.open(record.Material)
+			if err != nil {
+				// The keyring secret changed; the key cannot be used anymore
+				kr.logger.Warn().Err(err).Str("kid", record.ID).Msg("Skipping signing key that cannot be decrypted")
+				continue
+			}
+			key, err := parseSigningKey(record.Algorithm, material)
+			if err != nil {
+				kr.logger.Warn().Err(err).Str("kid", record.ID).Msg("Skipping unreadable signing key")
+				continue
+			}
+			entry.key = key
+		}
+		entry.retireAt = record.RetireAt
+		entry.retired = record.Retired
+		kr.keys[record.ID] = entry
+
+		// Replicas rotating at the same moment can leave several active keys; the newest wins
+		if record.Active && entry.key != nil && (activeEntry == nil || entry.createdAt.After(activeEntry.createdAt)) {
+			activeEntry = entry
+		}
+	}
+
+	if activeEntry != nil {
+		kr.active = activeEntry.key
+	}
+}
+
+// reloadFor reloads the store for a token whose kid is unknown, at most once
+// per keyReloadInterval. The store is read without holding kr.mu so that
+// verifications with known keys carry on meanwhile.
+func (kr *Keyring) reloadFor(kid string) {
+	kr.mu.Lock()
+	if kr.store == nil || time.Since(kr.reloadedAt) < keyReloadInterval {
+		kr.mu.Unlock()
+		return
+	}
+	kr.reloadedAt = time.Now()
+	kr.mu.Unlock()
+
+	stored, err := kr.store.LoadSigningKeys(kr.tokenType)
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if err != nil {
+		kr.logger.Error().Err(err).Msg("Failed to reload signing keys")
+		return
+	}
+	kr.merge(stored)
+
+	// The key had to be stored before any token was signed with it
+	if _, ok := kr.keys[kid]; !ok {
+		now := time.Now()
+		for unknown, seenAt := range kr.unknownKids {
+			if now.Sub(seenAt) >= unknownKidTTL {
+				delete(kr.unknownKids, unknown)
+			}
+		}
+		kr.unknownKids[kid] = now
+	}
+}
+
+// Seed makes key the active signing key unless the keyring has seen it
+// before. This lets a changed configured secret rotate in without
+// invalidating tokens signed with the previous one.
+func (kr *Keyring) Seed(key *SigningKey) error {
+	kr.mu.RLock()
+	_, known := kr.keys[key.ID]
+	hasActive := kr.active != nil
+	kr.mu.RUnlock()
+
+	if known && hasActive {
+		return nil
+	}
+	return kr.Rotate(key)
+}
+
+// Rotate makes key the signing key. The previous signing keys remain valid
+// for verification until the grace period has passed.
+func (kr *Keyring) Rotate(key *SigningKey) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	// Pick up rotations made by other replicas so that their keys are retired too
+	if err := kr.load(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	retireAt := now.Add(kr.gracePeriod)
+	for kid, entry := range kr.keys {
+		if kid == key.ID || entry.key == nil || entry.retired || entry.retireAt != nil {
+			continue
+		}
+		entry.retireAt = &retireAt
+		if err := kr.persist(kid, entry, false); err != nil {
+			return err
+		}
+	}
+
+	entry, exists := kr.keys[key.ID]
+	if !exists {
+		entry = &keyringEntry{createdAt: now}
+		kr.keys[key.ID] = entry
+	}
+	entry.key = key
+	entry.retireAt = nil
+	entry.retired = false
+	if err := kr.persist(key.ID, entry, true); err != nil {
+		return err
+	}
+
+	kr.active = key
+	kr.logger.Info().Str("kid", key.ID).Str("algorithm", key.Method.Alg()).Msg("Signing key rotated")
+	return nil
+}
+
+// RotateGenerated rotates to a freshly generated key of the keyring's algorithm
+func (kr *Keyring) RotateGenerated() (*SigningKey, error) {
+	key, err := GenerateSigningKey(kr.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := kr.Rotate(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// rotateIfDue rotates to a generated key unless the active key, which another
+// replica sharing the store may have rotated in, is younger than minAge
+func (kr *Keyring) rotateIfDue(minAge time.Duration) (bool, error) {
+	kr.mu.Lock()
+	err := kr.load()
+	due := kr.active == nil || time.Since(kr.keys[kr.active.ID].createdAt) >= minAge
+	kr.mu.Unlock()
+
+	if err != nil || !due {
+		return false, err
+	}
+	if _, err := kr.RotateGenerated(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SigningKey returns the key used for new tokens
+func (kr *Keyring) SigningKey() *SigningKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.active
+}
+
+// VerificationKey returns the key with the given ID if it is still accepted.
+// Unknown IDs reload the store, as another replica may have rotated, unless
+// that happened moments ago or the ID was already looked for.
+func (kr *Keyring) VerificationKey(kid string) (*SigningKey, bool) {
+	kr.mu.RLock()
+	_, known := kr.keys[kid]
+	seenAt, refused := kr.unknownKids[kid]
+	kr.mu.RUnlock()
+	if !known && (!refused || time.Since(seenAt) >= unknownKidTTL) {
+		kr.reloadFor(kid)
+	}
+
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	entry, ok := kr.keys[kid]
+	if !ok || entry.key == nil || entry.retired {
+		return nil, false
+	}
+	if entry.retireAt != nil && !time.Now().Before(*entry.retireAt) {
+		return nil, false
+	}
+	return entry.key, true
+}
+
+// PurgeExpired retires keys whose grace period has passed and erases their
+// material. It satisfies ExpiryPurger so a RevocationSweeper can drive it,
+// which also keeps the keyring in sync with the store.
+func (kr *Keyring) PurgeExpired() (int64, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if err := kr.load(); err != nil {
+		return 0, err
+	}
+
+	var retired int64
+	now := time.Now()
+	for kid, entry := range kr.keys {
+		if entry.retired || entry.retireAt == nil || now.Before(*entry.retireAt) {
+			continue
+		}
+		entry.retired = true
+		entry.key = nil
+		if err := kr.persist(kid, entry, false); err != nil {
+			return retired, err
+		}
+		kr.logger.Info().Str("kid", kid).Msg("Signing key retired")
+		retired++
+	}
+	return retired, nil
+}
+
+// Keys describes every key that is still accepted, newest first
+func (kr *Keyring) Keys() []KeyInfo {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	var infos []KeyInfo
+	for kid, entry := range kr.keys {
+		if entry.retired || entry.key == nil {
+			continue
+		}
+		infos = append(infos, KeyInfo{
+			ID:        kid,
+			Algorithm: entry.key.Method.Alg(),
+			Active:    kr.active != nil && kr.active.ID == kid,
+			CreatedAt: entry.createdAt,
+			RetireAt:  entry.retireAt,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.After(infos[j].CreatedAt) })
+	return infos
+}
+
+// publicKeys returns the JWKs of every accepted asymmetric key
+func (kr *Keyring) publicKeys() []JSONWebKey {
+	var jwks []JSONWebKey
+	for _, info := range kr.Keys() {
+		key, ok := kr.VerificationKey(info.ID)
+		if !ok || key.IsSymmetric() {
+			continue
+		}
+		if jwk, err := key.publicJWK(); err == nil {
+			jwks = append(jwks, *jwk)
+		}
+	}
+	return jwks
+}
+
+// persist writes an entry to the store. Callers must hold kr.mu.
+func (kr *Keyring) persist(kid string, entry *keyringEntry, active bool) error {
+	if kr.store == nil {
+		return nil
+	}
+
+	record := StoredSigningKey{
+		ID:        kid,
+		TokenType: kr.tokenType,
+		Active:    active,
+		CreatedAt: entry.createdAt,
+		RetireAt:  entry.retireAt,
+		Retired:   entry.retired,
+	}
+
+	if entry.key != nil {
+		material, err := entry.key.marshalPrivate()
+		if err != nil {
+			return err
+		}
+		record.Algorithm = entry.key.Method.Alg()
+		if record.Material, err = kr.seal(material); err != nil {
+			return err
+		}
+	}
+
+	if err := kr.store.SaveSigningKey(record); err != nil {
+		return fmt.Errorf("error saving signing key: %w", err)
+	}
+	return nil
+}
+
+func (kr *Keyring) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, kr.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	return kr.aead.Seal(nonce, nonce, plaintext, []byte(kr.tokenType)), nil
+}
+
+func (kr *Keyring) open(ciphertext []byte) ([]byte, error) {
+	nonceSize := kr.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, fmt.Errorf("signing key material is truncated")
+	}
+	return kr.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], []byte(kr.tokenType))
+}
+
+// KeyRotator rotates a set of keyrings to freshly generated keys on a schedule
+type KeyRotator struct {
+	keyrings []*Keyring
+	logger   zerolog.Logger
+	task     *periodicTask
+}
+
+// NewKeyRotator creates a rotator for the given keyrings
+func NewKeyRotator(interval time.Duration, logger zerolog.Logger, keyrings ...*Keyring) *KeyRotator {
+	r := &KeyRotator{
+		keyrings: keyrings,
+		logger:   logger.With().Str("component", "KeyRotator").Logger(),
+	}
+	r.task = &periodicTask{interval: interval, run: r.rotate}
+	return r
+}
+
+// Start launches the background rotation loop
+func (r *KeyRotator) Start() {
+	r.task.start()
+}
+
+// Stop signals the rotation loop to exit and waits for it to finish
+func (r *KeyRotator) Stop() {
+	if r.task.stop() {
+		r.logger.Info().Msg("Key rotator stopped")
+	}
+}
+
+func (r *KeyRotator) rotate() {
+	// Every replica runs a rotator; only the first one to reach a tick rotates
+	for _, kr := range r.keyrings {
+		if _, err := kr.rotateIfDue(r.task.interval / 2); err != nil {
+			r.logger.Error().Err(err).Str("token_type", string(kr.tokenType)).Msg("Scheduled key rotation failed")
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package token_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+// memoryKeyringStore is a KeyringStore that survives "restarts" of a keyring
+type memoryKeyringStore struct {
+	mu   sync.Mutex
+	keys map[string]token.StoredSigningKey
+}
+
+func newMemoryKeyringStore() *memoryKeyringStore {
+	return &memoryKeyringStore{keys: make(map[string]token.StoredSigningKey)}
+}
+
+func (s *memoryKeyringStore) LoadSigningKeys(tokenType token.TokenType) ([]token.StoredSigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []token.StoredSigningKey
+	for _, key := range s.keys {
+		if key.TokenType == tokenType {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memoryKeyringStore) SaveSigningKey(key token.StoredSigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = key
+	return nil
+}
+
+func newTestKeyrings(t *testing.T, store token.KeyringStore, algorithm string, grace time.Duration) (*token.Keyring, *token.Keyring) {
+	accessKeys, err := token.NewKeyring(token.AccessToken, algorithm, grace, store, "keyring_secret", zerolog.Nop())
+	require.NoError(t, err)
+	refreshKeys, err := token.NewKeyring(token.RefreshToken, token.AlgorithmHS256, grace, store, "keyring_secret", zerolog.Nop())
+	require.NoError(t, err)
+	return accessKeys, refreshKeys
+}
+
+func TestKeyringRotationKeepsOutstandingTokensValid(t *testing.T) {
+	accessKeys, refreshKeys := newTestKeyrings(t, nil, token.AlgorithmHS256, time.Hour)
+	require.NoError(t, accessKeys.Seed(token.NewHMACSigningKey("secret_key")))
+	require.NoError(t, refreshKeys.Seed(token.NewHMACSigningKey("refresh_secret_key")))
+
+	tm := token.NewTokenManager("", "", token.DefaultTokenPolicy(), nil, token.WithKeyrings(accessKeys, refreshKeys))
+
+	oldToken, err := tm.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+
+	// Changing the configured secret rotates instead of replacing
+	require.NoError(t, accessKeys.Seed(token.NewHMACSigningKey("new_secret_key")))
+	newToken, err := tm.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+
+	_, err = tm.ValidateToken(oldToken, token.AccessToken)
+	assert.NoError(t, err)
+	_, err = tm.ValidateToken(newToken, token.AccessToken)
+	assert.NoError(t, err)
+	assert.Len(t, accessKeys.Keys(), 2)
+}
+
+func TestKeyringRetiresKeysAfterGracePeriod(t *testing.T) {
+	accessKeys, refreshKeys := newTestKeyrings(t, nil, token.AlgorithmES256, 50*time.Millisecond)
+	_, err := accessKeys.RotateGenerated()
+	require.NoError(t, err)
+	_, err = refreshKeys.RotateGenerated()
+	require.NoError(t, err)
+
+	tm := token.NewTokenManager("", "", token.DefaultTokenPolicy(), nil, token.WithKeyrings(accessKeys, refreshKeys))
+	oldToken, appErr := tm.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, appErr)
+
+	_, err = accessKeys.RotateGenerated()
+	require.NoError(t, err)
+	assert.Len(t, tm.JWKS().Keys, 2)
+
+	time.Sleep(60 * time.Millisecond)
+	retired, err := accessKeys.PurgeExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), retired)
+
+	_, appErr = tm.ValidateToken(oldToken, token.AccessToken)
+	assert.Error(t, appErr)
+	assert.Len(t, tm.JWKS().Keys, 1)
+}
+
+func TestKeyringSurvivesRestart(t *testing.T) {
+	store := newMemoryKeyringStore()
+
+	accessKeys, refreshKeys := newTestKeyrings(t, store, token.AlgorithmEdDSA, time.Hour)
+	_, err := accessKeys.RotateGenerated()
+	require.NoError(t, err)
+	require.NoError(t, refreshKeys.Seed(token.NewHMACSigningKey("refresh_secret_key")))
+	tm := token.NewTokenManager("", "", token.DefaultTokenPolicy(), nil, token.WithKeyrings(accessKeys, refreshKeys))
+	issued, appErr := tm.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, appErr)
+
+	// Persisted material must not be stored in the clear
+	for _, stored := range store.keys {
+		assert.NotContains(t, string(stored.Material), "PRIVATE KEY")
+	}
+
+	// A new process loads the same keys
+	accessKeys, refreshKeys = newTestKeyrings(t, store, token.AlgorithmEdDSA, time.Hour)
+	tm = token.NewTokenManager("", "", token.DefaultTokenPolicy(), nil, token.WithKeyrings(accessKeys, refreshKeys))
+	_, appErr = tm.ValidateToken(issued, token.AccessToken)
+	assert.NoError(t, appErr)
+
+	// Without the keyring secret the keys cannot be recovered
+	lockedOut, err := token.NewKeyring(token.AccessToken, token.AlgorithmEdDSA, time.Hour, store, "other_secret", zerolog.Nop())
+	require.NoError(t, err)
+	assert.Nil(t, lockedOut.SigningKey())
+}
+
+func TestKeyringReplicasShareRotations(t *testing.T) {
+	store := newMemoryKeyringStore()
+	first, err := token.NewKeyring(token.AccessToken, token.AlgorithmEdDSA, time.Hour, store, "keyring_secret", zerolog.Nop())
+	require.NoError(t, err)
+	_, err = first.RotateGenerated()
+	require.NoError(t, err)
+	second, err := token.NewKeyring(token.AccessToken, token.AlgorithmEdDSA, time.Hour, store, "keyring_secret", zerolog.Nop())
+	require.NoError(t, err)
+	require.Equal(t, first.SigningKey().ID, second.SigningKey().ID)
+
+	// A key rotated in by one replica is accepted by the other
+	rotated, err := first.RotateGenerated()
+	require.NoError(t, err)
+	key, ok := second.VerificationKey(rotated.ID)
+	require.True(t, ok)
+	assert.Equal(t, rotated.ID, key.ID)
+	assert.Equal(t, rotated.ID, second.SigningKey().ID)
+
+	// Rotating on the other replica retires both earlier keys
+	latest, err := second.RotateGenerated()
+	require.NoError(t, err)
+	var active []string
+	for _, info := range second.Keys() {
+		if info.Active {
+			active = append(active, info.ID)
+		} else {
+			assert.NotNil(t, info.RetireAt, info.ID)
+		}
+	}
+	assert.Equal(t, []string{latest.ID}, active)
+	_, err = first.PurgeExpired()
+	require.NoError(t, err)
+	assert.Equal(t, latest.ID, first.SigningKey().ID)
+}
+
+// countingKeyringStore counts how often keyrings read the store
+type countingKeyringStore struct {
+	*memoryKeyringStore
+	loads atomic.Int64
+}
+
+func (s *countingKeyringStore) LoadSigningKeys(tokenType token.TokenType) ([]token.StoredSigningKey, error) {
+	s.loads.Add(1)
+	return s.memoryKeyringStore.LoadSigningKeys(tokenType)
+}
+
+func TestKeyringThrottlesReloadsForUnknownKids(t *testing.T) {
+	store := &countingKeyringStore{memoryKeyringStore: newMemoryKeyringStore()}
+	keys, err := token.NewKeyring(token.AccessToken, token.AlgorithmEdDSA, time.Hour, store, "keyring_secret", zerolog.Nop())
+	require.NoError(t, err)
+	active, err := keys.RotateGenerated()
+	require.NoError(t, err)
+	loads := store.loads.Load()
+
+	// Forged kids, new or repeated, fail fast after the first reload
+	for i := 0; i < 100; i++ {
+		_, ok := keys.VerificationKey(fmt.Sprintf("forged-%d", i%10))
+		assert.False(t, ok)
+	}
+	assert.Equal(t, loads+1, store.loads.Load())
+
+	// Known keys never touch the store
+	_, ok := keys.VerificationKey(active.ID)
+	assert.True(t, ok)
+	assert.Equal(t, loads+1, store.loads.Load())
+}
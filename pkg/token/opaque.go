@@ -0,0 +1,185 @@
+// pkg/token/opaque.go
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+)
+
+// opaqueTokenSize is the number of random bytes in an opaque token
+const opaqueTokenSize = 32
+
+// OpaqueTokenStore keeps the claims behind opaque reference tokens, keyed by
+// a hash of the token so a leaked store cannot be replayed
+type OpaqueTokenStore interface {
+	ExpiryPurger
+	StoreToken(tokenHash string, claims *Claims) error
+	// LookupToken returns nil claims and no error for unknown tokens
+	LookupToken(tokenHash string) (*Claims, error)
+}
+
+// OpaqueTokenManager issues random reference tokens that carry no data at
+// all. Their claims stay on the server and are looked up on every request.
+type OpaqueTokenManager struct {
+	store       OpaqueTokenStore
+	revocations RevocationStore
+	policy      TokenPolicy
+}
+
+// NewOpaqueTokenManager creates an OpaqueTokenManager
+func NewOpaqueTokenManager(
+	store OpaqueTokenStore,
+	policy TokenPolicy,
+	revocations RevocationStore,
+) *OpaqueTokenManager {
+	if revocations == nil {
+		revocations = NewMemoryRevocationStore()
+	}
+	return &OpaqueTokenManager{
+		store:       store,
+		revocations: revocations,
+		policy:      policy,
+	}
+}
+
+// GenerateToken generates a new token with specified type
+func (tm *OpaqueTokenManager) GenerateToken(
+	userID uint,
+	username string,
+	tokenType TokenType,
+	opts ...TokenOption,
+) (string, apperrors.AppError) {
+	opaqueToken, _, err := tm.IssueToken(userID, username, tokenType, opts...)
+	return opaqueToken, err
+}
+
+// IssueToken generates a new token and stores the claims it refers to
+func (tm *OpaqueTokenManager) IssueToken(
+	userID uint,
+	username string,
+	tokenType TokenType,
+	opts ...TokenOption,
+) (string, *Claims, apperrors.AppError) {
+	if tokenType != AccessToken && tokenType != RefreshToken {
+		return "", nil, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Invalid token type", nil)
+	}
+
+	random := make([]byte, opaqueTokenSize)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, apperrors.NewTokenError(apperrors.ErrCodeTokenSigningError, "Failed to generate token", err)
+	}
+	opaqueToken := base64.RawURLEncoding.EncodeToString(random)
+
+	claims := newClaims(tm.policy, userID, username, tokenType, opts...)
+	if err := tm.store.StoreToken(hashOpaqueToken(opaqueToken), claims); err != nil {
+		return "", nil, apperrors.NewInternalError("Failed to store token", err)
+	}
+	return opaqueToken, claims, nil
+}
+
+// ValidateToken looks up a token and returns its claims
+func (tm *OpaqueTokenManager) ValidateToken(
+	tokenString string,
+	expectedTokenType TokenType,
+) (*Claims, apperrors.AppError) {
+	if expectedTokenType != AccessToken && expectedTokenType != RefreshToken {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Invalid token type", nil)
+	}
+
+	raw, decodeErr := base64.RawURLEncoding.DecodeString(tokenString)
+	if decodeErr != nil || len(raw) != opaqueTokenSize {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenMalformed, "Token is malformed", decodeErr)
+	}
+
+	claims, lookupErr := tm.store.LookupToken(hashOpaqueToken(tokenString))
+	if lookupErr != nil {
+		return nil, apperrors.NewInternalError("Failed to look up token", lookupErr)
+	}
+	if claims == nil {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidToken, "Token is not recognized", nil)
+	}
+
+	if err := tm.policy.validateRegisteredClaims(claims); err != nil {
+		return nil, err
+	}
+	if err := checkClaims(tm.policy, tm.revocations, claims, expectedTokenType); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// InvalidateToken revokes a token of the given type for the rest of its lifetime
+func (tm *OpaqueTokenManager) InvalidateToken(tokenString string, tokenType TokenType) apperrors.AppError {
+	claims, err := tm.ValidateToken(tokenString, tokenType)
+	if err != nil {
+		return err
+	}
+	return revokeClaims(tm.revocations, claims)
+}
+
+// RevokeClaims revokes an already validated token for the rest of its lifetime
+func (tm *OpaqueTokenManager) RevokeClaims(claims *Claims) apperrors.AppError {
+	return revokeClaims(tm.revocations, claims)
+}
+
+// hashOpaqueToken returns the key an opaque token is stored under
+func hashOpaqueToken(opaqueToken string) string {
+	sum := sha256.Sum256([]byte(opaqueToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryOpaqueTokenStore is a process-local OpaqueTokenStore
+type MemoryOpaqueTokenStore struct {
+	tokens map[string]Claims
+	mu     sync.RWMutex
+}
+
+// NewMemoryOpaqueTokenStore creates a new in-memory opaque token store
+func NewMemoryOpaqueTokenStore() *MemoryOpaqueTokenStore {
+	return &MemoryOpaqueTokenStore{
+		tokens: make(map[string]Claims),
+	}
+}
+
+func (s *MemoryOpaqueTokenStore) StoreToken(tokenHash string, claims *Claims) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[tokenHash] = *claims
+	return nil
+}
+
+func (s *MemoryOpaqueTokenStore) LookupToken(tokenHash string) (*Claims, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	claims, exists := s.tokens[tokenHash]
+	if !exists {
+		return nil, nil
+	}
+	return &claims, nil
+}
+
+func (s *MemoryOpaqueTokenStore) PurgeExpired() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	now := time.Now()
+	for tokenHash, claims := range s.tokens {
+		if claims.ExpiresAt == nil || !now.Before(claims.ExpiresAt.Time) {
+			delete(s.tokens, tokenHash)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+var _ TokenManager = (*OpaqueTokenManager)(nil)
+var _ OpaqueTokenStore = (*MemoryOpaqueTokenStore)(nil)
@@ -0,0 +1,60 @@
+package token_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func TestOpaqueTokens(t *testing.T) {
+	store := token.NewMemoryOpaqueTokenStore()
+	tm := token.NewOpaqueTokenManager(store, testPolicy(), nil)
+
+	accessToken, issued, err := tm.IssueToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+	assert.NotContains(t, accessToken, ".")
+
+	claims, err := tm.ValidateToken(accessToken, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, issued.ID, claims.ID)
+	assert.Equal(t, "testuser", claims.Username)
+
+	_, err = tm.ValidateToken(accessToken, token.RefreshToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenInvalidType, err.Code())
+
+	// Well-formed tokens that were never issued are rejected
+	other, err := token.NewOpaqueTokenManager(token.NewMemoryOpaqueTokenStore(), testPolicy(), nil).GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+	_, err = tm.ValidateToken(other, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeInvalidToken, err.Code())
+
+	require.NoError(t, tm.InvalidateToken(accessToken, token.AccessToken))
+	_, err = tm.ValidateToken(accessToken, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenBlacklisted, err.Code())
+}
+
+func TestOpaqueTokensExpire(t *testing.T) {
+	store := token.NewMemoryOpaqueTokenStore()
+	policy := testPolicy()
+	policy.AccessTokenTTL = -time.Minute
+	tm := token.NewOpaqueTokenManager(store, policy, nil)
+
+	accessToken, err := tm.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+
+	_, err = tm.ValidateToken(accessToken, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenExpired, err.Code())
+
+	purged, purgeErr := store.PurgeExpired()
+	require.NoError(t, purgeErr)
+	assert.Equal(t, int64(1), purged)
+}
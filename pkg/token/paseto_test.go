@@ -0,0 +1,94 @@
+package token_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func TestPasetoLocalTokens(t *testing.T) {
+	tm := token.NewPasetoTokenManager(token.NewPasetoLocalKeyFromSecret("secret_key"),
+		token.NewPasetoLocalKeyFromSecret("refresh_secret_key"), testPolicy(), nil)
+
+	accessToken, issued, err := tm.IssueToken(1, "testuser", token.AccessToken, token.WithFamilyID("family-1"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(accessToken, "v4.local."))
+	// The claims are encrypted, so nothing readable leaks into the token
+	assert.NotContains(t, accessToken, "testuser")
+
+	claims, err := tm.ValidateToken(accessToken, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, issued.ID, claims.ID)
+	assert.Equal(t, "testuser", claims.Username)
+	assert.Equal(t, "family-1", claims.FamilyID)
+	assert.Equal(t, issued.ExpiresAt.Unix(), claims.ExpiresAt.Unix())
+	assert.Equal(t, issued.Audience, claims.Audience)
+
+	// A token of one type never opens as the other
+	_, err = tm.ValidateToken(accessToken, token.RefreshToken)
+	assert.Error(t, err)
+
+	require.NoError(t, tm.InvalidateToken(accessToken, token.AccessToken))
+	_, err = tm.ValidateToken(accessToken, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenBlacklisted, err.Code())
+}
+
+func TestPasetoPublicTokens(t *testing.T) {
+	_, privateKey, genErr := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, genErr)
+	tm := token.NewPasetoTokenManager(token.NewPasetoPublicKey(privateKey),
+		token.NewPasetoLocalKeyFromSecret("refresh_secret_key"), testPolicy(), nil)
+
+	accessToken, err := tm.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(accessToken, "v4.public."))
+
+	_, err = tm.ValidateToken(accessToken, token.AccessToken)
+	require.NoError(t, err)
+
+	// Flipping a character of the payload breaks the signature
+	tampered := []byte(accessToken)
+	tampered[len("v4.public.")+10] ^= 1
+	_, err = tm.ValidateToken(string(tampered), token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeInvalidTokenSignature, err.Code())
+
+	// A JWT is not accepted in place of a PASETO token
+	jwtManager := token.NewTokenManager("secret_key", "refresh_secret_key", testPolicy(), nil)
+	jwtToken, err := jwtManager.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+	_, err = tm.ValidateToken(jwtToken, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenMalformed, err.Code())
+}
+
+func TestPasetoTokenPolicy(t *testing.T) {
+	key := token.NewPasetoLocalKeyFromSecret("secret_key")
+
+	expiredPolicy := testPolicy()
+	expiredPolicy.AccessTokenTTL = -time.Minute
+	expired, err := token.NewPasetoTokenManager(key, key, expiredPolicy, nil).GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+
+	tm := token.NewPasetoTokenManager(key, key, testPolicy(), nil)
+	_, err = tm.ValidateToken(expired, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenExpired, err.Code())
+
+	foreignPolicy := testPolicy()
+	foreignPolicy.Issuer = "https://evil.example.com"
+	foreign, err := token.NewPasetoTokenManager(key, key, foreignPolicy, nil).GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+	_, err = tm.ValidateToken(foreign, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenInvalidIssuer, err.Code())
+}
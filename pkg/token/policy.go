@@ -0,0 +1,77 @@
+// pkg/token/policy.go
+package token
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+)
+
+// TokenPolicy controls the registered claims of issued tokens and how strictly
+// they are checked on validation
+type TokenPolicy struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Issuer          string
+	// Audiences are written to the aud claim of issued tokens. A token is
+	// accepted if it names at least one of them. An empty list disables the check.
+	Audiences []string
+	// Leeway tolerates clock skew when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+// DefaultTokenPolicy returns the policy used when none is configured
+func DefaultTokenPolicy() TokenPolicy {
+	return TokenPolicy{
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
+		Issuer:          "user-management-api",
+	}
+}
+
+// TTL returns the lifetime of tokens of the given type
+func (p TokenPolicy) TTL(tokenType TokenType) time.Duration {
+	if tokenType == RefreshToken {
+		return p.RefreshTokenTTL
+	}
+	return p.AccessTokenTTL
+}
+
+// parserOptions returns the jwt parser options enforcing the policy. The
+// audience is checked separately because the parser only accepts one value.
+func (p TokenPolicy) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{jwt.WithIssuedAt()}
+	if p.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(p.Issuer))
+	}
+	if p.Leeway > 0 {
+		opts = append(opts, jwt.WithLeeway(p.Leeway))
+	}
+	return opts
+}
+
+// validateRegisteredClaims checks exp, nbf, iat and iss of claims that were not
+// read by the jwt parser, such as those of PASETO and opaque tokens
+func (p TokenPolicy) validateRegisteredClaims(claims *Claims) apperrors.AppError {
+	opts := append(p.parserOptions(), jwt.WithExpirationRequired())
+	if err := jwt.NewValidator(opts...).Validate(claims); err != nil {
+		return parseError(err)
+	}
+	return nil
+}
+
+// allowsAudience reports whether the token is meant for one of the allowed audiences
+func (p TokenPolicy) allowsAudience(audience jwt.ClaimStrings) bool {
+	if len(p.Audiences) == 0 {
+		return true
+	}
+	for _, allowed := range p.Audiences {
+		for _, aud := range audience {
+			if aud == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
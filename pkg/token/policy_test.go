@@ -0,0 +1,108 @@
+package token_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func testPolicy() token.TokenPolicy {
+	return token.TokenPolicy{
+		AccessTokenTTL:  5 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+		Issuer:          "https://auth.example.com",
+		Audiences:       []string{"orders-api", "billing-api"},
+		Leeway:          30 * time.Second,
+	}
+}
+
+func TestTokenPolicyIsApplied(t *testing.T) {
+	tm := token.NewTokenManager("secret_key", "refresh_secret_key", testPolicy(), nil)
+
+	_, accessClaims, err := tm.IssueToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.example.com", accessClaims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{"orders-api", "billing-api"}, accessClaims.Audience)
+	assert.Equal(t, 5*time.Minute, accessClaims.ExpiresAt.Sub(accessClaims.IssuedAt.Time))
+
+	_, refreshClaims, err := tm.IssueToken(1, "testuser", token.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, refreshClaims.ExpiresAt.Sub(refreshClaims.IssuedAt.Time))
+}
+
+func TestTokenPolicyRejectsForeignIssuer(t *testing.T) {
+	foreignPolicy := testPolicy()
+	foreignPolicy.Issuer = "https://other.example.com"
+	foreign := token.NewTokenManager("secret_key", "refresh_secret_key", foreignPolicy, nil)
+	tm := token.NewTokenManager("secret_key", "refresh_secret_key", testPolicy(), nil)
+
+	issued, err := foreign.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+
+	_, err = tm.ValidateToken(issued, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenInvalidIssuer, err.Code())
+}
+
+func TestTokenPolicyRejectsForeignAudience(t *testing.T) {
+	foreignPolicy := testPolicy()
+	foreignPolicy.Audiences = []string{"reporting-api"}
+	foreign := token.NewTokenManager("secret_key", "refresh_secret_key", foreignPolicy, nil)
+	tm := token.NewTokenManager("secret_key", "refresh_secret_key", testPolicy(), nil)
+
+	issued, err := foreign.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+
+	_, err = tm.ValidateToken(issued, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenInvalidAudience, err.Code())
+
+	// A token naming any one allowed audience is accepted
+	foreignPolicy.Audiences = []string{"reporting-api", "billing-api"}
+	foreign = token.NewTokenManager("secret_key", "refresh_secret_key", foreignPolicy, nil)
+	issued, err = foreign.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+	_, err = tm.ValidateToken(issued, token.AccessToken)
+	assert.NoError(t, err)
+}
+
+func TestTokenPolicyLeeway(t *testing.T) {
+	policy := testPolicy()
+	sign := func(notBefore, expiresAt time.Time) string {
+		claims := &token.Claims{
+			UserID:    1,
+			TokenType: token.AccessToken,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    policy.Issuer,
+				Audience:  jwt.ClaimStrings{"orders-api"},
+				NotBefore: jwt.NewNumericDate(notBefore),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret_key"))
+		require.NoError(t, err)
+		return signed
+	}
+	tm := token.NewTokenManager("secret_key", "refresh_secret_key", policy, nil)
+	now := time.Now()
+
+	// Skew within the leeway is tolerated
+	_, err := tm.ValidateToken(sign(now.Add(10*time.Second), now.Add(time.Minute)), token.AccessToken)
+	assert.NoError(t, err)
+	_, err = tm.ValidateToken(sign(now.Add(-time.Minute), now.Add(-10*time.Second)), token.AccessToken)
+	assert.NoError(t, err)
+
+	// Beyond it the token is rejected
+	_, err = tm.ValidateToken(sign(now.Add(time.Minute), now.Add(2*time.Minute)), token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenNotYetValid, err.Code())
+	_, err = tm.ValidateToken(sign(now.Add(-2*time.Minute), now.Add(-time.Minute)), token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenExpired, err.Code())
+}
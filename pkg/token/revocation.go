@@ -0,0 +1,122 @@
+// pkg/token/revocation.go
+package token
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ExpiryPurger is implemented by stores whose entries become useless once
+// the tokens they describe have expired
+type ExpiryPurger interface {
+	PurgeExpired() (int64, error)
+}
+
+// RevocationStore keeps track of revoked tokens by their JWT ID (jti).
+// Entries only need to be kept until the token would have expired anyway.
+type RevocationStore interface {
+	ExpiryPurger
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+}
+
+// RevokeFamily revokes every token issued to a refresh token family, such as
+// all access tokens of a session, until the last of them has expired
+func RevokeFamily(revocations RevocationStore, familyID string, expiresAt time.Time) error {
+	return revocations.Revoke(familyRevocationID(familyID), expiresAt)
+}
+
+// familyRevocationID is the store entry that revokes a whole family. Family
+// IDs are UUIDs like jtis, so the prefix keeps the two apart.
+func familyRevocationID(familyID string) string {
+	return "family:" + familyID
+}
+
+// MemoryRevocationStore is a process-local RevocationStore
+type MemoryRevocationStore struct {
+	tokens map[string]time.Time
+	mu     sync.RWMutex
+}
+
+// NewMemoryRevocationStore creates a new in-memory revocation store
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiresAt, exists := s.tokens[jti]
+	return exists && time.Now().Before(expiresAt), nil
+}
+
+func (s *MemoryRevocationStore) PurgeExpired() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	now := time.Now()
+	for jti, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// RevocationSweeper periodically purges expired entries from a set of stores
+type RevocationSweeper struct {
+	stores []ExpiryPurger
+	logger zerolog.Logger
+	task   *periodicTask
+}
+
+// NewRevocationSweeper creates a new sweeper for the given stores
+func NewRevocationSweeper(interval time.Duration, logger zerolog.Logger, stores ...ExpiryPurger) *RevocationSweeper {
+	s := &RevocationSweeper{
+		stores: stores,
+		logger: logger.With().Str("component", "RevocationSweeper").Logger(),
+	}
+	s.task = &periodicTask{interval: interval, run: s.sweep}
+	return s
+}
+
+// Start launches the background sweep loop. Calling Start on a running sweeper is a no-op.
+func (s *RevocationSweeper) Start() {
+	s.task.start()
+}
+
+// Stop signals the sweep loop to exit and waits for it to finish
+func (s *RevocationSweeper) Stop() {
+	if s.task.stop() {
+		s.logger.Info().Msg("Revocation sweeper stopped")
+	}
+}
+
+func (s *RevocationSweeper) sweep() {
+	for _, store := range s.stores {
+		purged, err := store.PurgeExpired()
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Failed to purge expired entries")
+			continue
+		}
+		if purged > 0 {
+			s.logger.Info().Int64("purged", purged).Msg("Purged expired entries")
+		}
+	}
+}
+
+var _ RevocationStore = (*MemoryRevocationStore)(nil)
@@ -0,0 +1,233 @@
+// pkg/token/signing_key.go
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningKey pairs a signing method with the key material used to sign and
+// verify tokens. The ID is published as the "kid" header of every token.
+type SigningKey struct {
+	ID              string
+	Method          jwt.SigningMethod
+	signKey         interface{}
+	verificationKey interface{}
+}
+
+// NewHMACSigningKey creates an HS256 key from a shared secret
+func NewHMACSigningKey(secret string) *SigningKey {
+	sum := sha256.Sum256([]byte(secret))
+	return &SigningKey{
+		ID:              "hs256-" + base64.RawURLEncoding.EncodeToString(sum[:9]),
+		Method:          jwt.SigningMethodHS256,
+		signKey:         []byte(secret),
+		verificationKey: []byte(secret),
+	}
+}
+
+// NewAsymmetricSigningKey creates a key for the given algorithm from a private key.
+// The key ID is the RFC 7638 thumbprint of the public key.
+func NewAsymmetricSigningKey(algorithm string, privateKey crypto.Signer) (*SigningKey, error) {
+	key := &SigningKey{
+		signKey:         privateKey,
+		verificationKey: privateKey.Public(),
+	}
+
+	switch algorithm {
+	case AlgorithmRS256:
+		if _, ok := privateKey.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("%s requires an RSA private key", algorithm)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case AlgorithmES256:
+		ecKey, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s requires a P-256 ECDSA private key", algorithm)
+		}
+		key.Method = jwt.SigningMethodES256
+	case AlgorithmEdDSA:
+		if _, ok := privateKey.(ed25519.PrivateKey); !ok {
+			return nil, fmt.Errorf("%s requires an Ed25519 private key", algorithm)
+		}
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	jwk, err := key.publicJWK()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = jwk.Thumbprint()
+
+	return key, nil
+}
+
+// LoadSigningKey reads a PEM encoded private key from disk
+func LoadSigningKey(algorithm, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing key: %w", err)
+	}
+
+	privateKey, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAsymmetricSigningKey(algorithm, privateKey)
+}
+
+// ParsePrivateKeyPEM parses PKCS#8, PKCS#1 and SEC 1 private keys
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in signing key")
+	}
+
+	var (
+		parsed interface{}
+		err    error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing signing key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signing key of type %T cannot sign", parsed)
+	}
+	return signer, nil
+}
+
+// GenerateSigningKey creates a fresh random key for the given algorithm
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var (
+		privateKey crypto.Signer
+		err        error
+	)
+
+	switch algorithm {
+	case AlgorithmHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("error generating secret: %w", err)
+		}
+		return NewHMACSigningKey(string(secret)), nil
+	case AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error generating %s key: %w", algorithm, err)
+	}
+
+	return NewAsymmetricSigningKey(algorithm, privateKey)
+}
+
+// parseSigningKey restores a key from the material produced by marshalPrivate
+func parseSigningKey(algorithm string, material []byte) (*SigningKey, error) {
+	if algorithm == AlgorithmHS256 {
+		return NewHMACSigningKey(string(material)), nil
+	}
+
+	privateKey, err := ParsePrivateKeyPEM(material)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsymmetricSigningKey(algorithm, privateKey)
+}
+
+// marshalPrivate returns the secret or PKCS#8 PEM needed to restore the key
+func (k *SigningKey) marshalPrivate() ([]byte, error) {
+	if secret, ok := k.signKey.([]byte); ok {
+		return secret, nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.signKey)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding signing key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// IsSymmetric reports whether the key is a shared secret
+func (k *SigningKey) IsSymmetric() bool {
+	_, ok := k.verificationKey.([]byte)
+	return ok
+}
+
+// publicJWK returns the verification key in JWK form
+func (k *SigningKey) publicJWK() (*JSONWebKey, error) {
+	jwk := &JSONWebKey{
+		Use: "sig",
+		Alg: k.Method.Alg(),
+		Kid: k.ID,
+	}
+
+	switch pub := k.verificationKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(bigEndianBytes(pub.E))
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return nil, fmt.Errorf("key of type %T has no public JWK form", pub)
+	}
+
+	return jwk, nil
+}
+
+func bigEndianBytes(value int) []byte {
+	var out []byte
+	for value > 0 {
+		out = append([]byte{byte(value & 0xff)}, out...)
+		value >>= 8
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+package token_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func writePrivateKeyPEM(t *testing.T, key crypto.Signer) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// publicKeyFromJWK rebuilds a verification key the way a downstream service would
+func publicKeyFromJWK(t *testing.T, jwk token.JSONWebKey) interface{} {
+	decode := func(value string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		require.NoError(t, err)
+		return b
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decode(jwk.N)),
+			E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64()),
+		}
+	case "EC":
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(decode(jwk.X)),
+			Y:     new(big.Int).SetBytes(decode(jwk.Y)),
+		}
+	case "OKP":
+		return ed25519.PublicKey(decode(jwk.X))
+	}
+	t.Fatalf("unexpected key type %s", jwk.Kty)
+	return nil
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		algorithm string
+		key       crypto.Signer
+	}{
+		{token.AlgorithmRS256, rsaKey},
+		{token.AlgorithmES256, ecKey},
+		{token.AlgorithmEdDSA, edKey},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			signingKey, err := token.LoadSigningKey(tc.algorithm, writePrivateKeyPEM(t, tc.key))
+			require.NoError(t, err)
+
+			tm := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), nil, token.WithAccessSigningKey(signingKey))
+
+			accessToken, appErr := tm.GenerateToken(1, "testuser", token.AccessToken)
+			require.NoError(t, appErr)
+
+			_, appErr = tm.ValidateToken(accessToken, token.AccessToken)
+			require.NoError(t, appErr)
+
+			// The key set publishes exactly the signing key
+			keySet := tm.JWKS()
+			require.Len(t, keySet.Keys, 1)
+			jwk := keySet.Keys[0]
+			assert.Equal(t, signingKey.ID, jwk.Kid)
+			assert.Equal(t, tc.algorithm, jwk.Alg)
+
+			// A downstream service can verify with nothing but the JWKS
+			parsed, err := jwt.ParseWithClaims(accessToken, &token.Claims{}, func(tok *jwt.Token) (interface{}, error) {
+				assert.Equal(t, jwk.Kid, tok.Header["kid"])
+				return publicKeyFromJWK(t, jwk), nil
+			}, jwt.WithValidMethods([]string{jwk.Alg}))
+			require.NoError(t, err)
+			assert.True(t, parsed.Valid)
+
+			// Refresh tokens keep working with the shared secret
+			refreshToken, appErr := tm.GenerateToken(1, "testuser", token.RefreshToken)
+			require.NoError(t, appErr)
+			_, appErr = tm.ValidateToken(refreshToken, token.RefreshToken)
+			require.NoError(t, appErr)
+		})
+	}
+}
+
+func TestAsymmetricSigningRejectsAlgorithmConfusion(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signingKey, err := token.NewAsymmetricSigningKey(token.AlgorithmES256, ecKey)
+	require.NoError(t, err)
+
+	tm := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), nil, token.WithAccessSigningKey(signingKey))
+
+	// An HS256 token using the published key material as the secret must be refused
+	publicDER, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &token.Claims{UserID: 1, TokenType: token.AccessToken})
+	forged.Header["kid"] = signingKey.ID
+	forgedToken, err := forged.SignedString(publicDER)
+	require.NoError(t, err)
+
+	_, appErr := tm.ValidateToken(forgedToken, token.AccessToken)
+	assert.Error(t, appErr)
+}
+
+func TestSymmetricKeysAreNotPublished(t *testing.T) {
+	tm := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), nil)
+	assert.Empty(t, tm.JWKS().Keys)
+}
+
+func TestNewAsymmetricSigningKeyRejectsMismatchedKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	_, err = token.NewAsymmetricSigningKey(token.AlgorithmRS256, edKey)
+	assert.Error(t, err)
+}
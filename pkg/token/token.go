@@ -2,7 +2,7 @@
 package token
 
 import (
-	"sync"
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -20,87 +20,185 @@ const (
 
 // Claims represents the standard claims for our tokens
 type Claims struct {
-	UserID      uint                   `json:"user_id"`
-	Username    string                 `json:"username"`
-	TokenType   TokenType              `json:"token_type"`
-	Permissions []string               `json:"permissions"`
-	DeviceInfo  map[string]interface{} `json:"device_info"`
+	UserID            uint      `json:"user_id"`
+	Username          string    `json:"username"`
+	TokenType         TokenType `json:"token_type"`
+	Permissions       []string  `json:"permissions"`
+	FamilyID          string    `json:"fid,omitempty"`
+	DeviceFingerprint string    `json:"dfp,omitempty"`
+	Actor             *Actor    `json:"act,omitempty"`
+	ClientID          string    `json:"client_id,omitempty"`
+	// TokenVersion is the user's token version when the token was issued
+	TokenVersion uint `json:"ver,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Actor identifies who is acting on behalf of the token's subject, as in the
+// act claim of RFC 8693. It is only set on impersonation tokens.
+type Actor struct {
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	TokenVersion uint   `json:"ver,omitempty"`
+}
+
+// TokenOption customizes the claims of a token before it is signed
+type TokenOption func(*Claims)
+
+// WithFamilyID ties a token to a refresh token family
+func WithFamilyID(familyID string) TokenOption {
+	return func(claims *Claims) {
+		claims.FamilyID = familyID
+	}
+}
+
+// WithTokenVersion stamps the user's token version into a token, so bumping
+// the version invalidates it
+func WithTokenVersion(version uint) TokenOption {
+	return func(claims *Claims) {
+		claims.TokenVersion = version
+	}
+}
+
+// WithActor marks a token as issued to actor acting as the token's subject
+func WithActor(actor Actor) TokenOption {
+	return func(claims *Claims) {
+		claims.Actor = &actor
+	}
+}
+
+// WithClientID marks a token as issued to an OAuth client on the user's behalf
+func WithClientID(clientID string) TokenOption {
+	return func(claims *Claims) {
+		claims.ClientID = clientID
+	}
+}
+
+// WithPermissions replaces the default permissions, e.g. with granted scopes
+func WithPermissions(permissions []string) TokenOption {
+	return func(claims *Claims) {
+		claims.Permissions = permissions
+	}
+}
+
+// WithLifetime shortens the lifetime of a token below the policy's TTL
+func WithLifetime(lifetime time.Duration) TokenOption {
+	return func(claims *Claims) {
+		if expiresAt := claims.IssuedAt.Add(lifetime); expiresAt.Before(claims.ExpiresAt.Time) {
+			claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+		}
+	}
+}
+
 type TokenManager interface {
 	ValidateToken(tokenString string, tokenType TokenType) (*Claims, apperrors.AppError)
-	InvalidateToken(tokenString string) apperrors.AppError
+	InvalidateToken(tokenString string, tokenType TokenType) apperrors.AppError
+	RevokeClaims(claims *Claims) apperrors.AppError
 	GenerateToken(
 		userID uint,
 		username string,
 		tokenType TokenType,
+		opts ...TokenOption,
 	) (string, apperrors.AppError)
+	IssueToken(
+		userID uint,
+		username string,
+		tokenType TokenType,
+		opts ...TokenOption,
+	) (string, *Claims, apperrors.AppError)
 }
 
 // TokenManager handles all token-related operations
 type TokenManagerImpl struct {
-	secretKey        []byte
-	refreshSecretKey []byte
-	blacklist        *TokenBlacklist
+	accessKeys  *Keyring
+	refreshKeys *Keyring
+	revocations RevocationStore
+	policy      TokenPolicy
 }
 
-// NewTokenManager creates a new TokenManager
-func NewTokenManager(secretKey, refreshSecretKey string) *TokenManagerImpl {
-	return &TokenManagerImpl{
-		secretKey:        []byte(secretKey),
-		refreshSecretKey: []byte(refreshSecretKey),
-		blacklist:        NewTokenBlacklist(),
+// ManagerOption customizes a TokenManagerImpl
+type ManagerOption func(*TokenManagerImpl)
+
+// WithAccessSigningKey replaces the HS256 access token key, e.g. with an asymmetric key
+func WithAccessSigningKey(key *SigningKey) ManagerOption {
+	return func(tm *TokenManagerImpl) {
+		tm.accessKeys = NewStaticKeyring(AccessToken, key)
 	}
 }
 
+// WithKeyrings replaces the static keys with rotating keyrings
+func WithKeyrings(accessKeys, refreshKeys *Keyring) ManagerOption {
+	return func(tm *TokenManagerImpl) {
+		tm.accessKeys = accessKeys
+		tm.refreshKeys = refreshKeys
+	}
+}
+
+// NewTokenManager creates a new TokenManager. Refresh tokens are only ever
+// verified by this service, so by default they use the HS256 refresh secret.
+func NewTokenManager(
+	secretKey, refreshSecretKey string,
+	policy TokenPolicy,
+	revocations RevocationStore,
+	opts ...ManagerOption,
+) *TokenManagerImpl {
+	if revocations == nil {
+		revocations = NewMemoryRevocationStore()
+	}
+	tm := &TokenManagerImpl{
+		accessKeys:  NewStaticKeyring(AccessToken, NewHMACSigningKey(secretKey)),
+		refreshKeys: NewStaticKeyring(RefreshToken, NewHMACSigningKey(refreshSecretKey)),
+		revocations: revocations,
+		policy:      policy,
+	}
+
+	for _, opt := range opts {
+		opt(tm)
+	}
+
+	return tm
+}
+
 // GenerateToken generates a new token with specified type
 func (tm *TokenManagerImpl) GenerateToken(
 	userID uint,
 	username string,
 	tokenType TokenType,
+	opts ...TokenOption,
 ) (string, apperrors.AppError) {
-	var (
-		expirationDuration time.Duration
-		secretKey          []byte
-	)
+	signedToken, _, err := tm.IssueToken(userID, username, tokenType, opts...)
+	return signedToken, err
+}
+
+// IssueToken generates a new token and also returns the claims it was signed with
+func (tm *TokenManagerImpl) IssueToken(
+	userID uint,
+	username string,
+	tokenType TokenType,
+	opts ...TokenOption,
+) (string, *Claims, apperrors.AppError) {
+	var signingKey *SigningKey
 
 	switch tokenType {
 	case AccessToken:
-		expirationDuration = 15 * time.Minute
-		secretKey = tm.secretKey
+		signingKey = tm.accessKeys.SigningKey()
 	case RefreshToken:
-		expirationDuration = 7 * 24 * time.Hour
-		secretKey = tm.refreshSecretKey
+		signingKey = tm.refreshKeys.SigningKey()
 	default:
-		return "", apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Invalid token type", nil)
+		return "", nil, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Invalid token type", nil)
 	}
-
-	claims := Claims{
-		UserID:    userID,
-		Username:  username,
-		TokenType: tokenType,
-		Permissions: []string{
-			"read",
-			"write",
-		},
-		DeviceInfo: map[string]interface{}{
-			"ip": "127.0.0.1", // TODO: Implement actual device detection
-		},
-		RegisteredClaims: jwt.RegisteredClaims{
-			ID:        uuid.New().String(),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expirationDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "user-management-api",
-		},
+	if signingKey == nil {
+		return "", nil, apperrors.NewTokenError(apperrors.ErrCodeTokenSigningError, "No active signing key", nil)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(secretKey)
+	claims := newClaims(tm.policy, userID, username, tokenType, opts...)
+
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+	signedToken, err := token.SignedString(signingKey.signKey)
 	if err != nil {
-		return "", apperrors.NewTokenError(apperrors.ErrCodeTokenSigningError, "Failed to sign token", nil)
+		return "", nil, apperrors.NewTokenError(apperrors.ErrCodeTokenSigningError, "Failed to sign token", nil)
 	}
-	return signedToken, nil
+	return signedToken, claims, nil
 }
 
 // ValidateToken validates a token and returns its claims
@@ -108,18 +206,13 @@ func (tm *TokenManagerImpl) ValidateToken(
 	tokenString string,
 	expectedTokenType TokenType,
 ) (*Claims, apperrors.AppError) {
-	// Check if token is blacklisted first
-	if tm.blacklist.IsBlacklisted(tokenString) {
-		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenBlacklisted, "Token is blacklisted", nil)
-	}
-
-	// Determine which secret key to use
-	var secretKey []byte
+	// Determine which keyring to use
+	var keyring *Keyring
 	switch expectedTokenType {
 	case AccessToken:
-		secretKey = tm.secretKey
+		keyring = tm.accessKeys
 	case RefreshToken:
-		secretKey = tm.refreshSecretKey
+		keyring = tm.refreshKeys
 	default:
 		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Invalid token type", nil)
 	}
@@ -129,12 +222,13 @@ func (tm *TokenManagerImpl) ValidateToken(
 		tokenString,
 		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
-			return secretKey, nil
+			return verificationKeyFor(keyring, token)
 		},
+		tm.policy.parserOptions()...,
 	)
 
 	if err != nil {
-		return &Claims{}, apperrors.NewTokenError(apperrors.ErrCodeParseError, "Token parsing error", err)
+		return &Claims{}, parseError(err)
 	}
 
 	// Type assert claims
@@ -143,64 +237,143 @@ func (tm *TokenManagerImpl) ValidateToken(
 		return &Claims{}, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidClaim, "Invalid token claims", nil)
 	}
 
-	// Validate token type
-	if claims.TokenType != expectedTokenType {
-		return nil, apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Token type does not match expected type", nil)
+	if err := checkClaims(tm.policy, tm.revocations, claims, expectedTokenType); err != nil {
+		return nil, err
 	}
 
 	return claims, nil
 }
 
-func (tm *TokenManagerImpl) InvalidateToken(tokenString string) apperrors.AppError {
-	if tm.blacklist.IsBlacklisted(tokenString) {
-		return apperrors.NewTokenError(apperrors.ErrCodeTokenBlacklisted, "Token is blacklisted", nil)
-	}
-
-	_, err := tm.ValidateToken(tokenString, "access")
+// InvalidateToken revokes a token of the given type for the rest of its lifetime
+func (tm *TokenManagerImpl) InvalidateToken(tokenString string, tokenType TokenType) apperrors.AppError {
+	claims, err := tm.ValidateToken(tokenString, tokenType)
 	if err != nil {
 		return err
 	}
 
-	tm.blacklist.AddToken(tokenString, time.Now().Add(24*time.Hour))
-	return nil
+	return revokeClaims(tm.revocations, claims)
 }
 
-// Blacklist management
-type TokenBlacklist struct {
-	tokens map[string]time.Time
-	mu     sync.RWMutex
+// RevokeClaims revokes an already validated token for the rest of its lifetime
+func (tm *TokenManagerImpl) RevokeClaims(claims *Claims) apperrors.AppError {
+	return revokeClaims(tm.revocations, claims)
 }
 
-func NewTokenBlacklist() *TokenBlacklist {
-	return &TokenBlacklist{
-		tokens: make(map[string]time.Time),
+// newClaims builds the claims of a new token according to the policy
+func newClaims(policy TokenPolicy, userID uint, username string, tokenType TokenType, opts ...TokenOption) *Claims {
+	now := time.Now()
+	claims := &Claims{
+		UserID:    userID,
+		Username:  username,
+		TokenType: tokenType,
+		Permissions: []string{
+			"read",
+			"write",
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(policy.TTL(tokenType))),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    policy.Issuer,
+			Audience:  jwt.ClaimStrings(policy.Audiences),
+		},
 	}
-}
 
-func (tb *TokenBlacklist) AddToken(token string, expiration time.Time) {
-	tb.mu.Lock()
-	defer tb.mu.Unlock()
-	tb.tokens[token] = expiration
+	for _, opt := range opts {
+		opt(claims)
+	}
+	return claims
 }
 
-func (tb *TokenBlacklist) IsBlacklisted(token string) bool {
-	tb.mu.RLock()
-	defer tb.mu.RUnlock()
+// checkClaims applies the checks every token format shares once its registered
+// claims are known to be valid: audience, token type and revocation
+func checkClaims(policy TokenPolicy, revocations RevocationStore, claims *Claims, expectedTokenType TokenType) apperrors.AppError {
+	// Validate audience
+	if !policy.allowsAudience(claims.Audience) {
+		return apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidAudience, "Token audience is not accepted", nil)
+	}
 
-	expiration, exists := tb.tokens[token]
-	return exists && time.Now().Before(expiration)
+	// Validate token type
+	if claims.TokenType != expectedTokenType {
+		return apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidType, "Token type does not match expected type", nil)
+	}
+
+	// Check if token has been revoked
+	revoked, revErr := revocations.IsRevoked(claims.ID)
+	if revErr != nil {
+		return apperrors.NewInternalError("Failed to check token revocation", revErr)
+	}
+	if revoked {
+		return apperrors.NewTokenError(apperrors.ErrCodeTokenBlacklisted, "Token is blacklisted", nil)
+	}
+
+	// Tokens of a revoked session are rejected whichever jti they carry
+	if claims.FamilyID != "" {
+		revoked, revErr = revocations.IsRevoked(familyRevocationID(claims.FamilyID))
+		if revErr != nil {
+			return apperrors.NewInternalError("Failed to check token revocation", revErr)
+		}
+		if revoked {
+			return apperrors.NewTokenError(apperrors.ErrCodeTokenBlacklisted, "Token session has been revoked", nil)
+		}
+	}
+	return nil
 }
 
-func (tb *TokenBlacklist) Cleanup() {
-	tb.mu.Lock()
-	defer tb.mu.Unlock()
+// revokeClaims revokes a validated token for the rest of its lifetime
+func revokeClaims(revocations RevocationStore, claims *Claims) apperrors.AppError {
+	if revErr := revocations.Revoke(claims.ID, claims.ExpiresAt.Time); revErr != nil {
+		return apperrors.NewInternalError("Failed to revoke token", revErr)
+	}
+	return nil
+}
 
-	now := time.Now()
-	for token, expiresAt := range tb.tokens {
-		if now.After(expiresAt) {
-			delete(tb.tokens, token)
+// parseError maps jwt validation failures to their error codes
+func parseError(err error) apperrors.AppError {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return apperrors.NewTokenError(apperrors.ErrCodeTokenExpired, "Token has expired", err)
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return apperrors.NewTokenError(apperrors.ErrCodeTokenNotYetValid, "Token is not valid yet", err)
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return apperrors.NewTokenError(apperrors.ErrCodeTokenInvalidIssuer, "Token issuer is not accepted", err)
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return apperrors.NewTokenError(apperrors.ErrCodeTokenMalformed, "Token is malformed", err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return apperrors.NewTokenError(apperrors.ErrCodeInvalidTokenSignature, "Token signature is invalid", err)
+	default:
+		return apperrors.NewTokenError(apperrors.ErrCodeParseError, "Token parsing error", err)
+	}
+}
+
+// verificationKeyFor selects the key named by the token's kid header. Tokens
+// without a kid predate key rotation and are checked against the signing key.
+// The algorithm is pinned to the key's to avoid algorithm confusion.
+func verificationKeyFor(keyring *Keyring, token *jwt.Token) (interface{}, error) {
+	key := keyring.SigningKey()
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = keyring.VerificationKey(kid); !ok {
+			return nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidTokenSignature, "Unknown signing key", nil)
 		}
 	}
+	if key == nil {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidTokenSignature, "No active signing key", nil)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, apperrors.NewTokenError(apperrors.ErrCodeInvalidTokenSignature, "Unexpected signing method", nil)
+	}
+	return key.verificationKey, nil
+}
+
+// JWKS returns the public keys that verify access tokens, including keys in
+// their rotation grace period. Shared secrets are never published.
+func (tm *TokenManagerImpl) JWKS() JSONWebKeySet {
+	keySet := JSONWebKeySet{Keys: []JSONWebKey{}}
+	keySet.Keys = append(keySet.Keys, tm.accessKeys.publicKeys()...)
+	return keySet
 }
 
 var _ TokenManager = (*TokenManagerImpl)(nil)
+var _ KeySetProvider = (*TokenManagerImpl)(nil)
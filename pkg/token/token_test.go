@@ -0,0 +1,96 @@
+package token_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/errors/apperrors"
+	"github.com/yourusername/user-management-api/pkg/token"
+)
+
+func TestInvalidateToken(t *testing.T) {
+	store := token.NewMemoryRevocationStore()
+	tm := token.NewTokenManager("secret_key", "refresh_secret_key", token.DefaultTokenPolicy(), store)
+
+	accessToken, err := tm.GenerateToken(1, "testuser", token.AccessToken)
+	require.NoError(t, err)
+
+	claims, err := tm.ValidateToken(accessToken, token.AccessToken)
+	require.NoError(t, err)
+
+	require.NoError(t, tm.InvalidateToken(accessToken, token.AccessToken))
+
+	_, err = tm.ValidateToken(accessToken, token.AccessToken)
+	require.Error(t, err)
+	assert.Equal(t, apperrors.ErrCodeTokenBlacklisted, err.Code())
+
+	// Revocation is keyed by jti, so the store must know about it
+	revoked, revErr := store.IsRevoked(claims.ID)
+	require.NoError(t, revErr)
+	assert.True(t, revoked)
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	store := token.NewMemoryRevocationStore()
+
+	require.NoError(t, store.Revoke("active", time.Now().Add(time.Hour)))
+	require.NoError(t, store.Revoke("expired", time.Now().Add(-time.Minute)))
+
+	revoked, err := store.IsRevoked("active")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked("expired")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	purged, err := store.PurgeExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
+
+// countingPurger records what the sweeper purges from the store it wraps
+type countingPurger struct {
+	store  token.ExpiryPurger
+	calls  atomic.Int64
+	purged atomic.Int64
+}
+
+func (p *countingPurger) PurgeExpired() (int64, error) {
+	purged, err := p.store.PurgeExpired()
+	p.calls.Add(1)
+	p.purged.Add(purged)
+	return purged, err
+}
+
+func TestRevocationSweeper(t *testing.T) {
+	store := token.NewMemoryRevocationStore()
+	require.NoError(t, store.Revoke("expired", time.Now().Add(-time.Minute)))
+	require.NoError(t, store.Revoke("live", time.Now().Add(time.Hour)))
+	purger := &countingPurger{store: store}
+
+	sweeper := token.NewRevocationSweeper(10*time.Millisecond, zerolog.Nop(), purger)
+	sweeper.Start()
+
+	// Only the sweeper purges, and it keeps running after the first sweep
+	assert.Eventually(t, func() bool {
+		return purger.calls.Load() >= 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(1), purger.purged.Load())
+
+	// Stop must return once the loop has exited and be safe to call twice
+	sweeper.Stop()
+	sweeper.Stop()
+	calls := purger.calls.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, calls, purger.calls.Load())
+
+	revoked, err := store.IsRevoked("live")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
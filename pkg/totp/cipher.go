@@ -0,0 +1,59 @@
+// pkg/totp/cipher.go
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// SecretCipher encrypts TOTP secrets at rest. Unlike password hashes they
+// must be recoverable, as the server computes the same codes as the user.
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipher derives an AES-256-GCM key from the encryption secret
+func NewSecretCipher(encryptionSecret string) (*SecretCipher, error) {
+	sum := sha256.Sum256([]byte("totp-secret-key:" + encryptionSecret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("error creating TOTP cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating TOTP cipher: %w", err)
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Seal encrypts a secret, binding it to the owner so it cannot be moved to
+// another account in the database
+func (c *SecretCipher) Seal(secret, owner string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(secret), []byte(owner))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret sealed for the owner
+func (c *SecretCipher) Open(sealed, owner string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("invalid sealed TOTP secret: %w", err)
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(raw) < nonceSize {
+		return "", fmt.Errorf("sealed TOTP secret is truncated")
+	}
+	secret, err := c.aead.Open(nil, raw[:nonceSize], raw[nonceSize:], []byte(owner))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting TOTP secret: %w", err)
+	}
+	return string(secret), nil
+}
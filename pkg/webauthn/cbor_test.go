@@ -0,0 +1,161 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+)
+
+// TestCBORSpecExamples decodes the examples of RFC 8949 appendix A that fall
+// within the definite-length subset the decoder supports
+func TestCBORSpecExamples(t *testing.T) {
+	examples := []struct {
+		encoded string
+		want    interface{}
+	}{
+		{"00", int64(0)},
+		{"01", int64(1)},
+		{"0a", int64(10)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1819", int64(25)},
+		{"1864", int64(100)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"29", int64(-10)},
+		{"3863", int64(-100)},
+		{"3903e7", int64(-1000)},
+		{"f90000", float64(0)},
+		{"f93c00", float64(1)},
+		{"fb3ff199999999999a", 1.1},
+		{"f93e00", 1.5},
+		{"f97bff", float64(65504)},
+		{"fa47c35000", float64(100000)},
+		{"fa7f7fffff", 3.4028234663852886e+38},
+		{"fb7e37e43c8800759c", 1.0e+300},
+		{"f90001", 5.960464477539063e-08},
+		{"f90400", 6.103515625e-05},
+		{"f9c400", float64(-4)},
+		{"fbc010666666666666", -4.1},
+		{"f97c00", math.Inf(1)},
+		{"f9fc00", math.Inf(-1)},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f7", nil},
+		{"c11a514b67b0", int64(1363896240)},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+		{"d74401020304", []byte{1, 2, 3, 4}},
+		{"d818456449455446", []byte("dIETF")},
+		{"40", []byte(nil)},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6161", "a"},
+		{"6449455446", "IETF"},
+		{"62225c", "\"\\"},
+		{"62c3bc", "ü"},
+		{"63e6b0b4", "水"},
+		{"64f0908591", "\U00010151"},
+		{"80", []interface{}{}},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"98190102030405060708090a0b0c0d0e0f101112131415161718181819", func() []interface{} {
+			items := make([]interface{}, 0, 25)
+			for i := int64(1); i <= 25; i++ {
+				items = append(items, i)
+			}
+			return items
+		}()},
+		{"a0", map[interface{}]interface{}{}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"826161a161626163", []interface{}{"a", map[interface{}]interface{}{"b": "c"}}},
+		{"a56161614161626142616361436164614461656145", map[interface{}]interface{}{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}},
+	}
+
+	for _, example := range examples {
+		t.Run(example.encoded, func(t *testing.T) {
+			encoded, err := hex.DecodeString(example.encoded)
+			require.NoError(t, err)
+			value, rest, err := webauthn.DecodeCBOR(encoded)
+			require.NoError(t, err)
+			assert.Empty(t, rest)
+			assert.Equal(t, example.want, value)
+		})
+	}
+
+	// NaN never equals itself
+	value, _, err := webauthn.DecodeCBOR([]byte{0xf9, 0x7e, 0x00})
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(value.(float64)))
+}
+
+// malformedCBOR holds the malformed examples of RFC 8949 appendix F, inputs
+// outside the supported subset and hostile lengths and nesting
+var malformedCBOR = []string{
+	// End of input in a head
+	"18", "19", "1a", "1b", "1901", "1a0102", "1b01020304050607", "38", "58", "78", "98", "9a01ff00", "b8", "d8", "f8", "f900", "fa0000", "fb000000",
+	// Definite-length strings with short data
+	"41", "61", "5affffffff00", "5bffffffffffffffff010203", "7affffffff00", "7b7fffffffffffffff010203",
+	// Definite-length maps and arrays not closed with enough items
+	"81", "818181818181818181", "8200", "a1", "a20102", "a100", "a2000000",
+	// Tag number not followed by tag content
+	"c0",
+	// Reserved additional information values
+	"1c", "1d", "1e", "3c", "3d", "3e", "5c", "5d", "5e", "7c", "7d", "7e", "9c", "9d", "9e", "bc", "bd", "be", "dc", "dd", "de", "fc", "fd", "fe",
+	// Break occurring on its own or inside a definite-length item
+	"ff", "81ff", "8200ff", "a1ff", "a1ff00", "a100ff", "a20000ff",
+	// Indefinite lengths, which authenticators do not produce
+	"5f42010243030405ff", "7f657374726561646d696e67ff", "9fff", "9f018202039f0405ffff", "bf61610161629f0203ffff",
+	// Integers outside int64
+	"1bffffffffffffffff", "3bffffffffffffffff",
+	// Unsupported simple values
+	"f0", "f800", "f818", "f8ff",
+	// Lengths larger than the input
+	"9bffffffffffffffff", "bbffffffffffffffff", "5b7fffffffffffffff",
+	// Map keys that COSE and WebAuthn never use, and duplicated keys
+	"a1f400", "a1400000", "a1800000", "a201020103",
+}
+
+func TestCBORRejectsMalformedInput(t *testing.T) {
+	for _, input := range malformedCBOR {
+		t.Run(input, func(t *testing.T) {
+			encoded, err := hex.DecodeString(input)
+			require.NoError(t, err)
+			assert.NotPanics(t, func() {
+				_, _, err = webauthn.DecodeCBOR(encoded)
+			})
+			assert.Error(t, err)
+		})
+	}
+
+	// Deep nesting must fail instead of exhausting the stack
+	nested := append(bytes.Repeat([]byte{0x81}, 100000), 0x00)
+	_, _, err := webauthn.DecodeCBOR(nested)
+	assert.Error(t, err)
+	tagged := append(bytes.Repeat([]byte{0xc0}, 100000), 0x00)
+	_, _, err = webauthn.DecodeCBOR(tagged)
+	assert.Error(t, err)
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	for _, input := range malformedCBOR {
+		encoded, _ := hex.DecodeString(input)
+		f.Add(encoded)
+	}
+	f.Add(webAuthnSpecCOSEKey)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, rest, err := webauthn.DecodeCBOR(data)
+		if err == nil && len(rest) > len(data) {
+			t.Fatalf("decoded %v leaving more bytes than the input", value)
+		}
+	})
+}
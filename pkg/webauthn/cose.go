@@ -0,0 +1,141 @@
+// pkg/webauthn/cose.go
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSEAlgorithm identifies a signature algorithm in the IANA COSE registry
+type COSEAlgorithm int64
+
+const (
+	AlgES256 COSEAlgorithm = -7
+	AlgEdDSA COSEAlgorithm = -8
+	AlgRS256 COSEAlgorithm = -257
+)
+
+// SupportedAlgorithms are offered to authenticators in order of preference
+var SupportedAlgorithms = []COSEAlgorithm{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 9052 section 7 and RFC 9053)
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+	coseRSAN      = -1
+	coseRSAE      = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// minRSAKeyBits refuses RSA keys too short to be trusted
+const minRSAKeyBits = 2048
+
+// PublicKey is a credential public key decoded from its COSE_Key form
+type PublicKey struct {
+	Algorithm COSEAlgorithm
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key as stored with a credential
+func ParsePublicKey(coseKey []byte) (*PublicKey, error) {
+	value, rest, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid COSE key: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("invalid COSE key: trailing data")
+	}
+	return publicKeyFromCOSE(value)
+}
+
+func publicKeyFromCOSE(value interface{}) (*PublicKey, error) {
+	params, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid COSE key: not a map")
+	}
+
+	keyType, _ := params[int64(coseKeyType)].(int64)
+	algorithm, ok := params[int64(coseAlgorithm)].(int64)
+	if !ok {
+		return nil, errors.New("invalid COSE key: missing algorithm")
+	}
+
+	switch COSEAlgorithm(algorithm) {
+	case AlgES256:
+		curve, _ := params[int64(coseCurve)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		y, _ := params[int64(coseY)].([]byte)
+		if keyType != coseKeyTypeEC2 || curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid COSE key: malformed ES256 key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid COSE key: point is not on the curve")
+		}
+		return &PublicKey{Algorithm: AlgES256, Key: key}, nil
+
+	case AlgEdDSA:
+		curve, _ := params[int64(coseCurve)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		if keyType != coseKeyTypeOKP || curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid COSE key: malformed EdDSA key")
+		}
+		return &PublicKey{Algorithm: AlgEdDSA, Key: ed25519.PublicKey(x)}, nil
+
+	case AlgRS256:
+		n, _ := params[int64(coseRSAN)].([]byte)
+		e, _ := params[int64(coseRSAE)].([]byte)
+		if keyType != coseKeyTypeRSA || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid COSE key: malformed RS256 key")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, errors.New("invalid COSE key: RSA key is too short")
+		}
+		return &PublicKey{Algorithm: AlgRS256, Key: key}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported COSE algorithm %d", algorithm)
+	}
+}
+
+// Verify checks a signature over message
+func (k *PublicKey) Verify(message, signature []byte) error {
+	switch k.Algorithm {
+	case AlgES256:
+		digest := sha256.Sum256(message)
+		if !ecdsa.VerifyASN1(k.Key.(*ecdsa.PublicKey), digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case AlgEdDSA:
+		if !ed25519.Verify(k.Key.(ed25519.PublicKey), message, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case AlgRS256:
+		digest := sha256.Sum256(message)
+		if err := rsa.VerifyPKCS1v15(k.Key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported COSE algorithm %d", k.Algorithm)
+	}
+}
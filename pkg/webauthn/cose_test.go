@@ -0,0 +1,65 @@
+package webauthn_test
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+)
+
+// webAuthnSpecCOSEKey is the ES256 credential public key example of WebAuthn
+// section 6.5.1.1
+var webAuthnSpecCOSEKey = mustDecodeHex(
+	"a5" +
+		"0102" + // kty: EC2
+		"0326" + // alg: ES256
+		"2001" + // crv: P-256
+		"215820" + "65eda5a12577c2bae829437fe338701a10aaa375e1bb5b5de108de439c08551d" + // x
+		"225820" + "1e52ed75701163f7f9e40ddf9f341b3dc9ba860af7e0ca7ca7e9eecd0084d19c", // y
+)
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestParsePublicKeySpecExample(t *testing.T) {
+	key, err := webauthn.ParsePublicKey(webAuthnSpecCOSEKey)
+	require.NoError(t, err)
+	assert.Equal(t, webauthn.AlgES256, key.Algorithm)
+	ecKey, ok := key.Key.(*ecdsa.PublicKey)
+	require.True(t, ok)
+	assert.Equal(t, "65eda5a12577c2bae829437fe338701a10aaa375e1bb5b5de108de439c08551d", hex.EncodeToString(ecKey.X.Bytes()))
+	assert.Equal(t, "1e52ed75701163f7f9e40ddf9f341b3dc9ba860af7e0ca7ca7e9eecd0084d19c", hex.EncodeToString(ecKey.Y.Bytes()))
+
+	// A point moved off the curve is refused
+	offCurve := append([]byte(nil), webAuthnSpecCOSEKey...)
+	offCurve[len(offCurve)-1] ^= 0x01
+	_, err = webauthn.ParsePublicKey(offCurve)
+	assert.Error(t, err)
+
+	// So is every truncation and trailing data
+	for i := range webAuthnSpecCOSEKey {
+		_, err = webauthn.ParsePublicKey(webAuthnSpecCOSEKey[:i])
+		assert.Error(t, err, i)
+	}
+	_, err = webauthn.ParsePublicKey(append(append([]byte(nil), webAuthnSpecCOSEKey...), 0x00))
+	assert.Error(t, err)
+}
+
+func FuzzParsePublicKey(f *testing.F) {
+	f.Add(webAuthnSpecCOSEKey)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		key, err := webauthn.ParsePublicKey(data)
+		if err == nil && key.Key == nil {
+			t.Fatal("parsed a key without key material")
+		}
+	})
+}
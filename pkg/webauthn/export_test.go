@@ -0,0 +1,4 @@
+package webauthn
+
+// Exported so the RFC 8949 examples can exercise the decoder directly
+var DecodeCBOR = decodeCBOR
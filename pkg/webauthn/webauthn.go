@@ -0,0 +1,366 @@
+// pkg/webauthn/webauthn.go
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// challengeSize is the number of random bytes in a ceremony challenge
+	challengeSize = 32
+	// DefaultTimeout is how long the client and server wait for a ceremony
+	DefaultTimeout = 5 * time.Minute
+
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+)
+
+// ErrSignCountRegression means the authenticator's signature counter did not
+// increase, which suggests the credential was cloned
+var ErrSignCountRegression = errors.New("signature counter did not increase")
+
+// Config describes the relying party. Origins are the exact origins, such as
+// https://app.example.com, the ceremonies may run on.
+type Config struct {
+	RPID    string
+	RPName  string
+	Origins []string
+	Timeout time.Duration
+}
+
+// RelyingParty runs the server side of WebAuthn registration and
+// authentication ceremonies (https://www.w3.org/TR/webauthn-3/)
+type RelyingParty struct {
+	config   Config
+	rpIDHash []byte
+}
+
+// NewRelyingParty validates the configuration. Every origin must be the
+// relying party ID or one of its subdomains.
+func NewRelyingParty(config Config) (*RelyingParty, error) {
+	if config.RPID == "" {
+		return nil, errors.New("webauthn: relying party ID is required")
+	}
+	if len(config.Origins) == 0 {
+		return nil, errors.New("webauthn: at least one origin is required")
+	}
+	for _, origin := range config.Origins {
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("webauthn: invalid origin %q", origin)
+		}
+		host := parsed.Hostname()
+		if host != config.RPID && !strings.HasSuffix(host, "."+config.RPID) {
+			return nil, fmt.Errorf("webauthn: origin %q is not within relying party ID %q", origin, config.RPID)
+		}
+	}
+	if config.RPName == "" {
+		config.RPName = config.RPID
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	hash := sha256.Sum256([]byte(config.RPID))
+	return &RelyingParty{config: config, rpIDHash: hash[:]}, nil
+}
+
+// Timeout is how long a ceremony challenge stays valid
+func (rp *RelyingParty) Timeout() time.Duration {
+	return rp.config.Timeout
+}
+
+// User is the account a credential is registered for. The handle must not
+// contain personal information, as authenticators may disclose it.
+type User struct {
+	Handle      []byte
+	Name        string
+	DisplayName string
+}
+
+// Credential is a registered public key credential
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	Algorithm      COSEAlgorithm
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	UserVerified   bool
+	BackupEligible bool
+	BackupState    bool
+}
+
+// Descriptor refers to the credential in ceremony options
+func (c *Credential) Descriptor() CredentialDescriptor {
+	return CredentialDescriptor{Type: publicKeyCredentialType, ID: c.ID, Transports: c.Transports}
+}
+
+// BeginRegistration returns options for navigator.credentials.create(). The
+// challenge in the options must be kept to verify the response.
+func (rp *RelyingParty) BeginRegistration(user User, exclude []CredentialDescriptor, verification UserVerification) (*CreationOptions, error) {
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]CredentialParameter, len(SupportedAlgorithms))
+	for i, algorithm := range SupportedAlgorithms {
+		params[i] = CredentialParameter{Type: publicKeyCredentialType, Algorithm: algorithm}
+	}
+
+	return &CreationOptions{
+		RP:                 RelyingPartyEntity{ID: rp.config.RPID, Name: rp.config.RPName},
+		User:               UserEntity{ID: user.Handle, Name: user.Name, DisplayName: user.DisplayName},
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            rp.config.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		// Discoverable credentials allow logging in without a username
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: verification,
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// BeginLogin returns options for navigator.credentials.get(). Without allowed
+// credentials the authenticator offers its discoverable credentials.
+func (rp *RelyingParty) BeginLogin(allow []CredentialDescriptor, verification UserVerification) (*RequestOptions, error) {
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.config.Timeout.Milliseconds(),
+		RPID:             rp.config.RPID,
+		AllowCredentials: allow,
+		UserVerification: verification,
+	}, nil
+}
+
+// Registration is a parsed registration response. Its challenge identifies
+// the ceremony it answers.
+type Registration struct {
+	Challenge      []byte
+	clientData     CollectedClientData
+	clientDataHash []byte
+	attestation    *attestationObject
+	transports     []string
+}
+
+// ParseRegistration decodes a registration response without verifying it
+func (rp *RelyingParty) ParseRegistration(credential *RegistrationCredential) (*Registration, error) {
+	if err := checkCredentialID(credential.ID, credential.RawID, credential.Type); err != nil {
+		return nil, err
+	}
+	clientData, challenge, err := parseClientData(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	attestation, err := parseAttestationObject(credential.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(attestation.AuthData.CredentialID, credential.RawID) {
+		return nil, errors.New("webauthn: credential ID does not match the attested credential")
+	}
+
+	hash := sha256.Sum256(credential.Response.ClientDataJSON)
+	return &Registration{
+		Challenge:      challenge,
+		clientData:     *clientData,
+		clientDataHash: hash[:],
+		attestation:    attestation,
+		transports:     credential.Response.Transports,
+	}, nil
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// issued for it (WebAuthn section 7.1) and returns the new credential
+func (rp *RelyingParty) VerifyRegistration(registration *Registration, challenge []byte, verification UserVerification) (*Credential, error) {
+	if err := rp.verifyClientData(&registration.clientData, registration.Challenge, ceremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	authData := registration.attestation.AuthData
+	if err := rp.verifyAuthenticatorData(authData, verification); err != nil {
+		return nil, err
+	}
+	if !authData.HasFlag(FlagAttestedCredentialData) {
+		return nil, errors.New("webauthn: registration carries no credential")
+	}
+
+	publicKey, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: %w", err)
+	}
+	if err := registration.attestation.verifyAttestation(publicKey, registration.clientDataHash); err != nil {
+		return nil, fmt.Errorf("webauthn: %w", err)
+	}
+
+	return &Credential{
+		ID:             append([]byte(nil), authData.CredentialID...),
+		PublicKey:      append([]byte(nil), authData.PublicKey...),
+		Algorithm:      publicKey.Algorithm,
+		SignCount:      authData.SignCount,
+		AAGUID:         append([]byte(nil), authData.AAGUID...),
+		Transports:     registration.transports,
+		UserVerified:   authData.HasFlag(FlagUserVerified),
+		BackupEligible: authData.HasFlag(FlagBackupEligible),
+		BackupState:    authData.HasFlag(FlagBackupState),
+	}, nil
+}
+
+// Assertion is a parsed authentication response. The credential ID and user
+// handle find the credential, the challenge the ceremony it answers.
+type Assertion struct {
+	CredentialID []byte
+	UserHandle   []byte
+	Challenge    []byte
+	clientData   CollectedClientData
+	clientJSON   []byte
+	rawAuthData  []byte
+	authData     *AuthenticatorData
+	signature    []byte
+}
+
+// ParseAssertion decodes an authentication response without verifying it
+func (rp *RelyingParty) ParseAssertion(credential *AssertionCredential) (*Assertion, error) {
+	if err := checkCredentialID(credential.ID, credential.RawID, credential.Type); err != nil {
+		return nil, err
+	}
+	clientData, challenge, err := parseClientData(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	authData, err := parseAuthenticatorData(credential.Response.AuthenticatorData)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: %w", err)
+	}
+
+	return &Assertion{
+		CredentialID: credential.RawID,
+		UserHandle:   credential.Response.UserHandle,
+		Challenge:    challenge,
+		clientData:   *clientData,
+		clientJSON:   credential.Response.ClientDataJSON,
+		rawAuthData:  credential.Response.AuthenticatorData,
+		authData:     authData,
+		signature:    credential.Response.Signature,
+	}, nil
+}
+
+// VerifyAssertion checks an authentication response against the challenge
+// issued for it and the stored credential (WebAuthn section 7.2). It returns
+// the authenticator data, whose sign count is to be stored with the
+// credential.
+func (rp *RelyingParty) VerifyAssertion(assertion *Assertion, challenge []byte, verification UserVerification, credential *Credential) (*AuthenticatorData, error) {
+	if !bytes.Equal(assertion.CredentialID, credential.ID) {
+		return nil, errors.New("webauthn: assertion is for a different credential")
+	}
+	if err := rp.verifyClientData(&assertion.clientData, assertion.Challenge, ceremonyGet, challenge); err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(assertion.authData, verification); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := ParsePublicKey(credential.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: %w", err)
+	}
+	clientDataHash := sha256.Sum256(assertion.clientJSON)
+	signed := append(append([]byte(nil), assertion.rawAuthData...), clientDataHash[:]...)
+	if err := publicKey.Verify(signed, assertion.signature); err != nil {
+		return nil, fmt.Errorf("webauthn: %w", err)
+	}
+
+	// Authenticators without a counter always report zero
+	signCount := assertion.authData.SignCount
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return nil, ErrSignCountRegression
+	}
+
+	return assertion.authData, nil
+}
+
+func (rp *RelyingParty) verifyClientData(clientData *CollectedClientData, received []byte, ceremony string, expected []byte) error {
+	if clientData.Type != ceremony {
+		return fmt.Errorf("webauthn: client data type is %q, expected %q", clientData.Type, ceremony)
+	}
+	if len(expected) == 0 || subtle.ConstantTimeCompare(received, expected) != 1 {
+		return errors.New("webauthn: challenge does not match")
+	}
+	if clientData.CrossOrigin {
+		return errors.New("webauthn: cross-origin ceremonies are not allowed")
+	}
+	for _, origin := range rp.config.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("webauthn: origin %q is not allowed", clientData.Origin)
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(authData *AuthenticatorData, verification UserVerification) error {
+	if subtle.ConstantTimeCompare(authData.RPIDHash, rp.rpIDHash) != 1 {
+		return errors.New("webauthn: relying party ID hash does not match")
+	}
+	if !authData.HasFlag(FlagUserPresent) {
+		return errors.New("webauthn: user was not present")
+	}
+	if verification == VerificationRequired && !authData.HasFlag(FlagUserVerified) {
+		return errors.New("webauthn: user was not verified")
+	}
+	if !authData.HasFlag(FlagBackupEligible) && authData.HasFlag(FlagBackupState) {
+		return errors.New("webauthn: backup state set on a credential that cannot be backed up")
+	}
+	return nil
+}
+
+func parseClientData(raw []byte) (*CollectedClientData, []byte, error) {
+	clientData := &CollectedClientData{}
+	if err := json.Unmarshal(raw, clientData); err != nil {
+		return nil, nil, fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(clientData.Challenge, "="))
+	if err != nil || len(challenge) == 0 {
+		return nil, nil, errors.New("webauthn: invalid client data challenge")
+	}
+	return clientData, challenge, nil
+}
+
+func checkCredentialID(id string, rawID []byte, credentialType string) error {
+	if credentialType != publicKeyCredentialType {
+		return fmt.Errorf("webauthn: unsupported credential type %q", credentialType)
+	}
+	if len(rawID) == 0 {
+		return errors.New("webauthn: credential ID is missing")
+	}
+	if id != "" && id != Base64URL(rawID).String() {
+		return errors.New("webauthn: credential id does not match rawId")
+	}
+	return nil
+}
+
+func newChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("webauthn: error generating challenge: %w", err)
+	}
+	return challenge, nil
+}
@@ -0,0 +1,198 @@
+package webauthn_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+	"github.com/yourusername/user-management-api/pkg/webauthn/webauthntest"
+)
+
+const testOrigin = "https://login.example.com"
+
+func newTestRelyingParty(t testing.TB) *webauthn.RelyingParty {
+	rp, err := webauthn.NewRelyingParty(webauthn.Config{
+		RPID:    "example.com",
+		RPName:  "Example",
+		Origins: []string{testOrigin},
+	})
+	require.NoError(t, err)
+	return rp
+}
+
+// register runs a registration ceremony with the authenticator
+func register(t *testing.T, rp *webauthn.RelyingParty, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	options, err := rp.BeginRegistration(webauthn.User{Handle: []byte{1}, Name: "alice"}, nil, webauthn.VerificationPreferred)
+	require.NoError(t, err)
+	response, err := authenticator.Register(options, testOrigin)
+	require.NoError(t, err)
+
+	registration, err := rp.ParseRegistration(response)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(options.Challenge), registration.Challenge)
+	credential, err := rp.VerifyRegistration(registration, options.Challenge, webauthn.VerificationPreferred)
+	require.NoError(t, err)
+	return credential
+}
+
+// assertion runs an authentication ceremony for the credential
+func assertion(t *testing.T, rp *webauthn.RelyingParty, authenticator *webauthntest.Authenticator, credential *webauthn.Credential, origin string) (*webauthn.AuthenticatorData, error) {
+	options, err := rp.BeginLogin([]webauthn.CredentialDescriptor{credential.Descriptor()}, webauthn.VerificationRequired)
+	require.NoError(t, err)
+	response, err := authenticator.Assert(options, origin)
+	require.NoError(t, err)
+
+	parsed, err := rp.ParseAssertion(response)
+	require.NoError(t, err)
+	return rp.VerifyAssertion(parsed, options.Challenge, webauthn.VerificationRequired, credential)
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := webauthntest.NewAuthenticator()
+
+	credential := register(t, rp, authenticator)
+	assert.Equal(t, webauthn.AlgES256, credential.Algorithm)
+	assert.True(t, credential.UserVerified)
+	_, err := webauthn.ParsePublicKey(credential.PublicKey)
+	require.NoError(t, err)
+
+	authData, err := assertion(t, rp, authenticator, credential, testOrigin)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), authData.SignCount)
+	assert.True(t, authData.HasFlag(webauthn.FlagUserVerified))
+}
+
+func TestVerifyRegistrationRejectsWrongChallenge(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	options, err := rp.BeginRegistration(webauthn.User{Handle: []byte{1}, Name: "alice"}, nil, webauthn.VerificationPreferred)
+	require.NoError(t, err)
+	response, err := webauthntest.NewAuthenticator().Register(options, testOrigin)
+	require.NoError(t, err)
+
+	registration, err := rp.ParseRegistration(response)
+	require.NoError(t, err)
+	_, err = rp.VerifyRegistration(registration, []byte("another challenge"), webauthn.VerificationPreferred)
+	assert.Error(t, err)
+}
+
+func TestVerifyAssertionRejectsForeignOrigin(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := webauthntest.NewAuthenticator()
+	credential := register(t, rp, authenticator)
+
+	_, err := assertion(t, rp, authenticator, credential, "https://login.example.com.evil.test")
+	assert.ErrorContains(t, err, "origin")
+}
+
+func TestVerifyAssertionRequiresUserVerification(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := webauthntest.NewAuthenticator()
+	credential := register(t, rp, authenticator)
+
+	authenticator.SkipUserVerification = true
+	_, err := assertion(t, rp, authenticator, credential, testOrigin)
+	assert.ErrorContains(t, err, "not verified")
+}
+
+func TestVerifyAssertionDetectsClonedAuthenticator(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := webauthntest.NewAuthenticator()
+	credential := register(t, rp, authenticator)
+
+	authData, err := assertion(t, rp, authenticator, credential, testOrigin)
+	require.NoError(t, err)
+	credential.SignCount = authData.SignCount
+
+	// A clone continues from the counter value it was copied at
+	authenticator.SetSignCount(credential.ID, 0)
+	_, err = assertion(t, rp, authenticator, credential, testOrigin)
+	assert.ErrorIs(t, err, webauthn.ErrSignCountRegression)
+}
+
+func TestNewRelyingPartyRejectsForeignOrigins(t *testing.T) {
+	_, err := webauthn.NewRelyingParty(webauthn.Config{RPID: "example.com", Origins: []string{"https://example.org"}})
+	assert.Error(t, err)
+}
+
+// ceremonyResponses captures one registration and one assertion response
+func ceremonyResponses(t testing.TB, rp *webauthn.RelyingParty) (*webauthn.RegistrationCredential, *webauthn.AssertionCredential) {
+	authenticator := webauthntest.NewAuthenticator()
+	creation, err := rp.BeginRegistration(webauthn.User{Handle: []byte{1}, Name: "alice"}, nil, webauthn.VerificationPreferred)
+	require.NoError(t, err)
+	registration, err := authenticator.Register(creation, testOrigin)
+	require.NoError(t, err)
+
+	request, err := rp.BeginLogin([]webauthn.CredentialDescriptor{{Type: "public-key", ID: registration.RawID}}, webauthn.VerificationRequired)
+	require.NoError(t, err)
+	login, err := authenticator.Assert(request, testOrigin)
+	require.NoError(t, err)
+	return registration, login
+}
+
+func TestParseRejectsTruncatedResponses(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	registration, login := ceremonyResponses(t, rp)
+
+	attestationObject := registration.Response.AttestationObject
+	for i := range attestationObject {
+		truncated := *registration
+		truncated.Response.AttestationObject = attestationObject[:i]
+		assert.NotPanics(t, func() {
+			_, err := rp.ParseRegistration(&truncated)
+			assert.Error(t, err, i)
+		})
+	}
+
+	authenticatorData := login.Response.AuthenticatorData
+	for i := range authenticatorData {
+		truncated := *login
+		truncated.Response.AuthenticatorData = authenticatorData[:i]
+		assert.NotPanics(t, func() {
+			_, err := rp.ParseAssertion(&truncated)
+			assert.Error(t, err, i)
+		})
+	}
+
+	// Malformed CBOR in place of the attestation object
+	for _, input := range malformedCBOR {
+		malformed := *registration
+		malformed.Response.AttestationObject = mustDecodeHex(input)
+		assert.NotPanics(t, func() {
+			_, err := rp.ParseRegistration(&malformed)
+			assert.Error(t, err, input)
+		})
+	}
+}
+
+func FuzzParseRegistration(f *testing.F) {
+	rp := newTestRelyingParty(f)
+	registration, _ := ceremonyResponses(f, rp)
+	f.Add([]byte(registration.Response.AttestationObject), []byte(registration.Response.ClientDataJSON))
+	f.Fuzz(func(t *testing.T, attestationObject, clientDataJSON []byte) {
+		fuzzed := *registration
+		fuzzed.Response.AttestationObject = attestationObject
+		fuzzed.Response.ClientDataJSON = clientDataJSON
+		if parsed, err := rp.ParseRegistration(&fuzzed); err == nil {
+			_, _ = rp.VerifyRegistration(parsed, parsed.Challenge, webauthn.VerificationPreferred)
+		}
+	})
+}
+
+func FuzzParseAssertion(f *testing.F) {
+	rp := newTestRelyingParty(f)
+	_, login := ceremonyResponses(f, rp)
+	f.Add([]byte(login.Response.AuthenticatorData), []byte(login.Response.ClientDataJSON), []byte(login.Response.Signature))
+	f.Fuzz(func(t *testing.T, authenticatorData, clientDataJSON, signature []byte) {
+		fuzzed := *login
+		fuzzed.Response.AuthenticatorData = authenticatorData
+		fuzzed.Response.ClientDataJSON = clientDataJSON
+		fuzzed.Response.Signature = signature
+		if parsed, err := rp.ParseAssertion(&fuzzed); err == nil {
+			credential := &webauthn.Credential{ID: login.RawID, PublicKey: webAuthnSpecCOSEKey, Algorithm: webauthn.AlgES256}
+			_, _ = rp.VerifyAssertion(parsed, parsed.Challenge, webauthn.VerificationRequired, credential)
+		}
+	})
+}
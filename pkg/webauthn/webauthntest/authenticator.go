@@ -0,0 +1,247 @@
+// Package webauthntest provides a software authenticator for exercising
+// WebAuthn ceremonies in tests without a browser or security key.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/yourusername/user-management-api/pkg/webauthn"
+)
+
+// Authenticator is an in-memory platform authenticator holding ES256
+// discoverable credentials. It verifies the user unless told otherwise.
+type Authenticator struct {
+	// AAGUID identifies the authenticator model
+	AAGUID []byte
+	// SkipUserVerification leaves the UV flag unset, like a security key
+	// without a PIN
+	SkipUserVerification bool
+
+	credentials []*credential
+}
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{AAGUID: make([]byte, 16)}
+}
+
+// Register answers navigator.credentials.create() as a browser on origin would
+func (a *Authenticator) Register(options *webauthn.CreationOptions, origin string) (*webauthn.RegistrationCredential, error) {
+	supported := false
+	for _, param := range options.PubKeyCredParams {
+		supported = supported || param.Algorithm == webauthn.AlgES256
+	}
+	if !supported {
+		return nil, errors.New("webauthntest: ES256 was not offered")
+	}
+	for _, excluded := range options.ExcludeCredentials {
+		if a.find(options.RP.ID, excluded.ID) != nil {
+			return nil, errors.New("webauthntest: credential already registered")
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	cred := &credential{id: id, rpID: options.RP.ID, userHandle: options.User.ID, key: key}
+	a.credentials = append(a.credentials, cred)
+
+	clientDataJSON, err := clientData("webauthn.create", options.Challenge, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	attested := append([]byte(nil), a.AAGUID...)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(id)))
+	attested = append(attested, id...)
+	attested = append(attested, coseKey(&key.PublicKey)...)
+	authData := a.authenticatorData(cred, webauthn.FlagAttestedCredentialData, attested)
+
+	attestationObject := &cborWriter{}
+	attestationObject.writeMap(3)
+	attestationObject.writeText("fmt")
+	attestationObject.writeText("none")
+	attestationObject.writeText("attStmt")
+	attestationObject.writeMap(0)
+	attestationObject.writeText("authData")
+	attestationObject.writeBytes(authData)
+
+	return &webauthn.RegistrationCredential{
+		ID:    webauthn.Base64URL(id).String(),
+		RawID: id,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: attestationObject.Bytes(),
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Assert answers navigator.credentials.get() as a browser on origin would,
+// using the first matching credential
+func (a *Authenticator) Assert(options *webauthn.RequestOptions, origin string) (*webauthn.AssertionCredential, error) {
+	var cred *credential
+	if len(options.AllowCredentials) == 0 {
+		for _, candidate := range a.credentials {
+			if candidate.rpID == options.RPID {
+				cred = candidate
+				break
+			}
+		}
+	}
+	for _, allowed := range options.AllowCredentials {
+		if cred = a.find(options.RPID, allowed.ID); cred != nil {
+			break
+		}
+	}
+	if cred == nil {
+		return nil, errors.New("webauthntest: no matching credential")
+	}
+
+	clientDataJSON, err := clientData("webauthn.get", options.Challenge, origin)
+	if err != nil {
+		return nil, err
+	}
+	cred.signCount++
+	authData := a.authenticatorData(cred, 0, nil)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionCredential{
+		ID:    webauthn.Base64URL(cred.id).String(),
+		RawID: cred.id,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         signature,
+			UserHandle:        cred.userHandle,
+		},
+	}, nil
+}
+
+// SetSignCount overwrites a credential's signature counter, for example to
+// act like a cloned authenticator
+func (a *Authenticator) SetSignCount(credentialID []byte, signCount uint32) {
+	for _, cred := range a.credentials {
+		if bytes.Equal(cred.id, credentialID) {
+			cred.signCount = signCount
+		}
+	}
+}
+
+func (a *Authenticator) find(rpID string, id []byte) *credential {
+	for _, cred := range a.credentials {
+		if cred.rpID == rpID && bytes.Equal(cred.id, id) {
+			return cred
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) authenticatorData(cred *credential, flags byte, attested []byte) []byte {
+	flags |= webauthn.FlagUserPresent
+	if !a.SkipUserVerification {
+		flags |= webauthn.FlagUserVerified
+	}
+	rpIDHash := sha256.Sum256([]byte(cred.rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, cred.signCount)
+	return append(data, attested...)
+}
+
+func clientData(ceremony string, challenge webauthn.Base64URL, origin string) ([]byte, error) {
+	return json.Marshal(webauthn.CollectedClientData{
+		Type:      ceremony,
+		Challenge: challenge.String(),
+		Origin:    origin,
+	})
+}
+
+// coseKey encodes an ES256 public key as a COSE_Key, in CTAP2 canonical order
+func coseKey(key *ecdsa.PublicKey) []byte {
+	w := &cborWriter{}
+	w.writeMap(5)
+	w.writeInt(1) // kty: EC2
+	w.writeInt(2)
+	w.writeInt(3) // alg: ES256
+	w.writeInt(int64(webauthn.AlgES256))
+	w.writeInt(-1) // crv: P-256
+	w.writeInt(1)
+	w.writeInt(-2) // x
+	w.writeBytes(key.X.FillBytes(make([]byte, 32)))
+	w.writeInt(-3) // y
+	w.writeBytes(key.Y.FillBytes(make([]byte, 32)))
+	return w.Bytes()
+}
+
+// cborWriter encodes the few CBOR items authenticators emit
+type cborWriter struct {
+	bytes.Buffer
+}
+
+func (w *cborWriter) writeHead(major byte, argument uint64) {
+	switch {
+	case argument < 24:
+		w.WriteByte(major<<5 | byte(argument))
+	case argument <= 0xff:
+		w.WriteByte(major<<5 | 24)
+		w.WriteByte(byte(argument))
+	case argument <= 0xffff:
+		w.WriteByte(major<<5 | 25)
+		w.Write(binary.BigEndian.AppendUint16(nil, uint16(argument)))
+	case argument <= 0xffffffff:
+		w.WriteByte(major<<5 | 26)
+		w.Write(binary.BigEndian.AppendUint32(nil, uint32(argument)))
+	default:
+		w.WriteByte(major<<5 | 27)
+		w.Write(binary.BigEndian.AppendUint64(nil, argument))
+	}
+}
+
+func (w *cborWriter) writeInt(value int64) {
+	if value < 0 {
+		w.writeHead(1, uint64(-1-value))
+		return
+	}
+	w.writeHead(0, uint64(value))
+}
+
+func (w *cborWriter) writeBytes(value []byte) {
+	w.writeHead(2, uint64(len(value)))
+	w.Write(value)
+}
+
+func (w *cborWriter) writeText(value string) {
+	w.writeHead(3, uint64(len(value)))
+	w.WriteString(value)
+}
+
+func (w *cborWriter) writeMap(entries int) {
+	w.writeHead(5, uint64(entries))
+}